@@ -1,23 +1,86 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// DefaultMaxGroupByFields is the aggregate_logs group_by dimension cap when
+// GRAYLOG_MAX_GROUP_BY_FIELDS isn't set.
+const DefaultMaxGroupByFields = 3
+
 type Config struct {
 	GraylogURL    string
 	Username      string
 	Password      string
 	Token         string
+	TokenFile     string // re-read on a 401 in stdio mode so rotated tokens are picked up
 	TLSSkipVerify bool
 	Timeout       time.Duration
-	Transport     string // "stdio" or "http"
-	Bind          string // HTTP listen address, e.g. "0.0.0.0:8090"
+	DialTimeout   time.Duration // TCP connect timeout, bounded separately from Timeout
+	ToolTimeout   time.Duration // per-tool-call deadline; 0 disables it
+	MetadataTTL   time.Duration // cache lifetime of list_fields/list_streams results; 0 disables the cache
+	SearchTTL     time.Duration // cache lifetime of identical Graylog search responses; 0 disables the cache
+	Transport     string        // "stdio" or "http"
+	Bind          string        // HTTP listen address, e.g. "0.0.0.0:8090"
+
+	DefaultStreamID  string // applied to search/aggregate/context tools when no stream_id is passed
+	DefaultFields    string // fields projection for search_logs/get_log_context when no fields is passed
+	MaxResponseBytes int64  // cap on a single Graylog response body
+	ExportMaxRows    int    // hard cap on rows written by export_logs
+	MaxSearchLimit   int    // largest search_logs limit accepted, and cap on its dedup/template fetch
+	MaxConcurrent    int    // cap on requests in flight to one Graylog; 0 is unlimited
+	MaxGroupByFields int    // most aggregate_logs group_by dimensions accepted
+	Metrics          bool   // expose Prometheus metrics on /metrics (http transport only)
+	VerifyOnStart    bool   // check the static credentials against Graylog before serving (stdio transport only)
+	StructuredOutput bool   // also return tool results as MCP structuredContent
+	EnableRaw        bool   // register the raw_search tool for caller-supplied Views search types
+
+	// Redact scrubs RedactPatterns (or built-in email/IPv4/bearer token
+	// patterns when empty) from message bodies in every tool result; without
+	// it, callers opt in per call with 'redact'.
+	Redact         bool
+	RedactPatterns []*regexp.Regexp
+
+	// HiddenFieldPrefixes and HiddenValues extend the built-in "gl2_" prefix
+	// and "fullyCutByExtractor" placeholder dropped from returned messages.
+	HiddenFieldPrefixes []string
+	HiddenValues        []string
+
+	ValidateURLEndpoint bool // serve the unauthenticated /validate-url dry run (http transport only)
+
+	// AllowedHosts restricts X-Graylog-URL overrides to these host or host:port
+	// patterns ("*.example.com" matches subdomains). Empty allows any public host.
+	AllowedHosts []string
+
+	UserAgent    string      // User-Agent sent to Graylog; empty keeps Go's default
+	ExtraHeaders http.Header // additional headers sent with every Graylog request
+
+	LogLevel  slog.Level // minimum level of server log records
+	LogFormat string     // "text" or "json"
+	Quiet     bool       // drop non-fatal configuration and security warnings
+
+	// Warnings are non-fatal configuration problems found by Load, logged by
+	// main once the logger is set up.
+	Warnings []string
+}
+
+// logLevels maps GRAYLOG_MCP_LOG_LEVEL values to slog levels.
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
 }
 
 func Load() (*Config, error) {
@@ -27,6 +90,10 @@ func Load() (*Config, error) {
 	flag.StringVar(&cfg.Username, "username", os.Getenv("GRAYLOG_USERNAME"), "Graylog username")
 	flag.StringVar(&cfg.Password, "password", os.Getenv("GRAYLOG_PASSWORD"), "Graylog password")
 	flag.StringVar(&cfg.Token, "token", os.Getenv("GRAYLOG_TOKEN"), "Graylog API access token (alternative to username/password)")
+	tokenFile := flag.String("token-file", os.Getenv("GRAYLOG_TOKEN_FILE"), "Path to a file containing the Graylog API access token (overrides --token)")
+	passwordFile := flag.String("password-file", os.Getenv("GRAYLOG_PASSWORD_FILE"), "Path to a file containing the Graylog password (overrides --password)")
+	flag.StringVar(&cfg.DefaultStreamID, "default-stream-id", os.Getenv("GRAYLOG_DEFAULT_STREAM_ID"), "Stream ID applied to search_logs, aggregate_logs and get_log_context when stream_id is omitted")
+	flag.StringVar(&cfg.DefaultFields, "default-fields", os.Getenv("GRAYLOG_DEFAULT_FIELDS"), `Comma-separated fields returned by search_logs and get_log_context when fields is omitted, e.g. "level,http_status,kubernetes_*"`)
 	var tlsSkipVerifyDefault bool
 	if v := os.Getenv("GRAYLOG_TLS_SKIP_VERIFY"); v != "" {
 		parsed, err := strconv.ParseBool(v)
@@ -47,6 +114,72 @@ func Load() (*Config, error) {
 	if bindDefault == "" {
 		bindDefault = "0.0.0.0:8090"
 	}
+	var metricsDefault bool
+	if v := os.Getenv("GRAYLOG_MCP_METRICS"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_MCP_METRICS %q: must be true/false/1/0", v)
+		}
+		metricsDefault = parsed
+	}
+	flag.BoolVar(&cfg.Metrics, "metrics", metricsDefault, "Expose Prometheus metrics on /metrics (http transport only)")
+
+	verifyOnStartDefault := true
+	if v := os.Getenv("GRAYLOG_VERIFY_ON_START"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_VERIFY_ON_START %q: must be true/false/1/0", v)
+		}
+		verifyOnStartDefault = parsed
+	}
+	flag.BoolVar(&cfg.VerifyOnStart, "verify-on-start", verifyOnStartDefault, "Check the Graylog credentials before serving and exit if they are rejected (stdio transport only)")
+
+	var structuredOutputDefault bool
+	if v := os.Getenv("GRAYLOG_STRUCTURED_OUTPUT"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_STRUCTURED_OUTPUT %q: must be true/false/1/0", v)
+		}
+		structuredOutputDefault = parsed
+	}
+	flag.BoolVar(&cfg.StructuredOutput, "structured-output", structuredOutputDefault, "Also return tool results as MCP structured content, next to the JSON text")
+
+	var enableRawDefault bool
+	if v := os.Getenv("GRAYLOG_MCP_ENABLE_RAW"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_MCP_ENABLE_RAW %q: must be true/false/1/0", v)
+		}
+		enableRawDefault = parsed
+	}
+	flag.BoolVar(&cfg.EnableRaw, "enable-raw", enableRawDefault, "Register the raw_search tool, which posts caller-supplied Views search types")
+
+	var validateURLDefault bool
+	if v := os.Getenv("GRAYLOG_VALIDATE_URL_ENDPOINT"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_VALIDATE_URL_ENDPOINT %q: must be true/false/1/0", v)
+		}
+		validateURLDefault = parsed
+	}
+	flag.BoolVar(&cfg.ValidateURLEndpoint, "validate-url-endpoint", validateURLDefault, "Serve GET /validate-url?url=..., which reports whether an X-Graylog-URL override would be accepted (http transport only)")
+
+	var redactDefault bool
+	if v := os.Getenv("GRAYLOG_REDACT"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_REDACT %q: must be true/false/1/0", v)
+		}
+		redactDefault = parsed
+	}
+	flag.BoolVar(&cfg.Redact, "redact", redactDefault, "Redact sensitive substrings from message and full_message in all tool results")
+	redactPatterns := flag.String("redact-patterns", os.Getenv("GRAYLOG_REDACT_PATTERNS"), "Newline-separated regular expressions to redact instead of the built-in email, IPv4 and bearer token patterns")
+
+	hiddenFieldPrefixes := flag.String("hidden-field-prefixes", os.Getenv("GRAYLOG_HIDDEN_FIELD_PREFIXES"), `Comma-separated field name prefixes dropped from returned messages, in addition to "gl2_"`)
+	hiddenValues := flag.String("hidden-values", os.Getenv("GRAYLOG_HIDDEN_VALUES"), `Comma-separated placeholder values whose fields are dropped from returned messages, in addition to "fullyCutByExtractor"`)
+
+	allowedHosts := flag.String("allowed-hosts", os.Getenv("GRAYLOG_ALLOWED_HOSTS"), `Comma-separated host or host:port patterns X-Graylog-URL may point to, e.g. "graylog.example.com,*.logs.example.com" (http transport only)`)
+
 	flag.StringVar(&cfg.Bind, "bind", bindDefault, `HTTP listen address (http transport only), e.g. "0.0.0.0:8090"`)
 
 	defaultTimeout := 30 * time.Second
@@ -59,19 +192,232 @@ func Load() (*Config, error) {
 	}
 	flag.DurationVar(&cfg.Timeout, "timeout", defaultTimeout, "HTTP request timeout")
 
+	defaultDialTimeout := 10 * time.Second
+	if t := os.Getenv("GRAYLOG_DIAL_TIMEOUT"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_DIAL_TIMEOUT %q: %w", t, err)
+		}
+		defaultDialTimeout = parsed
+	}
+	flag.DurationVar(&cfg.DialTimeout, "dial-timeout", defaultDialTimeout, "TCP connection timeout to Graylog")
+
+	var defaultToolTimeout time.Duration
+	if t := os.Getenv("GRAYLOG_TOOL_TIMEOUT"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_TOOL_TIMEOUT %q: %w", t, err)
+		}
+		defaultToolTimeout = parsed
+	}
+	flag.DurationVar(&cfg.ToolTimeout, "tool-timeout", defaultToolTimeout, "Deadline for a single tool call, across all its Graylog requests (0 = none)")
+
+	var defaultMetadataTTL time.Duration
+	if t := os.Getenv("GRAYLOG_METADATA_CACHE_TTL"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_METADATA_CACHE_TTL %q: %w", t, err)
+		}
+		defaultMetadataTTL = parsed
+	}
+	flag.DurationVar(&cfg.MetadataTTL, "metadata-cache-ttl", defaultMetadataTTL, "Cache list_fields/list_streams results for this long (0 = no cache)")
+
+	var defaultSearchTTL time.Duration
+	if t := os.Getenv("GRAYLOG_SEARCH_CACHE_TTL"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_SEARCH_CACHE_TTL %q: %w", t, err)
+		}
+		defaultSearchTTL = parsed
+	}
+	flag.DurationVar(&cfg.SearchTTL, "search-cache-ttl", defaultSearchTTL, "Answer identical searches from a cache for this long, e.g. 10s (0 = no cache)")
+
+	var maxResponseBytesDefault int64 = 10 * 1024 * 1024
+	if v := os.Getenv("GRAYLOG_MAX_RESPONSE_BYTES"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid GRAYLOG_MAX_RESPONSE_BYTES %q: must be a positive integer", v)
+		}
+		maxResponseBytesDefault = parsed
+	}
+	flag.Int64Var(&cfg.MaxResponseBytes, "max-response-bytes", maxResponseBytesDefault, "Maximum Graylog response body size in bytes")
+
+	exportMaxRowsDefault := 10000
+	if v := os.Getenv("GRAYLOG_EXPORT_MAX_ROWS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid GRAYLOG_EXPORT_MAX_ROWS %q: must be a positive integer", v)
+		}
+		exportMaxRowsDefault = parsed
+	}
+	flag.IntVar(&cfg.ExportMaxRows, "export-max-rows", exportMaxRowsDefault, "Maximum number of messages export_logs writes to a file")
+
+	maxSearchLimitDefault := 10000
+	if v := os.Getenv("GRAYLOG_MAX_SEARCH_LIMIT"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid GRAYLOG_MAX_SEARCH_LIMIT %q: must be a positive integer", v)
+		}
+		maxSearchLimitDefault = parsed
+	}
+	flag.IntVar(&cfg.MaxSearchLimit, "max-search-limit", maxSearchLimitDefault, "Largest 'limit' search_logs accepts; also caps its dedup/template fetch")
+
+	maxGroupByFieldsDefault := DefaultMaxGroupByFields
+	if v := os.Getenv("GRAYLOG_MAX_GROUP_BY_FIELDS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid GRAYLOG_MAX_GROUP_BY_FIELDS %q: must be a positive integer", v)
+		}
+		maxGroupByFieldsDefault = parsed
+	}
+	flag.IntVar(&cfg.MaxGroupByFields, "max-group-by-fields", maxGroupByFieldsDefault, "Most group_by dimensions aggregate_logs accepts")
+
+	var maxConcurrentDefault int
+	if v := os.Getenv("GRAYLOG_MAX_CONCURRENT_REQUESTS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid GRAYLOG_MAX_CONCURRENT_REQUESTS %q: must be a non-negative integer", v)
+		}
+		maxConcurrentDefault = parsed
+	}
+	flag.IntVar(&cfg.MaxConcurrent, "max-concurrent-requests", maxConcurrentDefault, "Maximum requests in flight to one Graylog; further requests wait (0 = unlimited)")
+
+	logLevelDefault := os.Getenv("GRAYLOG_MCP_LOG_LEVEL")
+	if logLevelDefault == "" {
+		logLevelDefault = "info"
+	}
+	logLevel := flag.String("log-level", logLevelDefault, "Log level: debug, info, warn or error")
+	logFormatDefault := os.Getenv("GRAYLOG_MCP_LOG_FORMAT")
+	if logFormatDefault == "" {
+		logFormatDefault = "text"
+	}
+	flag.StringVar(&cfg.LogFormat, "log-format", logFormatDefault, `Log format: "text" or "json"`)
+	var quietDefault bool
+	if v := os.Getenv("GRAYLOG_MCP_QUIET"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_MCP_QUIET %q: must be true/false/1/0", v)
+		}
+		quietDefault = parsed
+	}
+	flag.BoolVar(&cfg.Quiet, "quiet", quietDefault, "Suppress non-fatal configuration and security warnings (errors are still reported)")
+
+	flag.StringVar(&cfg.UserAgent, "user-agent", os.Getenv("GRAYLOG_USER_AGENT"), "User-Agent header sent to Graylog")
+	extraHeaders := flag.String("extra-headers", os.Getenv("GRAYLOG_EXTRA_HEADERS"), `Extra headers sent to Graylog as comma or newline separated "Key: Value" pairs`)
+
 	flag.Parse()
 
 	// Warn if secrets are passed via CLI flags (visible in process listings)
 	flag.Visit(func(f *flag.Flag) {
 		if f.Name == "password" || f.Name == "token" {
-			fmt.Fprintf(os.Stderr, "WARNING: --%s passed via CLI flag; visible in process listings. Prefer environment variables.\n", f.Name)
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("--%s passed via CLI flag; visible in process listings. Prefer environment variables.", f.Name))
 		}
 	})
 
+	if *tokenFile != "" {
+		token, err := readSecretFile(*tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_TOKEN_FILE: %w", err)
+		}
+		cfg.Token = token
+		cfg.TokenFile = *tokenFile
+	}
+	if *passwordFile != "" {
+		password, err := readSecretFile(*passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_PASSWORD_FILE: %w", err)
+		}
+		cfg.Password = password
+	}
+
+	if *extraHeaders != "" {
+		headers, err := parseExtraHeaders(*extraHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_EXTRA_HEADERS: %w", err)
+		}
+		cfg.ExtraHeaders = headers
+	}
+
+	if *allowedHosts != "" {
+		hosts, err := parseAllowedHosts(*allowedHosts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_ALLOWED_HOSTS: %w", err)
+		}
+		cfg.AllowedHosts = hosts
+	}
+
+	if *redactPatterns != "" {
+		patterns, err := parseRedactPatterns(*redactPatterns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GRAYLOG_REDACT_PATTERNS: %w", err)
+		}
+		cfg.RedactPatterns = patterns
+	}
+
+	cfg.HiddenFieldPrefixes = splitList(*hiddenFieldPrefixes)
+	cfg.HiddenValues = splitList(*hiddenValues)
+
+	if cfg.DialTimeout <= 0 {
+		return nil, fmt.Errorf("invalid --dial-timeout %s: must be positive", cfg.DialTimeout)
+	}
+
+	if cfg.ToolTimeout < 0 {
+		return nil, fmt.Errorf("invalid --tool-timeout %s: must not be negative", cfg.ToolTimeout)
+	}
+
+	if cfg.MetadataTTL < 0 {
+		return nil, fmt.Errorf("invalid --metadata-cache-ttl %s: must not be negative", cfg.MetadataTTL)
+	}
+
+	if cfg.SearchTTL < 0 {
+		return nil, fmt.Errorf("invalid --search-cache-ttl %s: must not be negative", cfg.SearchTTL)
+	}
+
+	if cfg.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("invalid --max-response-bytes %d: must be a positive integer", cfg.MaxResponseBytes)
+	}
+
+	level, ok := logLevels[strings.ToLower(*logLevel)]
+	if !ok {
+		return nil, fmt.Errorf("invalid GRAYLOG_MCP_LOG_LEVEL %q: must be debug, info, warn or error", *logLevel)
+	}
+	cfg.LogLevel = level
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return nil, fmt.Errorf("invalid GRAYLOG_MCP_LOG_FORMAT %q: must be \"text\" or \"json\"", cfg.LogFormat)
+	}
+
+	if cfg.ExportMaxRows <= 0 {
+		return nil, fmt.Errorf("invalid --export-max-rows %d: must be a positive integer", cfg.ExportMaxRows)
+	}
+
+	if cfg.MaxSearchLimit <= 0 {
+		return nil, fmt.Errorf("invalid --max-search-limit %d: must be a positive integer", cfg.MaxSearchLimit)
+	}
+
+	if cfg.MaxGroupByFields <= 0 {
+		return nil, fmt.Errorf("invalid --max-group-by-fields %d: must be a positive integer", cfg.MaxGroupByFields)
+	}
+
+	if cfg.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("invalid --max-concurrent-requests %d: must be a non-negative integer", cfg.MaxConcurrent)
+	}
+
 	if cfg.Transport != "stdio" && cfg.Transport != "http" {
 		return nil, fmt.Errorf("invalid transport %q: must be \"stdio\" or \"http\"", cfg.Transport)
 	}
 
+	if cfg.Metrics && cfg.Transport != "http" {
+		cfg.Warnings = append(cfg.Warnings, "metrics are only exposed in http transport mode; GRAYLOG_MCP_METRICS is ignored.")
+	}
+
+	if len(cfg.AllowedHosts) > 0 && cfg.Transport != "http" {
+		cfg.Warnings = append(cfg.Warnings, "X-Graylog-URL overrides only exist in http transport mode; GRAYLOG_ALLOWED_HOSTS is ignored.")
+	}
+
+	if cfg.ValidateURLEndpoint && cfg.Transport != "http" {
+		cfg.Warnings = append(cfg.Warnings, "/validate-url is only served in http transport mode; GRAYLOG_VALIDATE_URL_ENDPOINT is ignored.")
+	}
+
 	// In http transport, GRAYLOG_URL can be omitted and supplied per-request via X-Graylog-URL header.
 	if cfg.GraylogURL == "" && cfg.Transport == "stdio" {
 		return nil, fmt.Errorf("GRAYLOG_URL is required (env or --url flag)")
@@ -88,13 +434,13 @@ func Load() (*Config, error) {
 	}
 
 	if cfg.TLSSkipVerify {
-		fmt.Fprintf(os.Stderr, "WARNING: TLS certificate verification is disabled. Credentials may be vulnerable to interception.\n")
+		cfg.Warnings = append(cfg.Warnings, "TLS certificate verification is disabled. Credentials may be vulnerable to interception.")
 	}
 
 	// In http transport, credentials are provided per-request via Authorization header.
 	// In stdio transport, static credentials are required at startup.
 	if cfg.Transport == "http" && (cfg.Token != "" || cfg.Username != "" || cfg.Password != "") {
-		fmt.Fprintf(os.Stderr, "WARNING: Graylog token or username/password are ignored in http transport mode; credentials are provided per-request via the Authorization header.\n")
+		cfg.Warnings = append(cfg.Warnings, "Graylog token or username/password are ignored in http transport mode; credentials are provided per-request via the Authorization header.")
 	}
 	if cfg.Transport == "stdio" {
 		hasToken := cfg.Token != ""
@@ -104,5 +450,117 @@ func Load() (*Config, error) {
 		}
 	}
 
+	if cfg.Quiet {
+		cfg.Warnings = nil
+	}
+
 	return cfg, nil
 }
+
+// ReloadToken re-reads the token from TokenFile.
+func (c *Config) ReloadToken(_ context.Context) (string, error) {
+	if c.TokenFile == "" {
+		return "", errors.New("no GRAYLOG_TOKEN_FILE configured")
+	}
+	return readSecretFile(c.TokenFile)
+}
+
+// readSecretFile reads a secret mounted as a file, trimming the trailing
+// newline most secret managers and editors add.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file %q: %w", path, err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("secret file %q is empty", path)
+	}
+	return secret, nil
+}
+
+// reservedHeaders are set by the Graylog client itself and must not be
+// replaced through GRAYLOG_EXTRA_HEADERS.
+var reservedHeaders = map[string]bool{
+	"Authorization":  true,
+	"X-Requested-By": true,
+}
+
+// parseExtraHeaders parses comma or newline separated "Key: Value" pairs.
+func parseExtraHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("header %q must be in \"Key: Value\" form", entry)
+		}
+		key = http.CanonicalHeaderKey(key)
+		if reservedHeaders[key] {
+			return nil, fmt.Errorf("header %q cannot be overridden", key)
+		}
+		headers.Add(key, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// parseRedactPatterns compiles newline-separated regular expressions. Commas
+// are common in regexes, so unlike other lists they don't separate entries.
+func parseRedactPatterns(s string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", line, err)
+		}
+		patterns = append(patterns, re)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns listed")
+	}
+	return patterns, nil
+}
+
+// splitList splits a comma-separated list, trimming entries and skipping
+// empty ones.
+func splitList(s string) []string {
+	var list []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// parseAllowedHosts parses comma-separated host or host:port patterns,
+// lowercasing hosts. A leading "*." is the only wildcard form accepted.
+func parseAllowedHosts(s string) ([]string, error) {
+	var hosts []string
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		u, err := url.Parse("//" + entry)
+		if err != nil || u.Hostname() == "" || u.Host != entry {
+			return nil, fmt.Errorf("%q is not a host or host:port pattern", entry)
+		}
+		if strings.Contains(strings.TrimPrefix(u.Hostname(), "*."), "*") {
+			return nil, fmt.Errorf("%q: only a leading \"*.\" wildcard is supported", entry)
+		}
+		hosts = append(hosts, entry)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts listed")
+	}
+	return hosts, nil
+}
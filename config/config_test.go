@@ -1,9 +1,15 @@
 package config_test
 
 import (
+	"context"
 	"flag"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/n0madic/graylog-mcp/config"
 )
@@ -86,3 +92,759 @@ func TestLoad_ValidTLSSkipVerify(t *testing.T) {
 		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	}
 }
+
+// writeSecretFile writes content to a temp file and returns its path.
+func writeSecretFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing secret file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_MetadataCacheTTL(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MetadataTTL != 0 {
+		t.Errorf("expected cache off by default, got %s", cfg.MetadataTTL)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_METADATA_CACHE_TTL", "2m")
+	if cfg, err = config.Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MetadataTTL != 2*time.Minute {
+		t.Errorf("expected MetadataTTL=2m, got %s", cfg.MetadataTTL)
+	}
+
+	for _, bad := range []string{"soon", "-1m"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_METADATA_CACHE_TTL", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_METADATA_CACHE_TTL=%q", bad)
+		}
+	}
+}
+
+func TestLoad_SearchCacheTTL(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SearchTTL != 0 {
+		t.Errorf("expected cache off by default, got %s", cfg.SearchTTL)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_SEARCH_CACHE_TTL", "15s")
+	if cfg, err = config.Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SearchTTL != 15*time.Second {
+		t.Errorf("expected SearchTTL=15s, got %s", cfg.SearchTTL)
+	}
+
+	for _, bad := range []string{"soon", "-1m"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_SEARCH_CACHE_TTL", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_SEARCH_CACHE_TTL=%q", bad)
+		}
+	}
+}
+
+// setupStdioEnv sets a stdio config with no inline credentials.
+func setupStdioEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GRAYLOG_URL", "https://graylog.example.com")
+	t.Setenv("GRAYLOG_MCP_TRANSPORT", "stdio")
+	t.Setenv("GRAYLOG_TOKEN", "")
+	t.Setenv("GRAYLOG_USERNAME", "")
+	t.Setenv("GRAYLOG_PASSWORD", "")
+	t.Setenv("GRAYLOG_TOKEN_FILE", "")
+	t.Setenv("GRAYLOG_PASSWORD_FILE", "")
+	t.Setenv("GRAYLOG_MAX_RESPONSE_BYTES", "")
+	t.Setenv("GRAYLOG_MCP_METRICS", "")
+	t.Setenv("GRAYLOG_VERIFY_ON_START", "")
+	t.Setenv("GRAYLOG_STRUCTURED_OUTPUT", "")
+	t.Setenv("GRAYLOG_MCP_ENABLE_RAW", "")
+	t.Setenv("GRAYLOG_VALIDATE_URL_ENDPOINT", "")
+	t.Setenv("GRAYLOG_USER_AGENT", "")
+	t.Setenv("GRAYLOG_DEFAULT_FIELDS", "")
+	t.Setenv("GRAYLOG_EXTRA_HEADERS", "")
+	t.Setenv("GRAYLOG_ALLOWED_HOSTS", "")
+	t.Setenv("GRAYLOG_EXPORT_MAX_ROWS", "")
+	t.Setenv("GRAYLOG_MAX_SEARCH_LIMIT", "")
+	t.Setenv("GRAYLOG_MCP_LOG_LEVEL", "")
+	t.Setenv("GRAYLOG_MCP_LOG_FORMAT", "")
+	t.Setenv("GRAYLOG_MCP_QUIET", "")
+	t.Setenv("GRAYLOG_TLS_SKIP_VERIFY", "")
+	t.Setenv("GRAYLOG_TIMEOUT", "")
+	t.Setenv("GRAYLOG_DIAL_TIMEOUT", "")
+	t.Setenv("GRAYLOG_TOOL_TIMEOUT", "")
+	t.Setenv("GRAYLOG_METADATA_CACHE_TTL", "")
+	t.Setenv("GRAYLOG_REDACT", "")
+	t.Setenv("GRAYLOG_REDACT_PATTERNS", "")
+	t.Setenv("GRAYLOG_HIDDEN_FIELD_PREFIXES", "")
+	t.Setenv("GRAYLOG_HIDDEN_VALUES", "")
+	t.Setenv("GRAYLOG_MAX_CONCURRENT_REQUESTS", "")
+	t.Setenv("GRAYLOG_MAX_GROUP_BY_FIELDS", "")
+	t.Setenv("GRAYLOG_SEARCH_CACHE_TTL", "")
+}
+
+func TestLoad_TokenFromFile(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	path := writeSecretFile(t, "file-token\n")
+	t.Setenv("GRAYLOG_TOKEN_FILE", path)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("expected token file to satisfy auth, got: %v", err)
+	}
+	if cfg.Token != "file-token" {
+		t.Errorf("expected token %q with trailing newline trimmed, got %q", "file-token", cfg.Token)
+	}
+
+	// A rotated file is picked up by ReloadToken.
+	if err := os.WriteFile(path, []byte("rotated-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	token, err := cfg.ReloadToken(context.Background())
+	if err != nil || token != "rotated-token" {
+		t.Errorf("expected rotated token, got %q (err %v)", token, err)
+	}
+}
+
+func TestLoad_SecretFilesOverrideInline(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "inline-token")
+	t.Setenv("GRAYLOG_TOKEN_FILE", writeSecretFile(t, "file-token\r\n"))
+	t.Setenv("GRAYLOG_USERNAME", "admin")
+	t.Setenv("GRAYLOG_PASSWORD", "inline-password")
+	t.Setenv("GRAYLOG_PASSWORD_FILE", writeSecretFile(t, "file-password\n"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Token != "file-token" {
+		t.Errorf("expected token file to take precedence, got %q", cfg.Token)
+	}
+	if cfg.Password != "file-password" {
+		t.Errorf("expected password file to take precedence, got %q", cfg.Password)
+	}
+}
+
+func TestLoad_SecretFileErrors(t *testing.T) {
+	for name, path := range map[string]func(t *testing.T) string{
+		"missing": func(t *testing.T) string { return filepath.Join(t.TempDir(), "does-not-exist") },
+		"empty":   func(t *testing.T) string { return writeSecretFile(t, "\n") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			setupConfigTest(t)
+			setupStdioEnv(t)
+			t.Setenv("GRAYLOG_TOKEN", "inline-token")
+			t.Setenv("GRAYLOG_TOKEN_FILE", path(t))
+
+			_, err := config.Load()
+			if err == nil || !strings.Contains(err.Error(), "GRAYLOG_TOKEN_FILE") {
+				t.Fatalf("expected GRAYLOG_TOKEN_FILE error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_MaxResponseBytes(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_MAX_RESPONSE_BYTES", "52428800")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxResponseBytes != 52428800 {
+		t.Errorf("expected MaxResponseBytes=52428800, got %d", cfg.MaxResponseBytes)
+	}
+
+	for _, bad := range []string{"0", "-1", "10MB"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_MAX_RESPONSE_BYTES", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_MAX_RESPONSE_BYTES=%q", bad)
+		}
+	}
+}
+
+func TestLoad_Metrics(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_MCP_TRANSPORT", "http")
+	t.Setenv("GRAYLOG_MCP_METRICS", "true")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Metrics {
+		t.Error("expected Metrics=true")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MCP_METRICS", "on")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_MCP_METRICS value 'on'")
+	}
+}
+
+func TestLoad_VerifyOnStart(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.VerifyOnStart {
+		t.Error("expected VerifyOnStart=true by default")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_VERIFY_ON_START", "false")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.VerifyOnStart {
+		t.Error("expected VerifyOnStart=false")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_VERIFY_ON_START", "maybe")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_VERIFY_ON_START value 'maybe'")
+	}
+}
+
+func TestLoad_StructuredOutput(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StructuredOutput {
+		t.Error("expected StructuredOutput=false by default")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_STRUCTURED_OUTPUT", "true")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.StructuredOutput {
+		t.Error("expected StructuredOutput=true")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_STRUCTURED_OUTPUT", "yes please")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_STRUCTURED_OUTPUT value")
+	}
+}
+
+func TestLoad_EnableRaw(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.EnableRaw {
+		t.Error("expected EnableRaw=false by default")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MCP_ENABLE_RAW", "1")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.EnableRaw {
+		t.Error("expected EnableRaw=true")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MCP_ENABLE_RAW", "on")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_MCP_ENABLE_RAW value")
+	}
+}
+
+func TestLoad_Redact(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Redact || cfg.RedactPatterns != nil {
+		t.Errorf("expected redaction off with no patterns by default, got %v %v", cfg.Redact, cfg.RedactPatterns)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_REDACT", "true")
+	t.Setenv("GRAYLOG_REDACT_PATTERNS", "\\bacct-[0-9]{4,8}\\b\n\n  ssn=\\d{3}-\\d{2}-\\d{4}  \n")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Redact {
+		t.Error("expected Redact=true")
+	}
+	var got []string
+	for _, re := range cfg.RedactPatterns {
+		got = append(got, re.String())
+	}
+	if want := []string{`\bacct-[0-9]{4,8}\b`, `ssn=\d{3}-\d{2}-\d{4}`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected patterns %q, got %q", want, got)
+	}
+
+	for _, bad := range []string{"acct-(", "\n \n"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_REDACT_PATTERNS", bad)
+		if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "GRAYLOG_REDACT_PATTERNS") {
+			t.Errorf("GRAYLOG_REDACT_PATTERNS=%q: expected error, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoad_MaxConcurrentRequests(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxConcurrent != 0 {
+		t.Errorf("expected unlimited requests by default, got %d", cfg.MaxConcurrent)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MAX_CONCURRENT_REQUESTS", "4")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxConcurrent != 4 {
+		t.Errorf("expected MaxConcurrent=4, got %d", cfg.MaxConcurrent)
+	}
+
+	for _, bad := range []string{"-1", "two"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_MAX_CONCURRENT_REQUESTS", bad)
+		if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "GRAYLOG_MAX_CONCURRENT_REQUESTS") {
+			t.Errorf("GRAYLOG_MAX_CONCURRENT_REQUESTS=%q: expected error, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoad_HiddenFields(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HiddenFieldPrefixes != nil || cfg.HiddenValues != nil {
+		t.Errorf("expected no extra hidden fields by default, got %v %v", cfg.HiddenFieldPrefixes, cfg.HiddenValues)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_HIDDEN_FIELD_PREFIXES", " kubernetes_annotations_, ,_internal")
+	t.Setenv("GRAYLOG_HIDDEN_VALUES", "-,N/A")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"kubernetes_annotations_", "_internal"}; !reflect.DeepEqual(cfg.HiddenFieldPrefixes, want) {
+		t.Errorf("expected HiddenFieldPrefixes %q, got %q", want, cfg.HiddenFieldPrefixes)
+	}
+	if want := []string{"-", "N/A"}; !reflect.DeepEqual(cfg.HiddenValues, want) {
+		t.Errorf("expected HiddenValues %q, got %q", want, cfg.HiddenValues)
+	}
+}
+
+func TestLoad_DefaultFields(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DefaultFields != "" {
+		t.Errorf("expected empty DefaultFields by default, got %q", cfg.DefaultFields)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_DEFAULT_FIELDS", "level,kubernetes_*")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DefaultFields != "level,kubernetes_*" {
+		t.Errorf("expected DefaultFields=level,kubernetes_*, got %q", cfg.DefaultFields)
+	}
+}
+
+func TestLoad_ValidateURLEndpoint(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ValidateURLEndpoint {
+		t.Error("expected ValidateURLEndpoint=false by default")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_VALIDATE_URL_ENDPOINT", "true")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ValidateURLEndpoint {
+		t.Error("expected ValidateURLEndpoint=true")
+	}
+	if len(cfg.Warnings) == 0 || !strings.Contains(strings.Join(cfg.Warnings, "\n"), "GRAYLOG_VALIDATE_URL_ENDPOINT is ignored") {
+		t.Errorf("expected a stdio warning, got %v", cfg.Warnings)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_VALIDATE_URL_ENDPOINT", "sure")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_VALIDATE_URL_ENDPOINT value")
+	}
+}
+
+func TestLoad_UserAgentAndExtraHeaders(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_USER_AGENT", "graylog-mcp/ops")
+	t.Setenv("GRAYLOG_EXTRA_HEADERS", "X-Gateway-Token: abc, x-tenant:  team-a\nX-Trace: 1")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.UserAgent != "graylog-mcp/ops" {
+		t.Errorf("expected UserAgent %q, got %q", "graylog-mcp/ops", cfg.UserAgent)
+	}
+	for k, want := range map[string]string{"X-Gateway-Token": "abc", "X-Tenant": "team-a", "X-Trace": "1"} {
+		if got := cfg.ExtraHeaders.Get(k); got != want {
+			t.Errorf("header %s: expected %q, got %q", k, want, got)
+		}
+	}
+}
+
+func TestLoad_ExtraHeadersRejected(t *testing.T) {
+	for _, bad := range []string{"authorization: Bearer x", "X-Requested-By: me", "NoColon", ": value"} {
+		setupConfigTest(t)
+		setupStdioEnv(t)
+		t.Setenv("GRAYLOG_TOKEN", "mytoken")
+		t.Setenv("GRAYLOG_EXTRA_HEADERS", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_EXTRA_HEADERS=%q", bad)
+		}
+	}
+}
+
+func TestLoad_AllowedHosts(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_ALLOWED_HOSTS", " Graylog.example.com, *.logs.example.com:9000 ,,")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"graylog.example.com", "*.logs.example.com:9000"}
+	if !reflect.DeepEqual(cfg.AllowedHosts, want) {
+		t.Errorf("expected AllowedHosts %v, got %v", want, cfg.AllowedHosts)
+	}
+}
+
+func TestLoad_AllowedHostsRejected(t *testing.T) {
+	for _, bad := range []string{"https://graylog.example.com", "graylog.example.com/api", "gray*.example.com", "host:port", ","} {
+		setupConfigTest(t)
+		setupStdioEnv(t)
+		t.Setenv("GRAYLOG_TOKEN", "mytoken")
+		t.Setenv("GRAYLOG_ALLOWED_HOSTS", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_ALLOWED_HOSTS=%q", bad)
+		}
+	}
+}
+
+func TestLoad_ExportMaxRows(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ExportMaxRows != 10000 {
+		t.Errorf("expected default ExportMaxRows 10000, got %d", cfg.ExportMaxRows)
+	}
+
+	for _, bad := range []string{"0", "-5", "many"} {
+		setupConfigTest(t)
+		setupStdioEnv(t)
+		t.Setenv("GRAYLOG_TOKEN", "mytoken")
+		t.Setenv("GRAYLOG_EXPORT_MAX_ROWS", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_EXPORT_MAX_ROWS=%q", bad)
+		}
+	}
+}
+
+func TestLoad_MaxSearchLimit(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxSearchLimit != 10000 {
+		t.Errorf("expected default MaxSearchLimit 10000, got %d", cfg.MaxSearchLimit)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MAX_SEARCH_LIMIT", "500")
+	if cfg, err = config.Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxSearchLimit != 500 {
+		t.Errorf("expected MaxSearchLimit 500, got %d", cfg.MaxSearchLimit)
+	}
+
+	for _, bad := range []string{"0", "-5", "lots"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_MAX_SEARCH_LIMIT", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_MAX_SEARCH_LIMIT=%q", bad)
+		}
+	}
+}
+
+func TestLoad_MaxGroupByFields(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxGroupByFields != 3 {
+		t.Errorf("expected default MaxGroupByFields 3, got %d", cfg.MaxGroupByFields)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MAX_GROUP_BY_FIELDS", "5")
+	if cfg, err = config.Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxGroupByFields != 5 {
+		t.Errorf("expected MaxGroupByFields 5, got %d", cfg.MaxGroupByFields)
+	}
+
+	for _, bad := range []string{"0", "-1", "many"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_MAX_GROUP_BY_FIELDS", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_MAX_GROUP_BY_FIELDS=%q", bad)
+		}
+	}
+}
+
+func TestLoad_LogSettings(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LogLevel != slog.LevelInfo || cfg.LogFormat != "text" {
+		t.Errorf("expected info/text defaults, got %v/%s", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_MCP_LOG_LEVEL", "DEBUG")
+	t.Setenv("GRAYLOG_MCP_LOG_FORMAT", "json")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LogLevel != slog.LevelDebug || cfg.LogFormat != "json" {
+		t.Errorf("expected debug/json, got %v/%s", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	for env, bad := range map[string]string{"GRAYLOG_MCP_LOG_LEVEL": "verbose", "GRAYLOG_MCP_LOG_FORMAT": "xml"} {
+		setupConfigTest(t)
+		setupStdioEnv(t)
+		t.Setenv("GRAYLOG_TOKEN", "mytoken")
+		t.Setenv(env, bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for %s=%q", env, bad)
+		}
+	}
+}
+
+func TestLoad_WarningsCollected(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_TLS_SKIP_VERIFY", "true")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "TLS certificate verification is disabled") {
+		t.Errorf("expected a TLS warning, got %v", cfg.Warnings)
+	}
+}
+
+func TestLoad_QuietSuppressesWarnings(t *testing.T) {
+	for _, quiet := range []string{"", "true"} {
+		setupConfigTest(t)
+		setupStdioEnv(t)
+		t.Setenv("GRAYLOG_TOKEN", "mytoken")
+		t.Setenv("GRAYLOG_TLS_SKIP_VERIFY", "true")
+		t.Setenv("GRAYLOG_MCP_METRICS", "true")
+		t.Setenv("GRAYLOG_MCP_QUIET", quiet)
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("GRAYLOG_MCP_QUIET=%q: unexpected error: %v", quiet, err)
+		}
+		if quiet == "" && len(cfg.Warnings) != 2 {
+			t.Errorf("expected TLS and metrics warnings without quiet, got %v", cfg.Warnings)
+		}
+		if quiet != "" && (!cfg.Quiet || len(cfg.Warnings) != 0) {
+			t.Errorf("expected warnings suppressed with quiet, got Quiet=%v %v", cfg.Quiet, cfg.Warnings)
+		}
+	}
+
+	// Fatal errors are still reported.
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_MCP_QUIET", "true")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected missing credentials to fail even with quiet")
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_MCP_QUIET", "shh")
+	if _, err := config.Load(); err == nil {
+		t.Error("expected error for invalid GRAYLOG_MCP_QUIET value 'shh'")
+	}
+}
+
+func TestLoad_DialTimeout(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DialTimeout != 10*time.Second {
+		t.Errorf("expected default DialTimeout=10s, got %s", cfg.DialTimeout)
+	}
+
+	setupConfigTest(t)
+	t.Setenv("GRAYLOG_DIAL_TIMEOUT", "2s")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DialTimeout != 2*time.Second {
+		t.Errorf("expected DialTimeout=2s, got %s", cfg.DialTimeout)
+	}
+
+	for _, bad := range []string{"soon", "0s", "-1s"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_DIAL_TIMEOUT", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_DIAL_TIMEOUT=%q", bad)
+		}
+	}
+}
+
+func TestLoad_ToolTimeout(t *testing.T) {
+	setupConfigTest(t)
+	setupStdioEnv(t)
+	t.Setenv("GRAYLOG_TOKEN", "mytoken")
+	t.Setenv("GRAYLOG_TOOL_TIMEOUT", "45s")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ToolTimeout != 45*time.Second {
+		t.Errorf("expected ToolTimeout=45s, got %s", cfg.ToolTimeout)
+	}
+
+	for _, bad := range []string{"forever", "-5s"} {
+		setupConfigTest(t)
+		t.Setenv("GRAYLOG_TOOL_TIMEOUT", bad)
+		if _, err := config.Load(); err == nil {
+			t.Errorf("expected error for GRAYLOG_TOOL_TIMEOUT=%q", bad)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"time"
 
 	"github.com/n0madic/graylog-mcp/graylog"
 )
@@ -15,10 +16,18 @@ type DedupResult struct {
 	Index      string          `json:"index"`
 	Count      int             `json:"count"`
 	MessageIDs []string        `json:"message_ids"`
+	// FirstSeen and LastSeen are the oldest and newest timestamps in the
+	// group, as sent by Graylog. Empty if none of them could be parsed.
+	FirstSeen string `json:"first_seen,omitempty"`
+	LastSeen  string `json:"last_seen,omitempty"`
+	// ContextRef is an opaque token naming the representative message,
+	// filled in by callers that offer a context lookup.
+	ContextRef string `json:"context_ref,omitempty"`
 }
 
 func (d DedupResult) MarshalJSON() ([]byte, error) {
-	// Build message map without _id (redundant with message_ids)
+	// Build message map without _id: message_ids lists the group's IDs and
+	// representative_id names the one shown, even when message_ids is capped.
 	msgMap := make(map[string]any)
 	msgMap["timestamp"] = d.Message.Timestamp
 	msgMap["source"] = d.Message.Source
@@ -28,17 +37,25 @@ func (d DedupResult) MarshalJSON() ([]byte, error) {
 	}
 
 	type alias struct {
-		Message    map[string]any `json:"message"`
-		Index      string         `json:"index"`
-		Count      int            `json:"count"`
-		MessageIDs []string       `json:"message_ids"`
+		Message          map[string]any `json:"message"`
+		Index            string         `json:"index"`
+		Count            int            `json:"count"`
+		RepresentativeID string         `json:"representative_id,omitempty"`
+		MessageIDs       []string       `json:"message_ids"`
+		FirstSeen        string         `json:"first_seen,omitempty"`
+		LastSeen         string         `json:"last_seen,omitempty"`
+		ContextRef       string         `json:"context_ref,omitempty"`
 	}
 
 	return json.Marshal(alias{
-		Message:    msgMap,
-		Index:      d.Index,
-		Count:      d.Count,
-		MessageIDs: d.MessageIDs,
+		Message:          msgMap,
+		Index:            d.Index,
+		Count:            d.Count,
+		RepresentativeID: d.Message.ID,
+		MessageIDs:       d.MessageIDs,
+		FirstSeen:        d.FirstSeen,
+		LastSeen:         d.LastSeen,
+		ContextRef:       d.ContextRef,
 	})
 }
 
@@ -50,31 +67,120 @@ func CapMessageIDs(results []DedupResult, maxIDs int) {
 	}
 }
 
+// Representative selects which message of a group becomes DedupResult.Message.
+type Representative string
+
+const (
+	// RepresentativeFirst keeps the first message in input order, i.e. the
+	// newest or oldest depending on the search sort.
+	RepresentativeFirst Representative = "first"
+	// RepresentativeNewest keeps the message with the latest timestamp.
+	RepresentativeNewest Representative = "newest"
+	// RepresentativeLongest keeps the message with the most text (message
+	// plus full_message), usually the most detailed one.
+	RepresentativeLongest Representative = "longest"
+)
+
+// ParseRepresentative validates a representative strategy name. An empty
+// name means RepresentativeFirst.
+func ParseRepresentative(name string) (Representative, error) {
+	switch rep := Representative(name); rep {
+	case "":
+		return RepresentativeFirst, nil
+	case RepresentativeFirst, RepresentativeNewest, RepresentativeLongest:
+		return rep, nil
+	default:
+		return "", fmt.Errorf("unknown representative %q: must be 'first', 'newest' or 'longest'", name)
+	}
+}
+
+// Deduplicate groups content-identical messages, keeping the first message of
+// each group as its representative. Messages from different indices can share
+// a group.
 func Deduplicate(messages []graylog.MessageWrapper, hashFields []string) []DedupResult {
+	return DeduplicateWith(messages, hashFields, RepresentativeFirst, false)
+}
+
+// DeduplicateWith is Deduplicate with a choice of representative message.
+// Ties keep the earlier message, so every strategy is deterministic. With
+// includeIndex, the index is part of the hash, so identical messages from
+// different indices (e.g. retention sets or tenants) stay in separate groups.
+func DeduplicateWith(messages []graylog.MessageWrapper, hashFields []string, rep Representative, includeIndex bool) []DedupResult {
 	seen := make(map[string]int) // hash -> index in results
 	var results []DedupResult
+	var spans []timeSpan // parallel to results
 
 	for _, mw := range messages {
-		h := hashMessage(mw.Message, hashFields)
-		if idx, ok := seen[h]; ok {
+		h := hashMessage(mw, hashFields, includeIndex)
+		idx, ok := seen[h]
+		if ok {
 			results[idx].Count++
 			results[idx].MessageIDs = append(results[idx].MessageIDs, mw.Message.ID)
 		} else {
-			seen[h] = len(results)
+			idx = len(results)
+			seen[h] = idx
 			results = append(results, DedupResult{
 				Message:    mw.Message,
 				Index:      mw.Index,
 				Count:      1,
 				MessageIDs: []string{mw.Message.ID},
 			})
+			spans = append(spans, timeSpan{})
+		}
+		newest := spans[idx].observe(&results[idx], mw.Message.Timestamp)
+		if !ok {
+			continue
+		}
+		if (rep == RepresentativeNewest && newest) ||
+			(rep == RepresentativeLongest && textLength(mw.Message) > textLength(results[idx].Message)) {
+			results[idx].Message = mw.Message
+			results[idx].Index = mw.Index
 		}
 	}
 
 	return results
 }
 
-func hashMessage(msg graylog.Message, hashFields []string) string {
+// textLength is the amount of text a message carries, for RepresentativeLongest.
+func textLength(msg graylog.Message) int {
+	n := len(msg.Message)
+	if full, ok := msg.Extra["full_message"].(string); ok {
+		n += len(full)
+	}
+	return n
+}
+
+// timeSpan tracks the parsed bounds behind a group's FirstSeen/LastSeen, so
+// the comparison doesn't depend on Graylog's string format.
+type timeSpan struct {
+	first, last time.Time
+}
+
+// observe widens the span with timestamp and reports whether it is the
+// group's newest so far.
+func (s *timeSpan) observe(r *DedupResult, timestamp string) bool {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return false
+	}
+	if r.FirstSeen == "" || t.Before(s.first) {
+		s.first, r.FirstSeen = t, timestamp
+	}
+	if r.LastSeen == "" || t.After(s.last) {
+		s.last, r.LastSeen = t, timestamp
+		return true
+	}
+	return false
+}
+
+func hashMessage(mw graylog.MessageWrapper, hashFields []string, includeIndex bool) string {
 	h := sha256.New()
+	msg := mw.Message
+	if includeIndex {
+		// Hashed ahead of the fields, so it can't collide with an "index"
+		// field of the message itself.
+		marshalToHash(h, mw.Index)
+	}
 
 	if len(hashFields) > 0 {
 		data := make(map[string]any)
@@ -126,8 +232,11 @@ func messageToMap(msg graylog.Message) map[string]any {
 	return result
 }
 
+// shouldSkipField reports fields left out of the default hash. full_message
+// is skipped because it usually embeds per-occurrence details (stack frames,
+// request IDs) that would split otherwise identical messages into groups.
 func shouldSkipField(field string) bool {
-	return field == "_id" || field == "timestamp" || field == "index"
+	return field == "_id" || field == "timestamp" || field == "index" || field == "full_message"
 }
 
 // sortedMap returns a deterministic representation for hashing.
@@ -1,6 +1,7 @@
 package dedup
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/n0madic/graylog-mcp/graylog"
@@ -115,8 +116,8 @@ func TestHashMessage_stability(t *testing.T) {
 		Source:    "myhost",
 		Message:   "test message",
 	}
-	h1 := hashMessage(msg, nil)
-	h2 := hashMessage(msg, nil)
+	h1 := hashMessage(graylog.MessageWrapper{Message: msg}, nil, false)
+	h2 := hashMessage(graylog.MessageWrapper{Message: msg}, nil, false)
 	if h1 != h2 {
 		t.Errorf("hash must be stable: got %s and %s", h1, h2)
 	}
@@ -126,8 +127,8 @@ func TestHashMessage_differentContent(t *testing.T) {
 	msg1 := graylog.Message{Source: "myhost", Message: "error A"}
 	msg2 := graylog.Message{Source: "myhost", Message: "error B"}
 
-	h1 := hashMessage(msg1, nil)
-	h2 := hashMessage(msg2, nil)
+	h1 := hashMessage(graylog.MessageWrapper{Message: msg1}, nil, false)
+	h2 := hashMessage(graylog.MessageWrapper{Message: msg2}, nil, false)
 	if h1 == h2 {
 		t.Error("different messages should produce different hashes")
 	}
@@ -137,13 +138,28 @@ func TestHashMessage_ignoresIDAndTimestamp(t *testing.T) {
 	msg1 := graylog.Message{ID: "id-1", Timestamp: "2024-01-01T00:00:00Z", Source: "host", Message: "msg"}
 	msg2 := graylog.Message{ID: "id-2", Timestamp: "2024-06-15T12:00:00Z", Source: "host", Message: "msg"}
 
-	h1 := hashMessage(msg1, nil)
-	h2 := hashMessage(msg2, nil)
+	h1 := hashMessage(graylog.MessageWrapper{Message: msg1}, nil, false)
+	h2 := hashMessage(graylog.MessageWrapper{Message: msg2}, nil, false)
 	if h1 != h2 {
 		t.Error("hash should ignore _id and timestamp fields")
 	}
 }
 
+func TestDeduplicate_ignoresFullMessage(t *testing.T) {
+	msgs := []graylog.MessageWrapper{makeMsg("1", "NullPointerException"), makeMsg("2", "NullPointerException")}
+	msgs[0].Message.Extra = map[string]any{"full_message": "NullPointerException\n\tat Foo.bar(Foo.java:10) req=a1"}
+	msgs[1].Message.Extra = map[string]any{"full_message": "NullPointerException\n\tat Foo.bar(Foo.java:10) req=b2"}
+
+	results := Deduplicate(msgs, nil)
+	if len(results) != 1 || results[0].Count != 2 {
+		t.Fatalf("expected full_message differences to collapse into 1 group of 2, got %+v", results)
+	}
+
+	if hashMessage(msgs[0], []string{"full_message"}, false) == hashMessage(msgs[1], []string{"full_message"}, false) {
+		t.Error("explicit hashFields should still hash full_message")
+	}
+}
+
 func TestHashMessageDoesNotPanicOnNonMarshalableExtra(t *testing.T) {
 	msg := graylog.Message{
 		ID:      "id-1",
@@ -160,8 +176,184 @@ func TestHashMessageDoesNotPanicOnNonMarshalableExtra(t *testing.T) {
 		}
 	}()
 
-	h := hashMessage(msg, nil)
+	h := hashMessage(graylog.MessageWrapper{Message: msg}, nil, false)
 	if h == "" {
 		t.Fatal("expected non-empty hash")
 	}
 }
+
+func TestDeduplicate_firstAndLastSeen(t *testing.T) {
+	at := func(id, msg, ts string) graylog.MessageWrapper {
+		mw := makeMsg(id, msg)
+		mw.Message.Timestamp = ts
+		return mw
+	}
+	// Newest-first, as search_logs returns them, with one out-of-order and
+	// one unparseable timestamp in the burst.
+	msgs := []graylog.MessageWrapper{
+		at("1", "burst", "2024-01-01T10:05:00.000Z"),
+		at("2", "other", "2024-01-01T10:04:00.000Z"),
+		at("3", "burst", "2024-01-01T10:07:30.250Z"),
+		at("4", "burst", "not-a-time"),
+		at("5", "burst", "2024-01-01T10:00:00.000Z"),
+		at("6", "undated", ""),
+	}
+	results := Deduplicate(msgs, nil)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(results))
+	}
+
+	burst := results[0]
+	if burst.FirstSeen != "2024-01-01T10:00:00.000Z" || burst.LastSeen != "2024-01-01T10:07:30.250Z" {
+		t.Errorf("burst: expected 10:00:00.000 .. 10:07:30.250, got %q .. %q", burst.FirstSeen, burst.LastSeen)
+	}
+	if burst.Message.ID != "1" {
+		t.Errorf("representative should stay the first message seen, got %s", burst.Message.ID)
+	}
+	if single := results[1]; single.FirstSeen != "2024-01-01T10:04:00.000Z" || single.LastSeen != single.FirstSeen {
+		t.Errorf("single message: expected first_seen == last_seen, got %q .. %q", single.FirstSeen, single.LastSeen)
+	}
+
+	b, err := json.Marshal(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0]["first_seen"] != burst.FirstSeen || decoded[0]["last_seen"] != burst.LastSeen {
+		t.Errorf("first_seen/last_seen missing from JSON: %v", decoded[0])
+	}
+	if _, ok := decoded[2]["first_seen"]; ok {
+		t.Errorf("first_seen should be omitted when no timestamp parses: %v", decoded[2])
+	}
+}
+
+func TestDeduplicateWith_representative(t *testing.T) {
+	msg := func(id, ts, full string) graylog.MessageWrapper {
+		mw := makeMsg(id, "disk full")
+		mw.Message.Timestamp = ts
+		mw.Index = "index-" + id
+		if full != "" {
+			mw.Message.Extra = map[string]any{"full_message": full}
+		}
+		return mw
+	}
+	// One group; full_message is not hashed, so all four are duplicates.
+	msgs := []graylog.MessageWrapper{
+		msg("1", "2024-01-01T10:01:00.000Z", ""),
+		msg("2", "2024-01-01T10:03:00.000Z", "disk full\n  at write()"),
+		msg("3", "2024-01-01T10:03:00.000Z", "disk full\n  at write()\n  at flush()"),
+		msg("4", "2024-01-01T10:02:00.000Z", "disk full\n  at write()\n  at flush()"),
+	}
+
+	tests := []struct {
+		rep    Representative
+		wantID string
+	}{
+		{rep: RepresentativeFirst, wantID: "1"},
+		{rep: RepresentativeNewest, wantID: "2"},  // 3 ties on timestamp and loses
+		{rep: RepresentativeLongest, wantID: "3"}, // 4 ties on length and loses
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.rep), func(t *testing.T) {
+			results := DeduplicateWith(msgs, nil, tt.rep, false)
+			if len(results) != 1 {
+				t.Fatalf("expected 1 group, got %d", len(results))
+			}
+			r := results[0]
+			if r.Message.ID != tt.wantID || r.Index != "index-"+tt.wantID {
+				t.Errorf("expected representative %s, got %s (index %s)", tt.wantID, r.Message.ID, r.Index)
+			}
+			if r.Count != 4 || len(r.MessageIDs) != 4 || r.MessageIDs[0] != "1" {
+				t.Errorf("representative choice must not change count or message_ids: %+v", r)
+			}
+			if r.FirstSeen != "2024-01-01T10:01:00.000Z" || r.LastSeen != "2024-01-01T10:03:00.000Z" {
+				t.Errorf("unexpected span %q .. %q", r.FirstSeen, r.LastSeen)
+			}
+		})
+	}
+}
+
+func TestDeduplicateWith_newestSkipsUnparseableTimestamp(t *testing.T) {
+	a := makeMsg("1", "same")
+	a.Message.Timestamp = "garbage"
+	b := makeMsg("2", "same")
+	b.Message.Timestamp = "2024-01-01T10:00:00.000Z"
+	results := DeduplicateWith([]graylog.MessageWrapper{a, b}, nil, RepresentativeNewest, false)
+	if results[0].Message.ID != "2" {
+		t.Errorf("expected the parseable timestamp to win, got %s", results[0].Message.ID)
+	}
+}
+
+func TestParseRepresentative(t *testing.T) {
+	for name, want := range map[string]Representative{"": RepresentativeFirst, "first": RepresentativeFirst, "newest": RepresentativeNewest, "longest": RepresentativeLongest} {
+		if got, err := ParseRepresentative(name); err != nil || got != want {
+			t.Errorf("ParseRepresentative(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseRepresentative("oldest"); err == nil {
+		t.Error("expected error for unknown representative")
+	}
+}
+
+func TestDeduplicateWith_includeIndex(t *testing.T) {
+	a := makeMsg("1", "disk full")
+	b := makeMsg("2", "disk full")
+	b.Index = "tenant_b_0"
+	msgs := []graylog.MessageWrapper{a, b}
+
+	if results := DeduplicateWith(msgs, nil, RepresentativeFirst, false); len(results) != 1 || results[0].Count != 2 {
+		t.Fatalf("expected cross-index duplicates to collapse by default, got %+v", results)
+	}
+	results := DeduplicateWith(msgs, nil, RepresentativeFirst, true)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 groups with includeIndex, got %+v", results)
+	}
+	if results[0].Index != "index-1" || results[1].Index != "tenant_b_0" {
+		t.Errorf("expected one group per index, got %s and %s", results[0].Index, results[1].Index)
+	}
+
+	// The message's own "index" field doesn't stand in for the index.
+	c := makeMsg("3", "disk full")
+	c.Message.Extra = map[string]any{"index": "tenant_b_0"}
+	if hashMessage(c, nil, true) == hashMessage(b, nil, true) {
+		t.Error("expected the wrapper index, not an 'index' field, to be hashed")
+	}
+	if hashMessage(a, []string{"message"}, true) == hashMessage(b, []string{"message"}, true) {
+		t.Error("expected includeIndex to apply with explicit hashFields too")
+	}
+}
+
+func TestDedupResultMarshalRepresentativeID(t *testing.T) {
+	msgs := []graylog.MessageWrapper{
+		makeMsg("a1", "disk full"),
+		makeMsg("b1", "cache miss"),
+		makeMsg("a2", "disk full"),
+		makeMsg("a3", "disk full"),
+	}
+	results := Deduplicate(msgs, nil)
+	// The representative ID survives even with every message ID capped away.
+	CapMessageIDs(results, 0)
+
+	b, err := json.Marshal(results)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var groups []map[string]any
+	if err := json.Unmarshal(b, &groups); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	for i, want := range []string{"a1", "b1"} {
+		if got := groups[i]["representative_id"]; got != want {
+			t.Errorf("group %d: representative_id = %v, want %q", i, got, want)
+		}
+		if _, ok := groups[i]["message"].(map[string]any)["_id"]; ok {
+			t.Errorf("group %d: expected no _id in message", i)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package graylog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrBackendUnavailable is wrapped by errors returned when the circuit breaker
+// for a Graylog base URL is open and the request was not sent.
+var ErrBackendUnavailable = errors.New("Graylog backend temporarily unavailable")
+
+const (
+	breakerFailureThreshold = 5
+	breakerCooldown         = 30 * time.Second
+	breakerMaxCooldown      = 5 * time.Minute
+)
+
+// defaultBreakers is shared by every Client so that per-request clones in http
+// transport see the same breaker state for the same Graylog backend.
+var defaultBreakers = newBreakerRegistry(breakerFailureThreshold, breakerCooldown)
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+	// probeUntil is set while the one trial request let through after the
+	// cooldown is in flight. A probe that never reports back (e.g. its caller
+	// gave up) stops holding the slot at this time.
+	probeUntil time.Time
+}
+
+// breakerRegistry tracks consecutive failures per base URL. After threshold
+// consecutive failures (or a single 429/503 carrying Retry-After) requests to
+// that backend fail fast until the cooldown expires. Then a single request is
+// let through as a probe while the others keep failing fast: success closes
+// the breaker, failure re-opens it for another cooldown.
+type breakerRegistry struct {
+	mu        sync.Mutex
+	states    map[string]*breakerState
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newBreakerRegistry(threshold int, cooldown time.Duration) *breakerRegistry {
+	return &breakerRegistry{
+		states:    make(map[string]*breakerState),
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow returns an error wrapping ErrBackendUnavailable if the breaker for key
+// is open, or if its cooldown has expired and another request is already
+// probing the backend. Otherwise the caller may send its request; after a
+// cooldown that request becomes the probe.
+func (r *breakerRegistry) allow(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.states[key]
+	if !ok || st.openUntil.IsZero() {
+		return nil
+	}
+	now := r.now()
+	if wait := st.openUntil.Sub(now); wait > 0 {
+		return fmt.Errorf("%w: too many consecutive failures, retry in %s", ErrBackendUnavailable, wait.Round(time.Second))
+	}
+	if st.probeUntil.After(now) {
+		return fmt.Errorf("%w: a trial request is checking whether it recovered", ErrBackendUnavailable)
+	}
+	st.probeUntil = now.Add(r.cooldown)
+	return nil
+}
+
+func (r *breakerRegistry) recordSuccess(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.states, key)
+}
+
+// recordFailure counts a failure for key. A positive retryAfter (from a
+// Retry-After header) opens the breaker immediately for that long; a failure
+// while a probe is out re-opens it for the cooldown.
+func (r *breakerRegistry) recordFailure(key string, retryAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.states[key]
+	if !ok {
+		st = &breakerState{}
+		r.states[key] = st
+	}
+	st.failures++
+	probing := !st.probeUntil.IsZero()
+	st.probeUntil = time.Time{}
+
+	switch {
+	case retryAfter > 0:
+		st.openUntil = r.now().Add(min(retryAfter, breakerMaxCooldown))
+	case probing || st.failures >= r.threshold:
+		st.openUntil = r.now().Add(r.cooldown)
+	}
+}
+
+// isBreakerFailure reports whether a response status indicates the backend is
+// unhealthy. Client errors other than 429 are the caller's fault and don't count.
+func isBreakerFailure(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusTooManyRequests
+}
+
+// parseRetryAfter parses a Retry-After header (delay-seconds or HTTP-date).
+// It returns 0 when the header is absent or unparseable.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
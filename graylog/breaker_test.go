@@ -0,0 +1,197 @@
+package graylog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBreakerTestClient returns a client with an isolated breaker registry and a
+// controllable clock, so tests don't leak state through defaultBreakers.
+func newBreakerTestClient(baseURL string, threshold int, cooldown time.Duration) (*Client, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClient(baseURL, "user", "pass", false, 2*time.Second)
+	c.breakers = newBreakerRegistry(threshold, cooldown)
+	c.breakers.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	healthy := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if !healthy {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+	}))
+	defer srv.Close()
+
+	c, now := newBreakerTestClient(srv.URL, 3, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetStreams(ctx)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("call %d: expected APIError, got %v", i, err)
+		}
+	}
+
+	// Breaker is open: the request must not reach the server.
+	_, err := c.GetStreams(ctx)
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected ErrBackendUnavailable after threshold, got %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("expected short-circuited request not to hit server, got %d requests", requests)
+	}
+
+	// Breaker state is keyed by base URL: clones for the same backend share it.
+	clone := c.CloneWithAuth(srv.URL, "other", "creds")
+	if _, err := clone.GetStreams(ctx); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected clone to share open breaker, got %v", err)
+	}
+
+	// After the cooldown a probe is let through and success closes the breaker.
+	*now = now.Add(time.Minute + time.Second)
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	if _, err := c.GetStreams(ctx); err != nil {
+		t.Fatalf("expected recovery after cooldown, got %v", err)
+	}
+	if _, err := c.GetStreams(ctx); err != nil {
+		t.Fatalf("expected breaker to stay closed after success, got %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad query", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c, _ := newBreakerTestClient(srv.URL, 2, time.Minute)
+	for i := 0; i < 5; i++ {
+		_, err := c.GetStreams(context.Background())
+		if errors.Is(err, ErrBackendUnavailable) {
+			t.Fatalf("call %d: 4xx responses must not trip the breaker", i)
+		}
+	}
+}
+
+func TestCircuitBreakerHonorsRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c, now := newBreakerTestClient(srv.URL, 5, time.Minute)
+	ctx := context.Background()
+
+	if _, err := c.GetStreams(ctx); errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("first call should reach the server, got %v", err)
+	}
+	if _, err := c.GetStreams(ctx); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected Retry-After to open the breaker immediately, got %v", err)
+	}
+
+	*now = now.Add(11 * time.Second)
+	if _, err := c.GetStreams(ctx); errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected breaker to allow a probe after Retry-After elapsed, got %v", err)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newBreakerRegistry(2, time.Minute)
+	r.now = func() time.Time { return now }
+	const key = "http://graylog"
+
+	r.recordFailure(key, 0)
+	r.recordFailure(key, 0)
+	if err := r.allow(key); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected an open breaker, got %v", err)
+	}
+
+	// After the cooldown only one request goes through until it reports back.
+	now = now.Add(time.Minute)
+	if err := r.allow(key); err != nil {
+		t.Fatalf("expected the probe to be let through, got %v", err)
+	}
+	if err := r.allow(key); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected concurrent requests to wait for the probe, got %v", err)
+	}
+
+	// A failed probe re-opens the breaker for another cooldown.
+	r.recordFailure(key, 0)
+	now = now.Add(time.Minute - time.Second)
+	if err := r.allow(key); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected a failed probe to re-open the breaker, got %v", err)
+	}
+
+	// A probe that never reports back stops holding the slot after a cooldown.
+	now = now.Add(time.Second)
+	if err := r.allow(key); err != nil {
+		t.Fatalf("expected a second probe, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := r.allow(key); err != nil {
+		t.Fatalf("expected an abandoned probe to free the slot, got %v", err)
+	}
+
+	r.recordSuccess(key)
+	for i := range 3 {
+		if err := r.allow(key); err != nil {
+			t.Fatalf("request %d: expected a closed breaker after a successful probe, got %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreakerProbeFailureAfterRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newBreakerRegistry(5, time.Minute)
+	r.now = func() time.Time { return now }
+	const key = "http://graylog"
+
+	r.recordFailure(key, 10*time.Second)
+	now = now.Add(11 * time.Second)
+	if err := r.allow(key); err != nil {
+		t.Fatalf("expected a probe after Retry-After elapsed, got %v", err)
+	}
+	// Below the failure threshold, but the probe failed: stay open.
+	r.recordFailure(key, 0)
+	if err := r.allow(key); !errors.Is(err, ErrBackendUnavailable) {
+		t.Fatalf("expected the failed probe to re-open the breaker, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "", want: 0},
+		{in: "30", want: 30 * time.Second},
+		{in: "-1", want: 0},
+		{in: "Mon, 01 Jan 2024 00:01:00 GMT", want: time.Minute},
+		{in: "garbage", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -3,65 +3,221 @@ package graylog
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultMaxResponseBytes is the default cap on a Graylog response body.
+const DefaultMaxResponseBytes = 10 * 1024 * 1024
+
+// ErrResponseTooLarge is wrapped by errors returned when a response body
+// exceeds the client's maximum response size.
+var ErrResponseTooLarge = errors.New("Graylog response exceeded max size")
+
+// ErrNoResultStructure is wrapped by Search errors when Graylog answers 200 but
+// the Views response lacks the expected result keys. Callers should not treat
+// it as "zero matches".
+var ErrNoResultStructure = errors.New("search backend returned no result structure")
+
+// ErrAggregateUnavailable is wrapped by Aggregate errors when Graylog answers
+// 404 for the Scripting API endpoint itself, which older Graylog versions
+// lack. A 404 about something in the request (e.g. an unknown stream) is
+// returned as a plain *APIError.
+var ErrAggregateUnavailable = errors.New("scripting aggregate API not available")
+
 type Client struct {
-	baseURL    string
-	username   string
-	password   string
-	httpClient *http.Client
+	baseURL          string
+	credMu           sync.RWMutex // guards username/password, replaced by a token refresh
+	username         string
+	password         string
+	refreshToken     RefreshTokenFunc
+	httpClient       *http.Client
+	breakers         *breakerRegistry
+	limiter          *requestLimiter
+	searchCache      *searchCache
+	hidden           *hiddenFields
+	maxResponseBytes int64
+	userAgent        string
+	extraHeaders     http.Header
+	extraHeadersURL  string // the only base URL extraHeaders are sent to
+	dialTimeout      time.Duration
 }
 
-func NewClient(baseURL, username, password string, tlsSkipVerify bool, timeout time.Duration) *Client {
-	t, ok := http.DefaultTransport.(*http.Transport)
-	if !ok {
-		t = &http.Transport{}
+// DefaultDialTimeout bounds TCP connection setup so an unreachable Graylog
+// fails fast instead of consuming the whole request timeout.
+const DefaultDialTimeout = 10 * time.Second
+
+// Option configures optional Client settings.
+type Option func(*Client)
+
+// WithMaxResponseBytes sets the maximum accepted response body size.
+// Non-positive values keep DefaultMaxResponseBytes.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxResponseBytes = n
+		}
 	}
-	transport := t.Clone()
-	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: tlsSkipVerify} //nolint:gosec
-	return &Client{
-		baseURL:  strings.TrimRight(baseURL, "/"),
-		username: username,
-		password: password,
-		httpClient: &http.Client{
-			Timeout:   timeout,
-			Transport: transport,
-		},
+}
+
+// WithDialTimeout sets the TCP connect timeout, separate from the overall
+// request timeout. Non-positive values keep DefaultDialTimeout.
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.dialTimeout = d
+		}
+	}
+}
+
+// WithMaxConcurrentRequests caps the requests in flight to one Graylog at n;
+// further requests wait for a slot or for their context to end. The cap is
+// shared with clones. Non-positive values leave requests unlimited.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.limiter = newRequestLimiter(n)
+		}
 	}
 }
 
+// WithSearchCache caches Search responses for ttl, keyed by the backend,
+// credentials and full request, so an identical search repeated within ttl is
+// answered without calling Graylog. The cache is bounded by entry count and
+// total bytes, and skips bodies over 1 MiB. Non-positive values disable it.
+// Callers that must see fresh data use WithoutSearchCache.
+func WithSearchCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.searchCache = newSearchCache(ttl, maxSearchCacheEntries, maxSearchCacheBytes, maxSearchCacheBodyBytes)
+		}
+	}
+}
+
+// WithHiddenFields drops fields whose names start with one of prefixes, or
+// whose value is one of values, from the messages the client returns, in
+// addition to the built-in "gl2_" prefix and "fullyCutByExtractor" value.
+func WithHiddenFields(prefixes, values []string) Option {
+	return func(c *Client) {
+		if len(prefixes) > 0 || len(values) > 0 {
+			c.hidden = newHiddenFields(prefixes, values)
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// RefreshTokenFunc returns a fresh Graylog API token.
+type RefreshTokenFunc func(ctx context.Context) (string, error)
+
+// WithRefreshToken sets a callback invoked once when Graylog answers 401. The
+// returned token replaces the client's credentials and the request is retried
+// with it. Without a callback a 401 is returned as-is.
+func WithRefreshToken(fn RefreshTokenFunc) Option {
+	return func(c *Client) {
+		c.refreshToken = fn
+	}
+}
+
+// WithExtraHeaders adds headers to every request sent to baseURL, the
+// configured Graylog. Clones pointed at another base URL (an X-Graylog-URL
+// override in http transport) don't send them, so a secret meant for the
+// operator's gateway never reaches a host the caller picked. Authorization
+// and X-Requested-By are always set by the client and cannot be overridden.
+func WithExtraHeaders(baseURL string, h http.Header) Option {
+	return func(c *Client) {
+		c.extraHeadersURL = strings.TrimRight(baseURL, "/")
+		c.extraHeaders = h.Clone()
+		c.extraHeaders.Del("Authorization")
+		c.extraHeaders.Del("X-Requested-By")
+	}
+}
+
+func NewClient(baseURL, username, password string, tlsSkipVerify bool, timeout time.Duration, opts ...Option) *Client {
+	c := &Client{
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		username:         username,
+		password:         password,
+		breakers:         defaultBreakers,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		dialTimeout:      DefaultDialTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	transport := newTransport(tlsSkipVerify)
+	transport.DialContext = (&net.Dialer{Timeout: c.dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	c.httpClient = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	return c
+}
+
 // NewSSRFSafeClient creates a Client whose transport resolves DNS and checks
 // every resolved IP against ipBlocker before connecting. This prevents DNS
 // rebinding attacks where a hostname resolves to a public IP at validation time
 // but to a private IP when the HTTP client actually connects.
-func NewSSRFSafeClient(tlsSkipVerify bool, timeout time.Duration, ipBlocker func(net.IP) bool) *Client {
+func NewSSRFSafeClient(tlsSkipVerify bool, timeout time.Duration, ipBlocker func(net.IP) bool, opts ...Option) *Client {
+	c := &Client{
+		breakers:         defaultBreakers,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		dialTimeout:      DefaultDialTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	transport := newTransport(tlsSkipVerify)
+	dialer := &net.Dialer{Timeout: c.dialTimeout}
+	transport.DialContext = ssrfSafeDialContext(dialer, ipBlocker)
+	c.httpClient = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	return c
+}
+
+// newTransport clones http.DefaultTransport with the TLS settings applied.
+// It sets no ResponseHeaderTimeout: synchronous Graylog searches only send
+// headers once the search completes, so the http.Client timeout (or a
+// per-call WithTimeout) is the one deadline.
+func newTransport(tlsSkipVerify bool) *http.Transport {
 	t, ok := http.DefaultTransport.(*http.Transport)
 	if !ok {
 		t = &http.Transport{}
 	}
 	transport := t.Clone()
 	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: tlsSkipVerify} //nolint:gosec
-
-	dialer := &net.Dialer{Timeout: 10 * time.Second}
-	transport.DialContext = ssrfSafeDialContext(dialer, ipBlocker)
-
-	return &Client{
-		httpClient: &http.Client{
-			Timeout:   timeout,
-			Transport: transport,
-		},
-	}
+	// All http-mode sessions share this transport and usually talk to the
+	// same Graylog host; the default of 2 idle connections per host would
+	// close most of them after a burst of concurrent calls.
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return transport
 }
 
+// maxIdleConnsPerHost is how many keep-alive connections per Graylog host the
+// transport keeps for reuse.
+const maxIdleConnsPerHost = 32
+
 // ssrfSafeDialContext returns a DialContext function that resolves DNS itself,
 // checks each IP against ipBlocker, and connects directly to the verified IP.
 func ssrfSafeDialContext(dialer *net.Dialer, ipBlocker func(net.IP) bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -89,7 +245,8 @@ func ssrfSafeDialContext(dialer *net.Dialer, ipBlocker func(net.IP) bool) func(c
 		}
 
 		for _, ipAddr := range ips {
-			if ipBlocker(ipAddr.IP) {
+			// A zoned literal ("fe80::1%eth0") is scoped to a local interface.
+			if ipAddr.Zone != "" || ipBlocker(ipAddr.IP) {
 				return nil, fmt.Errorf("connection to %s (%s) blocked: private or special-use address", host, ipAddr.IP)
 			}
 		}
@@ -99,17 +256,41 @@ func ssrfSafeDialContext(dialer *net.Dialer, ipBlocker func(net.IP) bool) func(c
 	}
 }
 
+// CacheKey identifies the Graylog backend and credentials of c without
+// exposing the credentials, for caches that must not leak data across users.
+func (c *Client) CacheKey() string {
+	c.credMu.RLock()
+	sum := sha256.Sum256([]byte(c.username + "\x00" + c.password))
+	c.credMu.RUnlock()
+	return c.baseURL + "|" + hex.EncodeToString(sum[:])
+}
+
 // CloneWithAuth returns a lightweight client that reuses the same underlying
-// http.Client/Transport while overriding base URL and credentials.
+// http.Client/Transport while overriding base URL and credentials. The token
+// refresh callback is not copied: it belongs to the original credentials.
+//
+// Credentials travel in each request's headers, never in the transport, so
+// clones are safe to use concurrently and share one connection pool: the
+// http transport calls this per request, and a fresh Transport per clone
+// would lose keep-alives.
 func (c *Client) CloneWithAuth(baseURL, username, password string) *Client {
 	if c == nil {
 		return nil
 	}
 	return &Client{
-		baseURL:    strings.TrimRight(baseURL, "/"),
-		username:   username,
-		password:   password,
-		httpClient: c.httpClient,
+		baseURL:          strings.TrimRight(baseURL, "/"),
+		username:         username,
+		password:         password,
+		httpClient:       c.httpClient,
+		breakers:         c.breakers,
+		limiter:          c.limiter,
+		searchCache:      c.searchCache,
+		hidden:           c.hidden,
+		maxResponseBytes: c.maxResponseBytes,
+		userAgent:        c.userAgent,
+		extraHeaders:     c.extraHeaders,
+		extraHeadersURL:  c.extraHeadersURL,
+		dialTimeout:      c.dialTimeout,
 	}
 }
 
@@ -127,30 +308,8 @@ func (c *Client) doGet(ctx context.Context, path string, params url.Values) ([]b
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.SetBasicAuth(c.username, c.password)
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("X-Requested-By", "XMLHttpRequest")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
-	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
-	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Body:       string(body),
-			Path:       path,
-		}
-	}
-
-	return body, nil
+	c.setHeaders(req)
+	return c.execute(req, path)
 }
 
 func (c *Client) doPost(ctx context.Context, path string, body any) ([]byte, error) {
@@ -169,37 +328,159 @@ func (c *Client) doPost(ctx context.Context, path string, body any) ([]byte, err
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	return c.execute(req, path)
+}
+
+// RequestIDHeader carries the tool call's request ID to Graylog, so calls can
+// be matched with Graylog's access logs.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose Graylog requests carry id in the
+// RequestIDHeader.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type timeoutKey struct{}
+
+// WithTimeout returns a context whose Graylog requests each get timeout
+// instead of the client's configured timeout, longer or shorter. Non-positive
+// values keep the default.
+func WithTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, timeout)
+}
+
+// setHeaders applies the configured extra headers (for the configured Graylog
+// only) and User-Agent, then the request ID and the headers Graylog requires,
+// so the latter always win.
+func (c *Client) setHeaders(req *http.Request) {
+	if c.baseURL == c.extraHeadersURL {
+		for k, vs := range c.extraHeaders {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	c.credMu.RLock()
 	req.SetBasicAuth(c.username, c.password)
+	c.credMu.RUnlock()
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Requested-By", "XMLHttpRequest")
+}
+
+// execute sends req and returns the response body, or an *APIError for
+// non-2xx statuses. On a 401 with a refresh callback configured, the token is
+// refreshed once and the request retried with it.
+func (c *Client) execute(req *http.Request, path string) ([]byte, error) {
+	body, err := c.executeOnce(req, path)
+	var apiErr *APIError
+	if c.refreshToken == nil || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		return body, err
+	}
 
-	resp, err := c.httpClient.Do(req)
+	token, refreshErr := c.refreshToken(req.Context())
+	if refreshErr != nil {
+		return nil, fmt.Errorf("refreshing token after 401 (path %s): %w", path, refreshErr)
+	}
+	// Graylog token auth: the token is the username, the password is literally "token".
+	c.credMu.Lock()
+	c.username, c.password = token, "token"
+	c.credMu.Unlock()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("rewinding request body: %w", err)
+		}
+	}
+	retry.SetBasicAuth(token, "token")
+	return c.executeOnce(retry, path)
+}
+
+// executeOnce sends req through the per-backend circuit breaker, holding a
+// request slot until the response body is read.
+func (c *Client) executeOnce(req *http.Request, path string) ([]byte, error) {
+	if err := c.breakers.allow(c.baseURL); err != nil {
+		return nil, err
+	}
+	release, err := c.limiter.acquire(req.Context(), c.baseURL)
 	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	hc := c.httpClient
+	if timeout, _ := req.Context().Value(timeoutKey{}).(time.Duration); timeout > 0 {
+		// A copy with the same transport, so the connection pool is shared.
+		override := *hc
+		override.Timeout = timeout
+		hc = &override
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		// A cancelled caller context says nothing about backend health.
+		if req.Context().Err() == nil {
+			c.breakers.recordFailure(c.baseURL, 0)
+		}
 		return nil, fmt.Errorf("executing request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	// Read one byte past the limit so an oversized body is reported as such
+	// instead of surfacing later as a confusing JSON parse error.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponseBytes+1))
 	if err != nil {
 		return nil, fmt.Errorf("reading response body: %w", err)
 	}
+	if int64(len(body)) > c.maxResponseBytes {
+		return nil, fmt.Errorf("%w of %d bytes (path %s); raise GRAYLOG_MAX_RESPONSE_BYTES or narrow the request", ErrResponseTooLarge, c.maxResponseBytes, path)
+	}
+
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if isBreakerFailure(resp.StatusCode) {
+		c.breakers.recordFailure(c.baseURL, retryAfter)
+	} else {
+		c.breakers.recordSuccess(c.baseURL)
+	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, &APIError{
+		apiErr := &APIError{
 			StatusCode: resp.StatusCode,
-			Body:       string(respBody),
+			Body:       string(body),
 			Path:       path,
 		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &RateLimitError{APIError: apiErr, RetryAfter: retryAfter}
+		}
+		return nil, apiErr
 	}
 
-	return respBody, nil
+	return body, nil
 }
 
-func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchResponse, error) {
+// buildSearchRequest translates params into a Views API search request.
+func buildSearchRequest(params SearchParams) viewsSearchRequest {
 	// Build time range
 	var tr viewsTimeRange
-	if params.From != "" && params.To != "" {
+	if params.Keyword != "" {
+		tr = viewsTimeRange{Type: "keyword", Keyword: params.Keyword}
+	} else if params.From != "" && params.To != "" {
 		tr = viewsTimeRange{Type: "absolute", From: params.From, To: params.To}
 	} else {
 		r := params.Range
@@ -219,48 +500,70 @@ func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchRespon
 		filter = &viewsFilter{Type: "or", Filters: streamFilters}
 	}
 
-	// Build sort
+	// Build sort: newest first unless overridden, with _id as a tiebreaker so
+	// messages sharing a timestamp keep a stable order across pages.
+	sortSpec := params.Sort
+	if sortSpec == "" {
+		sortSpec = defaultSearchSort
+	}
 	var sortItems []viewsSortItem
-	if params.Sort != "" {
-		parts := strings.SplitN(params.Sort, ":", 2)
-		if len(parts) == 2 {
-			sortItems = []viewsSortItem{{Field: parts[0], Order: strings.ToUpper(parts[1])}}
+	if parts := strings.SplitN(sortSpec, ":", 2); len(parts) == 2 {
+		sortItems = []viewsSortItem{{Field: parts[0], Order: strings.ToUpper(parts[1])}}
+	}
+	if len(sortItems) == 0 || sortItems[0].Field != "_id" {
+		order := "DESC"
+		if len(sortItems) > 0 {
+			order = sortItems[0].Order
 		}
+		sortItems = append(sortItems, viewsSortItem{Field: "_id", Order: order})
 	}
 
-	// Build fields list
+	// Build fields list; patterns are resolved on the returned messages.
 	var fields []string
 	if params.Fields != "" {
-		for _, f := range strings.Split(params.Fields, ",") {
-			fields = append(fields, strings.TrimSpace(f))
-		}
+		fields = NewFieldFilter(strings.Split(params.Fields, ",")).ServerFields()
 	}
 
+	// Elasticsearch honors size 0 and still reports the total hit count,
+	// so count-only searches skip fetching message bodies entirely.
 	limit := params.Limit
-	if limit == 0 {
+	if params.CountOnly {
+		limit = 0
+	} else if limit == 0 {
 		limit = 50
 	}
 
-	reqBody := viewsSearchRequest{
+	return viewsSearchRequest{
 		Queries: []viewsQuery{{
 			ID:        "q1",
 			TimeRange: tr,
 			Query:     viewsBackendQuery{Type: "elasticsearch", QueryString: params.Query},
 			Filter:    filter,
 			SearchTypes: []viewsSearchType{{
-				ID:     "msgs",
-				Type:   "messages",
-				Limit:  limit,
-				Offset: params.Offset,
-				Sort:   sortItems,
-				Fields: fields,
+				ID:       "msgs",
+				Type:     "messages",
+				Limit:    limit,
+				Offset:   params.Offset,
+				Sort:     sortItems,
+				Fields:   fields,
+				Decorate: params.Decorate,
 			}},
 		}},
 	}
+}
 
-	data, err := c.doPost(ctx, "/api/views/search/sync", reqBody)
-	if err != nil {
-		return nil, err
+// defaultSearchSort is applied when SearchParams.Sort is empty.
+const defaultSearchSort = "timestamp:desc"
+
+func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchResponse, error) {
+	reqBody := buildSearchRequest(params)
+	cacheKey := c.searchCacheKey(ctx, reqBody)
+	data, cached := c.searchCache.get(cacheKey)
+	if !cached {
+		var err error
+		if data, err = c.doPost(ctx, searchPath, reqBody); err != nil {
+			return nil, err
+		}
 	}
 
 	var viewsResp viewsSearchResponse
@@ -271,7 +574,7 @@ func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchRespon
 	// Extract results from Views response
 	queryResult, ok := viewsResp.Results["q1"]
 	if !ok {
-		return nil, fmt.Errorf("unexpected Graylog response: missing query result 'q1'")
+		return nil, fmt.Errorf("%w: missing query result 'q1'", ErrNoResultStructure)
 	}
 	if len(queryResult.Errors) > 0 {
 		descs := make([]string, 0, len(queryResult.Errors))
@@ -290,37 +593,312 @@ func (c *Client) Search(ctx context.Context, params SearchParams) (*SearchRespon
 	}
 	searchTypeResult, ok := queryResult.SearchTypes["msgs"]
 	if !ok {
-		return nil, fmt.Errorf("unexpected Graylog response: missing search type 'msgs' in query result")
+		return nil, fmt.Errorf("%w: missing search type 'msgs' in query result", ErrNoResultStructure)
+	}
+	if searchTypeResult.TotalResults == nil {
+		return nil, fmt.Errorf("%w: missing 'total_results' in search type 'msgs'", ErrNoResultStructure)
 	}
 
 	// Convert viewsResultMessage → MessageWrapper directly from map
 	messages := make([]MessageWrapper, len(searchTypeResult.Messages))
 	for i, vrm := range searchTypeResult.Messages {
 		messages[i] = MessageWrapper{
-			Message: messageFromMap(vrm.Message),
-			Index:   vrm.Index,
+			Message:         messageFromMap(vrm.Message, c.hidden),
+			Index:           vrm.Index,
+			HighlightRanges: vrm.HighlightRanges,
+			DecorationStats: vrm.DecorationStats,
 		}
 	}
 
+	// Only responses that parsed into a result are cached.
+	if !cached {
+		c.searchCache.set(cacheKey, data)
+	}
 	return &SearchResponse{
-		Messages:     messages,
-		TotalResults: searchTypeResult.TotalResults,
+		Messages:           messages,
+		TotalResults:       *searchTypeResult.TotalResults,
+		EffectiveTimerange: searchTypeResult.EffectiveTimerange,
 	}, nil
 }
 
+// searchStreamPageSize is the page size SearchStream uses when params.Limit is unset.
+const searchStreamPageSize = 500
+
+// SearchStream pages through every message matching params using offset
+// pagination and calls fn once per message, so callers can process large
+// result sets incrementally instead of buffering them. params.Limit is the
+// page size and params.Offset the starting position. Paging stops when a page
+// comes back short, TotalResults is reached, or fn returns an error (which is
+// returned as-is).
+func (c *Client) SearchStream(ctx context.Context, params SearchParams, fn func(MessageWrapper) error) error {
+	return c.SearchStreamPages(ctx, params, fn, nil)
+}
+
+// PageFunc is called by SearchStreamPages after every message of a page has
+// been passed to the per-message callback. visited counts messages across all
+// pages so far; total is Graylog's TotalResults for the query. A non-nil
+// error stops paging before the next page is requested and is returned.
+type PageFunc func(visited, total int) error
+
+// SearchStreamPages is SearchStream with an optional onPage callback, for
+// reporting progress on long exports. onPage may be nil.
+func (c *Client) SearchStreamPages(ctx context.Context, params SearchParams, fn func(MessageWrapper) error, onPage PageFunc) error {
+	if params.Limit <= 0 {
+		params.Limit = searchStreamPageSize
+	}
+	visited := 0
+	for {
+		resp, err := c.Search(ctx, params)
+		if err != nil {
+			return err
+		}
+		for _, mw := range resp.Messages {
+			if err := fn(mw); err != nil {
+				return err
+			}
+		}
+		visited += len(resp.Messages)
+		if onPage != nil {
+			if err := onPage(visited, resp.TotalResults); err != nil {
+				return err
+			}
+		}
+		params.Offset += len(resp.Messages)
+		if len(resp.Messages) < params.Limit || params.Offset >= resp.TotalResults {
+			return nil
+		}
+	}
+}
+
+// Stream listing page size and the most pages GetStreams follows.
+const (
+	streamsPageSize = 200
+	maxStreamPages  = 50
+)
+
+// GetStreams returns every stream. Graylog versions that paginate
+// /api/streams report a total larger than the first page, so pages are
+// followed until total streams are collected, a page adds no new stream
+// (a server ignoring page), or maxStreamPages is reached. Total stays
+// Graylog's count, so a capped listing shows as len(Streams) < Total.
 func (c *Client) GetStreams(ctx context.Context) (*StreamsResponse, error) {
-	data, err := c.doGet(ctx, "/api/streams", nil)
+	all := &StreamsResponse{}
+	seen := make(map[string]bool)
+	for page := 1; page <= maxStreamPages; page++ {
+		params := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(streamsPageSize)},
+		}
+		data, err := c.doGet(ctx, "/api/streams", params)
+		if err != nil {
+			return nil, err
+		}
+
+		// Paginated endpoints name the list "elements" instead of "streams".
+		var resp struct {
+			StreamsResponse
+			Elements []Stream `json:"elements"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("parsing streams response: %w", err)
+		}
+		streams := resp.Streams
+		if len(streams) == 0 {
+			streams = resp.Elements
+		}
+		if page == 1 {
+			all.Total = resp.Total
+		}
+
+		added := 0
+		for _, s := range streams {
+			if !seen[s.ID] {
+				seen[s.ID] = true
+				all.Streams = append(all.Streams, s)
+				added++
+			}
+		}
+		if added == 0 || len(all.Streams) >= all.Total {
+			break
+		}
+	}
+	return all, nil
+}
+
+func (c *Client) GetStreamRules(ctx context.Context, streamID string) (*StreamRulesResponse, error) {
+	path := fmt.Sprintf("/api/streams/%s/rules", url.PathEscape(streamID))
+	data, err := c.doGet(ctx, path, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var resp StreamsResponse
+	var resp StreamRulesResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("parsing streams response: %w", err)
+		return nil, fmt.Errorf("parsing stream rules response: %w", err)
 	}
 	return &resp, nil
 }
 
+func (c *Client) GetInputs(ctx context.Context) (*InputsResponse, error) {
+	var resp InputsResponse
+	if err := c.getJSON(ctx, "/api/system/inputs", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetInputStates returns the input states of the node answering the request
+// only; inputs running on other nodes are missing from it.
+func (c *Client) GetInputStates(ctx context.Context) (*InputStatesResponse, error) {
+	var resp InputStatesResponse
+	if err := c.getJSON(ctx, "/api/system/inputstates", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetClusterInputStates returns the input states of every node in the cluster.
+func (c *Client) GetClusterInputStates(ctx context.Context) (ClusterInputStatesResponse, error) {
+	var resp ClusterInputStatesResponse
+	if err := c.getJSON(ctx, "/api/cluster/inputstates", &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// eventDefinitionsPageSize is the per_page used when listing event definitions.
+const eventDefinitionsPageSize = 100
+
+// GetEventDefinitions returns every event definition, following the
+// endpoint's pagination until all of them are collected.
+func (c *Client) GetEventDefinitions(ctx context.Context) (*EventDefinitionsResponse, error) {
+	all := &EventDefinitionsResponse{EventDefinitions: []EventDefinition{}}
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("page", strconv.Itoa(page))
+		params.Set("per_page", strconv.Itoa(eventDefinitionsPageSize))
+		data, err := c.doGet(ctx, "/api/events/definitions", params)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp EventDefinitionsResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("parsing event definitions response: %w", err)
+		}
+		all.EventDefinitions = append(all.EventDefinitions, resp.EventDefinitions...)
+		all.Total = resp.Total
+		if len(resp.EventDefinitions) < eventDefinitionsPageSize || len(all.EventDefinitions) >= resp.Total {
+			return all, nil
+		}
+	}
+}
+
+// CheckAuth makes one cheap authenticated request (GET /api/system) so bad
+// credentials surface as an *APIError with status 401 or 403.
+func (c *Client) CheckAuth(ctx context.Context) error {
+	_, err := c.doGet(ctx, "/api/system", nil)
+	return err
+}
+
+func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
+	data, err := c.doGet(ctx, "/api/system", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sys struct {
+		Version      string `json:"version"`
+		Hostname     string `json:"hostname"`
+		ClusterID    string `json:"cluster_id"`
+		Lifecycle    string `json:"lifecycle"`
+		IsProcessing bool   `json:"is_processing"`
+		Timezone     string `json:"timezone"`
+	}
+	if err := json.Unmarshal(data, &sys); err != nil {
+		return nil, fmt.Errorf("parsing system response: %w", err)
+	}
+
+	info := &SystemInfo{
+		Version:      sys.Version,
+		Hostname:     sys.Hostname,
+		ClusterID:    sys.ClusterID,
+		Lifecycle:    sys.Lifecycle,
+		IsProcessing: sys.IsProcessing,
+		Timezone:     sys.Timezone,
+	}
+
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := c.getJSON(ctx, "/api/system/indexer/cluster/health", &health); err != nil {
+		info.Warnings = append(info.Warnings, "cluster health unavailable: "+err.Error())
+	} else {
+		info.ClusterStatus = health.Status
+	}
+
+	var nodes struct {
+		Total int `json:"total"`
+	}
+	if err := c.getJSON(ctx, "/api/system/cluster/nodes", &nodes); err != nil {
+		info.Warnings = append(info.Warnings, "node list unavailable: "+err.Error())
+	} else {
+		info.NodeCount = nodes.Total
+	}
+
+	var count struct {
+		Events int64 `json:"events"`
+	}
+	if err := c.getJSON(ctx, "/api/count/total", &count); err != nil {
+		info.Warnings = append(info.Warnings, "message count unavailable: "+err.Error())
+	} else {
+		info.TotalMessages = count.Events
+	}
+
+	return info, nil
+}
+
+// getJSON performs a GET without query params and unmarshals the body into v.
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	data, err := c.doGet(ctx, path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing %s response: %w", path, err)
+	}
+	return nil
+}
+
+// GetCurrentUser returns the user the client authenticates as. With token
+// auth the username isn't known locally, so it is looked up from the session
+// Graylog validates the token as (/api/system/sessions) first.
+func (c *Client) GetCurrentUser(ctx context.Context) (*CurrentUser, error) {
+	c.credMu.RLock()
+	username, password := c.username, c.password
+	c.credMu.RUnlock()
+
+	if password == "token" {
+		var session struct {
+			IsValid  bool   `json:"is_valid"`
+			Username string `json:"username"`
+		}
+		if err := c.getJSON(ctx, "/api/system/sessions", &session); err != nil {
+			return nil, err
+		}
+		if !session.IsValid || session.Username == "" {
+			return nil, fmt.Errorf("Graylog did not report a user for the API token")
+		}
+		username = session.Username
+	}
+
+	var user CurrentUser
+	if err := c.getJSON(ctx, "/api/users/"+url.PathEscape(username), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (c *Client) GetFields(ctx context.Context) (FieldsResponse, error) {
 	data, err := c.doGet(ctx, "/api/system/fields", nil)
 	if err != nil {
@@ -342,9 +920,39 @@ func (c *Client) GetFields(ctx context.Context) (FieldsResponse, error) {
 	return resp, nil
 }
 
+// GetFieldTypes is GetFields with each field's mapped type and properties,
+// read from /api/views/fields across all streams the user can see.
+func (c *Client) GetFieldTypes(ctx context.Context) (FieldsResponse, error) {
+	data, err := c.doGet(ctx, "/api/views/fields", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []struct {
+		Name string `json:"name"`
+		Type struct {
+			Type       string   `json:"type"`
+			Properties []string `json:"properties"`
+		} `json:"type"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing field types response: %w", err)
+	}
+
+	resp := make(FieldsResponse, len(entries))
+	for _, e := range entries {
+		resp[e.Name] = FieldInfo{FieldName: e.Name, Type: e.Type.Type, Properties: e.Type.Properties}
+	}
+	return resp, nil
+}
+
 func (c *Client) Aggregate(ctx context.Context, req ScriptingAggregateRequest) (*ScriptingTabularResponse, error) {
-	data, err := c.doPost(ctx, "/api/search/aggregate", req)
+	data, err := c.doPost(ctx, aggregatePath, req)
 	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && isRouteNotFound(apiErr.Body) {
+			return nil, fmt.Errorf("%w: %w", ErrAggregateUnavailable, err)
+		}
 		return nil, err
 	}
 
@@ -355,6 +963,77 @@ func (c *Client) Aggregate(ctx context.Context, req ScriptingAggregateRequest) (
 	return &resp, nil
 }
 
+// isRouteNotFound reports whether a 404 body means the endpoint doesn't
+// exist rather than that a resource named in the request wasn't found.
+// Graylog reports the latter as a JSON API error ({"type": "ApiError",
+// "message": "Stream <id> not found"}); an unmatched route gets a non-JSON
+// body or Jersey's generic "HTTP 404 Not Found" message.
+func isRouteNotFound(body string) bool {
+	var apiErr struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal([]byte(body), &apiErr) != nil || apiErr.Message == "" {
+		return true
+	}
+	return apiErr.Message == "HTTP 404 Not Found"
+}
+
+// RawSearch posts searchTypes verbatim inside the query, time range and stream
+// filter that Search would build for params, and returns Graylog's result
+// for the query (its search_types and errors) unparsed.
+func (c *Client) RawSearch(ctx context.Context, params SearchParams, searchTypes []json.RawMessage) (json.RawMessage, error) {
+	q := buildSearchRequest(params).Queries[0]
+	reqBody := struct {
+		Queries []rawViewsQuery `json:"queries"`
+	}{Queries: []rawViewsQuery{{
+		ID:          q.ID,
+		TimeRange:   q.TimeRange,
+		Query:       q.Query,
+		Filter:      q.Filter,
+		SearchTypes: searchTypes,
+	}}}
+	data, err := c.doPost(ctx, searchPath, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Results map[string]json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parsing views search response: %w", err)
+	}
+	result, ok := resp.Results[q.ID]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing query result '%s'", ErrNoResultStructure, q.ID)
+	}
+	return result, nil
+}
+
+// searchPath and aggregatePath are the endpoints used by Search and Aggregate.
+const (
+	searchPath    = "/api/views/search/sync"
+	aggregatePath = "/api/search/aggregate"
+)
+
+// RequestPreview describes an API request without sending it.
+type RequestPreview struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   any    `json:"body"`
+}
+
+// PreviewSearch returns the request Search would send for params.
+func (c *Client) PreviewSearch(params SearchParams) RequestPreview {
+	return RequestPreview{Method: http.MethodPost, Path: searchPath, Body: buildSearchRequest(params)}
+}
+
+// PreviewAggregate returns the request Aggregate would send for req.
+func (c *Client) PreviewAggregate(req ScriptingAggregateRequest) RequestPreview {
+	return RequestPreview{Method: http.MethodPost, Path: aggregatePath, Body: req}
+}
+
 func (c *Client) GetMessage(ctx context.Context, index, messageID string) (*MessageWrapper, error) {
 	path := fmt.Sprintf("/api/messages/%s/%s", url.PathEscape(index), url.PathEscape(messageID))
 	data, err := c.doGet(ctx, path, nil)
@@ -375,5 +1054,5 @@ func (c *Client) GetMessage(ctx context.Context, index, messageID string) (*Mess
 		return nil, fmt.Errorf("parsing message response: %w", err)
 	}
 
-	return &MessageWrapper{Message: messageFromMap(raw.Message.Fields), Index: raw.Index}, nil
+	return &MessageWrapper{Message: messageFromMap(raw.Message.Fields, c.hidden), Index: raw.Index}, nil
 }
@@ -1,11 +1,18 @@
 package graylog
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -87,3 +94,710 @@ func TestSearchEmptyResults(t *testing.T) {
 		t.Errorf("expected 0 messages, got %d", len(resp.Messages))
 	}
 }
+
+// TestSearchMissingResultStructure verifies that a Views response without the
+// expected result keys wraps ErrNoResultStructure instead of looking like an
+// empty result set.
+func TestSearchMissingResultStructure(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "no results", body: `{}`},
+		{name: "no q1", body: `{"results":{"other":{"search_types":{}}}}`},
+		{name: "no msgs", body: `{"results":{"q1":{"search_types":{}}}}`},
+		{name: "no total_results", body: `{"results":{"q1":{"search_types":{"msgs":{}}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+			_, err := c.Search(context.Background(), SearchParams{Query: "*", Limit: 10})
+			if !errors.Is(err, ErrNoResultStructure) {
+				t.Fatalf("expected ErrNoResultStructure, got %v", err)
+			}
+		})
+	}
+}
+
+// TestSearchSortDefaultsAndTiebreak verifies that Search sorts newest-first
+// when no sort is given and always appends an _id tiebreaker so paging over
+// messages with identical timestamps is deterministic.
+func TestSearchSortDefaultsAndTiebreak(t *testing.T) {
+	tests := []struct {
+		name string
+		sort string
+		want []viewsSortItem
+	}{
+		{"default", "", []viewsSortItem{{Field: "timestamp", Order: "DESC"}, {Field: "_id", Order: "DESC"}}},
+		{"explicit", "timestamp:asc", []viewsSortItem{{Field: "timestamp", Order: "ASC"}, {Field: "_id", Order: "ASC"}}},
+		{"explicit _id", "_id:asc", []viewsSortItem{{Field: "_id", Order: "ASC"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []viewsSortItem
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body viewsSearchRequest
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				got = body.Queries[0].SearchTypes[0].Sort
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"results":{"q1":{"search_types":{"msgs":{"total_results":0,"messages":[]}}}}}`))
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+			if _, err := c.Search(context.Background(), SearchParams{Query: "*", Sort: tt.sort}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected sort %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// newPagedSearchServer serves total messages through the Views API honoring
+// the limit/offset of each request, and counts the requests it receives.
+func newPagedSearchServer(t *testing.T, total int, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		var body viewsSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		st := body.Queries[0].SearchTypes[0]
+
+		messages := []map[string]any{}
+		for i := st.Offset; i < total && i < st.Offset+st.Limit; i++ {
+			messages = append(messages, map[string]any{
+				"message": map[string]any{"_id": fmt.Sprintf("id-%d", i), "message": "m"},
+				"index":   "idx",
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": map[string]any{
+				"q1": map[string]any{
+					"search_types": map[string]any{
+						"msgs": map[string]any{"total_results": total, "messages": messages},
+					},
+				},
+			},
+		})
+	}))
+}
+
+func TestSearchStreamVisitsEveryPage(t *testing.T) {
+	requests := 0
+	srv := newPagedSearchServer(t, 25, &requests)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	var ids []string
+	err := c.SearchStream(context.Background(), SearchParams{Query: "*", Limit: 10}, func(mw MessageWrapper) error {
+		ids = append(ids, mw.Message.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SearchStream returned error: %v", err)
+	}
+	if len(ids) != 25 {
+		t.Fatalf("expected callback for 25 messages, got %d", len(ids))
+	}
+	for i, id := range ids {
+		if id != fmt.Sprintf("id-%d", i) {
+			t.Fatalf("message %d: expected id-%d, got %s", i, i, id)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 page requests, got %d", requests)
+	}
+}
+
+func TestSearchStreamCallbackErrorAborts(t *testing.T) {
+	requests := 0
+	srv := newPagedSearchServer(t, 25, &requests)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	stop := errors.New("stop")
+	seen := 0
+	err := c.SearchStream(context.Background(), SearchParams{Query: "*", Limit: 10}, func(mw MessageWrapper) error {
+		seen++
+		if seen == 12 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected callback error to be returned, got %v", err)
+	}
+	if seen != 12 {
+		t.Errorf("expected paging to stop at message 12, got %d callbacks", seen)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 page requests before abort, got %d", requests)
+	}
+}
+
+func TestSearchStreamPagesReportsEachPage(t *testing.T) {
+	requests := 0
+	srv := newPagedSearchServer(t, 25, &requests)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	type page struct{ visited, total int }
+	var pages []page
+	seen := 0
+	err := c.SearchStreamPages(context.Background(), SearchParams{Query: "*", Limit: 10}, func(mw MessageWrapper) error {
+		seen++
+		return nil
+	}, func(visited, total int) error {
+		if visited != seen {
+			t.Errorf("onPage called with visited=%d after %d messages", visited, seen)
+		}
+		pages = append(pages, page{visited, total})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("SearchStreamPages returned error: %v", err)
+	}
+	want := []page{{10, 25}, {20, 25}, {25, 25}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("expected page callbacks %v, got %v", want, pages)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	body := `{"streams":[{"id":"s1","title":"` + strings.Repeat("x", 100) + `"}],"total":1}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second, WithMaxResponseBytes(int64(len(body)-1)))
+	_, err := c.GetStreams(context.Background())
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge for oversized body, got %v", err)
+	}
+	if strings.Contains(err.Error(), "parsing") {
+		t.Errorf("oversized body must not surface as a parse error, got %q", err.Error())
+	}
+
+	c = NewClient(srv.URL, "user", "pass", false, 5*time.Second, WithMaxResponseBytes(int64(len(body))))
+	if _, err := c.GetStreams(context.Background()); err != nil {
+		t.Fatalf("body exactly at the limit should be accepted, got %v", err)
+	}
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+	}))
+	defer srv.Close()
+
+	extra := http.Header{}
+	extra.Set("X-Gateway-Token", "gw-secret")
+	extra.Set("Authorization", "Bearer evil")
+	extra.Set("X-Requested-By", "evil")
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second,
+		WithUserAgent("graylog-mcp-test/1.0"),
+		WithExtraHeaders(srv.URL+"/", extra),
+	)
+	// Per-request clones in http transport must keep the custom headers.
+	c = c.CloneWithAuth(srv.URL, "user", "pass")
+
+	if _, err := c.GetStreams(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ua := got.Get("User-Agent"); ua != "graylog-mcp-test/1.0" {
+		t.Errorf("expected custom User-Agent, got %q", ua)
+	}
+	if v := got.Get("X-Gateway-Token"); v != "gw-secret" {
+		t.Errorf("expected X-Gateway-Token to be sent, got %q", v)
+	}
+	if v := got.Values("Authorization"); len(v) != 1 || !strings.HasPrefix(v[0], "Basic ") {
+		t.Errorf("Authorization must stay Basic auth, got %q", v)
+	}
+	if v := got.Values("X-Requested-By"); len(v) != 1 || v[0] != "XMLHttpRequest" {
+		t.Errorf("X-Requested-By must not be overridden, got %q", v)
+	}
+}
+
+func TestExtraHeadersNotSentToOverrideHost(t *testing.T) {
+	newServer := func(got *http.Header) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*got = r.Header.Clone()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+		}))
+	}
+	var configuredGot, overrideGot http.Header
+	configured, override := newServer(&configuredGot), newServer(&overrideGot)
+	defer configured.Close()
+	defer override.Close()
+
+	extra := http.Header{}
+	extra.Set("X-Gateway-Token", "gw-secret")
+	// The http transport's base client has no URL of its own.
+	base := NewClient("", "", "", false, 5*time.Second, WithExtraHeaders(configured.URL, extra))
+
+	if _, err := base.CloneWithAuth(override.URL, "user", "pass").GetStreams(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := overrideGot.Get("X-Gateway-Token"); v != "" {
+		t.Errorf("extra headers must not reach an X-Graylog-URL override host, got %q", v)
+	}
+	if _, err := base.CloneWithAuth(configured.URL, "user", "pass").GetStreams(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := configuredGot.Get("X-Gateway-Token"); v != "gw-secret" {
+		t.Errorf("expected extra headers for the configured Graylog, got %q", v)
+	}
+}
+
+func TestGetStreamsFollowsPages(t *testing.T) {
+	pages := map[string]string{
+		"1": `{"streams":[{"id":"s1","title":"A"},{"id":"s2","title":"B"}],"total":5}`,
+		"2": `{"streams":[{"id":"s3","title":"C"},{"id":"s4","title":"D"}],"total":5}`,
+		"3": `{"elements":[{"id":"s5","title":"E"}],"total":5}`,
+	}
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		requested = append(requested, page)
+		if r.URL.Query().Get("per_page") == "" {
+			t.Errorf("expected per_page on page %s", page)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(pages[page]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	resp, err := c.GetStreams(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for _, s := range resp.Streams {
+		ids = append(ids, s.ID)
+	}
+	if want := []string{"s1", "s2", "s3", "s4", "s5"}; !reflect.DeepEqual(ids, want) || resp.Total != 5 {
+		t.Fatalf("expected streams %v (total 5), got %v (total %d)", want, ids, resp.Total)
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("expected pages %v, got %v", want, requested)
+	}
+}
+
+func TestGetStreamsStopsWhenPagesRepeat(t *testing.T) {
+	// A server that ignores page and overstates total must not be polled
+	// until the page cap.
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[{"id":"s1","title":"A"}],"total":100}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	resp, err := c.GetStreams(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Streams) != 1 || calls != 2 {
+		t.Fatalf("expected 1 stream after 2 requests, got %d streams after %d requests", len(resp.Streams), calls)
+	}
+}
+
+func TestGetStreamsBoundsPages(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"streams":[{"id":"s%s","title":"T"}],"total":1000000}`, r.URL.Query().Get("page"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	resp, err := c.GetStreams(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != maxStreamPages || len(resp.Streams) != maxStreamPages {
+		t.Fatalf("expected %d pages, got %d requests and %d streams", maxStreamPages, calls, len(resp.Streams))
+	}
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(RequestIDHeader))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	if _, err := c.GetStreams(WithRequestID(context.Background(), "req-42")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetStreams(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "req-42" || got[1] != "" {
+		t.Fatalf("expected request ID only on the first call, got %q", got)
+	}
+}
+
+func TestPreviewSearchMatchesSentRequest(t *testing.T) {
+	var sent map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":{"q1":{"search_types":{"msgs":{"total_results":0,"messages":[]}}}}}`))
+	}))
+	defer srv.Close()
+
+	params := SearchParams{
+		Query:     "level:ERROR",
+		From:      "2024-01-15T10:00:00.000Z",
+		To:        "2024-01-15T11:00:00.000Z",
+		Limit:     20,
+		Offset:    40,
+		Fields:    "source, level",
+		Sort:      "timestamp:asc",
+		StreamIDs: []string{"s1"},
+	}
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	if _, err := c.Search(context.Background(), params); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	preview := c.PreviewSearch(params)
+	if preview.Method != http.MethodPost || preview.Path != "/api/views/search/sync" {
+		t.Errorf("unexpected preview target %s %s", preview.Method, preview.Path)
+	}
+	b, _ := json.Marshal(preview.Body)
+	var previewed map[string]any
+	_ = json.Unmarshal(b, &previewed)
+	if !reflect.DeepEqual(previewed, sent) {
+		t.Fatalf("preview body differs from sent request:\npreview: %v\nsent:    %v", previewed, sent)
+	}
+}
+
+func TestDialTimeoutFailsFast(t *testing.T) {
+	// 10.255.255.1 is non-routable: connection attempts hang until the dial
+	// timeout (or fail immediately when the sandbox has no route at all).
+	c := NewClient("http://10.255.255.1:9000", "user", "pass", false, 10*time.Second,
+		WithDialTimeout(200*time.Millisecond),
+	)
+	c.breakers = newBreakerRegistry(breakerFailureThreshold, breakerCooldown)
+
+	start := time.Now()
+	_, err := c.GetStreams(context.Background())
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatal("expected dial error")
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("expected failure within the dial timeout, took %s", elapsed)
+	}
+}
+
+func TestSearchKeywordTimeRange(t *testing.T) {
+	req := buildSearchRequest(SearchParams{
+		Query:   "*",
+		Keyword: "last 24 hours",
+		From:    "2024-01-15T10:00:00.000Z",
+		To:      "2024-01-15T11:00:00.000Z",
+	})
+	b, err := json.Marshal(req.Queries[0].TimeRange)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"type":"keyword","keyword":"last 24 hours"}`; string(b) != want {
+		t.Fatalf("expected timerange %s, got %s", want, b)
+	}
+}
+
+func TestGetEventDefinitionsFollowsPages(t *testing.T) {
+	const total = eventDefinitionsPageSize + 5
+	var pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start := (page - 1) * eventDefinitionsPageSize
+		end := min(start+eventDefinitionsPageSize, total)
+		defs := make([]map[string]any, 0, end-start)
+		for i := start; i < end; i++ {
+			defs = append(defs, map[string]any{"id": fmt.Sprintf("ed-%d", i), "title": "def", "priority": 2, "config": map[string]any{"type": "aggregation-v1"}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"event_definitions": defs, "total": total})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	resp, err := c.GetEventDefinitions(context.Background())
+	if err != nil {
+		t.Fatalf("GetEventDefinitions returned error: %v", err)
+	}
+	if len(resp.EventDefinitions) != total || resp.Total != total {
+		t.Fatalf("expected %d definitions, got %d (total %d)", total, len(resp.EventDefinitions), resp.Total)
+	}
+	if !reflect.DeepEqual(pages, []string{"1", "2"}) {
+		t.Errorf("expected pages 1 and 2 to be requested, got %v", pages)
+	}
+	if resp.EventDefinitions[total-1].Config.Type != "aggregation-v1" {
+		t.Errorf("expected config type to be parsed, got %+v", resp.EventDefinitions[total-1])
+	}
+}
+
+func TestRefreshTokenRetriesOnce(t *testing.T) {
+	var users, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		users = append(users, user)
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(r.Body)
+		bodies = append(bodies, body.String())
+		if user != "fresh-token" || pass != "token" {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": map[string]any{"q1": map[string]any{"search_types": map[string]any{
+				"msgs": map[string]any{"total_results": 0, "messages": []any{}},
+			}}},
+		})
+	}))
+	defer srv.Close()
+
+	refreshes := 0
+	c := NewClient(srv.URL, "stale-token", "token", false, 5*time.Second,
+		WithRefreshToken(func(ctx context.Context) (string, error) {
+			refreshes++
+			return "fresh-token", nil
+		}))
+	if _, err := c.Search(context.Background(), SearchParams{Query: "*", Limit: 10}); err != nil {
+		t.Fatalf("expected retried request to succeed, got: %v", err)
+	}
+	if refreshes != 1 {
+		t.Errorf("expected 1 refresh, got %d", refreshes)
+	}
+	if !reflect.DeepEqual(users, []string{"stale-token", "fresh-token"}) {
+		t.Fatalf("expected stale then fresh token, got %v", users)
+	}
+	if bodies[0] == "" || bodies[0] != bodies[1] {
+		t.Errorf("expected retry to resend the same body, got %q and %q", bodies[0], bodies[1])
+	}
+
+	// The refreshed token is kept for later requests.
+	if _, err := c.Search(context.Background(), SearchParams{Query: "*", Limit: 10}); err != nil {
+		t.Fatalf("second search failed: %v", err)
+	}
+	if refreshes != 1 || users[len(users)-1] != "fresh-token" {
+		t.Errorf("expected fresh token reused without refresh, refreshes=%d users=%v", refreshes, users)
+	}
+}
+
+func TestRefreshTokenStillUnauthorized(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "stale-token", "token", false, 5*time.Second,
+		WithRefreshToken(func(ctx context.Context) (string, error) { return "also-bad", nil }))
+	_, err := c.GetStreams(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 APIError, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected exactly one retry, got %d requests", calls)
+	}
+}
+
+func TestRateLimitErrorCarriesRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		http.Error(w, `{"message":"too many requests"}`, http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	_, err := c.GetStreams(context.Background())
+
+	var rlErr *RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("expected *RateLimitError, got %T: %v", err, err)
+	}
+	if rlErr.RetryAfter != 7*time.Second {
+		t.Errorf("expected RetryAfter=7s, got %s", rlErr.RetryAfter)
+	}
+	if want := "Graylog is rate limiting; retry after 7s"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected message containing %q, got %q", want, err.Error())
+	}
+	if strings.Contains(err.Error(), "too many requests") {
+		t.Errorf("raw body should not be part of the message, got %q", err.Error())
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the underlying *APIError with status 429, got %v", apiErr)
+	}
+}
+
+func TestCloneWithAuthSharesTransport(t *testing.T) {
+	c := NewClient("https://graylog.example.com", "user", "pass", false, 5*time.Second)
+	a := c.CloneWithAuth("https://a.example.com", "alice", "secret")
+	b := a.CloneWithAuth("https://b.example.com", "token", "token")
+	if a.httpClient != c.httpClient || b.httpClient != c.httpClient {
+		t.Fatal("clones must share the base http.Client")
+	}
+	if a.httpClient.Transport != c.httpClient.Transport {
+		t.Fatal("clones must share the base transport")
+	}
+}
+
+func TestCloneWithAuthReusesPooledConnections(t *testing.T) {
+	var mu sync.Mutex
+	newConns := 0
+	users := make(map[string]bool)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		mu.Lock()
+		users[user] = true
+		mu.Unlock()
+		// Hold each request briefly so a round's requests overlap.
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	base := NewClient(srv.URL, "base", "base", false, 5*time.Second)
+	const concurrency, rounds = 8, 5
+	for round := range rounds {
+		var wg sync.WaitGroup
+		for i := range concurrency {
+			// A fresh clone per call, as the http transport does per request.
+			clone := base.CloneWithAuth(srv.URL, fmt.Sprintf("user-%d", i), "pass")
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := clone.GetStreams(context.Background()); err != nil {
+					t.Errorf("round %d: %v", round, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(users) != concurrency {
+		t.Errorf("expected requests from %d distinct users, got %d", concurrency, len(users))
+	}
+	if newConns > concurrency {
+		t.Errorf("expected at most %d connections for %d requests, got %d", concurrency, concurrency*rounds, newConns)
+	}
+}
+
+func TestAggregateNotFoundWrapsErrAggregateUnavailable(t *testing.T) {
+	status := http.StatusNotFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", status)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	_, err := c.Aggregate(context.Background(), ScriptingAggregateRequest{Query: "*"})
+	if !errors.Is(err, ErrAggregateUnavailable) {
+		t.Fatalf("expected ErrAggregateUnavailable, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the wrapped APIError to stay reachable, got %v", err)
+	}
+
+	status = http.StatusBadRequest
+	if _, err := c.Aggregate(context.Background(), ScriptingAggregateRequest{Query: "*"}); errors.Is(err, ErrAggregateUnavailable) {
+		t.Fatalf("a 400 must not be reported as a missing API: %v", err)
+	}
+}
+
+func TestAggregateNotFoundClassification(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		unavailable bool
+	}{
+		{"plain text", "not found", true},
+		{"html", "<html><body>404</body></html>", true},
+		{"jersey route miss", `{"type":"ApiError","message":"HTTP 404 Not Found"}`, true},
+		{"unknown stream", `{"type":"ApiError","message":"Stream 000000000000000000000001 not found"}`, false},
+		{"unknown index", `{"message":"Index graylog_99 does not exist"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+			_, err := c.Aggregate(context.Background(), ScriptingAggregateRequest{Query: "*"})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+				t.Fatalf("expected a 404 APIError, got %v", err)
+			}
+			if got := errors.Is(err, ErrAggregateUnavailable); got != tt.unavailable {
+				t.Errorf("ErrAggregateUnavailable = %v, want %v (err %v)", got, tt.unavailable, err)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutOverridesClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[],"total":0}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 50*time.Millisecond)
+	if _, err := c.GetStreams(context.Background()); err == nil {
+		t.Fatal("expected the 50ms client timeout to fire")
+	}
+	if _, err := c.GetStreams(WithTimeout(context.Background(), 2*time.Second)); err != nil {
+		t.Fatalf("expected the longer per-call timeout to succeed: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package graylog
+
+import "strings"
+
+// FieldFilter selects message fields by exact name, by simple glob pattern
+// ("kubernetes_*", "*_id") or by exclusion ("-gl2_*"). Build it once per
+// request with NewFieldFilter; a nil filter selects every field.
+type FieldFilter struct {
+	include []fieldPattern
+	exclude []fieldPattern
+	exact   []string // include names without wildcards, in request order
+	globbed bool     // any pattern or exclusion present
+}
+
+// fieldPattern is a name split on '*'; a pattern without '*' has one part.
+type fieldPattern []string
+
+// NewFieldFilter compiles the entries of a 'fields' parameter. Blank entries
+// are ignored; it returns nil when nothing is left.
+func NewFieldFilter(fields []string) *FieldFilter {
+	f := &FieldFilter{}
+	for _, raw := range fields {
+		name := strings.TrimSpace(raw)
+		exclude := strings.HasPrefix(name, "-")
+		if exclude {
+			name = strings.TrimSpace(name[1:])
+		}
+		if name == "" {
+			continue
+		}
+		p := fieldPattern(strings.Split(name, "*"))
+		switch {
+		case exclude:
+			f.exclude = append(f.exclude, p)
+			f.globbed = true
+		case len(p) > 1:
+			f.include = append(f.include, p)
+			f.globbed = true
+		default:
+			f.include = append(f.include, p)
+			f.exact = append(f.exact, name)
+		}
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return nil
+	}
+	return f
+}
+
+// Match reports whether the filter keeps the field name. With only
+// exclusions, every field not excluded is kept.
+func (f *FieldFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, p := range f.exclude {
+		if p.match(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, p := range f.include {
+		if p.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerFields returns the field list to send to Graylog. Graylog only
+// understands exact names, so with any pattern or exclusion it returns nil
+// and filtering happens on the returned messages alone.
+func (f *FieldFilter) ServerFields() []string {
+	if f == nil || f.globbed {
+		return nil
+	}
+	return f.exact
+}
+
+// HasPatterns reports whether the filter uses globs or exclusions.
+func (f *FieldFilter) HasPatterns() bool {
+	return f != nil && f.globbed
+}
+
+func (p fieldPattern) match(name string) bool {
+	if len(p) == 1 {
+		return name == p[0]
+	}
+	if !strings.HasPrefix(name, p[0]) {
+		return false
+	}
+	name = name[len(p[0]):]
+	last := p[len(p)-1]
+	for _, part := range p[1 : len(p)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && strings.HasSuffix(name, last)
+}
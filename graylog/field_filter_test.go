@@ -0,0 +1,79 @@
+package graylog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		keep   []string
+		drop   []string
+	}{
+		{"exact", []string{"level", " host "}, []string{"level", "host"}, []string{"levels", "kubernetes_pod"}},
+		{"prefix glob", []string{"kubernetes_*"}, []string{"kubernetes_pod", "kubernetes_"}, []string{"k8s_pod", "pod_kubernetes_x"}},
+		{"suffix glob", []string{"*_id"}, []string{"trace_id", "_id"}, []string{"id", "trace_id_x"}},
+		{"infix glob", []string{"a*b*c"}, []string{"abc", "a-b-c", "abbc"}, []string{"ab", "acb", "abcd"}},
+		{"exclude only", []string{"-gl2_*"}, []string{"level", "gl2"}, []string{"gl2_source_input"}},
+		{"include with exclude", []string{"kubernetes_*", "-kubernetes_labels_*"}, []string{"kubernetes_pod"}, []string{"kubernetes_labels_app", "level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFieldFilter(tt.fields)
+			for _, name := range tt.keep {
+				if !f.Match(name) {
+					t.Errorf("expected %q to be kept", name)
+				}
+			}
+			for _, name := range tt.drop {
+				if f.Match(name) {
+					t.Errorf("expected %q to be dropped", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFieldFilterEmpty(t *testing.T) {
+	for _, fields := range [][]string{nil, {""}, {" ", "-"}} {
+		if f := NewFieldFilter(fields); f != nil {
+			t.Errorf("NewFieldFilter(%q) = %+v, want nil", fields, f)
+		}
+	}
+	var f *FieldFilter
+	if !f.Match("anything") || f.HasPatterns() || f.ServerFields() != nil {
+		t.Error("a nil filter must select every field and send none to Graylog")
+	}
+}
+
+func TestFieldFilterServerFields(t *testing.T) {
+	if got := NewFieldFilter([]string{"source", "level"}).ServerFields(); !reflect.DeepEqual(got, []string{"source", "level"}) {
+		t.Errorf("exact names should be sent to Graylog, got %v", got)
+	}
+	for _, fields := range [][]string{{"source", "kubernetes_*"}, {"source", "-gl2_*"}} {
+		if got := NewFieldFilter(fields).ServerFields(); got != nil {
+			t.Errorf("fields %v: patterns must not be sent to Graylog, got %v", fields, got)
+		}
+	}
+}
+
+func TestToFilteredMapPatternsKeepCoreFields(t *testing.T) {
+	m := Message{
+		ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "web-1", Message: "boom",
+		Extra: map[string]any{"kubernetes_pod": "api-0", "kubernetes_labels_app": "api", "trace_id": "t1", "level": 3},
+	}
+
+	got := m.ToFilteredMap([]string{"-message", "-source*", "-kubernetes_*", "-trace_id"})
+	want := map[string]any{"_id": "id-1", "timestamp": "2024-01-01T00:00:00.000Z", "source": "web-1", "message": "boom", "level": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("exclusions must only drop extra fields:\ngot:  %v\nwant: %v", got, want)
+	}
+
+	got = m.ToFilteredMap([]string{"kubernetes_*", "*_id", "-kubernetes_labels_*"})
+	want = map[string]any{"_id": "id-1", "timestamp": "2024-01-01T00:00:00.000Z", "source": "web-1", "message": "boom", "kubernetes_pod": "api-0", "trace_id": "t1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected glob selection:\ngot:  %v\nwant: %v", got, want)
+	}
+}
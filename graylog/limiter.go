@@ -0,0 +1,43 @@
+package graylog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// requestLimiter caps the requests in flight to each Graylog base URL. Clones
+// made by CloneWithAuth share their parent's limiter, so in http transport the
+// cap holds across all sessions talking to the same backend.
+type requestLimiter struct {
+	max int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newRequestLimiter(max int) *requestLimiter {
+	return &requestLimiter{max: max, sems: make(map[string]chan struct{})}
+}
+
+// acquire blocks until a request slot for key is free or ctx is done. The
+// returned func releases the slot. A nil limiter never blocks.
+func (l *requestLimiter) acquire(ctx context.Context, key string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	sem, ok := l.sems[key]
+	if !ok {
+		sem = make(chan struct{}, l.max)
+		l.sems[key] = sem
+	}
+	l.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a free request slot (%d in flight): %w", l.max, ctx.Err())
+	}
+}
@@ -0,0 +1,77 @@
+package graylog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentRequestsSerializesCalls(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "token", "token", false, 2*time.Second, WithMaxConcurrentRequests(1))
+	// A clone talks to the same Graylog, so it shares the cap.
+	clone := c.CloneWithAuth(srv.URL, "other", "token")
+
+	var wg sync.WaitGroup
+	for i := range 6 {
+		client := c
+		if i%2 == 1 {
+			client = clone
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.doGet(context.Background(), "/api/system", nil); err != nil {
+				t.Errorf("request failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 1 {
+		t.Errorf("expected requests to serialize, saw %d in flight", got)
+	}
+}
+
+func TestMaxConcurrentRequestsWaitHonorsContext(t *testing.T) {
+	started, block := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-block
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	c := NewClient(srv.URL, "token", "token", false, 2*time.Second, WithMaxConcurrentRequests(1))
+	go func() { _, _ = c.doGet(context.Background(), "/api/system", nil) }()
+	// The first request holds the only slot until block is closed.
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.doGet(ctx, "/api/system", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the queued request to give up with its context, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package graylog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Search cache bounds. When either the entry count or the total body size
+// would pass its limit, entries closest to expiry are evicted. Bodies over
+// maxSearchCacheBodyBytes (large pages, e.g. export or dedup fetches) are not
+// cached at all.
+const (
+	maxSearchCacheEntries   = 256
+	maxSearchCacheBytes     = 32 << 20
+	maxSearchCacheBodyBytes = 1 << 20
+)
+
+// searchCache holds recent Views search response bodies keyed by a
+// fingerprint of the backend, credentials and request. Bodies are stored raw,
+// so every hit is parsed into fresh values callers are free to mutate. Clones
+// share their parent's cache; the credentials in the key keep users apart.
+type searchCache struct {
+	ttl          time.Duration
+	maxEntries   int
+	maxBytes     int
+	maxBodyBytes int
+	now          func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]searchCacheEntry
+	size    int // total bytes of the cached bodies
+}
+
+type searchCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func newSearchCache(ttl time.Duration, maxEntries, maxBytes, maxBodyBytes int) *searchCache {
+	return &searchCache{
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		maxBytes:     maxBytes,
+		maxBodyBytes: min(maxBodyBytes, maxBytes),
+		now:          time.Now,
+		entries:      make(map[string]searchCacheEntry),
+	}
+}
+
+// get returns the cached body for key. A nil cache or empty key never hits.
+func (sc *searchCache) get(key string) ([]byte, bool) {
+	if sc == nil || key == "" {
+		return nil, false
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e, ok := sc.entries[key]
+	if !ok || !sc.now().Before(e.expires) {
+		return nil, false
+	}
+	return e.body, true
+}
+
+// set caches body under key unless it is over the per-body limit.
+func (sc *searchCache) set(key string, body []byte) {
+	if sc == nil || key == "" || len(body) > sc.maxBodyBytes {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := sc.now()
+	for k, e := range sc.entries {
+		if !now.Before(e.expires) {
+			sc.remove(k)
+		}
+	}
+	sc.remove(key)
+	for len(sc.entries) > 0 && (len(sc.entries) >= sc.maxEntries || sc.size+len(body) > sc.maxBytes) {
+		oldest := ""
+		for k, e := range sc.entries {
+			if oldest == "" || e.expires.Before(sc.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+		sc.remove(oldest)
+	}
+	sc.entries[key] = searchCacheEntry{body: body, expires: now.Add(sc.ttl)}
+	sc.size += len(body)
+}
+
+// remove drops key if present. sc.mu must be held.
+func (sc *searchCache) remove(key string) {
+	if e, ok := sc.entries[key]; ok {
+		sc.size -= len(e.body)
+		delete(sc.entries, key)
+	}
+}
+
+// searchCacheKey fingerprints a Views search request for c's backend and
+// credentials, or returns "" when the search must not be cached.
+func (c *Client) searchCacheKey(ctx context.Context, reqBody viewsSearchRequest) string {
+	if c.searchCache == nil || searchCacheDisabled(ctx) {
+		return ""
+	}
+	encoded, err := json.Marshal(reqBody)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(c.CacheKey()+"\x00"), encoded...))
+	return hex.EncodeToString(sum[:])
+}
+
+type noSearchCacheKey struct{}
+
+// WithoutSearchCache returns a context whose searches always go to Graylog,
+// for callers following new data (e.g. a tail cursor) that must never see a
+// cached page.
+func WithoutSearchCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noSearchCacheKey{}, true)
+}
+
+func searchCacheDisabled(ctx context.Context) bool {
+	off, _ := ctx.Value(noSearchCacheKey{}).(bool)
+	return off
+}
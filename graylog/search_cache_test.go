@@ -0,0 +1,156 @@
+package graylog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchCacheHitsIdenticalSearch(t *testing.T) {
+	requests := 0
+	srv := newPagedSearchServer(t, 25, &requests)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second, WithSearchCache(time.Minute))
+	params := SearchParams{Query: "level:ERROR", Limit: 5, StreamIDs: []string{"s1"}}
+
+	first, err := c.Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	// Callers mutate results (redaction, sorting); a hit must not see that.
+	first.Messages[0].Message.Message = "changed"
+	first.Messages = first.Messages[:1]
+
+	second, err := c.Search(context.Background(), params)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected the repeated search to hit the cache, got %d requests", requests)
+	}
+	if len(second.Messages) != 5 || second.Messages[0].Message.Message != "m" || second.TotalResults != 25 {
+		t.Errorf("expected an unchanged cached response, got %d messages, first %q", len(second.Messages), second.Messages[0].Message.Message)
+	}
+
+	// A clone with other credentials shares the cache but not its entries.
+	clone := c.CloneWithAuth(srv.URL, "other", "pass")
+	if _, err := clone.Search(context.Background(), params); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected other credentials to miss the cache, got %d requests", requests)
+	}
+}
+
+func TestSearchCacheBypass(t *testing.T) {
+	requests := 0
+	srv := newPagedSearchServer(t, 25, &requests)
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "user", "pass", false, 5*time.Second, WithSearchCache(time.Minute))
+	base := SearchParams{Query: "*", Limit: 5}
+	variants := []SearchParams{
+		base,
+		{Query: "level:ERROR", Limit: 5},
+		{Query: "*", Limit: 6},
+		{Query: "*", Limit: 5, Offset: 5},
+		{Query: "*", Limit: 5, Range: 3600},
+		{Query: "*", Limit: 5, StreamIDs: []string{"s1"}},
+		{Query: "*", Limit: 5, Sort: "source:asc"},
+	}
+	for _, p := range variants {
+		if _, err := c.Search(context.Background(), p); err != nil {
+			t.Fatalf("search %+v: %v", p, err)
+		}
+	}
+	if requests != len(variants) {
+		t.Errorf("expected every distinct search to reach Graylog, got %d of %d", requests, len(variants))
+	}
+
+	if _, err := c.Search(WithoutSearchCache(context.Background()), base); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if requests != len(variants)+1 {
+		t.Errorf("expected WithoutSearchCache to skip the cache, got %d requests", requests)
+	}
+
+	// Without the option nothing is cached.
+	plain := NewClient(srv.URL, "user", "pass", false, 5*time.Second)
+	for range 2 {
+		if _, err := plain.Search(context.Background(), base); err != nil {
+			t.Fatalf("search: %v", err)
+		}
+	}
+	if requests != len(variants)+3 {
+		t.Errorf("expected no caching without WithSearchCache, got %d requests", requests)
+	}
+}
+
+func TestSearchCacheExpiryAndBound(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc := newSearchCache(10*time.Second, 2, 1024, 1024)
+	sc.now = func() time.Time { return now }
+
+	sc.set("a", []byte("1"))
+	now = now.Add(time.Second)
+	sc.set("b", []byte("2"))
+	now = now.Add(time.Second)
+	sc.set("c", []byte("3"))
+	if _, ok := sc.get("a"); ok {
+		t.Error("expected the oldest entry evicted once the cache is full")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := sc.get(k); !ok {
+			t.Errorf("expected %q cached", k)
+		}
+	}
+
+	now = now.Add(10 * time.Second)
+	if body, ok := sc.get("c"); ok {
+		t.Errorf("expected %q expired, got %s", "c", body)
+	}
+	if len(sc.entries) > sc.maxEntries {
+		t.Errorf("cache grew past its bound: %d entries", len(sc.entries))
+	}
+
+	var nilCache *searchCache
+	nilCache.set("k", []byte("v"))
+	if _, ok := nilCache.get("k"); ok {
+		t.Error("a nil cache must never hit")
+	}
+}
+
+func TestSearchCacheByteBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc := newSearchCache(10*time.Second, 100, 10, 6)
+	sc.now = func() time.Time { return now }
+
+	sc.set("big", []byte("1234567"))
+	if _, ok := sc.get("big"); ok {
+		t.Error("expected a body over the per-body limit not to be cached")
+	}
+
+	sc.set("a", []byte("1234"))
+	now = now.Add(time.Second)
+	sc.set("b", []byte("1234"))
+	now = now.Add(time.Second)
+	sc.set("c", []byte("1234"))
+	if _, ok := sc.get("a"); ok {
+		t.Error("expected the oldest entry evicted to stay within the byte budget")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := sc.get(k); !ok {
+			t.Errorf("expected %q cached", k)
+		}
+	}
+	if sc.size != 8 {
+		t.Errorf("expected 8 cached bytes, got %d", sc.size)
+	}
+
+	// Replacing an entry counts only its new body.
+	sc.set("c", []byte("12"))
+	if sc.size != 6 || len(sc.entries) != 2 {
+		t.Errorf("expected 6 bytes in 2 entries after replacing, got %d bytes in %d", sc.size, len(sc.entries))
+	}
+}
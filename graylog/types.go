@@ -4,19 +4,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"maps"
+	"math"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
-// isHiddenField returns true for internal Graylog metadata fields
+// hiddenFields lists the field name prefixes and placeholder values dropped
+// from decoded messages. A nil *hiddenFields means the built-in defaults.
+type hiddenFields struct {
+	prefixes []string
+	values   map[string]bool
+}
+
+// defaultHiddenFields is used by clients without WithHiddenFields and by
+// Message.UnmarshalJSON. It is never modified.
+var defaultHiddenFields = newHiddenFields(nil, nil)
+
+// newHiddenFields returns the built-in "gl2_" prefix and
+// "fullyCutByExtractor" placeholder, extended by prefixes and values.
+func newHiddenFields(prefixes, values []string) *hiddenFields {
+	h := &hiddenFields{
+		prefixes: append([]string{"gl2_"}, prefixes...),
+		values:   map[string]bool{"fullyCutByExtractor": true},
+	}
+	for _, v := range values {
+		h.values[v] = true
+	}
+	return h
+}
+
+// hidesField returns true for internal Graylog metadata fields
 // that should be excluded from tool responses to reduce noise.
-func isHiddenField(key string) bool {
-	return strings.HasPrefix(key, "gl2_")
+func (h *hiddenFields) hidesField(key string) bool {
+	if h == nil {
+		h = defaultHiddenFields
+	}
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-// isHiddenValue returns true for placeholder values that carry no useful information.
-func isHiddenValue(v any) bool {
+// hidesValue returns true for placeholder values that carry no useful information.
+func (h *hiddenFields) hidesValue(v any) bool {
+	if h == nil {
+		h = defaultHiddenFields
+	}
 	s, ok := v.(string)
-	return ok && s == "fullyCutByExtractor"
+	return ok && h.values[s]
 }
 
 type SearchParams struct {
@@ -24,21 +63,41 @@ type SearchParams struct {
 	Range     int    // seconds, for relative search
 	From      string // ISO8601, for absolute search
 	To        string // ISO8601, for absolute search
+	Keyword   string // natural-language range (e.g. "last 24 hours"); wins over From/To and Range
 	Limit     int
 	Offset    int
 	Fields    string   // comma-separated
 	Sort      string   // field:asc or field:desc
 	StreamIDs []string // filter by stream IDs
+	CountOnly bool     // request limit 0: only total_results, no messages
+	Decorate  bool     // ask Graylog to apply its message decorators (where supported)
 }
 
 type SearchResponse struct {
 	Messages     []MessageWrapper `json:"messages"`
 	TotalResults int              `json:"total_results"`
+	// EffectiveTimerange is the absolute window Graylog resolved the search's
+	// time range to, as sent ({type, from, to}). Nil if Graylog omitted it.
+	EffectiveTimerange map[string]any `json:"effective_timerange,omitempty"`
 }
 
 type MessageWrapper struct {
 	Message Message `json:"message"`
 	Index   string  `json:"index"`
+	// HighlightRanges maps field names to the ranges that matched the query,
+	// as returned by the Views API. Empty when Graylog highlighting is disabled.
+	HighlightRanges map[string]any `json:"highlight_ranges,omitempty"`
+	// DecorationStats lists the fields Graylog decorators added, changed or
+	// removed. Nil unless the search asked for decoration and Graylog applied some.
+	DecorationStats *DecorationStats `json:"decoration_stats,omitempty"`
+}
+
+// DecorationStats is Graylog's per-message record of decorator changes. Changed
+// and removed fields map to their value before decoration.
+type DecorationStats struct {
+	AddedFields   map[string]any `json:"added_fields,omitempty"`
+	ChangedFields map[string]any `json:"changed_fields,omitempty"`
+	RemovedFields map[string]any `json:"removed_fields,omitempty"`
 }
 
 type Message struct {
@@ -68,16 +127,17 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 		m.Message, _ = v.(string)
 	}
 
-	populateExtra(m, raw)
+	populateExtra(m, raw, nil)
 	return nil
 }
 
-// populateExtra fills m.Extra with all non-core, non-hidden fields from raw.
-func populateExtra(m *Message, raw map[string]any) {
+// populateExtra fills m.Extra with all non-core fields from raw that hidden
+// doesn't drop.
+func populateExtra(m *Message, raw map[string]any, hidden *hiddenFields) {
 	m.Extra = make(map[string]any)
 	knownFields := map[string]bool{"_id": true, "timestamp": true, "source": true, "message": true}
 	for k, v := range raw {
-		if !knownFields[k] && !isHiddenField(k) && !isHiddenValue(v) {
+		if !knownFields[k] && !hidden.hidesField(k) && !hidden.hidesValue(v) {
 			m.Extra[k] = v
 		}
 	}
@@ -96,9 +156,16 @@ func (m Message) MarshalJSON() ([]byte, error) {
 }
 
 // ToFilteredMap returns a map with only the requested fields.
-// If fields is empty, all fields are returned.
+// If fields is empty, all fields are returned. Entries may be exact names,
+// globs ("kubernetes_*") or exclusions ("-gl2_*"), see FieldFilter.
 // Core fields (_id, timestamp, source, message) are always included regardless of the filter.
 func (m Message) ToFilteredMap(fields []string) map[string]any {
+	return m.FilteredMap(NewFieldFilter(fields))
+}
+
+// FilteredMap is ToFilteredMap with a filter compiled once per request.
+// A nil filter returns all fields.
+func (m Message) FilteredMap(filter *FieldFilter) map[string]any {
 	result := map[string]any{
 		"_id":       m.ID,
 		"timestamp": m.Timestamp,
@@ -106,17 +173,13 @@ func (m Message) ToFilteredMap(fields []string) map[string]any {
 		"message":   m.Message,
 	}
 
-	if len(fields) == 0 {
+	if filter == nil {
 		maps.Copy(result, m.Extra)
 		return result
 	}
 
-	fieldSet := make(map[string]bool, len(fields))
-	for _, f := range fields {
-		fieldSet[f] = true
-	}
 	for k, v := range m.Extra {
-		if fieldSet[k] {
+		if filter.Match(k) {
 			result[k] = v
 		}
 	}
@@ -124,8 +187,9 @@ func (m Message) ToFilteredMap(fields []string) map[string]any {
 }
 
 // messageFromMap constructs a Message directly from a map[string]any
-// without going through a JSON marshal/unmarshal round-trip.
-func messageFromMap(raw map[string]any) Message {
+// without going through a JSON marshal/unmarshal round-trip, dropping the
+// fields hidden selects.
+func messageFromMap(raw map[string]any, hidden *hiddenFields) Message {
 	var m Message
 	if v, ok := raw["_id"]; ok {
 		m.ID, _ = v.(string)
@@ -140,7 +204,7 @@ func messageFromMap(raw map[string]any) Message {
 		m.Message, _ = v.(string)
 	}
 
-	populateExtra(&m, raw)
+	populateExtra(&m, raw, hidden)
 	return m
 }
 
@@ -157,10 +221,127 @@ type Stream struct {
 	Disabled    bool   `json:"disabled"`
 }
 
+type StreamRulesResponse struct {
+	StreamRules []StreamRule `json:"stream_rules"`
+	Total       int          `json:"total"`
+}
+
+type StreamRule struct {
+	ID          string `json:"id"`
+	StreamID    string `json:"stream_id"`
+	Field       string `json:"field"`
+	Type        int    `json:"type"`
+	Value       string `json:"value"`
+	Inverted    bool   `json:"inverted"`
+	Description string `json:"description"`
+}
+
+type InputsResponse struct {
+	Inputs []Input `json:"inputs"`
+	Total  int     `json:"total"`
+}
+
+type Input struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Global bool   `json:"global"`
+	Node   string `json:"node"`
+}
+
+type InputStatesResponse struct {
+	States []InputState `json:"states"`
+}
+
+type InputState struct {
+	ID              string `json:"id"`
+	State           string `json:"state"`
+	StartedAt       string `json:"started_at"`
+	DetailedMessage string `json:"detailed_message"`
+}
+
+// ClusterInputStatesResponse maps each node ID to the input states that node
+// reports. A node Graylog couldn't reach maps to nil (JSON null).
+type ClusterInputStatesResponse map[string][]InputState
+
+type EventDefinitionsResponse struct {
+	EventDefinitions []EventDefinition `json:"event_definitions"`
+	Total            int               `json:"total"`
+}
+
+type EventDefinition struct {
+	ID          string                `json:"id"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Priority    int                   `json:"priority"`
+	Alert       bool                  `json:"alert"`
+	State       string                `json:"state"`
+	Config      EventDefinitionConfig `json:"config"`
+}
+
+// EventDefinitionConfig is the condition part of an event definition. Only the
+// fields shared by the common condition types are decoded.
+type EventDefinitionConfig struct {
+	Type  string `json:"type"`  // e.g. "aggregation-v1", "correlation-v1"
+	Query string `json:"query"` // search query of filter/aggregation conditions
+}
+
 type FieldsResponse map[string]FieldInfo
 
+// Names returns the field names in sorted order, so callers never depend on
+// map iteration order.
+func (f FieldsResponse) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type FieldInfo struct {
 	FieldName string `json:"field_name"`
+	// Type and Properties are only set by GetFieldTypes. Properties holds
+	// Graylog's capabilities such as "enumerable" (usable in aggregations)
+	// and "full-text-search" (analyzed).
+	Type       string   `json:"type,omitempty"`
+	Properties []string `json:"properties,omitempty"`
+}
+
+// Enumerable reports whether the field can be grouped by in aggregations.
+func (f FieldInfo) Enumerable() bool {
+	return slices.Contains(f.Properties, "enumerable")
+}
+
+// SystemInfo combines /api/system with cluster health, node and message counts.
+// Secondary lookups are best-effort: failures are recorded in Warnings so the
+// caller still gets version info when the indexer is unhealthy.
+type SystemInfo struct {
+	Version       string   `json:"version"`
+	Hostname      string   `json:"hostname"`
+	ClusterID     string   `json:"cluster_id"`
+	Lifecycle     string   `json:"lifecycle"`
+	IsProcessing  bool     `json:"is_processing"`
+	Timezone      string   `json:"timezone"`
+	ClusterStatus string   `json:"cluster_status,omitempty"`
+	NodeCount     int      `json:"node_count"`
+	TotalMessages int64    `json:"total_messages"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// CurrentUser is the authenticated Graylog user from /api/users/{username}.
+// Permissions are Graylog permission strings such as "streams:read:<id>".
+// GrantPermissions are the same strings derived from Graylog 4+ entity
+// shares (grants), which Permissions doesn't list.
+type CurrentUser struct {
+	ID               string   `json:"id"`
+	Username         string   `json:"username"`
+	FullName         string   `json:"full_name"`
+	Roles            []string `json:"roles"`
+	Permissions      []string `json:"permissions"`
+	GrantPermissions []string `json:"grant_permissions"`
+	ReadOnly         bool     `json:"read_only"`
 }
 
 type APIError struct {
@@ -177,6 +358,22 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("Graylog API error: status=%d path=%s body=%s", e.StatusCode, e.Path, body)
 }
 
+// RateLimitError is returned instead of a plain *APIError when Graylog answers
+// 429. RetryAfter comes from the Retry-After header and is 0 when absent.
+type RateLimitError struct {
+	*APIError
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("Graylog is rate limiting; retry after %ds (path %s)", int(math.Ceil(e.RetryAfter.Seconds())), e.Path)
+	}
+	return fmt.Sprintf("Graylog is rate limiting; retry later (path %s)", e.Path)
+}
+
+func (e *RateLimitError) Unwrap() error { return e.APIError }
+
 // Views Search API request types (POST /api/views/search/sync)
 
 type viewsSearchRequest struct {
@@ -191,11 +388,21 @@ type viewsQuery struct {
 	SearchTypes []viewsSearchType `json:"search_types"`
 }
 
+// rawViewsQuery is a viewsQuery whose search types are caller-supplied JSON.
+type rawViewsQuery struct {
+	ID          string            `json:"id"`
+	TimeRange   viewsTimeRange    `json:"timerange"`
+	Query       viewsBackendQuery `json:"query"`
+	Filter      *viewsFilter      `json:"filter,omitempty"`
+	SearchTypes []json.RawMessage `json:"search_types"`
+}
+
 type viewsTimeRange struct {
-	Type  string `json:"type"`
-	Range int    `json:"range,omitempty"`
-	From  string `json:"from,omitempty"`
-	To    string `json:"to,omitempty"`
+	Type    string `json:"type"`
+	Range   int    `json:"range,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Keyword string `json:"keyword,omitempty"`
 }
 
 type viewsBackendQuery struct {
@@ -210,12 +417,13 @@ type viewsFilter struct {
 }
 
 type viewsSearchType struct {
-	ID     string          `json:"id"`
-	Type   string          `json:"type"`
-	Limit  int             `json:"limit"`
-	Offset int             `json:"offset"`
-	Sort   []viewsSortItem `json:"sort,omitempty"`
-	Fields []string        `json:"fields,omitempty"`
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Limit    int             `json:"limit"`
+	Offset   int             `json:"offset"`
+	Sort     []viewsSortItem `json:"sort,omitempty"`
+	Fields   []string        `json:"fields,omitempty"`
+	Decorate bool            `json:"decorate,omitempty"` // run the stream decorators on the messages
 }
 
 type viewsSortItem struct {
@@ -241,14 +449,16 @@ type viewsSearchError struct {
 }
 
 type viewsSearchTypeResult struct {
-	TotalResults int                  `json:"total_results"`
-	Messages     []viewsResultMessage `json:"messages"`
+	TotalResults       *int                 `json:"total_results"` // nil when the key is absent
+	Messages           []viewsResultMessage `json:"messages"`
+	EffectiveTimerange map[string]any       `json:"effective_timerange"`
 }
 
 type viewsResultMessage struct {
-	Message         map[string]any `json:"message"`
-	Index           string         `json:"index"`
-	HighlightRanges map[string]any `json:"highlight_ranges"`
+	Message         map[string]any   `json:"message"`
+	Index           string           `json:"index"`
+	HighlightRanges map[string]any   `json:"highlight_ranges"`
+	DecorationStats *DecorationStats `json:"decoration_stats"`
 }
 
 // Scripting API types (POST /api/search/aggregate)
@@ -262,8 +472,9 @@ type ScriptingTimeRange struct {
 }
 
 type ScriptingGrouping struct {
-	Field string `json:"field"`
-	Limit int    `json:"limit,omitempty"`
+	Field    string `json:"field"`
+	Limit    int    `json:"limit,omitempty"`
+	TimeUnit string `json:"timeunit,omitempty"` // bucket interval (e.g. "1h") for a date histogram on Field
 }
 
 type ScriptingMetricConfig struct {
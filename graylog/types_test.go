@@ -0,0 +1,68 @@
+package graylog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHiddenFields(t *testing.T) {
+	raw := map[string]any{
+		"_id":                             "id-1",
+		"message":                         "hello",
+		"gl2_source_input":                "abc",
+		"kubernetes_annotations_checksum": "deadbeef",
+		"kubernetes_pod_name":             "api-0",
+		"trace_id":                        "-",
+		"user":                            "fullyCutByExtractor",
+		"level":                           "INFO",
+	}
+
+	extra := messageFromMap(raw, nil).Extra
+	for _, k := range []string{"gl2_source_input", "user"} {
+		if _, ok := extra[k]; ok {
+			t.Errorf("expected %q hidden by default, got %v", k, extra)
+		}
+	}
+	for _, k := range []string{"kubernetes_annotations_checksum", "kubernetes_pod_name", "trace_id", "level"} {
+		if _, ok := extra[k]; !ok {
+			t.Errorf("expected %q kept by default, got %v", k, extra)
+		}
+	}
+
+	extra = messageFromMap(raw, newHiddenFields([]string{"kubernetes_annotations_"}, []string{"-"})).Extra
+	for _, k := range []string{"gl2_source_input", "kubernetes_annotations_checksum", "trace_id", "user"} {
+		if _, ok := extra[k]; ok {
+			t.Errorf("expected %q hidden, got %v", k, extra)
+		}
+	}
+	for _, k := range []string{"kubernetes_pod_name", "level"} {
+		if _, ok := extra[k]; !ok {
+			t.Errorf("expected %q kept, got %v", k, extra)
+		}
+	}
+
+	// JSON decoding outside a client uses the defaults.
+	data, _ := json.Marshal(raw)
+	var m Message
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, ok := m.Extra["trace_id"]; !ok {
+		t.Errorf("expected UnmarshalJSON to keep %q, got %v", "trace_id", m.Extra)
+	}
+	if _, ok := m.Extra["gl2_source_input"]; ok {
+		t.Errorf("expected UnmarshalJSON to hide %q, got %v", "gl2_source_input", m.Extra)
+	}
+}
+
+func TestWithHiddenFieldsSharedWithClones(t *testing.T) {
+	c := NewClient("http://graylog", "user", "pass", false, time.Second, WithHiddenFields([]string{"k8s_"}, nil))
+	clone := c.CloneWithAuth("http://graylog", "other", "pass")
+	if _, ok := messageFromMap(map[string]any{"k8s_pod": "a"}, clone.hidden).Extra["k8s_pod"]; ok {
+		t.Error("expected a clone to keep the hidden field prefixes")
+	}
+	if plain := NewClient("http://graylog", "user", "pass", false, time.Second, WithHiddenFields(nil, nil)); plain.hidden != nil {
+		t.Error("expected no extra hidden fields without prefixes or values")
+	}
+}
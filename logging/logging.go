@@ -0,0 +1,82 @@
+// Package logging builds the server's structured logger and the middleware
+// that logs tool invocations. Credentials never reach it, and tool arguments
+// (which carry query contents) are only logged at debug level.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// New returns a logger writing to w at level, as JSON when format is "json"
+// and as logfmt-style text otherwise.
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// ToolMiddleware logs one "tool call" record per invocation with the tool name,
+// duration and outcome. A result with IsError counts as a failure, matching how
+// tool handlers report errors. At debug level the arguments and error text are
+// included too, since both may contain query contents.
+func ToolMiddleware(logger *slog.Logger) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+
+			success := err == nil && (result == nil || !result.IsError)
+			attrs := []slog.Attr{
+				slog.String("tool", request.Params.Name),
+				slog.Duration("duration", time.Since(start)),
+				slog.Bool("success", success),
+			}
+			if id := requestID(result); id != "" {
+				attrs = append(attrs, slog.String("request_id", id))
+			}
+			if logger.Enabled(ctx, slog.LevelDebug) {
+				attrs = append(attrs, slog.Any("arguments", request.GetArguments()))
+				if msg := errorText(result, err); msg != "" {
+					attrs = append(attrs, slog.String("error", msg))
+				}
+			}
+			level := slog.LevelInfo
+			if !success {
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(ctx, level, "tool call", attrs...)
+			return result, err
+		}
+	}
+}
+
+// requestID returns the request ID the tools echo in the result's _meta.
+func requestID(result *mcp.CallToolResult) string {
+	if result == nil || result.Meta == nil {
+		return ""
+	}
+	id, _ := result.Meta.AdditionalFields["request_id"].(string)
+	return id
+}
+
+// errorText returns the handler error or the text of an error result.
+func errorText(result *mcp.CallToolResult, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if result == nil || !result.IsError || len(result.Content) == 0 {
+		return ""
+	}
+	if text, ok := result.Content[0].(mcp.TextContent); ok {
+		return text.Text
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func callTool(t *testing.T, logger *slog.Logger, result *mcp.CallToolResult) {
+	t.Helper()
+	handler := ToolMiddleware(logger)(func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return result, nil
+	})
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "search_logs"
+	req.Params.Arguments = map[string]any{"query": "user:alice AND secret-query"}
+	if _, err := handler(context.Background(), req); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+}
+
+func TestToolMiddlewareDebugRecord(t *testing.T) {
+	var buf bytes.Buffer
+	callTool(t, New(&buf, slog.LevelDebug, "json"), mcp.NewToolResultError("Search failed: boom"))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "tool call" || record["tool"] != "search_logs" || record["success"] != false {
+		t.Fatalf("unexpected record: %v", record)
+	}
+	if _, ok := record["duration"]; !ok {
+		t.Error("expected a duration attribute")
+	}
+	if args, _ := record["arguments"].(map[string]any); args["query"] != "user:alice AND secret-query" {
+		t.Errorf("expected arguments at debug level, got %v", record["arguments"])
+	}
+	if record["error"] != "Search failed: boom" {
+		t.Errorf("expected error text at debug level, got %v", record["error"])
+	}
+}
+
+func TestToolMiddlewareInfoOmitsQueryContents(t *testing.T) {
+	var buf bytes.Buffer
+	callTool(t, New(&buf, slog.LevelInfo, "text"), mcp.NewToolResultText("ok"))
+
+	out := buf.String()
+	if !strings.Contains(out, "msg=\"tool call\"") || !strings.Contains(out, "tool=search_logs") || !strings.Contains(out, "success=true") {
+		t.Fatalf("expected a tool call record, got %q", out)
+	}
+	if strings.Contains(out, "secret-query") {
+		t.Fatalf("query contents must not be logged at info level: %q", out)
+	}
+}
+
+func TestToolMiddlewareRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	callTool(t, New(&buf, slog.LevelError, "text"), mcp.NewToolResultText("ok"))
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output at error level, got %q", buf.String())
+	}
+}
+
+func TestToolMiddlewareLogsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	result := mcp.NewToolResultText("ok")
+	result.Meta = mcp.NewMetaFromMap(map[string]any{"request_id": "req-42"})
+	callTool(t, New(&buf, slog.LevelInfo, "text"), result)
+	if !strings.Contains(buf.String(), "request_id=req-42") {
+		t.Fatalf("expected the request ID in the record, got %q", buf.String())
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"strings"
@@ -15,6 +18,8 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/graylog"
+	"github.com/n0madic/graylog-mcp/logging"
+	"github.com/n0madic/graylog-mcp/metrics"
 	"github.com/n0madic/graylog-mcp/tools"
 )
 
@@ -43,37 +48,64 @@ func main() {
 		os.Exit(1)
 	}
 
-	s := server.NewMCPServer(
-		"graylog-mcp",
-		"1.0.0",
+	logger := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	for _, w := range cfg.Warnings {
+		logger.Warn(w)
+	}
+
+	serverOpts := []server.ServerOption{
 		server.WithToolCapabilities(true),
-	)
+		server.WithResourceCapabilities(false, false),
+		server.WithToolHandlerMiddleware(logging.ToolMiddleware(logger)),
+	}
+	var metricsRegistry *metrics.Registry
+	if cfg.Metrics && cfg.Transport == "http" {
+		metricsRegistry = metrics.NewRegistry()
+		serverOpts = append(serverOpts, server.WithToolHandlerMiddleware(metricsRegistry.ToolMiddleware))
+	}
+
+	s := server.NewMCPServer("graylog-mcp", "1.0.0", serverOpts...)
 
 	if cfg.Transport == "http" {
 		// HTTP mode: credentials are provided per-request via the Authorization header.
 		// The auth middleware injects a graylog.Client into the request context before
 		// the MCP server sees the request. The LLM only ever sees tool results.
-		baseClient := graylog.NewSSRFSafeClient(cfg.TLSSkipVerify, cfg.Timeout, isPrivateOrSpecialIP)
-		tools.RegisterAll(s, clientFromContext)
+		baseClient := graylog.NewSSRFSafeClient(cfg.TLSSkipVerify, cfg.Timeout, isPrivateOrSpecialIP, clientOptions(cfg)...)
+		tools.RegisterAll(s, clientFromContext, cfg)
 
 		httpSrv := server.NewStreamableHTTPServer(s,
 			server.WithEndpointPath("/mcp"),
 			server.WithStateLess(true),
 		)
 
-		fmt.Fprintf(os.Stderr, "Graylog MCP server listening on %s (Streamable HTTP /mcp)\n", cfg.Bind)
-		fmt.Fprintf(os.Stderr, "WARNING: HTTP transport runs without TLS. Authorization headers are transmitted in plaintext. Use a TLS-terminating reverse proxy in production.\n")
+		logger.Info("Graylog MCP server listening", "addr", cfg.Bind, "endpoint", "/mcp", "transport", "http")
+		if !cfg.Quiet {
+			logger.Warn("HTTP transport runs without TLS. Authorization headers are transmitted in plaintext. Use a TLS-terminating reverse proxy in production.")
+		}
+
+		// /metrics and /validate-url are served outside authMiddleware: they
+		// expose no Graylog data and their callers don't carry Graylog credentials.
+		mux := http.NewServeMux()
+		mux.Handle("/", authMiddleware(cfg, baseClient, logger)(httpSrv))
+		if cfg.ValidateURLEndpoint {
+			mux.Handle("/validate-url", validateURLHandler(cfg))
+			logger.Info("X-Graylog-URL validation available", "addr", cfg.Bind, "endpoint", "/validate-url")
+		}
+		if metricsRegistry != nil {
+			mux.Handle("/metrics", metricsRegistry.Handler())
+			logger.Info("Prometheus metrics available", "addr", cfg.Bind, "endpoint", "/metrics")
+		}
 
 		srv := &http.Server{
 			Addr:              cfg.Bind,
-			Handler:           authMiddleware(cfg, baseClient)(httpSrv),
+			Handler:           mux,
 			ReadHeaderTimeout: 10 * time.Second,
 			ReadTimeout:       30 * time.Second,
 			WriteTimeout:      60 * time.Second,
 			IdleTimeout:       120 * time.Second,
 		}
 		if err := srv.ListenAndServe(); err != nil {
-			fmt.Fprintf(os.Stderr, "HTTP server error: %v\n", err)
+			logger.Error("HTTP server error", "error", err)
 			os.Exit(1)
 		}
 		return
@@ -82,19 +114,67 @@ func main() {
 	// stdio mode: static client from startup credentials.
 	var client *graylog.Client
 	if cfg.Token != "" {
-		client = graylog.NewClient(cfg.GraylogURL, cfg.Token, "token", cfg.TLSSkipVerify, cfg.Timeout)
+		opts := clientOptions(cfg)
+		if cfg.TokenFile != "" {
+			opts = append(opts, graylog.WithRefreshToken(cfg.ReloadToken))
+		}
+		client = graylog.NewClient(cfg.GraylogURL, cfg.Token, "token", cfg.TLSSkipVerify, cfg.Timeout, opts...)
 	} else {
-		client = graylog.NewClient(cfg.GraylogURL, cfg.Username, cfg.Password, cfg.TLSSkipVerify, cfg.Timeout)
+		client = graylog.NewClient(cfg.GraylogURL, cfg.Username, cfg.Password, cfg.TLSSkipVerify, cfg.Timeout, clientOptions(cfg)...)
 	}
 
-	tools.RegisterAll(s, func(_ context.Context) *graylog.Client { return client })
+	if cfg.VerifyOnStart {
+		if err := verifyCredentials(context.Background(), client, cfg.Timeout, logger); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	tools.RegisterAll(s, func(_ context.Context) *graylog.Client { return client }, cfg)
+
+	logger.Info("Graylog MCP server started", "transport", "stdio")
 
 	if err := server.ServeStdio(s); err != nil {
-		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
+		logger.Error("Server error", "error", err)
 		os.Exit(1)
 	}
 }
 
+// verifyCredentials checks the stdio client's credentials before serving.
+// Only a 401/403 is fatal: an unreachable or failing Graylog is logged and
+// left to surface on the first tool call, since it may recover.
+func verifyCredentials(ctx context.Context, client *graylog.Client, timeout time.Duration, logger *slog.Logger) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := client.CheckAuth(ctx)
+	if err == nil {
+		logger.Debug("Graylog credentials verified")
+		return nil
+	}
+	var apiErr *graylog.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("authentication failed: Graylog rejected the configured credentials (HTTP %d); check GRAYLOG_TOKEN or GRAYLOG_USERNAME/GRAYLOG_PASSWORD, or set GRAYLOG_VERIFY_ON_START=false to skip this check", apiErr.StatusCode)
+	}
+	logger.Warn("Startup credential check failed; serving anyway", "error", err)
+	return nil
+}
+
+// clientOptions translates config into graylog.Client options shared by both transports.
+func clientOptions(cfg *config.Config) []graylog.Option {
+	return []graylog.Option{
+		graylog.WithMaxResponseBytes(cfg.MaxResponseBytes),
+		graylog.WithDialTimeout(cfg.DialTimeout),
+		graylog.WithUserAgent(cfg.UserAgent),
+		graylog.WithExtraHeaders(cfg.GraylogURL, cfg.ExtraHeaders),
+		graylog.WithMaxConcurrentRequests(cfg.MaxConcurrent),
+		graylog.WithSearchCache(cfg.SearchTTL),
+		graylog.WithHiddenFields(cfg.HiddenFieldPrefixes, cfg.HiddenValues),
+	}
+}
+
 // writeJSONError writes a JSON error response. The message is JSON-encoded to
 // prevent injection of special characters (", \, newlines) from untrusted input.
 func writeJSONError(w http.ResponseWriter, msg string, code int) {
@@ -113,51 +193,128 @@ func writeJSONError(w http.ResponseWriter, msg string, code int) {
 //	X-Graylog-URL:  https://graylog.example.com   (overrides GRAYLOG_URL; optional if server has GRAYLOG_URL set)
 //	Authorization:  Bearer <graylog_api_token>
 //	Authorization:  Basic base64(username:password)
-func authMiddleware(cfg *config.Config, baseClient *graylog.Client) func(http.Handler) http.Handler {
+//	X-Request-Id:   <id>  (optional; forwarded to Graylog and echoed in tool results)
+func authMiddleware(cfg *config.Config, baseClient *graylog.Client, logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Auth decisions are logged without credentials; only the header
+			// scheme and the target host are recorded.
+			reject := func(msg string, code int) {
+				logger.Warn("auth rejected", "reason", msg, "status", code, "remote_addr", r.RemoteAddr)
+				writeJSONError(w, msg, code)
+			}
+
 			rawGraylogURL := r.Header.Get("X-Graylog-URL")
 			graylogURL := rawGraylogURL
 			if graylogURL == "" {
 				graylogURL = cfg.GraylogURL
 			}
 			if graylogURL == "" {
-				writeJSONError(w, "Graylog URL required", http.StatusBadRequest)
+				reject("Graylog URL required", http.StatusBadRequest)
 				return
 			}
 
-			if err := validateGraylogURL(graylogURL); err != nil {
-				if rawGraylogURL != "" {
-					writeJSONError(w, "invalid X-Graylog-URL: "+err.Error(), http.StatusBadRequest)
-					return
-				}
-				writeJSONError(w, "invalid GRAYLOG_URL: "+err.Error(), http.StatusBadRequest)
-				return
-			}
 			if rawGraylogURL != "" {
-				if err := validateGraylogOverrideURL(rawGraylogURL); err != nil {
-					writeJSONError(w, "invalid X-Graylog-URL: "+err.Error(), http.StatusBadRequest)
+				if msg, code := checkOverrideURL(cfg, rawGraylogURL); msg != "" {
+					reject(msg, code)
 					return
 				}
+			} else if err := validateGraylogURL(graylogURL); err != nil {
+				reject("invalid GRAYLOG_URL: "+err.Error(), http.StatusBadRequest)
+				return
 			}
 
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				writeJSONError(w, "Authorization header required", http.StatusUnauthorized)
+				reject("Authorization header required", http.StatusUnauthorized)
 				return
 			}
 			client := clientFromAuthHeader(authHeader, graylogURL, baseClient)
 			if client == nil {
-				writeJSONError(w, "invalid Authorization header: use Bearer <token> or Basic base64(user:pass)", http.StatusUnauthorized)
+				reject("invalid Authorization header: use Bearer <token> or Basic base64(user:pass)", http.StatusUnauthorized)
 				return
 			}
 
+			scheme, _, _ := strings.Cut(strings.TrimSpace(authHeader), " ")
+			logger.Debug("auth accepted", "scheme", strings.ToLower(scheme), "graylog_host", hostOf(graylogURL), "override", rawGraylogURL != "", "remote_addr", r.RemoteAddr)
+
 			ctx := context.WithValue(r.Context(), clientContextKey, client)
+			if id := inboundRequestID(r); id != "" {
+				ctx = graylog.WithRequestID(ctx, id)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// checkOverrideURL applies the X-Graylog-URL checks of authMiddleware to raw:
+// URL shape, GRAYLOG_ALLOWED_HOSTS, then the private-address check. It returns
+// the rejection message and HTTP status, or "" and 0 when raw is accepted.
+func checkOverrideURL(cfg *config.Config, raw string) (string, int) {
+	if err := validateGraylogURL(raw); err != nil {
+		return "invalid X-Graylog-URL: " + err.Error(), http.StatusBadRequest
+	}
+	if len(cfg.AllowedHosts) > 0 && !graylogHostAllowed(raw, cfg.AllowedHosts) {
+		return "X-Graylog-URL host is not in GRAYLOG_ALLOWED_HOSTS", http.StatusForbidden
+	}
+	if err := validateGraylogOverrideURL(raw); err != nil {
+		return "invalid X-Graylog-URL: " + err.Error(), http.StatusBadRequest
+	}
+	return "", 0
+}
+
+// validateURLHandler serves GET /validate-url?url=..., a dry run of the
+// X-Graylog-URL checks for operators. It needs no Authorization and never
+// contacts the URL beyond resolving its host.
+func validateURLHandler(cfg *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		raw := r.URL.Query().Get("url")
+		if raw == "" {
+			writeJSONError(w, "url query parameter required", http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{"url": raw, "valid": true}
+		if msg, _ := checkOverrideURL(cfg, raw); msg != "" {
+			resp["valid"] = false
+			resp["reason"] = msg
+		}
+		w.Header().Set("Content-Type", "application/json")
+		b, _ := json.Marshal(resp)
+		w.Write(b) //nolint:errcheck
+	})
+}
+
+// maxRequestIDLen bounds an inbound X-Request-Id before it is forwarded.
+const maxRequestIDLen = 128
+
+// inboundRequestID returns the client's X-Request-Id if it is safe to forward
+// to Graylog: at most maxRequestIDLen visible ASCII characters. Anything else
+// is ignored and the tool call gets a generated ID.
+func inboundRequestID(r *http.Request) string {
+	id := r.Header.Get(graylog.RequestIDHeader)
+	if len(id) > maxRequestIDLen {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return ""
+		}
+	}
+	return id
+}
+
+// hostOf returns the host[:port] of an already validated URL.
+func hostOf(raw string) string {
+	if p, err := url.Parse(raw); err == nil {
+		return p.Host
+	}
+	return ""
+}
+
 func validateGraylogURL(raw string) error {
 	p, err := url.Parse(raw)
 	if err != nil {
@@ -188,7 +345,7 @@ func validateGraylogOverrideURL(raw string) error {
 		return fmt.Errorf("host is required")
 	}
 
-	if ip := net.ParseIP(host); ip != nil {
+	if ip, ok := hostIP(host); ok {
 		if isPrivateOrSpecialIP(ip) {
 			return fmt.Errorf("host resolves to a private or special-use address")
 		}
@@ -207,7 +364,75 @@ func validateGraylogOverrideURL(raw string) error {
 	return nil
 }
 
+// graylogHostAllowed reports whether raw's host matches one of the host or
+// host:port patterns. "*.example.com" matches any subdomain but not
+// example.com itself; a pattern without a port matches any port.
+func graylogHostAllowed(raw string, patterns []string) bool {
+	p, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(p.Hostname()), ".")
+	port := p.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[p.Scheme]
+	}
+	for _, pattern := range patterns {
+		pu, err := url.Parse("//" + pattern)
+		if err != nil {
+			continue
+		}
+		if pu.Port() != "" && pu.Port() != port {
+			continue
+		}
+		patternHost := pu.Hostname()
+		if suffix, ok := strings.CutPrefix(patternHost, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == patternHost {
+			return true
+		}
+	}
+	return false
+}
+
+// hostIP parses host as an IP literal. Unlike net.ParseIP it accepts an IPv6
+// zone ("fe80::1%eth0"); a zoned address is scoped to a local interface, so it
+// is returned as an unspecified address and classified as special.
+func hostIP(host string) (net.IP, bool) {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil, false
+	}
+	if addr.Zone() != "" {
+		return net.IPv6unspecified, true
+	}
+	return net.IP(addr.AsSlice()), true
+}
+
+// nat64Prefix is the well-known NAT64 prefix (RFC 6052); its last 32 bits
+// address an IPv4 host behind the translator.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// isPrivateOrSpecialIP reports whether ip must not be used as a Graylog
+// override target. IPv6 forms that embed an IPv4 address (IPv4-mapped
+// ::ffff:a.b.c.d, IPv4-compatible ::a.b.c.d and NAT64) are judged by the
+// embedded address.
 func isPrivateOrSpecialIP(ip net.IP) bool {
+	if addr, ok := netip.AddrFromSlice(ip); ok {
+		addr = addr.Unmap()
+		if addr.Is6() {
+			b := addr.As16()
+			embedsV4 := nat64Prefix.Contains(addr) || [12]byte(b[:12]) == [12]byte{}
+			if embedsV4 && addr != netip.IPv6Unspecified() && addr != netip.IPv6Loopback() {
+				addr = netip.AddrFrom4([4]byte(b[12:]))
+			}
+		}
+		ip = net.IP(addr.AsSlice())
+	}
 	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() ||
 		ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || cgnatBlock.Contains(ip)
 }
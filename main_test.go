@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -52,6 +57,18 @@ func TestValidateGraylogOverrideURL(t *testing.T) {
 		{name: "ipv6 loopback", input: "http://[::1]", wantErr: true},
 		{name: "ipv6 private", input: "http://[fd00::1]", wantErr: true},
 		{name: "cgnat", input: "http://100.100.1.1", wantErr: true},
+		{name: "ipv6 public", input: "https://[2001:4860:4860::8888]", wantErr: false},
+		{name: "ipv6 zoned link-local", input: "http://[fe80::1%25eth0]:9000", wantErr: true},
+		{name: "ipv6 zoned global", input: "http://[2001:4860:4860::8888%25eth0]", wantErr: true},
+		{name: "ipv6 unique-local fc00::/7", input: "http://[fc00::1]", wantErr: true},
+		{name: "ipv6 unique-local mixed case", input: "http://[FD12:3456:789A::1]", wantErr: true},
+		{name: "ipv4-mapped private", input: "http://[::ffff:10.0.0.1]", wantErr: true},
+		{name: "ipv4-mapped private hex", input: "http://[::ffff:a00:1]", wantErr: true},
+		{name: "ipv4-mapped loopback", input: "http://[::ffff:127.0.0.1]", wantErr: true},
+		{name: "ipv4-mapped public", input: "http://[::ffff:8.8.8.8]", wantErr: false},
+		{name: "ipv4-compatible private", input: "http://[::10.0.0.1]", wantErr: true},
+		{name: "nat64 private", input: "http://[64:ff9b::a00:1]", wantErr: true},
+		{name: "nat64 public", input: "http://[64:ff9b::808:808]", wantErr: false},
 	}
 
 	for _, tt := range tests {
@@ -132,7 +149,7 @@ func TestAuthMiddlewareRejectsPrivateLoopbackAndLinkLocalOverrides(t *testing.T)
 	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	})
-	handler := authMiddleware(cfg, baseClient)(next)
+	handler := authMiddleware(cfg, baseClient, slog.New(slog.DiscardHandler))(next)
 
 	tests := []struct {
 		name        string
@@ -159,3 +176,242 @@ func TestAuthMiddlewareRejectsPrivateLoopbackAndLinkLocalOverrides(t *testing.T)
 		})
 	}
 }
+
+func TestValidateURLHandler(t *testing.T) {
+	handler := validateURLHandler(&config.Config{})
+	const privateReason = "invalid X-Graylog-URL: host resolves to a private or special-use address"
+
+	tests := []struct {
+		name       string
+		url        string
+		wantValid  bool
+		wantReason string
+	}{
+		{name: "public", url: "https://8.8.8.8", wantValid: true},
+		{name: "public with port", url: "https://1.1.1.1:9000/graylog", wantValid: true},
+		{name: "loopback", url: "http://127.0.0.1", wantReason: privateReason},
+		{name: "private", url: "http://10.1.2.3", wantReason: privateReason},
+		{name: "cgnat", url: "http://100.64.0.1", wantReason: privateReason},
+		{name: "ipv6 loopback", url: "http://[::1]", wantReason: privateReason},
+		{name: "bad scheme", url: "ftp://8.8.8.8", wantReason: `invalid X-Graylog-URL: must use http or https scheme, got "ftp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/validate-url?url="+url.QueryEscape(tt.url), nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			var got struct {
+				URL    string `json:"url"`
+				Valid  bool   `json:"valid"`
+				Reason string `json:"reason"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if got.URL != tt.url || got.Valid != tt.wantValid || got.Reason != tt.wantReason {
+				t.Fatalf("expected valid=%v reason=%q, got %+v", tt.wantValid, tt.wantReason, got)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/validate-url", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without url, got %d", rr.Code)
+	}
+}
+
+func TestValidateURLHandlerAllowedHosts(t *testing.T) {
+	handler := validateURLHandler(&config.Config{AllowedHosts: []string{"8.8.4.4"}})
+	req := httptest.NewRequest(http.MethodGet, "/validate-url?url="+url.QueryEscape("https://8.8.8.8"), nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "not in GRAYLOG_ALLOWED_HOSTS") {
+		t.Fatalf("expected allowlist rejection, got %s", rr.Body.String())
+	}
+}
+
+func TestGraylogHostAllowed(t *testing.T) {
+	patterns := []string{"graylog.example.com", "*.logs.example.com", "gl.example.org:9000"}
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "exact host", input: "https://graylog.example.com", want: true},
+		{name: "exact host any port", input: "https://graylog.example.com:9000/api", want: true},
+		{name: "case insensitive", input: "https://GrayLog.Example.com", want: true},
+		{name: "other host", input: "https://evil.example.net", want: false},
+		{name: "suffix trick", input: "https://graylog.example.com.evil.net", want: false},
+		{name: "wildcard subdomain", input: "https://eu.logs.example.com", want: true},
+		{name: "wildcard nested subdomain", input: "https://a.eu.logs.example.com", want: true},
+		{name: "wildcard apex not matched", input: "https://logs.example.com", want: false},
+		{name: "wildcard lookalike", input: "https://evillogs.example.com", want: false},
+		{name: "port pattern match", input: "http://gl.example.org:9000", want: true},
+		{name: "port pattern mismatch", input: "http://gl.example.org:9001", want: false},
+		{name: "port pattern default port", input: "https://gl.example.org", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graylogHostAllowed(tt.input, patterns); got != tt.want {
+				t.Errorf("graylogHostAllowed(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if !graylogHostAllowed("https://graylog.example.com", []string{"graylog.example.com:443"}) {
+		t.Error("expected explicit :443 pattern to match https URL without a port")
+	}
+}
+
+func TestAuthMiddlewareAllowedHosts(t *testing.T) {
+	cfg := &config.Config{GraylogURL: "https://8.8.8.8", AllowedHosts: []string{"8.8.4.4"}}
+	baseClient := graylog.NewClient("", "", "", false, 2*time.Second)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := authMiddleware(cfg, baseClient, slog.New(slog.DiscardHandler))(next)
+
+	tests := []struct {
+		name        string
+		overrideURL string
+		wantCode    int
+	}{
+		{name: "no override uses GRAYLOG_URL", overrideURL: "", wantCode: http.StatusNoContent},
+		{name: "allowed override", overrideURL: "https://8.8.4.4", wantCode: http.StatusNoContent},
+		{name: "disallowed override", overrideURL: "https://1.1.1.1", wantCode: http.StatusForbidden},
+		{name: "private not listed", overrideURL: "http://10.0.0.1", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			if tt.overrideURL != "" {
+				req.Header.Set("X-Graylog-URL", tt.overrideURL)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected %d for override %q, got %d", tt.wantCode, tt.overrideURL, rr.Code)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareLogsWithoutCredentials(t *testing.T) {
+	cfg := &config.Config{GraylogURL: "https://8.8.8.8"}
+	baseClient := graylog.NewClient("", "", "", false, 2*time.Second)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := authMiddleware(cfg, baseClient, logger)(next)
+
+	basic := base64.StdEncoding.EncodeToString([]byte("alice:hunter2-password"))
+	for _, auth := range []string{"Bearer s3cr3t-token-value", "Basic " + basic, "Token leaked-bad-scheme"} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", auth)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	out := buf.String()
+	if strings.Count(out, `msg="auth accepted"`) != 2 || strings.Count(out, `msg="auth rejected"`) != 1 {
+		t.Fatalf("expected two accepted and one rejected auth records, got %q", out)
+	}
+	for _, secret := range []string{"s3cr3t-token-value", "hunter2-password", basic, "leaked-bad-scheme"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log output contains credential %q: %q", secret, out)
+		}
+	}
+}
+
+func TestAuthMiddlewarePropagatesRequestID(t *testing.T) {
+	cfg := &config.Config{GraylogURL: "https://8.8.8.8"}
+	baseClient := graylog.NewClient("", "", "", false, 2*time.Second)
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = graylog.RequestIDFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := authMiddleware(cfg, baseClient, slog.New(slog.DiscardHandler))(next)
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "absent", header: "", want: ""},
+		{name: "forwarded", header: "trace-abc_123", want: "trace-abc_123"},
+		{name: "spaces ignored", header: "two words", want: ""},
+		{name: "too long ignored", header: strings.Repeat("a", maxRequestIDLen+1), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			if tt.header != "" {
+				req.Header.Set(graylog.RequestIDHeader, tt.header)
+			}
+			got = "unset"
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("expected request ID %q in context, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantErr  bool
+		wantWarn bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantErr: true},
+		{name: "forbidden", status: http.StatusForbidden, wantErr: true},
+		{name: "server error is not fatal", status: http.StatusInternalServerError, wantWarn: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				if _, _, ok := r.BasicAuth(); !ok {
+					t.Error("expected an authenticated request")
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			client := graylog.NewClient(server.URL, "bad-token", "token", false, 2*time.Second)
+
+			err := verifyCredentials(t.Context(), client, 2*time.Second, logger)
+			if gotPath != "/api/system" {
+				t.Errorf("expected GET /api/system, got %q", gotPath)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "authentication failed") {
+				t.Errorf("expected an authentication failed message, got %q", err)
+			}
+			if gotWarn := strings.Contains(buf.String(), "level=WARN"); gotWarn != tt.wantWarn {
+				t.Errorf("warning logged = %v, want %v (log: %q)", gotWarn, tt.wantWarn, buf.String())
+			}
+		})
+	}
+}
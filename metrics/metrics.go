@@ -0,0 +1,144 @@
+// Package metrics collects per-tool call counters and latency histograms and
+// exposes them in the Prometheus text exposition format. It is intentionally
+// dependency-free to keep the server a single self-contained binary.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// durationBuckets are the upper bounds (seconds) of the latency histogram.
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type callKey struct {
+	tool   string
+	status string
+}
+
+type histogram struct {
+	counts []uint64 // non-cumulative per-bucket counts; the last slot is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Registry holds tool call metrics. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.Mutex
+	calls     map[callKey]uint64
+	durations map[string]*histogram
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		calls:     make(map[callKey]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// ToolMiddleware records a call count (labeled success/error) and latency for
+// every tool invocation. A result with IsError counts as an error, matching how
+// tool handlers report failures.
+func (r *Registry) ToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		status := "success"
+		if err != nil || (result != nil && result.IsError) {
+			status = "error"
+		}
+		r.observe(request.Params.Name, status, time.Since(start))
+		return result, err
+	}
+}
+
+func (r *Registry) observe(tool, status string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls[callKey{tool: tool, status: status}]++
+
+	h, ok := r.durations[tool]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets)+1)}
+		r.durations[tool] = h
+	}
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(durationBuckets, secs)
+	h.counts[i]++
+	h.sum += secs
+	h.count++
+}
+
+// Handler serves the metrics in Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w) //nolint:errcheck
+	})
+}
+
+// WriteTo writes all metrics in Prometheus text format with deterministic ordering.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP graylog_mcp_tool_calls_total Total MCP tool calls by tool and status.\n")
+	b.WriteString("# TYPE graylog_mcp_tool_calls_total counter\n")
+	keys := make([]callKey, 0, len(r.calls))
+	for k := range r.calls {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tool != keys[j].tool {
+			return keys[i].tool < keys[j].tool
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "graylog_mcp_tool_calls_total{tool=%s,status=%s} %d\n", quoteLabel(k.tool), quoteLabel(k.status), r.calls[k])
+	}
+
+	b.WriteString("# HELP graylog_mcp_tool_call_duration_seconds MCP tool call latency in seconds.\n")
+	b.WriteString("# TYPE graylog_mcp_tool_call_duration_seconds histogram\n")
+	tools := make([]string, 0, len(r.durations))
+	for t := range r.durations {
+		tools = append(tools, t)
+	}
+	sort.Strings(tools)
+	for _, t := range tools {
+		h := r.durations[t]
+		var cumulative uint64
+		for i, le := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "graylog_mcp_tool_call_duration_seconds_bucket{tool=%s,le=\"%s\"} %d\n", quoteLabel(t), strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "graylog_mcp_tool_call_duration_seconds_bucket{tool=%s,le=\"+Inf\"} %d\n", quoteLabel(t), h.count)
+		fmt.Fprintf(&b, "graylog_mcp_tool_call_duration_seconds_sum{tool=%s} %s\n", quoteLabel(t), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "graylog_mcp_tool_call_duration_seconds_count{tool=%s} %d\n", quoteLabel(t), h.count)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// quoteLabel quotes a label value, escaping backslash, double quote and newline
+// as required by the exposition format.
+func quoteLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return `"` + v + `"`
+}
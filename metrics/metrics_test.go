@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sampleLine matches a Prometheus text-format sample: name{labels} value.
+var sampleLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{([a-zA-Z_][a-zA-Z0-9_]*="(\\.|[^"\\])*",?)*\})? [-+]?([0-9.eE+-]+|Inf|NaN)$`)
+
+func callTool(t *testing.T, h func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), name string) {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	_, _ = h(context.Background(), req)
+}
+
+func TestToolMiddlewareCountsAndExposition(t *testing.T) {
+	reg := NewRegistry()
+
+	ok := reg.ToolMiddleware(func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	toolErr := reg.ToolMiddleware(func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError("bad input"), nil
+	})
+	goErr := reg.ToolMiddleware(func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("boom")
+	})
+
+	callTool(t, ok, "search_logs")
+	callTool(t, ok, "search_logs")
+	callTool(t, toolErr, "search_logs")
+	callTool(t, goErr, "list_streams")
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	out := string(body)
+
+	for _, want := range []string{
+		`graylog_mcp_tool_calls_total{tool="search_logs",status="success"} 2`,
+		`graylog_mcp_tool_calls_total{tool="search_logs",status="error"} 1`,
+		`graylog_mcp_tool_calls_total{tool="list_streams",status="error"} 1`,
+		`graylog_mcp_tool_call_duration_seconds_count{tool="search_logs"} 3`,
+		`graylog_mcp_tool_call_duration_seconds_bucket{tool="search_logs",le="+Inf"} 3`,
+		`graylog_mcp_tool_call_duration_seconds_count{tool="list_streams"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing line %q in output:\n%s", want, out)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+			continue
+		}
+		if !sampleLine.MatchString(line) {
+			t.Errorf("invalid exposition line: %q", line)
+		}
+	}
+}
+
+func TestQuoteLabelEscapes(t *testing.T) {
+	got := quoteLabel("a\"b\\c\nd")
+	want := `"a\"b\\c\nd"`
+	if got != want {
+		t.Fatalf("quoteLabel = %s, want %s", got, want)
+	}
+}
@@ -2,11 +2,18 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
 
@@ -17,6 +24,13 @@ var nonAggregatableFields = map[string]bool{
 	"full_message": true,
 }
 
+// aggregateSampleConcurrency bounds the with_sample searches in flight.
+const aggregateSampleConcurrency = 4
+
+// groupByCrossProductWarn is the estimated row count above which
+// aggregate_logs warns that the grouping may be slow or truncated.
+const groupByCrossProductWarn = 10000
+
 var validAggFunctions = map[string]bool{
 	"count":        true,
 	"avg":          true,
@@ -31,6 +45,28 @@ var validAggFunctions = map[string]bool{
 	"sumofsquares": true,
 }
 
+// percentileRankMetric is a derived metric computed client-side: the share of
+// messages whose Field is below Value, as a percentage of the group total.
+// Graylog's Scripting API has no native equivalent.
+type percentileRankMetric struct {
+	Field string
+	Value float64
+}
+
+// columnName returns the result column name, following Graylog's "metric: fn(args)" naming.
+func (m percentileRankMetric) columnName() string {
+	return fmt.Sprintf("metric: percentile_rank(%s,%s)", m.Field, strconv.FormatFloat(m.Value, 'f', -1, 64))
+}
+
+// derivedMetrics are the metrics parseMetrics found that Graylog can't compute
+// and aggregate_logs derives from its results.
+type derivedMetrics struct {
+	ranks []percentileRankMetric
+	// movingAvgWindows holds the window, in time buckets, of each
+	// 'moving_avg:<window>' metric.
+	movingAvgWindows []int
+}
+
 func aggregateLogsTool() mcp.Tool {
 	return mcp.NewTool("aggregate_logs",
 		mcp.WithDescription("Aggregate Graylog logs using statistical functions (count, avg, min, max, percentile, etc.) with optional grouping. Uses Graylog Scripting API."),
@@ -40,11 +76,11 @@ func aggregateLogsTool() mcp.Tool {
 		),
 		mcp.WithString("metrics",
 			mcp.Required(),
-			mcp.Description("Comma-separated metrics: 'count', 'avg:field', 'min:field', 'max:field', 'sum:field', 'percentile:field:value', 'card:field', 'stddev:field', 'variance:field', 'latest:field'"),
+			mcp.Description("Comma-separated metrics: 'count', 'avg:field', 'min:field', 'max:field', 'sum:field', 'percentile:field:value', 'percentile_rank:field:value' (percent of messages with field below value), 'moving_avg:window' (average of the first metric over the last window time buckets; needs a 'time:' group_by), 'card:field', 'stddev:field', 'variance:field', 'latest:field'"),
 		),
 		mcp.WithString("group_by",
 			mcp.Required(),
-			mcp.Description("Comma-separated fields to group by (e.g. 'source', 'source,level')"),
+			mcp.Description("Comma-separated fields to group by (e.g. 'source', 'source,level'). Use 'time:<interval>' for a time bucket dimension, e.g. 'time:1h,source' (units: s, m, h, d, w, M, y)"),
 		),
 		mcp.WithNumber("group_limit",
 			mcp.Description("Maximum number of groups per field (default: 10)"),
@@ -64,10 +100,24 @@ func aggregateLogsTool() mcp.Tool {
 		mcp.WithString("sort",
 			mcp.Description("Sort direction for the first metric: 'asc' or 'desc'"),
 		),
+		mcp.WithBoolean("include_percentage",
+			mcp.Description("If true, add a 'percentage' column with each row's share of the first metric summed across all returned rows"),
+		),
+		mcp.WithBoolean("nested",
+			mcp.Description("If true, return 'groups' as a tree keyed by each group_by value in order (e.g. source → level → metrics) instead of flat 'rows'"),
+		),
+		mcp.WithBoolean("with_sample",
+			mcp.Description("If true, attach the most recent matching message to each row as 'sample' (one extra search per row, at most group_limit rows). Not supported with a time bucket in group_by."),
+		),
+		mcp.WithBoolean("debug",
+			mcp.Description("If true, don't run the aggregation; return the exact Scripting API request that would be sent"),
+		),
+		redactParam(),
+		timeoutSecondsParam(),
 	)
 }
 
-func aggregateLogsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func aggregateLogsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
 
@@ -81,23 +131,16 @@ func aggregateLogsHandler(getClient ClientFunc) func(ctx context.Context, reques
 			return toolError("'metrics' parameter is required"), nil
 		}
 
-		metrics, err := parseMetrics(metricsStr, getStringParam(args, "sort"))
+		metrics, derived, err := parseMetrics(metricsStr, getStringParam(args, "sort"))
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-
-		from := getStringParam(args, "from")
-		to := getStringParam(args, "to")
-
-		if (from == "") != (to == "") {
-			return toolError("'from' and 'to' must be used together"), nil
+		if len(metrics) == 0 {
+			// Only derived metrics requested — Graylog still needs one metric per group.
+			metrics = []graylog.ScriptingMetric{{Function: "count"}}
 		}
 
-		rangeVal, err := getStrictNonNegativeIntParam(args, "range", 0)
-		if err != nil {
-			return toolError(err.Error()), nil
-		}
-		timeRange, err := buildScriptingTimeRange(from, to, rangeVal)
+		tr, err := parseTimeRange(args, 0)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
@@ -111,10 +154,24 @@ func aggregateLogsHandler(getClient ClientFunc) func(ctx context.Context, reques
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-		groupBy := parseGroupBy(groupByStr, groupLimit)
+		groupBy, err := parseGroupBy(groupByStr, groupLimit)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
 		if len(groupBy) == 0 {
 			return toolError("'group_by' must contain at least one non-empty field name"), nil
 		}
+		maxGroupByFields := cfg.MaxGroupByFields
+		if maxGroupByFields <= 0 {
+			maxGroupByFields = config.DefaultMaxGroupByFields
+		}
+		if len(groupBy) > maxGroupByFields {
+			return toolError(fmt.Sprintf(
+				"'group_by' has %d dimensions but this server allows at most %d (GRAYLOG_MAX_GROUP_BY_FIELDS). "+
+					"Group by fewer fields, or filter on the others in 'query' instead.",
+				len(groupBy), maxGroupByFields,
+			)), nil
+		}
 
 		for _, g := range groupBy {
 			if nonAggregatableFields[g.Field] {
@@ -126,52 +183,152 @@ func aggregateLogsHandler(getClient ClientFunc) func(ctx context.Context, reques
 			}
 		}
 
+		if len(derived.movingAvgWindows) > 0 && timeBucketInterval(groupBy) == "" {
+			return toolError("'moving_avg' needs a time bucket in 'group_by', e.g. 'time:1h' or 'time:1h,source'"), nil
+		}
+
+		withSample := getBoolParam(args, "with_sample")
+		if withSample && timeBucketInterval(groupBy) != "" {
+			return toolError("'with_sample' can't be combined with a time bucket in 'group_by'"), nil
+		}
+
 		req := graylog.ScriptingAggregateRequest{
 			Query:     query,
-			TimeRange: timeRange,
+			TimeRange: tr.scripting(),
 			GroupBy:   groupBy,
 			Metrics:   metrics,
-		}
-
-		if streamID := getStringParam(args, "stream_id"); streamID != "" {
-			req.Streams = []string{streamID}
+			Streams:   getStreamIDsParam(args, cfg),
 		}
 
 		c := getClient(ctx)
 		if c == nil {
-			return toolError("no Graylog credentials: Authorization header required"), nil
+			return noCredentialsError(), nil
+		}
+		if getBoolParam(args, "debug") {
+			return debugResult(c.PreviewAggregate(req)), nil
 		}
 		resp, err := c.Aggregate(ctx, req)
-		if err != nil {
-			if apiErr, ok := err.(*graylog.APIError); ok {
-				// fragile: depends on Elasticsearch error format returning "script_exception" in body
-				if apiErr.StatusCode == 400 && strings.Contains(apiErr.Body, "script_exception") {
+		var keywordFields map[string]string
+		if apiErr, ok := err.(*graylog.APIError); ok {
+			// fragile: depends on Elasticsearch error format returning "script_exception" in body
+			if apiErr.StatusCode == 400 && strings.Contains(apiErr.Body, "script_exception") {
+				var suggestion string
+				keywordFields, suggestion = keywordGroupBy(ctx, c, groupBy)
+				if suggestion != "" {
+					return toolError(suggestion), nil
+				}
+				if len(keywordFields) == 0 {
 					return toolError(
 						"Aggregation failed: Elasticsearch cannot group by one or more of the requested fields. " +
 							"Analyzed text fields (e.g. 'message', 'full_message') are not supported in group_by — " +
 							"use keyword fields like 'source', 'level', 'facility' instead.",
 					), nil
 				}
-				return toolError(apiErr.Error()), nil
+				// req.GroupBy shares groupBy, so the retry and everything
+				// after it use the keyword sub-fields.
+				for i := range groupBy {
+					if kw, ok := keywordFields[groupBy[i].Field]; ok {
+						groupBy[i].Field = kw
+					}
+				}
+				resp, err = c.Aggregate(ctx, req)
+			}
+		}
+		if err != nil {
+			return aggregateError("Aggregate failed", err), nil
+		}
+
+		rows, rollups := tabularToRows(resp)
+
+		if len(derived.ranks) > 0 {
+			if err := applyPercentileRanks(ctx, c, req, derived.ranks, resp, rows); err != nil {
+				return aggregateError("Percentile rank computation failed", err), nil
 			}
-			return toolError("Aggregate failed: " + err.Error()), nil
 		}
 
-		rows := tabularToRows(resp.Schema, resp.DataRows)
+		labelTimeBuckets(resp.Schema, rows, groupBy)
+		for _, window := range derived.movingAvgWindows {
+			applyMovingAverage(resp.Schema, rows, groupBy, req.Metrics[0], window)
+		}
 
 		result := map[string]any{
 			"rows":       rows,
 			"total_rows": len(rows),
 			"metadata":   resp.Metadata,
 		}
+		if rollups > 0 {
+			result["rollup_rows_skipped"] = rollups
+		}
+		effective := resp.Metadata.EffectiveTimerange
+		if tr.From != "" {
+			// The requested window stands in if Graylog didn't report one.
+			effective = mergeProps(map[string]any{"from": tr.From, "to": tr.To}, effective)
+		}
+		if window := effectiveRange(effective, tr.Range, time.Now()); window != nil {
+			result["effective_range"] = window
+		}
+		if len(keywordFields) > 0 {
+			result["keyword_fields"] = keywordFields
+		}
+		if estimate := groupByCrossProduct(groupBy); estimate > groupByCrossProductWarn {
+			result["warning"] = fmt.Sprintf(
+				"group_by may produce up to %d rows (group_limit per field multiplied across %d fields); "+
+					"use fewer fields or a lower group_limit if the aggregation is slow or truncated",
+				estimate, len(groupBy),
+			)
+		}
+		if withSample {
+			sampleParams := graylog.SearchParams{StreamIDs: req.Streams}
+			tr.applyTo(&sampleParams)
+			if sampled := attachGroupSamples(ctx, c, query, sampleParams, resp, rows, groupLimit, getRedactPatterns(args, cfg)); sampled < len(rows) {
+				result["sample_note"] = fmt.Sprintf("samples attached to the first %d of %d rows (group_limit)", sampled, len(rows))
+			}
+		}
+		if getBoolParam(args, "include_percentage") {
+			result["percentage_total"] = applyPercentages(resp, rows, req.Metrics[0])
+		}
+		var nestLevels []string
+		if getBoolParam(args, "nested") {
+			nestLevels = groupingColumns(resp.Schema, groupBy)
+		}
+
+		return fitAggregateResult(ctx, result, nestLevels, defaultMaxResultSize)
+	}
+}
 
-		return fitAggregateResult(result, defaultMaxResultSize)
+// keywordGroupBy runs after Elasticsearch rejected a grouping, so the common
+// case costs no extra request. It reads the field types and maps each analyzed
+// (non-enumerable) group_by field to its enumerable ".keyword" sub-field. If an
+// analyzed field has none, it returns a suggestion to report instead. Both are
+// empty when the types can't be read or show no analyzed group_by field.
+func keywordGroupBy(ctx context.Context, c *graylog.Client, groupBy []graylog.ScriptingGrouping) (map[string]string, string) {
+	types, err := c.GetFieldTypes(ctx)
+	if err != nil {
+		return nil, ""
 	}
+	keywordFields := make(map[string]string)
+	for _, g := range groupBy {
+		info, ok := types[g.Field]
+		if !ok || g.TimeUnit != "" || info.Enumerable() {
+			continue
+		}
+		kw := g.Field + ".keyword"
+		if !types[kw].Enumerable() {
+			return nil, fmt.Sprintf(
+				"field '%s' is an analyzed text field and cannot be used for group_by aggregation, and Graylog lists no keyword sub-field for it. "+
+					"Try '%s' if your index mapping defines one, or group by a keyword field instead.",
+				g.Field, kw,
+			)
+		}
+		keywordFields[g.Field] = kw
+	}
+	return keywordFields, ""
 }
 
-func parseMetrics(metricsStr, sort string) ([]graylog.ScriptingMetric, error) {
+func parseMetrics(metricsStr, sort string) ([]graylog.ScriptingMetric, derivedMetrics, error) {
 	parts := strings.Split(metricsStr, ",")
 	metrics := make([]graylog.ScriptingMetric, 0, len(parts))
+	var derived derivedMetrics
 
 	for i, part := range parts {
 		part = strings.TrimSpace(part)
@@ -182,8 +339,25 @@ func parseMetrics(metricsStr, sort string) ([]graylog.ScriptingMetric, error) {
 		segments := strings.SplitN(part, ":", 3)
 		fn := strings.ToLower(strings.TrimSpace(segments[0]))
 
+		switch fn {
+		case "percentile_rank":
+			rank, err := parsePercentileRank(segments)
+			if err != nil {
+				return nil, derivedMetrics{}, err
+			}
+			derived.ranks = append(derived.ranks, rank)
+			continue
+		case "moving_avg":
+			window, err := parseMovingAvg(segments)
+			if err != nil {
+				return nil, derivedMetrics{}, err
+			}
+			derived.movingAvgWindows = append(derived.movingAvgWindows, window)
+			continue
+		}
+
 		if !validAggFunctions[fn] {
-			return nil, fmt.Errorf("unknown aggregation function '%s'. Valid functions: count, avg, min, max, sum, stddev, variance, card, percentile, latest, sumofsquares", fn)
+			return nil, derivedMetrics{}, fmt.Errorf("unknown aggregation function '%s'. Valid functions: count, avg, min, max, sum, stddev, variance, card, percentile, percentile_rank, moving_avg, latest, sumofsquares", fn)
 		}
 
 		m := graylog.ScriptingMetric{Function: fn}
@@ -195,17 +369,17 @@ func parseMetrics(metricsStr, sort string) ([]graylog.ScriptingMetric, error) {
 			}
 		} else if fn == "percentile" {
 			if len(segments) < 3 {
-				return nil, fmt.Errorf("percentile requires format 'percentile:field:value' (e.g. 'percentile:took_ms:95')")
+				return nil, derivedMetrics{}, fmt.Errorf("percentile requires format 'percentile:field:value' (e.g. 'percentile:took_ms:95')")
 			}
 			m.Field = strings.TrimSpace(segments[1])
 			pctVal, err := strconv.ParseFloat(strings.TrimSpace(segments[2]), 64)
 			if err != nil || pctVal <= 0 || pctVal > 100 {
-				return nil, fmt.Errorf("percentile value must be a number between 0 and 100, got '%s'", segments[2])
+				return nil, derivedMetrics{}, fmt.Errorf("percentile value must be a number between 0 and 100, got '%s'", segments[2])
 			}
 			m.Configuration = &graylog.ScriptingMetricConfig{Percentile: pctVal}
 		} else {
 			if len(segments) < 2 || strings.TrimSpace(segments[1]) == "" {
-				return nil, fmt.Errorf("'%s' requires a field (e.g. '%s:field_name')", fn, fn)
+				return nil, derivedMetrics{}, fmt.Errorf("'%s' requires a field (e.g. '%s:field_name')", fn, fn)
 			}
 			m.Field = strings.TrimSpace(segments[1])
 		}
@@ -221,73 +395,633 @@ func parseMetrics(metricsStr, sort string) ([]graylog.ScriptingMetric, error) {
 		metrics = append(metrics, m)
 	}
 
-	if len(metrics) == 0 {
-		return nil, fmt.Errorf("at least one metric is required")
+	if len(metrics) == 0 && len(derived.ranks) == 0 && len(derived.movingAvgWindows) == 0 {
+		return nil, derivedMetrics{}, fmt.Errorf("at least one metric is required")
 	}
 
-	return metrics, nil
+	return metrics, derived, nil
 }
 
-func parseGroupBy(groupByStr string, limit int) []graylog.ScriptingGrouping {
-	if groupByStr == "" {
+// parsePercentileRank parses the segments of a 'percentile_rank:field:value' metric.
+func parsePercentileRank(segments []string) (percentileRankMetric, error) {
+	if len(segments) < 3 || strings.TrimSpace(segments[1]) == "" {
+		return percentileRankMetric{}, fmt.Errorf("percentile_rank requires format 'percentile_rank:field:value' (e.g. 'percentile_rank:took_ms:500')")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(segments[2]), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return percentileRankMetric{}, fmt.Errorf("percentile_rank threshold must be a number, got '%s'", segments[2])
+	}
+	return percentileRankMetric{Field: strings.TrimSpace(segments[1]), Value: value}, nil
+}
+
+// parseMovingAvg parses the segments of a 'moving_avg:window' metric.
+func parseMovingAvg(segments []string) (int, error) {
+	if len(segments) != 2 {
+		return 0, fmt.Errorf("moving_avg requires format 'moving_avg:window' (e.g. 'moving_avg:3'); it averages the first metric")
+	}
+	window, err := strconv.Atoi(strings.TrimSpace(segments[1]))
+	if err != nil || window < 1 {
+		return 0, fmt.Errorf("moving_avg window must be a positive number of time buckets, got '%s'", segments[1])
+	}
+	return window, nil
+}
+
+// applyPercentileRanks computes each percentile_rank metric client-side and adds
+// it as a column to rows. For every rank it issues a count aggregation restricted
+// to 'field:<value' and divides it by a count of the group's messages that have
+// the field at all, joining the result sets on the grouping columns. Messages
+// without the field don't count, so a sparse field can still rank near 100%.
+// Groups without such messages get a null rank. The extra queries pick their
+// own top groups, so a group missing from one has no matching messages only
+// when that result wasn't cut by a group limit (groupsComplete); otherwise its
+// rank is unknown and null.
+func applyPercentileRanks(ctx context.Context, c *graylog.Client, req graylog.ScriptingAggregateRequest, ranks []percentileRankMetric, resp *graylog.ScriptingTabularResponse, rows []map[string]any) error {
+	query := req.Query
+	if strings.TrimSpace(query) == "" {
+		query = "*"
+	}
+	countReq := req
+	countReq.Metrics = []graylog.ScriptingMetric{{Function: "count"}}
+	countGroups := func(q string) (map[string]float64, bool, error) {
+		r := countReq
+		r.Query = q
+		countResp, err := c.Aggregate(ctx, r)
+		if err != nil {
+			return nil, false, err
+		}
+		return countsByGroup(countResp), groupsComplete(countResp, req.GroupBy), nil
+	}
+
+	type groupCounts struct {
+		counts   map[string]float64
+		complete bool
+	}
+	totalsByField := make(map[string]groupCounts)
+	for _, rank := range ranks {
+		totals, ok := totalsByField[rank.Field]
+		if !ok {
+			counts, complete, err := countGroups(fmt.Sprintf("(%s) AND _exists_:%s", query, rank.Field))
+			if err != nil {
+				return err
+			}
+			totals = groupCounts{counts, complete}
+			totalsByField[rank.Field] = totals
+		}
+		below, complete, err := countGroups(fmt.Sprintf("(%s) AND %s:<%s", query, rank.Field, strconv.FormatFloat(rank.Value, 'f', -1, 64)))
+		if err != nil {
+			return err
+		}
+
+		col := rank.columnName()
+		for i, dataRow := range resp.DataRows {
+			if i >= len(rows) {
+				break
+			}
+			key := groupKey(resp.Schema, dataRow)
+			total, hasTotal := totals.counts[key]
+			n, hasBelow := below[key]
+			if (!hasTotal && !totals.complete) || (!hasBelow && !complete) {
+				rows[i][col] = nil
+				continue
+			}
+			rows[i][col] = percentileRankValue(n, total)
+		}
+	}
+	return nil
+}
+
+// attachGroupSamples adds a "sample" column holding the most recent message of
+// each row's group, found by a one-message search scoped to the group's field
+// values. At most limit rows are sampled, with aggregateSampleConcurrency
+// searches in flight. A failed search sets "sample_error" on its row instead.
+// Sample messages are scrubbed with redact. It returns the number of rows
+// sampled.
+func attachGroupSamples(ctx context.Context, c *graylog.Client, query string, params graylog.SearchParams, resp *graylog.ScriptingTabularResponse, rows []map[string]any, limit int, redact []*regexp.Regexp) int {
+	n := min(len(rows), len(resp.DataRows), max(limit, 1))
+	sem := make(chan struct{}, aggregateSampleConcurrency)
+	var wg sync.WaitGroup
+	for i := range n {
+		p := params
+		p.Query = groupSampleQuery(query, resp.Schema, resp.DataRows[i])
+		p.Limit = 1
+		p.Sort = "timestamp:desc"
+		row := rows[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			sr, err := c.Search(ctx, p)
+			switch {
+			case err != nil:
+				row["sample_error"] = err.Error()
+			case len(sr.Messages) == 0:
+				row["sample"] = nil
+			default:
+				m := sr.Messages[0]
+				redactMessage(&m.Message, redact)
+				row["sample"] = map[string]any{
+					"_id":       m.Message.ID,
+					"index":     m.Index,
+					"timestamp": m.Message.Timestamp,
+					"source":    m.Message.Source,
+					"message":   m.Message.Message,
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return n
+}
+
+// groupSampleQuery ANDs a term for each grouping value of dataRow onto query.
+// A missing value matches messages without the field.
+func groupSampleQuery(query string, schema []graylog.ScriptingSchemaEntry, dataRow []any) string {
+	q := "(" + query + ")"
+	for j, entry := range schema {
+		if entry.ColumnType != "grouping" || j >= len(dataRow) {
+			continue
+		}
+		if dataRow[j] == nil {
+			q += " AND NOT _exists_:" + entry.Field
+		} else {
+			q += " AND " + entry.Field + ":" + escapeLuceneValue(dataRow[j])
+		}
+	}
+	return q
+}
+
+// applyMovingAverage adds a column averaging metric over each row's time bucket
+// and the window-1 buckets before it in the same series, the rows sharing
+// every other grouping value. Buckets Graylog didn't return (no messages, or
+// cut by group_limit) aren't counted as zeros: the window spans the previous
+// returned buckets. Rows with fewer than window buckets so far, or with a
+// non-numeric value in their window, get null. It runs after labelTimeBuckets,
+// so bucket values are RFC3339 UTC and sort as text.
+func applyMovingAverage(schema []graylog.ScriptingSchemaEntry, rows []map[string]any, groupBy []graylog.ScriptingGrouping, metric graylog.ScriptingMetric, window int) {
+	metricCol := metricColumn(schema, metric)
+	if metricCol < 0 {
+		return
+	}
+	valueName := schema[metricCol].Name
+	col := fmt.Sprintf("metric: moving_avg(%s,%d)", strings.TrimPrefix(valueName, "metric: "), window)
+	timeName := timeBucketLabel(timeBucketInterval(groupBy))
+	var seriesCols []string
+	for _, name := range groupingColumns(schema, groupBy) {
+		if name != timeName {
+			seriesCols = append(seriesCols, name)
+		}
+	}
+
+	series := make(map[string][]map[string]any)
+	for _, row := range rows {
+		values := make([]any, len(seriesCols))
+		for i, name := range seriesCols {
+			values[i] = row[name]
+		}
+		key, _ := json.Marshal(values)
+		series[string(key)] = append(series[string(key)], row)
+	}
+	for _, members := range series {
+		slices.SortStableFunc(members, func(a, b map[string]any) int {
+			return strings.Compare(fmt.Sprint(a[timeName]), fmt.Sprint(b[timeName]))
+		})
+		for i, row := range members {
+			row[col] = nil
+			if i+1 < window {
+				continue
+			}
+			var sum float64
+			numeric := true
+			for _, r := range members[i+1-window : i+1] {
+				n, ok := r[valueName].(float64)
+				if !ok || math.IsNaN(n) {
+					numeric = false
+					break
+				}
+				sum += n
+			}
+			if numeric {
+				row[col] = sum / float64(window)
+			}
+		}
+	}
+}
+
+// applyPercentages adds a "percentage" column holding each row's value of the
+// first requested metric as a share of that metric summed over all returned
+// rows, and returns the sum. Rows with a non-numeric value, or any row when the
+// sum is zero, get a null percentage.
+func applyPercentages(resp *graylog.ScriptingTabularResponse, rows []map[string]any, metric graylog.ScriptingMetric) float64 {
+	metricCol := metricColumn(resp.Schema, metric)
+	values := make([]float64, len(rows))
+	valid := make([]bool, len(rows))
+	var total float64
+	for i, dataRow := range resp.DataRows {
+		if i >= len(rows) || metricCol < 0 || metricCol >= len(dataRow) {
+			continue
+		}
+		if n, ok := dataRow[metricCol].(float64); ok && !math.IsNaN(n) {
+			values[i], valid[i] = n, true
+			total += n
+		}
+	}
+	for i, row := range rows {
+		if valid[i] && total != 0 {
+			row["percentage"] = values[i] / total * 100
+		} else {
+			row["percentage"] = nil
+		}
+	}
+	return total
+}
+
+// percentileRankValue returns below/total as a percentage, or nil when total is zero.
+func percentileRankValue(below, total float64) any {
+	if total <= 0 {
 		return nil
 	}
+	return below / total * 100
+}
+
+// metricColumn returns the index of the first metric column computing metric's
+// function over its field, or of the first metric column if none matches, so
+// lookups don't depend on the order Graylog lists columns in. It returns -1 for
+// a schema without metric columns.
+func metricColumn(schema []graylog.ScriptingSchemaEntry, metric graylog.ScriptingMetric) int {
+	first := -1
+	for j, entry := range schema {
+		if entry.ColumnType != "metric" {
+			continue
+		}
+		if entry.Function == metric.Function && entry.Field == metric.Field {
+			return j
+		}
+		if first < 0 {
+			first = j
+		}
+	}
+	return first
+}
+
+// countsByGroup maps each row's grouping key to its count() column value.
+func countsByGroup(resp *graylog.ScriptingTabularResponse) map[string]float64 {
+	metricCol := metricColumn(resp.Schema, graylog.ScriptingMetric{Function: "count"})
+	counts := make(map[string]float64, len(resp.DataRows))
+	if metricCol < 0 {
+		return counts
+	}
+	for _, dataRow := range resp.DataRows {
+		if metricCol < len(dataRow) {
+			if n, ok := dataRow[metricCol].(float64); ok {
+				counts[groupKey(resp.Schema, dataRow)] = n
+			}
+		}
+	}
+	return counts
+}
+
+// groupsComplete reports whether resp holds every group its query matched:
+// no limited grouping level has reached its limit under any parent group.
+// Time buckets aren't limited; a level without a known limit counts as cut.
+func groupsComplete(resp *graylog.ScriptingTabularResponse, groupBy []graylog.ScriptingGrouping) bool {
+	var cols []int
+	for j, entry := range resp.Schema {
+		if entry.ColumnType == "grouping" {
+			cols = append(cols, j)
+		}
+	}
+	for level, g := range groupBy {
+		if g.TimeUnit != "" {
+			continue
+		}
+		if g.Limit <= 0 || level >= len(cols) {
+			return false
+		}
+		seen := make(map[string]bool)
+		children := make(map[string]int)
+		for _, dataRow := range resp.DataRows {
+			if cols[level] >= len(dataRow) || isRollupRow(resp.Schema, dataRow) {
+				continue
+			}
+			prefix := make([]any, level)
+			for i := range level {
+				prefix[i] = dataRow[cols[i]]
+			}
+			p, _ := json.Marshal(prefix)
+			v, _ := json.Marshal(dataRow[cols[level]])
+			if child := string(p) + string(v); !seen[child] {
+				seen[child] = true
+				children[string(p)]++
+			}
+		}
+		for _, n := range children {
+			if n >= g.Limit {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// groupKey builds a join key from the grouping columns of a datarow.
+func groupKey(schema []graylog.ScriptingSchemaEntry, dataRow []any) string {
+	var values []any
+	for j, entry := range schema {
+		if entry.ColumnType == "grouping" && j < len(dataRow) {
+			values = append(values, dataRow[j])
+		}
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// timeGroupingPrefix marks a group_by token as a time bucket, e.g. "time:1h".
+const timeGroupingPrefix = "time:"
+
+// timeUnitPattern matches Graylog's bucket interval syntax: an amount and a unit.
+var timeUnitPattern = regexp.MustCompile(`^[1-9][0-9]*[smhdwMy]$`)
+
+// parseGroupBy splits a comma-separated group_by list. A "time:<interval>" token
+// becomes a date histogram on the message timestamp; group limits don't apply to it.
+func parseGroupBy(groupByStr string, limit int) ([]graylog.ScriptingGrouping, error) {
+	if groupByStr == "" {
+		return nil, nil
+	}
 
 	fields := strings.Split(groupByStr, ",")
 	groups := make([]graylog.ScriptingGrouping, 0, len(fields))
+	hasTime := false
 	for _, f := range fields {
 		f = strings.TrimSpace(f)
-		if f != "" {
-			g := graylog.ScriptingGrouping{Field: f}
-			if limit > 0 {
-				g.Limit = limit
+		if f == "" {
+			continue
+		}
+		if interval, ok := strings.CutPrefix(f, timeGroupingPrefix); ok {
+			if !timeUnitPattern.MatchString(interval) {
+				return nil, fmt.Errorf("invalid time bucket '%s': use 'time:<amount><unit>' with unit s, m, h, d, w, M or y (e.g. 'time:1h')", f)
+			}
+			if hasTime {
+				return nil, fmt.Errorf("only one 'time:<interval>' group_by dimension is allowed")
+			}
+			hasTime = true
+			groups = append(groups, graylog.ScriptingGrouping{Field: "timestamp", TimeUnit: interval})
+			continue
+		}
+		g := graylog.ScriptingGrouping{Field: f}
+		if limit > 0 {
+			g.Limit = limit
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// groupByCrossProduct estimates the most rows groupBy can produce: the product
+// of the per-field limits. Time buckets and fields without a limit are
+// skipped, as their size isn't known up front.
+func groupByCrossProduct(groupBy []graylog.ScriptingGrouping) int {
+	product := 1
+	for _, g := range groupBy {
+		if g.TimeUnit == "" && g.Limit > 0 {
+			if product > math.MaxInt/g.Limit {
+				return math.MaxInt
+			}
+			product *= g.Limit
+		}
+	}
+	return product
+}
+
+// timeBucketInterval returns the interval of the time bucket grouping, or "".
+func timeBucketInterval(groupBy []graylog.ScriptingGrouping) string {
+	for _, g := range groupBy {
+		if g.TimeUnit != "" {
+			return g.TimeUnit
+		}
+	}
+	return ""
+}
+
+func timeBucketLabel(interval string) string {
+	return fmt.Sprintf("grouping: time(%s)", interval)
+}
+
+// labelTimeBuckets renames the time bucket grouping column to "grouping: time(<interval>)"
+// and normalizes its values to RFC3339 UTC, whether Graylog returned them as
+// ISO8601 strings or epoch milliseconds.
+func labelTimeBuckets(schema []graylog.ScriptingSchemaEntry, rows []map[string]any, groupBy []graylog.ScriptingGrouping) {
+	interval := timeBucketInterval(groupBy)
+	if interval == "" {
+		return
+	}
+	for _, entry := range schema {
+		if entry.ColumnType != "grouping" || entry.Field != "timestamp" {
+			continue
+		}
+		label := timeBucketLabel(interval)
+		for _, row := range rows {
+			v, ok := row[entry.Name]
+			if !ok {
+				continue
+			}
+			delete(row, entry.Name)
+			row[label] = formatBucketTime(v)
+		}
+		return
+	}
+}
+
+// groupingColumns returns the row keys of the grouping columns in schema
+// order, which follows group_by, using the relabeled name for a time bucket.
+func groupingColumns(schema []graylog.ScriptingSchemaEntry, groupBy []graylog.ScriptingGrouping) []string {
+	interval := timeBucketInterval(groupBy)
+	var cols []string
+	for _, entry := range schema {
+		if entry.ColumnType != "grouping" {
+			continue
+		}
+		if interval != "" && entry.Field == "timestamp" {
+			cols = append(cols, timeBucketLabel(interval))
+			continue
+		}
+		cols = append(cols, entry.Name)
+	}
+	return cols
+}
+
+// nestRows turns flat rows into a tree keyed by the value of each grouping
+// column in turn; leaves hold the remaining (metric) columns. Because Graylog
+// applies group_limit per field within each parent group, every level of the
+// tree holds at most group_limit children.
+func nestRows(rows []map[string]any, levels []string) map[string]any {
+	tree := make(map[string]any)
+	if len(levels) == 0 {
+		return tree
+	}
+	isLevel := make(map[string]bool, len(levels))
+	for _, l := range levels {
+		isLevel[l] = true
+	}
+	for _, row := range rows {
+		node := tree
+		for _, l := range levels[:len(levels)-1] {
+			key := groupValueKey(row[l])
+			child, ok := node[key].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[key] = child
+			}
+			node = child
+		}
+		leaf := make(map[string]any, len(row)-len(levels))
+		for k, v := range row {
+			if !isLevel[k] {
+				leaf[k] = v
 			}
-			groups = append(groups, g)
 		}
+		node[groupValueKey(row[levels[len(levels)-1]])] = leaf
 	}
-	return groups
+	return tree
 }
 
-func buildScriptingTimeRange(from, to string, rangeSeconds int) (graylog.ScriptingTimeRange, error) {
-	if from != "" && to != "" {
-		return graylog.ScriptingTimeRange{Type: "absolute", From: from, To: to}, nil
+// groupValueKey renders a grouping value as a map key.
+func groupValueKey(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "(empty)"
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
 	}
+}
 
-	if rangeSeconds <= 0 {
-		rangeSeconds = 300
+func formatBucketTime(v any) any {
+	switch t := v.(type) {
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return parsed.UTC().Format(time.RFC3339)
+		}
+	case float64:
+		return time.UnixMilli(int64(t)).UTC().Format(time.RFC3339)
 	}
-	return graylog.ScriptingTimeRange{Type: "relative", Range: rangeSeconds}, nil
+	return v
 }
 
-func tabularToRows(schema []graylog.ScriptingSchemaEntry, dataRows [][]any) []map[string]any {
-	rows := make([]map[string]any, 0, len(dataRows))
-	for _, dataRow := range dataRows {
-		row := make(map[string]any, len(schema))
-		for j, entry := range schema {
+// tabularToRows converts resp's datarows into maps keyed by column name and
+// returns them with the number of rollup rows skipped. The schema lists the
+// columns in datarow cell order, so each cell is keyed by the schema entry at
+// its position; later steps find a metric by its function and field
+// (metricColumn), not by where Graylog put it. To keep later schema and datarow
+// lookups consistent with the rows, it fixes up resp in place: empty column
+// names are derived from the column's function and field, repeated names (e.g.
+// two percentiles Graylog named alike) get a " #2", " #3" suffix, and rollup
+// rows are removed from resp.DataRows.
+func tabularToRows(resp *graylog.ScriptingTabularResponse) ([]map[string]any, int) {
+	normalizeColumnNames(resp.Schema)
+
+	kept := resp.DataRows[:0]
+	for _, dataRow := range resp.DataRows {
+		if !isRollupRow(resp.Schema, dataRow) {
+			kept = append(kept, dataRow)
+		}
+	}
+	skipped := len(resp.DataRows) - len(kept)
+	resp.DataRows = kept
+
+	rows := make([]map[string]any, 0, len(resp.DataRows))
+	for _, dataRow := range resp.DataRows {
+		row := make(map[string]any, len(resp.Schema))
+		for j, entry := range resp.Schema {
 			if j < len(dataRow) {
 				row[entry.Name] = dataRow[j]
 			}
 		}
 		rows = append(rows, row)
 	}
-	return rows
+	return rows, skipped
+}
+
+// normalizeColumnNames gives every schema entry a unique, non-empty Name.
+func normalizeColumnNames(schema []graylog.ScriptingSchemaEntry) {
+	seen := make(map[string]int, len(schema))
+	for j := range schema {
+		entry := &schema[j]
+		if entry.Name == "" {
+			switch {
+			case entry.Function != "" && entry.Field != "":
+				entry.Name = fmt.Sprintf("%s: %s(%s)", entry.ColumnType, entry.Function, entry.Field)
+			case entry.Function != "":
+				entry.Name = fmt.Sprintf("%s: %s()", entry.ColumnType, entry.Function)
+			default:
+				entry.Name = fmt.Sprintf("%s: %s", entry.ColumnType, entry.Field)
+			}
+		}
+		seen[entry.Name]++
+		if n := seen[entry.Name]; n > 1 {
+			entry.Name = fmt.Sprintf("%s #%d", entry.Name, n)
+		}
+	}
 }
 
-func fitAggregateResult(result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
+// isRollupRow reports whether dataRow is a rollup (total or subtotal) row.
+// The Scripting API builds each datarow from a Views pivot row as the row's
+// key, one value per grouping level it covers, followed by one value per
+// metric. Rollup rows of a pivot (source "non-leaf") cover only the leading
+// grouping levels — none for the grand total — so their datarow has all the
+// metric cells but fewer grouping cells than the schema. A full-width row with
+// null grouping values is a real group of messages missing the field, and a
+// row too short to hold every metric isn't a rollup either.
+func isRollupRow(schema []graylog.ScriptingSchemaEntry, dataRow []any) bool {
+	metrics := 0
+	for _, entry := range schema {
+		if entry.ColumnType == "metric" {
+			metrics++
+		}
+	}
+	return len(dataRow) >= metrics && len(dataRow) < len(schema)
+}
+
+// fitAggregateResult fits result["rows"] to maxSize. With nestLevels the rows
+// are returned as "groups" (nestRows) instead, re-nested after every trim so
+// oversized nested output loses rows rather than all of its data.
+func fitAggregateResult(ctx context.Context, result map[string]any, nestLevels []string, maxSize int) (*mcp.CallToolResult, error) {
+	rows, _ := result["rows"].([]map[string]any)
+	setRows := func(r []map[string]any) {
+		rows = r
+		if nestLevels != nil {
+			result["groups"] = nestRows(r, nestLevels)
+		} else {
+			result["rows"] = r
+		}
+	}
+	if nestLevels != nil {
+		delete(result, "rows")
+		result["group_levels"] = nestLevels
+	}
+	setRows(rows)
+
 	adapter := resultAdapter{
 		truncateMsgs: func(maxLen int) {
-			// Aggregation rows don't have message bodies to truncate — no-op
+			// Only with_sample rows carry message bodies; nested leaves share
+			// the sample maps, so truncating them here covers both forms.
+			for _, row := range rows {
+				if sample, ok := row["sample"].(map[string]any); ok {
+					if msg, ok := sample["message"].(string); ok {
+						sample["message"] = truncateString(msg, maxLen)
+					}
+				}
+			}
 		},
 		reduceMsgs: func() bool {
-			rows, ok := result["rows"].([]map[string]any)
-			if !ok || len(rows) <= 1 {
+			if len(rows) <= 1 {
 				return false
 			}
-			newCount := len(rows) / 2
-			if newCount < 1 {
-				newCount = 1
-			}
-			result["rows"] = rows[:newCount]
+			setRows(rows[:len(rows)/2])
 			result["rows_truncated"] = true
 			return true
 		},
@@ -301,5 +1035,5 @@ func fitAggregateResult(result map[string]any, maxSize int) (*mcp.CallToolResult
 		},
 	}
 
-	return fitResult(result, maxSize, adapter)
+	return fitResult(ctx, result, maxSize, adapter)
 }
@@ -0,0 +1,1157 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestAggregateLogsHandlerDefaultStreamID(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        map[string]any
+		wantStreams []string
+	}{
+		{name: "default applied", args: map[string]any{}, wantStreams: []string{"default-stream"}},
+		{name: "explicit overrides default", args: map[string]any{"stream_id": "explicit-stream"}, wantStreams: []string{"explicit-stream"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got graylog.ScriptingAggregateRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"schema":[],"datarows":[],"metadata":{}}`))
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			cfg := &config.Config{DefaultStreamID: "default-stream"}
+			handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, cfg)
+
+			args := map[string]any{"query": "*", "metrics": "count", "group_by": "source"}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if !reflect.DeepEqual(got.Streams, tt.wantStreams) {
+				t.Fatalf("expected streams %v, got %v", tt.wantStreams, got.Streams)
+			}
+		})
+	}
+}
+
+func TestParseMetricsPercentileRank(t *testing.T) {
+	metrics, derived, err := parseMetrics("count, percentile_rank:took_ms:500", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].Function != "count" {
+		t.Fatalf("expected only count as a Graylog metric, got %+v", metrics)
+	}
+	want := []percentileRankMetric{{Field: "took_ms", Value: 500}}
+	if !reflect.DeepEqual(derived.ranks, want) {
+		t.Fatalf("expected ranks %+v, got %+v", want, derived.ranks)
+	}
+	if got := derived.ranks[0].columnName(); got != "metric: percentile_rank(took_ms,500)" {
+		t.Fatalf("unexpected column name %q", got)
+	}
+
+	for _, bad := range []string{
+		"percentile_rank",
+		"percentile_rank:took_ms",
+		"percentile_rank::500",
+		"percentile_rank:took_ms:fast",
+	} {
+		if _, _, err := parseMetrics(bad, ""); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAggregateLogsHandlerPercentileRank(t *testing.T) {
+	schema := []map[string]any{
+		{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+		{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graylog.ScriptingAggregateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		var rows [][]any
+		switch req.Query {
+		case "service:api":
+			rows = [][]any{{"web", 400}, {"db", 50}, {"idle", 0}}
+		case "(service:api) AND _exists_:took_ms":
+			// Half of web's messages have no took_ms and must not dilute its rank.
+			rows = [][]any{{"web", 200}, {"db", 50}}
+		case "(service:api) AND took_ms:<500":
+			rows = [][]any{{"web", 150}, {"db", 10}}
+		default:
+			t.Errorf("unexpected query %q", req.Query)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"schema": schema, "datarows": rows, "metadata": map[string]any{}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"query":    "service:api",
+		"metrics":  "percentile_rank:took_ms:500",
+		"group_by": "source",
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	rows := payload["rows"].([]any)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	col := "metric: percentile_rank(took_ms,500)"
+	want := map[string]any{"web": float64(75), "db": float64(20), "idle": nil}
+	for _, raw := range rows {
+		row := raw.(map[string]any)
+		source := row["grouping: source"].(string)
+		if row[col] != want[source] {
+			t.Errorf("source %s: expected %s=%v, got %v", source, col, want[source], row[col])
+		}
+	}
+}
+
+func TestApplyPercentileRanksEmptyQuery(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graylog.ScriptingAggregateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		queries = append(queries, req.Query)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"schema":   []map[string]any{{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"}},
+			"datarows": [][]any{{10}},
+			"metadata": map[string]any{},
+		})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	resp := &graylog.ScriptingTabularResponse{
+		Schema:   []graylog.ScriptingSchemaEntry{{ColumnType: "metric", Function: "count", Name: "metric: count()"}},
+		DataRows: [][]any{{float64(20)}},
+	}
+	rows := []map[string]any{{"metric: count()": float64(20)}}
+	ranks := []percentileRankMetric{{Field: "took_ms", Value: 500}}
+	if err := applyPercentileRanks(context.Background(), client, graylog.ScriptingAggregateRequest{}, ranks, resp, rows); err != nil {
+		t.Fatalf("applyPercentileRanks: %v", err)
+	}
+	if want := []string{"(*) AND _exists_:took_ms", "(*) AND took_ms:<500"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %q, want %q", queries, want)
+	}
+}
+
+func TestAggregateLogsHandlerPercentileRankDifferentGroups(t *testing.T) {
+	schema := []map[string]any{
+		{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+		{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+	}
+	tests := []struct {
+		name  string
+		below [][]any
+		want  map[string]any
+	}{
+		// The below query's top 2 groups include "batch" instead of "db", so
+		// db's share below the value is unknown.
+		{"cut below result", [][]any{{"web", 150}, {"batch", 90}}, map[string]any{"web": float64(75), "db": nil}},
+		// Under the group limit the below result lists every group, so a
+		// missing one has nothing below the value.
+		{"complete below result", [][]any{{"web", 150}}, map[string]any{"web": float64(75), "db": float64(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req graylog.ScriptingAggregateRequest
+				_ = json.NewDecoder(r.Body).Decode(&req)
+				rows := [][]any{{"web", 200}, {"db", 50}}
+				if strings.Contains(req.Query, "took_ms:<500") {
+					rows = tt.below
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{"schema": schema, "datarows": rows, "metadata": map[string]any{}})
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{
+				"query":       "*",
+				"metrics":     "percentile_rank:took_ms:500",
+				"group_by":    "source",
+				"group_limit": float64(2),
+			}
+			result, err := handler(context.Background(), req)
+			if err != nil || result.IsError {
+				t.Fatalf("unexpected result=%+v err=%v", result, err)
+			}
+
+			col := "metric: percentile_rank(took_ms,500)"
+			for _, raw := range decodeToolResultJSON(t, result)["rows"].([]any) {
+				row := raw.(map[string]any)
+				source := row["grouping: source"].(string)
+				if got, ok := row[col]; !ok || got != tt.want[source] {
+					t.Errorf("source %s: expected %s=%v, got %v", source, col, tt.want[source], got)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregateLogsHandlerPercentileRankWithRepeatedColumnNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req graylog.ScriptingAggregateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		grouping := map[string]any{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"}
+		var schema []map[string]any
+		var rows [][]any
+		switch {
+		case len(req.Metrics) > 1:
+			// Graylog names both percentiles alike and lists them before count().
+			percentile := map[string]any{"column_type": "metric", "type": "numeric", "function": "percentile", "field": "took_ms", "name": "metric: percentile(took_ms)"}
+			count := map[string]any{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"}
+			schema = []map[string]any{grouping, percentile, percentile, count}
+			rows = [][]any{{"web", 120, 480, 200}, {"db", 30, 90, 50}}
+		case strings.Contains(req.Query, "took_ms:<500"):
+			schema = []map[string]any{grouping, {"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"}}
+			rows = [][]any{{"web", 150}, {"db", 50}}
+		default:
+			schema = []map[string]any{grouping, {"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"}}
+			rows = [][]any{{"web", 200}, {"db", 50}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"schema": schema, "datarows": rows, "metadata": map[string]any{}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"query":              "*",
+		"metrics":            "percentile:took_ms:50,percentile:took_ms:99,count,percentile_rank:took_ms:500",
+		"group_by":           "source",
+		"include_percentage": true,
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	want := map[string]map[string]any{
+		"web": {"metric: percentile(took_ms)": float64(120), "metric: percentile(took_ms) #2": float64(480), "metric: percentile_rank(took_ms,500)": float64(75)},
+		"db":  {"metric: percentile(took_ms)": float64(30), "metric: percentile(took_ms) #2": float64(90), "metric: percentile_rank(took_ms,500)": float64(100)},
+	}
+	for _, raw := range payload["rows"].([]any) {
+		row := raw.(map[string]any)
+		source := row["grouping: source"].(string)
+		for col, v := range want[source] {
+			if row[col] != v {
+				t.Errorf("source %s: expected %s=%v, got %v", source, col, v, row[col])
+			}
+		}
+	}
+	// The percentages come from the first requested metric, the 50th percentile.
+	if payload["percentage_total"] != float64(150) {
+		t.Errorf("expected percentage_total 150, got %v", payload["percentage_total"])
+	}
+}
+
+func TestGroupsComplete(t *testing.T) {
+	resp := &graylog.ScriptingTabularResponse{
+		Schema: []graylog.ScriptingSchemaEntry{
+			{ColumnType: "grouping", Field: "source"},
+			{ColumnType: "grouping", Field: "level"},
+			{ColumnType: "metric", Function: "count"},
+		},
+		DataRows: [][]any{{"web", "ERROR", 3.0}, {"web", "INFO", 2.0}, {"db", "ERROR", 1.0}},
+	}
+	tests := []struct {
+		name    string
+		groupBy []graylog.ScriptingGrouping
+		want    bool
+	}{
+		{"under every limit", []graylog.ScriptingGrouping{{Field: "source", Limit: 3}, {Field: "level", Limit: 3}}, true},
+		{"top level at its limit", []graylog.ScriptingGrouping{{Field: "source", Limit: 2}, {Field: "level", Limit: 3}}, false},
+		{"one parent at its limit", []graylog.ScriptingGrouping{{Field: "source", Limit: 3}, {Field: "level", Limit: 2}}, false},
+		{"unknown limit", []graylog.ScriptingGrouping{{Field: "source"}, {Field: "level", Limit: 3}}, false},
+	}
+	for _, tt := range tests {
+		if got := groupsComplete(resp, tt.groupBy); got != tt.want {
+			t.Errorf("%s: groupsComplete = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseGroupByTimeBucket(t *testing.T) {
+	groups, err := parseGroupBy("time:1h, source", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []graylog.ScriptingGrouping{
+		{Field: "timestamp", TimeUnit: "1h"},
+		{Field: "source", Limit: 5},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("expected %+v, got %+v", want, groups)
+	}
+
+	for _, bad := range []string{"time:", "time:0h", "time:1x", "time:h", "time:1h,time:5m"} {
+		if _, err := parseGroupBy(bad, 5); err == nil {
+			t.Errorf("expected error for group_by %q", bad)
+		}
+	}
+}
+
+func TestAggregateLogsHandlerTimeBucketLabels(t *testing.T) {
+	var got graylog.ScriptingAggregateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"date","field":"timestamp","name":"grouping: timestamp"},
+				{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": [
+				["2024-01-15T10:00:00.000Z", "web", 12],
+				[1705316400000, "web", 7]
+			],
+			"metadata": {}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "time:1h,source"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if len(got.GroupBy) != 2 || got.GroupBy[0].TimeUnit != "1h" || got.GroupBy[0].Field != "timestamp" {
+		t.Fatalf("expected time grouping on timestamp first, got %+v", got.GroupBy)
+	}
+
+	rows := decodeToolResultJSON(t, result)["rows"].([]any)
+	wantBuckets := []string{"2024-01-15T10:00:00Z", "2024-01-15T11:00:00Z"}
+	for i, raw := range rows {
+		row := raw.(map[string]any)
+		if row["grouping: time(1h)"] != wantBuckets[i] {
+			t.Errorf("row %d: expected bucket %q, got %v", i, wantBuckets[i], row["grouping: time(1h)"])
+		}
+		if _, ok := row["grouping: timestamp"]; ok {
+			t.Errorf("row %d: raw timestamp column should be relabeled", i)
+		}
+		if row["grouping: source"] != "web" {
+			t.Errorf("row %d: expected source 'web', got %v", i, row["grouping: source"])
+		}
+	}
+}
+
+func TestParseMetricsMovingAvg(t *testing.T) {
+	metrics, derived, err := parseMetrics("avg:took_ms,moving_avg:3", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].Function != "avg" {
+		t.Fatalf("expected only avg as a Graylog metric, got %+v", metrics)
+	}
+	if !reflect.DeepEqual(derived.movingAvgWindows, []int{3}) {
+		t.Fatalf("expected a 3-bucket window, got %v", derived.movingAvgWindows)
+	}
+	for _, bad := range []string{"moving_avg", "moving_avg:0", "moving_avg:x", "moving_avg:took_ms:3"} {
+		if _, _, err := parseMetrics(bad, ""); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAggregateLogsHandlerMovingAverage(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		// Buckets arrive sorted by count, not time, and interleave two series.
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"date","field":"timestamp","name":"grouping: timestamp"},
+				{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": [
+				["2024-01-15T12:00:00.000Z", "web", 30],
+				["2024-01-15T11:00:00.000Z", "web", 20],
+				["2024-01-15T10:00:00.000Z", "db", 8],
+				["2024-01-15T10:00:00.000Z", "web", 10],
+				["2024-01-15T11:00:00.000Z", "db", 4]
+			],
+			"metadata": {}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	result := call(map[string]any{"query": "*", "metrics": "count,moving_avg:2", "group_by": "time:1h,source"})
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	col := "metric: moving_avg(count(),2)"
+	want := map[string]any{
+		"web 2024-01-15T10:00:00Z": nil,
+		"web 2024-01-15T11:00:00Z": float64(15),
+		"web 2024-01-15T12:00:00Z": float64(25),
+		"db 2024-01-15T10:00:00Z":  nil,
+		"db 2024-01-15T11:00:00Z":  float64(6),
+	}
+	for _, raw := range decodeToolResultJSON(t, result)["rows"].([]any) {
+		row := raw.(map[string]any)
+		key := fmt.Sprintf("%v %v", row["grouping: source"], row["grouping: time(1h)"])
+		if got, ok := row[col]; !ok || got != want[key] {
+			t.Errorf("%s: expected %s=%v, got %v", key, col, want[key], got)
+		}
+	}
+
+	requests = 0
+	result = call(map[string]any{"query": "*", "metrics": "count,moving_avg:2", "group_by": "source"})
+	if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "time bucket") {
+		t.Errorf("expected moving_avg without a time bucket to be rejected, got %+v", result.Content)
+	}
+	if requests != 0 {
+		t.Errorf("expected the check to run before calling Graylog, got %d requests", requests)
+	}
+}
+
+func TestAggregateLogsHandlerIncludePercentage(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics string
+		schema  []map[string]any
+		rows    [][]any
+		want    map[string]any
+	}{
+		{
+			name:    "count",
+			metrics: "count",
+			schema: []map[string]any{
+				{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+				{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+			},
+			rows: [][]any{{"web", 600}, {"db", 300}, {"cache", 100}},
+			want: map[string]any{"web": float64(60), "db": float64(30), "cache": float64(10)},
+		},
+		{
+			name:    "first metric not count",
+			metrics: "sum:bytes,count",
+			schema: []map[string]any{
+				{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+				{"column_type": "metric", "type": "numeric", "function": "sum", "field": "bytes", "name": "metric: sum(bytes)"},
+				{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+			},
+			rows: [][]any{{"web", 750, 1}, {"db", 250, 99}, {"cache", nil, 5}},
+			want: map[string]any{"web": float64(75), "db": float64(25), "cache": nil},
+		},
+		{
+			name:    "metrics reordered by Graylog",
+			metrics: "count,max:took_ms",
+			schema: []map[string]any{
+				{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+				{"column_type": "metric", "type": "numeric", "function": "max", "field": "took_ms", "name": "metric: max(took_ms)"},
+				{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+			},
+			rows: [][]any{{"web", 5, 750}, {"db", 900, 250}},
+			want: map[string]any{"web": float64(75), "db": float64(25)},
+		},
+		{
+			name:    "rollup row excluded",
+			metrics: "count",
+			schema: []map[string]any{
+				{"column_type": "grouping", "type": "string", "field": "source", "name": "grouping: source"},
+				{"column_type": "metric", "type": "numeric", "function": "count", "name": "metric: count()"},
+			},
+			rows: [][]any{{"web", 600}, {"db", 300}, {"cache", 100}, {1000}},
+			want: map[string]any{"web": float64(60), "db": float64(30), "cache": float64(10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{"schema": tt.schema, "datarows": tt.rows, "metadata": map[string]any{}})
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{
+				"query":              "*",
+				"metrics":            tt.metrics,
+				"group_by":           "source",
+				"include_percentage": true,
+			}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			if payload["percentage_total"] != float64(1000) {
+				t.Errorf("expected percentage_total 1000, got %v", payload["percentage_total"])
+			}
+			var sum float64
+			for _, raw := range payload["rows"].([]any) {
+				row := raw.(map[string]any)
+				source := row["grouping: source"].(string)
+				if row["percentage"] != tt.want[source] {
+					t.Errorf("source %s: expected percentage %v, got %v", source, tt.want[source], row["percentage"])
+				}
+				if p, ok := row["percentage"].(float64); ok {
+					sum += p
+				}
+			}
+			if math.Abs(sum-100) > 1e-9 {
+				t.Errorf("expected percentages to sum to 100, got %v", sum)
+			}
+		})
+	}
+}
+
+func TestAggregateToolsReportMissingScriptingAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"ApiError","message":"HTTP 404 Not Found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	getClient := func(_ context.Context) *graylog.Client { return client }
+	tests := []struct {
+		name    string
+		handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		args    map[string]any
+	}{
+		{name: "aggregate_logs", handler: aggregateLogsHandler(getClient, &config.Config{}), args: map[string]any{"query": "*", "metrics": "count", "group_by": "source"}},
+		{name: "field_values", handler: fieldValuesHandler(getClient, &config.Config{}), args: map[string]any{"field": "source"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := tt.handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			text := result.Content[0].(mcp.TextContent).Text
+			if !strings.HasPrefix(text, "[not_found] aggregation API not available") || !strings.Contains(text, "Graylog 5.2 or later") {
+				t.Errorf("expected version guidance, got %q", text)
+			}
+			if strings.Contains(text, "Graylog API error") {
+				t.Errorf("raw API error leaked into %q", text)
+			}
+		})
+	}
+}
+
+func TestAggregateLogsUnknownStreamIsNotAVersionProblem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"ApiError","message":"Stream 000000000000000000000bad not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "source", "stream_id": "000000000000000000000bad"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if !result.IsError || !strings.HasPrefix(text, "[not_found]") || !strings.Contains(text, "Stream 000000000000000000000bad not found") {
+		t.Errorf("expected the stream error, got %q", text)
+	}
+	if strings.Contains(text, "Graylog "+scriptingMinVersion) {
+		t.Errorf("an unknown stream must not be reported as a version problem: %q", text)
+	}
+}
+
+func TestTabularToRows(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      []graylog.ScriptingSchemaEntry
+		dataRows    [][]any
+		want        []map[string]any
+		wantSkipped int
+	}{
+		{
+			name: "subtotal and total rollup rows",
+			schema: []graylog.ScriptingSchemaEntry{
+				{ColumnType: "grouping", Field: "source", Name: "grouping: source"},
+				{ColumnType: "grouping", Field: "level", Name: "grouping: level"},
+				{ColumnType: "metric", Function: "count", Name: "metric: count()"},
+			},
+			dataRows: [][]any{{"web", "ERROR", float64(2)}, {"web", float64(2)}, {"db", "INFO", float64(5)}, {"db", float64(5)}, {float64(7)}},
+			want: []map[string]any{
+				{"grouping: source": "web", "grouping: level": "ERROR", "metric: count()": float64(2)},
+				{"grouping: source": "db", "grouping: level": "INFO", "metric: count()": float64(5)},
+			},
+			wantSkipped: 3,
+		},
+		{
+			name: "appended rollup row",
+			schema: []graylog.ScriptingSchemaEntry{
+				{ColumnType: "grouping", Field: "source", Name: "grouping: source"},
+				{ColumnType: "metric", Function: "count", Name: "metric: count()"},
+			},
+			dataRows: [][]any{{"web", float64(3)}, {nil, float64(1)}, {float64(4)}},
+			want: []map[string]any{
+				{"grouping: source": "web", "metric: count()": float64(3)},
+				{"grouping: source": nil, "metric: count()": float64(1)},
+			},
+			wantSkipped: 1,
+		},
+		{
+			name: "repeated and missing names",
+			schema: []graylog.ScriptingSchemaEntry{
+				{ColumnType: "grouping", Field: "source"},
+				{ColumnType: "metric", Function: "percentile", Field: "took_ms", Name: "metric: percentile(took_ms)"},
+				{ColumnType: "metric", Function: "percentile", Field: "took_ms", Name: "metric: percentile(took_ms)"},
+			},
+			dataRows: [][]any{{"web", float64(90), float64(250)}},
+			want: []map[string]any{
+				{"grouping: source": "web", "metric: percentile(took_ms)": float64(90), "metric: percentile(took_ms) #2": float64(250)},
+			},
+		},
+		{
+			name: "ungrouped totals are kept",
+			schema: []graylog.ScriptingSchemaEntry{
+				{ColumnType: "metric", Function: "count", Name: "metric: count()"},
+			},
+			dataRows: [][]any{{float64(42)}},
+			want:     []map[string]any{{"metric: count()": float64(42)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &graylog.ScriptingTabularResponse{Schema: tt.schema, DataRows: tt.dataRows}
+			rows, skipped := tabularToRows(resp)
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("skipped = %d, want %d", skipped, tt.wantSkipped)
+			}
+			if len(resp.DataRows) != len(rows) {
+				t.Errorf("resp.DataRows has %d rows, want it aligned with %d rows", len(resp.DataRows), len(rows))
+			}
+		})
+	}
+}
+
+func TestAggregateLogsHandlerNested(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+				{"column_type":"grouping","type":"string","field":"level","name":"grouping: level"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"},
+				{"column_type":"metric","type":"numeric","function":"avg","field":"took_ms","name":"metric: avg(took_ms)"}
+			],
+			"datarows": [
+				["web", "ERROR", 10, 120.5],
+				["web", "INFO", 90, 20],
+				["db", "ERROR", 3, 800],
+				["db", 4, 1, 5]
+			],
+			"metadata": {}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"query":    "*",
+		"metrics":  "count,avg:took_ms",
+		"group_by": "source,level",
+		"nested":   true,
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if _, ok := payload["rows"]; ok {
+		t.Error("nested output should replace flat rows")
+	}
+	if payload["total_rows"] != float64(4) {
+		t.Errorf("expected total_rows 4, got %v", payload["total_rows"])
+	}
+	wantLevels := []any{"grouping: source", "grouping: level"}
+	if !reflect.DeepEqual(payload["group_levels"], wantLevels) {
+		t.Errorf("expected group_levels %v, got %v", wantLevels, payload["group_levels"])
+	}
+	want := map[string]any{
+		"web": map[string]any{
+			"ERROR": map[string]any{"metric: count()": float64(10), "metric: avg(took_ms)": 120.5},
+			"INFO":  map[string]any{"metric: count()": float64(90), "metric: avg(took_ms)": float64(20)},
+		},
+		"db": map[string]any{
+			"ERROR": map[string]any{"metric: count()": float64(3), "metric: avg(took_ms)": float64(800)},
+			"4":     map[string]any{"metric: count()": float64(1), "metric: avg(took_ms)": float64(5)},
+		},
+	}
+	if !reflect.DeepEqual(payload["groups"], want) {
+		t.Fatalf("unexpected nested groups:\n got  %v\n want %v", payload["groups"], want)
+	}
+}
+
+func TestFitAggregateResultTrimsNestedGroups(t *testing.T) {
+	rows := make([]map[string]any, 200)
+	for i := range rows {
+		rows[i] = map[string]any{
+			"grouping: source":  fmt.Sprintf("host-%03d", i),
+			"grouping: level":   "ERROR",
+			"metric: count()":   float64(i),
+			"metric: avg(took)": 12.5,
+		}
+	}
+	result := map[string]any{"rows": rows, "total_rows": len(rows), "metadata": map[string]any{}}
+	levels := []string{"grouping: source", "grouping: level"}
+
+	res, err := fitAggregateResult(context.Background(), result, levels, 4000)
+	if err != nil {
+		t.Fatalf("fitAggregateResult returned error: %v", err)
+	}
+	text := res.Content[0].(mcp.TextContent).Text
+	if len(text) > 4000 {
+		t.Fatalf("result is %d bytes, over the limit", len(text))
+	}
+	payload := decodeToolResultJSON(t, res)
+	groups, _ := payload["groups"].(map[string]any)
+	if len(groups) == 0 || len(groups) >= len(rows) || payload["rows_truncated"] != true {
+		t.Fatalf("expected trimmed nested groups, got %s", text)
+	}
+	if _, ok := payload["rows"]; ok || payload["error"] != nil {
+		t.Errorf("expected nested data, not flat rows or the last resort: %s", text)
+	}
+	leaf := groups["host-000"].(map[string]any)["ERROR"]
+	if !reflect.DeepEqual(leaf, map[string]any{"metric: count()": float64(0), "metric: avg(took)": 12.5}) {
+		t.Errorf("unexpected leaf %v", leaf)
+	}
+}
+
+func TestAggregateLogsHandlerWithSample(t *testing.T) {
+	var mu sync.Mutex
+	var sampleQueries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/search/aggregate":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+				"schema": [
+					{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+					{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+				],
+				"datarows": [["web-1", 10], ["db \"primary\"", 3], [null, 1]],
+				"metadata": {}
+			}`))
+		case "/api/views/search/sync":
+			var body struct {
+				Queries []struct {
+					Query struct {
+						QueryString string `json:"query_string"`
+					} `json:"query"`
+					SearchTypes []struct {
+						Limit int `json:"limit"`
+					} `json:"search_types"`
+				} `json:"queries"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("failed to decode search request: %v", err)
+			}
+			q := body.Queries[0].Query.QueryString
+			if limit := body.Queries[0].SearchTypes[0].Limit; limit != 1 {
+				t.Errorf("expected sample search limit 1, got %d", limit)
+			}
+			mu.Lock()
+			sampleQueries = append(sampleQueries, q)
+			mu.Unlock()
+			writeViewsSearchResponse(w, 1, []testLogMessage{{
+				ID: "sample-for-" + q, Timestamp: "2024-01-01T00:00:00.000Z", Source: "src", Message: "example", Index: "idx",
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	run := func(groupLimit float64) map[string]any {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{
+			"query":       "level:ERROR",
+			"metrics":     "count",
+			"group_by":    "source",
+			"group_limit": groupLimit,
+			"with_sample": true,
+		}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %+v", result.Content)
+		}
+		return decodeToolResultJSON(t, result)
+	}
+
+	payload := run(10)
+	wantQueries := []string{
+		`(level:ERROR) AND source:web\-1`,
+		`(level:ERROR) AND source:"db \"primary\""`,
+		`(level:ERROR) AND NOT _exists_:source`,
+	}
+	rows := payload["rows"].([]any)
+	for i, raw := range rows {
+		sample, ok := raw.(map[string]any)["sample"].(map[string]any)
+		if !ok {
+			t.Fatalf("row %d has no sample: %v", i, raw)
+		}
+		if want := "sample-for-" + wantQueries[i]; sample["_id"] != want {
+			t.Errorf("row %d sample _id = %v, want %q", i, sample["_id"], want)
+		}
+	}
+	slices.Sort(sampleQueries)
+	slices.Sort(wantQueries)
+	if !reflect.DeepEqual(sampleQueries, wantQueries) {
+		t.Errorf("sample queries = %q, want %q", sampleQueries, wantQueries)
+	}
+	if _, ok := payload["sample_note"]; ok {
+		t.Errorf("no sample_note expected when every row is sampled, got %v", payload["sample_note"])
+	}
+
+	// Follow-ups are bounded by group_limit.
+	sampleQueries = nil
+	payload = run(2)
+	if len(sampleQueries) != 2 {
+		t.Errorf("expected 2 sample searches with group_limit 2, got %d", len(sampleQueries))
+	}
+	if _, ok := payload["rows"].([]any)[2].(map[string]any)["sample"]; ok {
+		t.Error("row beyond group_limit should not be sampled")
+	}
+	if payload["sample_note"] == nil {
+		t.Error("expected sample_note when rows were left unsampled")
+	}
+}
+
+func TestAggregateLogsHandlerWithSampleRejectsTimeBucket(t *testing.T) {
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "time:1h,source", "with_sample": true}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error for with_sample with a time bucket")
+	}
+}
+
+// scriptExceptionBody is the 400 Elasticsearch returns for a terms
+// aggregation on an analyzed text field.
+const scriptExceptionBody = `{"type":"ApiError","message":"script_exception: Fielddata is disabled on text fields by default"}`
+
+func TestAggregateLogsHandlerKeywordSubField(t *testing.T) {
+	var grouped []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/views/fields":
+			_, _ = w.Write([]byte(`[
+				{"name":"source","type":{"type":"string","properties":["enumerable"]}},
+				{"name":"user_agent","type":{"type":"string","properties":["full-text-search"]}},
+				{"name":"user_agent.keyword","type":{"type":"string","properties":["enumerable"]}}
+			]`))
+		case "/api/search/aggregate":
+			var req graylog.ScriptingAggregateRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			grouped = append(grouped, req.GroupBy[1].Field)
+			if req.GroupBy[1].Field == "user_agent" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(scriptExceptionBody))
+				return
+			}
+			_, _ = w.Write([]byte(`{
+				"schema": [
+					{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+					{"column_type":"grouping","type":"string","field":"user_agent.keyword","name":"grouping: user_agent.keyword"},
+					{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+				],
+				"datarows": [["web", "curl/8.4.0", 3]],
+				"metadata": {}
+			}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "source,user_agent"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if want := []string{"user_agent", "user_agent.keyword"}; !reflect.DeepEqual(grouped, want) {
+		t.Errorf("expected a retry on the keyword sub-field, got group_by fields %v", grouped)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if want := map[string]any{"user_agent": "user_agent.keyword"}; !reflect.DeepEqual(payload["keyword_fields"], want) {
+		t.Errorf("expected keyword_fields %v, got %v", want, payload["keyword_fields"])
+	}
+	row := payload["rows"].([]any)[0].(map[string]any)
+	if row["grouping: user_agent.keyword"] != "curl/8.4.0" {
+		t.Errorf("unexpected row %v", row)
+	}
+}
+
+func TestAggregateLogsHandlerAnalyzedFieldSuggestion(t *testing.T) {
+	aggregateCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/views/fields":
+			_, _ = w.Write([]byte(`[{"name":"request_path","type":{"type":"string","properties":["full-text-search"]}}]`))
+		case "/api/search/aggregate":
+			aggregateCalls++
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(scriptExceptionBody))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "request_path"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error")
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if !strings.Contains(text, "'request_path' is an analyzed text field") || !strings.Contains(text, "Try 'request_path.keyword'") {
+		t.Errorf("expected a .keyword suggestion, got %q", text)
+	}
+	if aggregateCalls != 1 {
+		t.Errorf("expected no retry without a keyword sub-field, got %d aggregate calls", aggregateCalls)
+	}
+}
+
+func TestAggregateLogsHandlerEffectiveRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": [["web", 3]],
+			"metadata": {"effective_timerange": {"type":"absolute","from":"2024-01-15T10:00:00.000+01:00","to":"2024-01-15T11:00:00.000+01:00"}}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "source", "range": float64(3600)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	payload := decodeToolResultJSON(t, result)
+	want := map[string]any{"from": "2024-01-15T09:00:00.000Z", "to": "2024-01-15T10:00:00.000Z"}
+	if !reflect.DeepEqual(payload["effective_range"], want) {
+		t.Errorf("expected effective_range %v, got %v", want, payload["effective_range"])
+	}
+	// The raw metadata is still passed through.
+	if _, ok := payload["metadata"].(map[string]any)["effective_timerange"]; !ok {
+		t.Errorf("expected metadata.effective_timerange, got %v", payload["metadata"])
+	}
+}
+
+func TestAggregateLogsHandlerGroupByFieldLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"schema":[{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}],"datarows":[],"metadata":{}}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	call := func(cfg *config.Config, args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, cfg)(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	result := call(&config.Config{}, map[string]any{"query": "*", "metrics": "count", "group_by": "source,level,host,time:1h"})
+	if !result.IsError {
+		t.Fatal("expected four dimensions to exceed the default limit of 3")
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "at most 3") || !strings.Contains(text, "fewer fields") {
+		t.Errorf("unexpected error %q", text)
+	}
+	if requests != 0 {
+		t.Errorf("expected the guard to run before calling Graylog, got %d requests", requests)
+	}
+
+	result = call(&config.Config{MaxGroupByFields: 4}, map[string]any{"query": "*", "metrics": "count", "group_by": "source,level,host,time:1h"})
+	if result.IsError {
+		t.Fatalf("expected GRAYLOG_MAX_GROUP_BY_FIELDS=4 to allow four dimensions: %+v", result.Content)
+	}
+}
+
+func TestAggregateLogsHandlerCrossProductWarning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"schema":[{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}],"datarows":[],"metadata":{}}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	tests := []struct {
+		args        map[string]any
+		wantWarning string
+	}{
+		{map[string]any{"query": "*", "metrics": "count", "group_by": "source,level,host", "group_limit": float64(50)}, "up to 125000 rows"},
+		// 10 x 10 x 10 stays under the threshold; time buckets aren't counted.
+		{map[string]any{"query": "*", "metrics": "count", "group_by": "source,level,time:1m"}, ""},
+		{map[string]any{"query": "*", "metrics": "count", "group_by": "source,level,host"}, ""},
+	}
+	for _, tt := range tests {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = tt.args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %+v", result.Content)
+		}
+		warning, _ := decodeToolResultJSON(t, result)["warning"].(string)
+		if tt.wantWarning == "" && warning != "" {
+			t.Errorf("group_by %v: unexpected warning %q", tt.args["group_by"], warning)
+		}
+		if tt.wantWarning != "" && !strings.Contains(warning, tt.wantWarning) {
+			t.Errorf("group_by %v: expected warning containing %q, got %q", tt.args["group_by"], tt.wantWarning, warning)
+		}
+	}
+}
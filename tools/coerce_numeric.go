@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// coerceNumericFields replaces string values of fields in m with numbers when
+// they parse as an integer or float. Non-numeric strings, non-string values and
+// core fields are left untouched.
+func coerceNumericFields(m map[string]any, fields []string) {
+	for _, f := range fields {
+		if coreMessageFields[f] {
+			continue
+		}
+		s, ok := m[f].(string)
+		if !ok {
+			continue
+		}
+		if n, ok := parseNumeric(s); ok {
+			m[f] = n
+		}
+	}
+}
+
+// parseNumeric parses s as an int64, falling back to a finite float64.
+func parseNumeric(s string) (any, bool) {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	// NaN and Inf parse but can't be encoded as JSON numbers.
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return f, true
+}
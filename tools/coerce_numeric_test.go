@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestCoerceNumericFields(t *testing.T) {
+	m := map[string]any{
+		"took_ms": "42",
+		"ratio":   " 0.75 ",
+		"status":  "OK",
+		"nan":     "NaN",
+		"count":   float64(3),
+		"message": "123",
+		"other":   "7",
+	}
+	coerceNumericFields(m, []string{"took_ms", "ratio", "status", "nan", "count", "message", "missing"})
+
+	want := map[string]any{
+		"took_ms": int64(42),
+		"ratio":   0.75,
+		"status":  "OK",
+		"nan":     "NaN",
+		"count":   float64(3),
+		"message": "123",
+		"other":   "7",
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("expected %v, got %v", want, m)
+	}
+}
+
+func TestExecuteSearchCoerceNumeric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "api", Message: "done", Index: "idx",
+			Extra: map[string]any{"took_ms": "1500", "ratio": "1e-3", "status": "n/a"},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	result, err := executeSearch(context.Background(), client, graylog.SearchParams{Query: "*", Limit: 10},
+		searchOptions{coerceNumeric: []string{"took_ms", "ratio", "status"}}, 50000)
+	if err != nil || result.IsError {
+		t.Fatalf("unexpected failure: %v %+v", err, result)
+	}
+	msg := decodeToolResultJSON(t, result)["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if msg["took_ms"] != float64(1500) || msg["ratio"] != 0.001 {
+		t.Errorf("expected numeric took_ms and ratio, got %v (%T) and %v (%T)", msg["took_ms"], msg["took_ms"], msg["ratio"], msg["ratio"])
+	}
+	if msg["status"] != "n/a" {
+		t.Errorf("expected non-numeric status untouched, got %v", msg["status"])
+	}
+}
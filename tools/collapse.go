@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"encoding/json"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// collapseByField keeps the first message for each distinct value of field,
+// in input order, and returns how many messages shared each kept value.
+// Messages without the field collapse into one group of their own.
+func collapseByField(messages []graylog.MessageWrapper, field string) ([]graylog.MessageWrapper, []int) {
+	index := make(map[string]int)
+	var kept []graylog.MessageWrapper
+	var counts []int
+	for _, mw := range messages {
+		key := collapseKey(mw.Message, field)
+		if i, ok := index[key]; ok {
+			counts[i]++
+			continue
+		}
+		index[key] = len(kept)
+		kept = append(kept, mw)
+		counts = append(counts, 1)
+	}
+	return kept, counts
+}
+
+// collapseKey identifies m's value of field. Values are keyed by their JSON
+// encoding so "1" and 1 stay distinct; a missing field gets the empty key,
+// which no JSON encoding produces.
+func collapseKey(m graylog.Message, field string) string {
+	v, ok := messageFieldValue(m, field)
+	if !ok {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// messageFieldValue returns m's value of field, core fields included, and
+// whether m has it.
+func messageFieldValue(m graylog.Message, field string) (any, bool) {
+	switch field {
+	case "_id":
+		return m.ID, true
+	case "timestamp":
+		return m.Timestamp, true
+	case "source":
+		return m.Source, true
+	case "message":
+		return m.Message, true
+	}
+	v, ok := m.Extra[field]
+	return v, ok
+}
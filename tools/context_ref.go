@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// contextRef returns the compact token get_log_context accepts as 'ref' for
+// a message: unpadded URL-safe base64 of "index:_id". Index names can't
+// contain ':', so the first one separates the two. It returns "" if either
+// part is unknown.
+func contextRef(index, messageID string) string {
+	if index == "" || messageID == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(index + ":" + messageID))
+}
+
+var errInvalidContextRef = errors.New("'ref' is not a valid context_ref token; pass the context_ref from a search_logs result")
+
+// parseContextRef reverses contextRef.
+func parseContextRef(ref string) (index, messageID string, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(ref, "="))
+	if err != nil {
+		return "", "", errInvalidContextRef
+	}
+	index, messageID, ok := strings.Cut(string(raw), ":")
+	if !ok || index == "" || messageID == "" {
+		return "", "", errInvalidContextRef
+	}
+	return index, messageID, nil
+}
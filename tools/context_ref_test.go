@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestContextRefRoundTrip(t *testing.T) {
+	tests := []struct{ index, id string }{
+		{"graylog_42", "01f2a3b4-c5d6-11ee-9a1b-0242ac120002"},
+		{"graylog_0", "id:with:colons"},
+		{"a", "b"},
+	}
+	for _, tt := range tests {
+		ref := contextRef(tt.index, tt.id)
+		index, id, err := parseContextRef(ref)
+		if err != nil {
+			t.Fatalf("parseContextRef(%q): %v", ref, err)
+		}
+		if index != tt.index || id != tt.id {
+			t.Errorf("round trip of %s/%s gave %s/%s", tt.index, tt.id, index, id)
+		}
+	}
+
+	if ref := contextRef("", "id"); ref != "" {
+		t.Errorf("expected no ref without an index, got %q", ref)
+	}
+	for _, bad := range []string{"not base64!", contextRef("idx", "x")[:2], "bm9jb2xvbg"} {
+		if _, _, err := parseContextRef(bad); err == nil {
+			t.Errorf("expected error for ref %q", bad)
+		}
+	}
+}
+
+func TestSearchLogsHandlerContextRef(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 2, []testLogMessage{
+			{ID: "id-1", Timestamp: "2024-01-01T00:00:01.000Z", Source: "web", Message: "boom", Index: "graylog_7"},
+			{ID: "id-2", Timestamp: "2024-01-01T00:00:00.000Z", Source: "web", Message: "boom", Index: "graylog_6"},
+		})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	for _, tt := range []struct {
+		name string
+		key  string
+		args map[string]any
+	}{
+		{name: "plain", key: "messages", args: map[string]any{"query": "*"}},
+		{name: "dedup", key: "deduplicated", args: map[string]any{"query": "*", "deduplicate": true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			first := decodeToolResultJSON(t, result)[tt.key].([]any)[0].(map[string]any)
+			ref, _ := first["context_ref"].(string)
+			index, id, err := parseContextRef(ref)
+			if err != nil {
+				t.Fatalf("context_ref %q: %v", ref, err)
+			}
+			if index != "graylog_7" || id != "id-1" {
+				t.Errorf("context_ref decodes to %s/%s, want graylog_7/id-1", index, id)
+			}
+		})
+	}
+}
+
+func TestGetLogContextRef(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/messages/graylog_7/id-1":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"message": map[string]any{
+					"fields": map[string]any{
+						"_id":       "id-1",
+						"timestamp": "2024-01-01T00:00:00.000Z",
+						"source":    "web",
+						"message":   "target message",
+					},
+				},
+				"index": "graylog_7",
+			})
+		case "/api/views/search/sync":
+			writeViewsSearchResponse(w, 0, nil)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"ref": contextRef("graylog_7", "id-1")}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	target := decodeToolResultJSON(t, result)["target_message"].(map[string]any)["message"].(map[string]any)
+	if target["_id"] != "id-1" {
+		t.Errorf("expected target id-1, got %v", target["_id"])
+	}
+
+	req.Params.Arguments = map[string]any{"ref": contextRef("graylog_7", "id-1"), "index": "graylog_7"}
+	if result, _ := handler(context.Background(), req); !result.IsError {
+		t.Error("expected error when 'ref' is combined with 'index'")
+	}
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// errorClass tells the calling agent what to do about a failed tool call. It
+// is rendered as a "[class] " prefix on the error text.
+type errorClass string
+
+const (
+	// errRetryable: timeouts, rate limiting, 5xx and connection failures.
+	// The same call may succeed later.
+	errRetryable errorClass = "retryable"
+	// errInvalidInput: the arguments or query are wrong; retrying as-is won't help.
+	errInvalidInput errorClass = "invalid_input"
+	// errAuth: credentials are missing, rejected or lack permission.
+	errAuth errorClass = "auth"
+	// errNotFound: the referenced stream, message or index doesn't exist.
+	errNotFound errorClass = "not_found"
+	// errInternal: anything else, e.g. an unparseable Graylog response.
+	errInternal errorClass = "internal"
+)
+
+// classifyError maps an error returned by the Graylog client to its class.
+func classifyError(err error) errorClass {
+	var rateErr *graylog.RateLimitError
+	if errors.As(err, &rateErr) {
+		return errRetryable
+	}
+	var apiErr *graylog.APIError
+	if errors.As(err, &apiErr) {
+		return classifyStatus(apiErr.StatusCode)
+	}
+	if errors.Is(err, graylog.ErrResponseTooLarge) {
+		return errInvalidInput
+	}
+	if errors.Is(err, graylog.ErrBackendUnavailable) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return errRetryable
+	}
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return errRetryable
+	}
+	return errInternal
+}
+
+// classifyStatus maps a non-2xx Graylog HTTP status to an error class.
+func classifyStatus(status int) errorClass {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return errAuth
+	case status == http.StatusNotFound:
+		return errNotFound
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500:
+		return errRetryable
+	case status >= 400:
+		return errInvalidInput
+	default:
+		return errInternal
+	}
+}
+
+// classifiedError builds a tool error whose text starts with "[class] ".
+func classifiedError(class errorClass, msg string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: "[" + string(class) + "] " + msg,
+			},
+		},
+	}
+}
+
+// graylogError reports a failed Graylog call. API errors carry their own
+// status and path and are shown as is; anything else is prefixed with action.
+func graylogError(action string, err error) *mcp.CallToolResult {
+	msg := action + ": " + err.Error()
+	if apiErr, ok := err.(*graylog.APIError); ok {
+		msg = apiErr.Error()
+	}
+	return classifiedError(classifyError(err), msg)
+}
+
+// scriptingMinVersion is the first Graylog release with the Scripting API
+// (POST /api/search/aggregate).
+const scriptingMinVersion = "5.2"
+
+// aggregateError is graylogError for Scripting API aggregations. It turns
+// graylog.ErrAggregateUnavailable, a 404 from a Graylog server that predates
+// the endpoint, into guidance instead of the raw API error.
+func aggregateError(action string, err error) *mcp.CallToolResult {
+	if errors.Is(err, graylog.ErrAggregateUnavailable) {
+		return classifiedError(errNotFound, "aggregation API not available on this Graylog version "+
+			"(POST /api/search/aggregate returned 404); aggregations require Graylog "+scriptingMinVersion+" or later. "+
+			"Use search_logs (e.g. with deduplicate or collapse_field) to summarize messages instead.")
+	}
+	return graylogError(action, err)
+}
+
+// noCredentialsError is returned when no Graylog client could be resolved
+// for the request.
+func noCredentialsError() *mcp.CallToolResult {
+	return classifiedError(errAuth, "no Graylog credentials: Authorization header required")
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{name: "400 bad query", err: &graylog.APIError{StatusCode: 400}, want: errInvalidInput},
+		{name: "422", err: &graylog.APIError{StatusCode: 422}, want: errInvalidInput},
+		{name: "401", err: &graylog.APIError{StatusCode: 401}, want: errAuth},
+		{name: "403", err: &graylog.APIError{StatusCode: 403}, want: errAuth},
+		{name: "404", err: &graylog.APIError{StatusCode: 404}, want: errNotFound},
+		{name: "408", err: &graylog.APIError{StatusCode: 408}, want: errRetryable},
+		{name: "500", err: &graylog.APIError{StatusCode: 500}, want: errRetryable},
+		{name: "503", err: &graylog.APIError{StatusCode: 503}, want: errRetryable},
+		{name: "rate limited", err: &graylog.RateLimitError{APIError: &graylog.APIError{StatusCode: 429}}, want: errRetryable},
+		{name: "wrapped api error", err: fmt.Errorf("percentiles: %w", &graylog.APIError{StatusCode: 404}), want: errNotFound},
+		{name: "deadline", err: fmt.Errorf("executing request: %w", context.DeadlineExceeded), want: errRetryable},
+		{name: "connection refused", err: fmt.Errorf("executing request: %w", &url.Error{Op: "Post", URL: "http://x", Err: syscall.ECONNREFUSED}), want: errRetryable},
+		{name: "breaker open", err: graylog.ErrBackendUnavailable, want: errRetryable},
+		{name: "response too large", err: fmt.Errorf("%w of 10 bytes", graylog.ErrResponseTooLarge), want: errInvalidInput},
+		{name: "decode failure", err: errors.New("parsing response: unexpected EOF"), want: errInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Fatalf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorsAreClassified(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		args       map[string]any
+		noClient   bool
+		wantPrefix string
+	}{
+		{name: "upstream 503", status: http.StatusServiceUnavailable, args: map[string]any{"query": "*"}, wantPrefix: "[retryable] Graylog API error: status=503"},
+		{name: "upstream 400", status: http.StatusBadRequest, args: map[string]any{"query": "level:("}, wantPrefix: "[invalid_input] Graylog API error: status=400"},
+		{name: "upstream 403", status: http.StatusForbidden, args: map[string]any{"query": "*"}, wantPrefix: "[auth] "},
+		{name: "local validation", args: map[string]any{"query": "*", "limit": -1}, wantPrefix: "[invalid_input] "},
+		{name: "no credentials", args: map[string]any{"query": "*"}, noClient: true, wantPrefix: "[auth] no Graylog credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"message":"boom"}`))
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client {
+				if tt.noClient {
+					return nil
+				}
+				return client
+			}, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			if text := result.Content[0].(mcp.TextContent).Text; !strings.HasPrefix(text, tt.wantPrefix) {
+				t.Fatalf("expected error starting with %q, got %q", tt.wantPrefix, text)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// expandJSONFields parses each named field holding a JSON object string and
+// replaces it in Extra with its flattened keys, prefixed by the field name
+// (payload → payload.user.id). Values that are not JSON objects are left as-is.
+// It returns the set of dotted keys added, grouped by source field.
+func expandJSONFields(messages []graylog.MessageWrapper, fields []string) map[string][]string {
+	added := make(map[string]map[string]struct{})
+	for i := range messages {
+		extra := messages[i].Message.Extra
+		if extra == nil {
+			continue
+		}
+		for _, field := range fields {
+			raw, ok := extra[field].(string)
+			if !ok {
+				continue
+			}
+			var nested map[string]any
+			if err := json.Unmarshal([]byte(raw), &nested); err != nil || nested == nil {
+				continue
+			}
+			delete(extra, field)
+			flattenInto(extra, field, nested)
+			if added[field] == nil {
+				added[field] = make(map[string]struct{})
+			}
+			for k := range extra {
+				if strings.HasPrefix(k, field+".") {
+					added[field][k] = struct{}{}
+				}
+			}
+		}
+	}
+
+	result := make(map[string][]string, len(added))
+	for field, keys := range added {
+		list := make([]string, 0, len(keys))
+		for k := range keys {
+			list = append(list, k)
+		}
+		sort.Strings(list)
+		result[field] = list
+	}
+	return result
+}
+
+// flattenInto writes nested into dst using dotted keys under prefix.
+// Arrays and scalars become leaf values.
+func flattenInto(dst map[string]any, prefix string, nested map[string]any) {
+	for k, v := range nested {
+		key := prefix + "." + k
+		if m, ok := v.(map[string]any); ok && len(m) > 0 {
+			flattenInto(dst, key, m)
+			continue
+		}
+		dst[key] = v
+	}
+}
+
+// expandedFieldsFor returns the dotted keys expanded from any field the
+// filter selects that the filter would not already keep on its own.
+func expandedFieldsFor(filter *graylog.FieldFilter, expanded map[string][]string) []string {
+	var keys []string
+	for src, srcKeys := range expanded {
+		if !filter.Match(src) {
+			continue
+		}
+		for _, k := range srcKeys {
+			if !filter.Match(k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
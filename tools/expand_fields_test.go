@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestExpandJSONFields(t *testing.T) {
+	messages := []graylog.MessageWrapper{
+		{Message: graylog.Message{ID: "1", Extra: map[string]any{
+			"payload": `{"user":{"id":42,"name":"bob"},"tags":["a","b"],"ok":true}`,
+			"level":   "INFO",
+		}}},
+		{Message: graylog.Message{ID: "2", Extra: map[string]any{
+			"payload": "not json at all",
+		}}},
+		{Message: graylog.Message{ID: "3", Extra: map[string]any{
+			"payload": `{"broken": `,
+		}}},
+		{Message: graylog.Message{ID: "4", Extra: map[string]any{
+			"payload": `[1,2,3]`,
+		}}},
+		{Message: graylog.Message{ID: "5", Extra: map[string]any{
+			"payload": float64(7),
+		}}},
+	}
+
+	added := expandJSONFields(messages, []string{"payload"})
+
+	got := messages[0].Message.Extra
+	want := map[string]any{
+		"payload.user.id":   float64(42),
+		"payload.user.name": "bob",
+		"payload.tags":      []any{"a", "b"},
+		"payload.ok":        true,
+		"level":             "INFO",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected expanded fields:\n got  %#v\n want %#v", got, want)
+	}
+
+	wantAdded := []string{"payload.ok", "payload.tags", "payload.user.id", "payload.user.name"}
+	if !reflect.DeepEqual(added["payload"], wantAdded) {
+		t.Fatalf("expected added keys %v, got %v", wantAdded, added["payload"])
+	}
+
+	// Non-JSON, malformed, non-object and non-string values are left untouched.
+	if messages[1].Message.Extra["payload"] != "not json at all" {
+		t.Errorf("plain string should be left as-is, got %v", messages[1].Message.Extra)
+	}
+	if messages[2].Message.Extra["payload"] != `{"broken": ` {
+		t.Errorf("malformed JSON should be left as-is, got %v", messages[2].Message.Extra)
+	}
+	if messages[3].Message.Extra["payload"] != `[1,2,3]` {
+		t.Errorf("JSON array should be left as-is, got %v", messages[3].Message.Extra)
+	}
+	if messages[4].Message.Extra["payload"] != float64(7) {
+		t.Errorf("non-string value should be left as-is, got %v", messages[4].Message.Extra)
+	}
+}
+
+func TestExecuteSearchExpandFieldsKeepsRequestedSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Message: "hello", Index: "idx",
+			Extra: map[string]any{"payload": `{"user":{"id":"u-1"}}`, "level": "INFO"},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	result, err := executeSearch(context.Background(), client, graylog.SearchParams{
+		Query:  "*",
+		Limit:  10,
+		Fields: "message,payload",
+	}, searchOptions{expandFields: []string{"payload"}}, 50000)
+	if err != nil {
+		t.Fatalf("executeSearch returned error: %v", err)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	msg := payload["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if msg["payload.user.id"] != "u-1" {
+		t.Fatalf("expected payload.user.id=u-1, got %v", msg)
+	}
+	if _, exists := msg["payload"]; exists {
+		t.Fatal("expanded source field should be replaced by its dotted keys")
+	}
+	if _, exists := msg["level"]; exists {
+		t.Fatal("level should still be filtered out by fields")
+	}
+}
@@ -0,0 +1,270 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	exportDefaultMaxRows = 10000
+	exportPageSize       = 1000
+	exportDefaultSort    = "timestamp:asc"
+)
+
+// exportDefaultCSVColumns are written when format is csv and no fields are given,
+// since a CSV header can't be derived from messages that haven't been fetched yet.
+var exportDefaultCSVColumns = []string{"timestamp", "source", "message"}
+
+// errExportCapReached stops SearchStreamPages once the row cap is hit.
+var errExportCapReached = errors.New("export row cap reached")
+
+func exportLogsTool() mcp.Tool {
+	return mcp.NewTool("export_logs",
+		mcp.WithDescription("Export every message matching a query to a local NDJSON or CSV file, for audits that need the full result set rather than a preview. Pages through results up to a hard row cap and returns the file path, row count and byte size instead of the data."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Lucene query string (e.g. 'level:ERROR AND service:auth')"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'ndjson' (default, one JSON object per line) or 'csv'"),
+		),
+		mcp.WithString("fields",
+			mcp.Description("Comma-separated fields to export. With csv these are the columns (default: 'timestamp,source,message'); with ndjson core fields are always included and '*' globs and '-' exclusions are supported."),
+		),
+		mcp.WithNumber("max_rows",
+			mcp.Description("Maximum number of messages to export (default and upper bound: the server's GRAYLOG_EXPORT_MAX_ROWS)"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+		mcp.WithString("sort",
+			mcp.Description("Sort order as 'field:asc' or 'field:desc' (default: 'timestamp:asc')"),
+		),
+		redactParam(),
+	)
+}
+
+func exportLogsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			return toolError("'query' parameter is required"), nil
+		}
+
+		format := strings.ToLower(getStringParam(args, "format"))
+		if format == "" {
+			format = "ndjson"
+		}
+		if format != "ndjson" && format != "csv" {
+			return toolError("'format' must be 'ndjson' or 'csv'"), nil
+		}
+
+		tr, err := parseTimeRange(args, 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		hardCap := cfg.ExportMaxRows
+		if hardCap <= 0 {
+			hardCap = exportDefaultMaxRows
+		}
+		maxRows, err := getStrictNonNegativeIntParam(args, "max_rows", hardCap)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if maxRows < 1 || maxRows > hardCap {
+			maxRows = hardCap
+		}
+
+		sort := getStringParam(args, "sort")
+		if sort == "" {
+			sort = exportDefaultSort
+		}
+		fields := getCommaListParam(args, "fields")
+		if format == "csv" && graylog.NewFieldFilter(fields).HasPatterns() {
+			return toolError("csv columns must be exact field names; globs and '-' exclusions need format 'ndjson'"), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+
+		params := graylog.SearchParams{
+			Query:     query,
+			Limit:     min(exportPageSize, maxRows),
+			Sort:      sort,
+			Fields:    strings.Join(fields, ","),
+			StreamIDs: getStreamIDsParam(args, cfg),
+		}
+		tr.applyTo(&params)
+		if format == "csv" && len(fields) == 0 {
+			fields = exportDefaultCSVColumns
+		}
+
+		res, err := exportMessages(ctx, c, params, format, fields, maxRows, getRedactPatterns(args, cfg), newProgressReporter(ctx, request))
+		if err != nil {
+			return graylogError("Export failed", err), nil
+		}
+
+		result := map[string]any{
+			"path":      res.path,
+			"format":    format,
+			"rows":      res.rows,
+			"bytes":     res.bytes,
+			"truncated": res.truncated,
+		}
+		if res.truncated {
+			result["note"] = fmt.Sprintf("export stopped at max_rows=%d; narrow the time range or query, or raise GRAYLOG_EXPORT_MAX_ROWS", maxRows)
+		}
+		return toolSuccess(result), nil
+	}
+}
+
+type exportResult struct {
+	path      string
+	rows      int
+	bytes     int64
+	truncated bool
+}
+
+// exportMessages streams every message matching params into a new temp file.
+// The file is removed if anything fails, including ctx cancellation, so a
+// partial export is never left behind. Messages are scrubbed with redact
+// before they are written. progress is told after each page.
+// Paging stops at the page that reaches maxRows, so no page starts at or past
+// the cap, and truncated is Graylog's total exceeding it.
+func exportMessages(ctx context.Context, c *graylog.Client, params graylog.SearchParams, format string, fields []string, maxRows int, redact []*regexp.Regexp, progress progressReporter) (res exportResult, err error) {
+	f, err := os.CreateTemp("", "graylog-export-*."+format)
+	if err != nil {
+		return res, fmt.Errorf("creating export file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	buf := bufio.NewWriter(f)
+	write, flush, err := newExportWriter(buf, format, fields)
+	if err != nil {
+		return res, err
+	}
+
+	onPage := func(_, total int) error {
+		shown := min(total, maxRows)
+		progress.report(float64(res.rows), float64(shown), fmt.Sprintf("exported %d of %d messages", res.rows, shown))
+		if res.rows >= maxRows {
+			res.truncated = total > maxRows
+			return errExportCapReached
+		}
+		return nil
+	}
+	err = c.SearchStreamPages(ctx, params, func(mw graylog.MessageWrapper) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if res.rows >= maxRows {
+			return nil
+		}
+		res.rows++
+		redactMessage(&mw.Message, redact)
+		return write(mw.Message)
+	}, onPage)
+	if errors.Is(err, errExportCapReached) {
+		err = nil
+	}
+	if err != nil {
+		return res, err
+	}
+	if err = flush(); err != nil {
+		return res, err
+	}
+	if err = buf.Flush(); err != nil {
+		return res, fmt.Errorf("writing export file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return res, err
+	}
+	if err = f.Close(); err != nil {
+		return res, fmt.Errorf("closing export file: %w", err)
+	}
+	res.path = f.Name()
+	res.bytes = info.Size()
+	return res, nil
+}
+
+// newExportWriter returns a per-message write func for format and a flush
+// func to call once all messages are written.
+func newExportWriter(w io.Writer, format string, fields []string) (write func(graylog.Message) error, flush func() error, err error) {
+	if format == "ndjson" {
+		enc := json.NewEncoder(w)
+		filter := graylog.NewFieldFilter(fields)
+		write = func(m graylog.Message) error {
+			return enc.Encode(m.FilteredMap(filter))
+		}
+		return write, func() error { return nil }, nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return nil, nil, err
+	}
+	write = func(m graylog.Message) error {
+		values := m.ToFilteredMap(fields)
+		record := make([]string, len(fields))
+		for i, f := range fields {
+			record[i] = csvValue(values[f])
+		}
+		return cw.Write(record)
+	}
+	flush = func() error {
+		cw.Flush()
+		return cw.Error()
+	}
+	return write, flush, nil
+}
+
+// csvValue renders a message field as a CSV cell; structured values are
+// JSON-encoded so they survive the round trip.
+func csvValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]any, []any:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,377 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// newPagingSearchServer serves messages honoring the limit/offset of each
+// Views search request and counts the requests it receives.
+func newPagingSearchServer(t *testing.T, messages []testLogMessage, calls *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				SearchTypes []struct {
+					Limit  int `json:"limit"`
+					Offset int `json:"offset"`
+				} `json:"search_types"`
+			} `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode search request: %v", err)
+		}
+		*calls++
+		st := body.Queries[0].SearchTypes[0]
+		start := min(st.Offset, len(messages))
+		end := min(start+st.Limit, len(messages))
+		writeViewsSearchResponse(w, len(messages), messages[start:end])
+	}))
+}
+
+func exportTestMessages(n int) []testLogMessage {
+	messages := make([]testLogMessage, n)
+	for i := range messages {
+		messages[i] = testLogMessage{
+			ID:        fmt.Sprintf("id-%04d", i),
+			Timestamp: fmt.Sprintf("2024-01-01T00:%02d:%02d.000Z", i/60, i%60),
+			Source:    "svc",
+			Message:   fmt.Sprintf("event, \"quoted\" %d", i),
+			Index:     "idx",
+			Extra:     map[string]any{"level": "ERROR", "seq": i},
+		}
+	}
+	return messages
+}
+
+func runExportLogs(t *testing.T, client *graylog.Client, cfg *config.Config, args map[string]any) map[string]any {
+	t.Helper()
+	handler := exportLogsHandler(func(_ context.Context) *graylog.Client { return client }, cfg)
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = args
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	payload := decodeToolResultJSON(t, result)
+	t.Cleanup(func() { os.Remove(payload["path"].(string)) })
+	return payload
+}
+
+func TestExportLogsNDJSONContainsAllPages(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	messages := exportTestMessages(2500)
+	calls := 0
+	server := newPagingSearchServer(t, messages, &calls)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	payload := runExportLogs(t, client, &config.Config{}, map[string]any{"query": "*"})
+
+	if calls != 3 {
+		t.Errorf("expected 3 paged requests, got %d", calls)
+	}
+	if payload["rows"] != float64(len(messages)) || payload["truncated"] != false {
+		t.Fatalf("unexpected result: %v", payload)
+	}
+
+	path := payload["path"].(string)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("export file missing: %v", err)
+	}
+	if payload["bytes"] != float64(info.Size()) {
+		t.Errorf("bytes = %v, file size %d", payload["bytes"], info.Size())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+		if row["_id"] != messages[i].ID || row["message"] != messages[i].Message || row["level"] != "ERROR" {
+			t.Fatalf("line %d = %v, want message %s", i, row, messages[i].ID)
+		}
+	}
+	if i != len(messages) {
+		t.Fatalf("expected %d lines, got %d", len(messages), i)
+	}
+}
+
+// progressTestSession is a minimal initialized MCP session that buffers the
+// notifications sent to it.
+type progressTestSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *progressTestSession) Initialize()       {}
+func (s *progressTestSession) Initialized() bool { return true }
+func (s *progressTestSession) SessionID() string { return "progress-test" }
+func (s *progressTestSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestExportLogsSendsProgressPerPage(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	calls := 0
+	graylogServer := newPagingSearchServer(t, exportTestMessages(2500), &calls)
+	defer graylogServer.Close()
+	client := graylog.NewClient(graylogServer.URL, "token", "token", false, 2*time.Second)
+
+	mcpServer := server.NewMCPServer("test", "0.0.0")
+	mcpServer.AddTool(exportLogsTool(), exportLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}))
+	session := &progressTestSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	ctx := mcpServer.WithContext(context.Background(), session)
+
+	call := func(meta string) {
+		t.Helper()
+		msg := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"export_logs","arguments":{"query":"*"}` + meta + `}}`
+		resp := mcpServer.HandleMessage(ctx, json.RawMessage(msg))
+		if r, ok := resp.(mcp.JSONRPCResponse); !ok || r.Result.(*mcp.CallToolResult).IsError {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	}
+
+	call(`,"_meta":{"progressToken":"export-1"}`)
+	close(session.notifications)
+	var got []string
+	for n := range session.notifications {
+		if n.Method != "notifications/progress" {
+			t.Fatalf("unexpected notification %q", n.Method)
+		}
+		p := n.Params.AdditionalFields
+		if p["progressToken"] != "export-1" || p["total"] != float64(2500) {
+			t.Fatalf("unexpected progress params: %v", p)
+		}
+		got = append(got, fmt.Sprint(p["progress"]))
+	}
+	if want := []string{"1000", "2000", "2500"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected progress %v, got %v", want, got)
+	}
+
+	// Without a progress token nothing is sent.
+	session.notifications = make(chan mcp.JSONRPCNotification, 10)
+	call("")
+	if n := len(session.notifications); n != 0 {
+		t.Fatalf("expected no notifications without a progress token, got %d", n)
+	}
+}
+
+func TestExportLogsCSVColumns(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	messages := exportTestMessages(30)
+	calls := 0
+	server := newPagingSearchServer(t, messages, &calls)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	payload := runExportLogs(t, client, &config.Config{}, map[string]any{
+		"query":  "*",
+		"format": "csv",
+		"fields": "timestamp,message,seq,missing",
+	})
+
+	f, err := os.Open(payload["path"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != len(messages)+1 {
+		t.Fatalf("expected header + %d rows, got %d records", len(messages), len(records))
+	}
+	if !reflect.DeepEqual(records[0], []string{"timestamp", "message", "seq", "missing"}) {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	for i, msg := range messages {
+		want := []string{msg.Timestamp, msg.Message, fmt.Sprint(i), ""}
+		if !reflect.DeepEqual(records[i+1], want) {
+			t.Fatalf("row %d = %v, want %v", i, records[i+1], want)
+		}
+	}
+}
+
+func TestExportLogsStopsAtRowCap(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	messages := exportTestMessages(50)
+	calls := 0
+	server := newPagingSearchServer(t, messages, &calls)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	// max_rows above the server cap is clamped to it.
+	payload := runExportLogs(t, client, &config.Config{ExportMaxRows: 20}, map[string]any{"query": "*", "max_rows": float64(100)})
+	if payload["rows"] != float64(20) || payload["truncated"] != true || payload["note"] == nil {
+		t.Fatalf("expected 20 truncated rows with a note, got %v", payload)
+	}
+}
+
+func TestExportLogsStaysInsideResultWindow(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	const window = 2500
+	// newWindowServer pages like newPagingSearchServer but, like
+	// Elasticsearch's max_result_window, rejects pages starting at the cap.
+	newWindowServer := func(messages []testLogMessage, calls *int) *httptest.Server {
+		paging := newPagingSearchServer(t, messages, calls)
+		t.Cleanup(paging.Close)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var req struct {
+				Queries []struct {
+					SearchTypes []struct {
+						Offset int `json:"offset"`
+					} `json:"search_types"`
+				} `json:"queries"`
+			}
+			_ = json.Unmarshal(body, &req)
+			if req.Queries[0].SearchTypes[0].Offset >= window {
+				http.Error(w, "Result window is too large", http.StatusInternalServerError)
+				return
+			}
+			resp, err := http.Post(paging.URL+r.URL.Path, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Errorf("proxying search: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.Copy(w, resp.Body)
+		}))
+	}
+	cfg := &config.Config{ExportMaxRows: window}
+	messages := exportTestMessages(3000)
+
+	calls := 0
+	server := newWindowServer(messages, &calls)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	payload := runExportLogs(t, client, cfg, map[string]any{"query": "*"})
+	if payload["rows"] != float64(window) || payload["truncated"] != true {
+		t.Fatalf("expected %d truncated rows, got %v", window, payload)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 page requests, got %d", calls)
+	}
+
+	// A result set exactly at the cap is complete.
+	exact := newWindowServer(messages[:window], &calls)
+	defer exact.Close()
+	client = graylog.NewClient(exact.URL, "token", "token", false, 2*time.Second)
+	payload = runExportLogs(t, client, cfg, map[string]any{"query": "*"})
+	if payload["rows"] != float64(window) || payload["truncated"] != false {
+		t.Fatalf("expected %d complete rows, got %v", window, payload)
+	}
+}
+
+func TestExportLogsRemovesFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		writeViewsSearchResponse(w, 5000, exportTestMessages(exportPageSize))
+	}))
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	handler := exportLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error when a page fails")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected partial export to be removed, found %v", entries)
+	}
+}
+
+func TestExportLogsRejectsUnknownFormat(t *testing.T) {
+	handler := exportLogsHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "format": "xml"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error for unknown format")
+	}
+}
+
+func TestExportLogsRegisteredOnlyForStdio(t *testing.T) {
+	getClient := func(_ context.Context) *graylog.Client { return nil }
+	for transport, want := range map[string]bool{"stdio": true, "http": false} {
+		s := server.NewMCPServer("test", "1.0.0")
+		RegisterAll(s, getClient, &config.Config{Transport: transport})
+		if got := s.GetTool("export_logs") != nil; got != want {
+			t.Errorf("transport %s: export_logs registered = %v", transport, got)
+		}
+	}
+}
+
+func TestExportLogsRedactsWhenForced(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	messages := []testLogMessage{{
+		ID: "m-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Index: "idx",
+		Message: "login by jane@example.com from 10.0.0.1",
+		Extra:   map[string]any{"full_message": "Authorization: Bearer abc.def"},
+	}}
+	calls := 0
+	server := newPagingSearchServer(t, messages, &calls)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	payload := runExportLogs(t, client, &config.Config{Redact: true}, map[string]any{"query": "*"})
+	data, err := os.ReadFile(payload["path"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var row map[string]any
+	if err := json.Unmarshal(data, &row); err != nil {
+		t.Fatalf("export is not NDJSON: %v", err)
+	}
+	if row["message"] != "login by [REDACTED] from [REDACTED]" || row["full_message"] != "Authorization: [REDACTED]" {
+		t.Errorf("expected redacted message fields, got %v", row)
+	}
+}
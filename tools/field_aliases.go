@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// coreMessageFields are always present in message output and can be neither
+// renamed nor used as alias targets.
+var coreMessageFields = map[string]bool{
+	"_id":       true,
+	"timestamp": true,
+	"source":    true,
+	"message":   true,
+}
+
+// parseFieldAliases parses a comma-separated list of 'field=alias' pairs.
+func parseFieldAliases(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	aliases := make(map[string]string)
+	targets := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		src, dst, ok := strings.Cut(pair, "=")
+		src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+		if !ok || src == "" || dst == "" {
+			return nil, fmt.Errorf("invalid field alias '%s': use 'field=alias'", pair)
+		}
+		if coreMessageFields[src] || coreMessageFields[dst] {
+			return nil, fmt.Errorf("invalid field alias '%s': core fields (_id, timestamp, source, message) cannot be renamed or overwritten", pair)
+		}
+		if _, dup := aliases[src]; dup {
+			return nil, fmt.Errorf("field '%s' is aliased more than once", src)
+		}
+		if other, dup := targets[dst]; dup {
+			return nil, fmt.Errorf("fields '%s' and '%s' are both aliased to '%s'", other, src, dst)
+		}
+		aliases[src] = dst
+		targets[dst] = src
+	}
+	return aliases, nil
+}
+
+// applyFieldAliases renames keys of m in place. All sources are removed before
+// targets are set, so swaps and chains work; a target that collides with a
+// field left in m is an error.
+func applyFieldAliases(m map[string]any, aliases map[string]string) error {
+	if len(aliases) == 0 || len(m) == 0 {
+		return nil
+	}
+	moved := make(map[string]any, len(aliases))
+	for src, dst := range aliases {
+		if v, ok := m[src]; ok {
+			moved[dst] = v
+			delete(m, src)
+		}
+	}
+	for dst, v := range moved {
+		if _, exists := m[dst]; exists {
+			return fmt.Errorf("field alias '%s' collides with an existing field of the same name", dst)
+		}
+		m[dst] = v
+	}
+	return nil
+}
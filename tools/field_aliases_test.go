@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestParseFieldAliases(t *testing.T) {
+	got, err := parseFieldAliases(" winlogbeat_winlog_event_data_TargetUserName = target_user ,level=severity")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"winlogbeat_winlog_event_data_TargetUserName": "target_user",
+		"level": "severity",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	for input, wantErr := range map[string]string{
+		"level":                "field=alias",
+		"level=":               "field=alias",
+		"a=x,b=x":              "both aliased to 'x'",
+		"a=x,a=y":              "aliased more than once",
+		"level=message":        "core fields",
+		"source=host":          "core fields",
+		"user=_id":             "core fields",
+		"timestamp=event_time": "core fields",
+	} {
+		if _, err := parseFieldAliases(input); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: expected error containing %q, got %v", input, wantErr, err)
+		}
+	}
+}
+
+func TestApplyFieldAliases(t *testing.T) {
+	m := map[string]any{"a": 1, "b": 2, "c": 3}
+	if err := applyFieldAliases(m, map[string]string{"a": "b", "b": "a"}); err != nil {
+		t.Fatalf("swap should succeed, got %v", err)
+	}
+	if want := (map[string]any{"a": 2, "b": 1, "c": 3}); !reflect.DeepEqual(m, want) {
+		t.Fatalf("expected %v, got %v", want, m)
+	}
+
+	m = map[string]any{"a": 1, "c": 3}
+	err := applyFieldAliases(m, map[string]string{"a": "c"})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Fatalf("expected collision error, got %v", err)
+	}
+}
+
+func TestExecuteSearchFieldAliases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "dc-01", Message: "logon", Index: "idx",
+			Extra: map[string]any{"winlogbeat_winlog_event_data_TargetUserName": "bob", "user": "svc", "level": "4"},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	search := func(aliases map[string]string, fields string) (map[string]any, string) {
+		t.Helper()
+		result, err := executeSearch(context.Background(), client, graylog.SearchParams{
+			Query: "*", Limit: 10, Fields: fields,
+		}, searchOptions{fieldAliases: aliases}, 50000)
+		if err != nil {
+			t.Fatalf("executeSearch returned error: %v", err)
+		}
+		if result.IsError {
+			return nil, result.Content[0].(mcp.TextContent).Text
+		}
+		messages := decodeToolResultJSON(t, result)["messages"].([]any)
+		return messages[0].(map[string]any)["message"].(map[string]any), ""
+	}
+
+	msg, errText := search(map[string]string{"winlogbeat_winlog_event_data_TargetUserName": "target_user"}, "winlogbeat_winlog_event_data_TargetUserName")
+	if errText != "" {
+		t.Fatalf("unexpected tool error: %s", errText)
+	}
+	if msg["target_user"] != "bob" {
+		t.Errorf("expected target_user=bob, got %v", msg["target_user"])
+	}
+	if _, ok := msg["winlogbeat_winlog_event_data_TargetUserName"]; ok {
+		t.Error("original field name should be gone after aliasing")
+	}
+	if msg["source"] != "dc-01" || msg["message"] != "logon" {
+		t.Errorf("core fields must be untouched, got %v", msg)
+	}
+
+	if _, errText := search(map[string]string{"winlogbeat_winlog_event_data_TargetUserName": "user"}, ""); !strings.Contains(errText, "collides") {
+		t.Errorf("expected collision error with existing 'user' field, got %q", errText)
+	}
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func fieldValuesTool() mcp.Tool {
+	return mcp.NewTool("field_values",
+		mcp.WithDescription("List the most frequent distinct values of a field with their counts. A shortcut for a count aggregation grouped by one field."),
+		mcp.WithString("field",
+			mcp.Required(),
+			mcp.Description("Field to list values for (e.g. 'source', 'level')"),
+		),
+		mcp.WithNumber("top_n",
+			mcp.Description("Maximum number of values to return (default: 10)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Lucene query to restrict counted messages (default: '*')"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+	)
+}
+
+func fieldValuesHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		field := getStringParam(args, "field")
+		if field == "" {
+			return toolError("'field' parameter is required"), nil
+		}
+		if nonAggregatableFields[field] {
+			return toolError(fmt.Sprintf(
+				"field '%s' is a full-text analyzed field and has no distinct values to list. "+
+					"Use keyword fields like 'source', 'level', 'facility', or your own indexed keyword fields instead.",
+				field,
+			)), nil
+		}
+
+		topN, err := getStrictNonNegativeIntParam(args, "top_n", 10)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if topN < 1 {
+			topN = 10
+		}
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = "*"
+		}
+
+		tr, err := parseTimeRange(args, 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		req := graylog.ScriptingAggregateRequest{
+			Query:     query,
+			TimeRange: tr.scripting(),
+			GroupBy:   []graylog.ScriptingGrouping{{Field: field, Limit: topN}},
+			Metrics:   []graylog.ScriptingMetric{{Function: "count", Sort: "desc"}},
+			Streams:   getStreamIDsParam(args, cfg),
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.Aggregate(ctx, req)
+		if err != nil {
+			return aggregateError("Failed to get field values", err), nil
+		}
+
+		values := fieldValueCounts(resp)
+		if len(values) > topN {
+			values = values[:topN]
+		}
+
+		return toolSuccess(map[string]any{
+			"field":  field,
+			"values": values,
+			"total":  len(values),
+		}), nil
+	}
+}
+
+type fieldValueCount struct {
+	Value any `json:"value"`
+	Count any `json:"count"`
+}
+
+// fieldValueCounts extracts {value, count} pairs from a single-grouping,
+// single-metric tabular response, preserving Graylog's row order.
+func fieldValueCounts(resp *graylog.ScriptingTabularResponse) []fieldValueCount {
+	groupCol, metricCol := -1, -1
+	for j, entry := range resp.Schema {
+		switch {
+		case entry.ColumnType == "grouping" && groupCol < 0:
+			groupCol = j
+		case entry.ColumnType == "metric" && metricCol < 0:
+			metricCol = j
+		}
+	}
+
+	values := make([]fieldValueCount, 0, len(resp.DataRows))
+	if groupCol < 0 || metricCol < 0 {
+		return values
+	}
+	for _, row := range resp.DataRows {
+		if groupCol < len(row) && metricCol < len(row) {
+			values = append(values, fieldValueCount{Value: row[groupCol], Count: row[metricCol]})
+		}
+	}
+	return values
+}
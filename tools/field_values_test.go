@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestFieldValuesHandler(t *testing.T) {
+	var got graylog.ScriptingAggregateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"string","field":"level","name":"grouping: level"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": [["ERROR", 120], ["WARN", 40], ["INFO", 3]],
+			"metadata": {}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := fieldValuesHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"field": "level", "top_n": float64(5)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	wantGroupBy := []graylog.ScriptingGrouping{{Field: "level", Limit: 5}}
+	if !reflect.DeepEqual(got.GroupBy, wantGroupBy) {
+		t.Errorf("expected group_by %+v, got %+v", wantGroupBy, got.GroupBy)
+	}
+	if len(got.Metrics) != 1 || got.Metrics[0].Function != "count" || got.Metrics[0].Sort != "desc" {
+		t.Errorf("expected a single count metric sorted desc, got %+v", got.Metrics)
+	}
+	if got.Query != "*" {
+		t.Errorf("expected default query '*', got %q", got.Query)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	want := []any{
+		map[string]any{"value": "ERROR", "count": float64(120)},
+		map[string]any{"value": "WARN", "count": float64(40)},
+		map[string]any{"value": "INFO", "count": float64(3)},
+	}
+	if !reflect.DeepEqual(payload["values"], want) {
+		t.Fatalf("unexpected values: %#v", payload["values"])
+	}
+}
+
+func TestFieldValuesHandlerRejectsAnalyzedField(t *testing.T) {
+	client := graylog.NewClient("https://graylog.example.com", "token", "token", false, 2*time.Second)
+	handler := fieldValuesHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"field": "message"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected IsError=true for analyzed field 'message'")
+	}
+}
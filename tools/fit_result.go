@@ -1,7 +1,7 @@
 package tools
 
 import (
-	"encoding/json"
+	"context"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -12,27 +12,35 @@ type resultAdapter struct {
 	lastResort   func() map[string]any // optional: return metadata-only fallback
 }
 
-func fitResult(result map[string]any, maxSize int, adapter resultAdapter) (*mcp.CallToolResult, error) {
-	if maxSize <= 0 {
-		return toolSuccess(result), nil
-	}
-
-	jsonBytes, err := json.Marshal(result)
+func fitResult(ctx context.Context, result map[string]any, maxSize int, adapter resultAdapter) (*mcp.CallToolResult, error) {
+	// Size is measured with the encoding the caller will see, so pretty
+	// output is fitted on its indented length.
+	marshal := resultMarshaler(ctx)
+	jsonBytes, err := marshal(result)
 	if err != nil {
-		return toolError("failed to marshal response: " + err.Error()), nil
+		return classifiedError(errInternal, "failed to marshal response: "+err.Error()), nil
+	}
+	if maxSize <= 0 {
+		return toolSuccessJSON(jsonBytes), nil
 	}
 
 	if len(jsonBytes) <= maxSize {
 		return toolSuccessJSON(jsonBytes), nil
 	}
 
+	// Each pass re-marshals the whole result, so stop as soon as the caller
+	// has gone away instead of finishing work nobody will read.
+
 	// Phase 1: Progressive message truncation
 	for _, truncLen := range []int{500, 200, 100, 50} {
+		if ctx.Err() != nil {
+			return fitCancelled(), nil
+		}
 		adapter.truncateMsgs(truncLen)
 		result["response_truncated"] = true
-		jsonBytes, err = json.Marshal(result)
+		jsonBytes, err = marshal(result)
 		if err != nil {
-			return toolError("failed to marshal response: " + err.Error()), nil
+			return classifiedError(errInternal, "failed to marshal response: "+err.Error()), nil
 		}
 		if len(jsonBytes) <= maxSize {
 			return toolSuccessJSON(jsonBytes), nil
@@ -41,25 +49,32 @@ func fitResult(result map[string]any, maxSize int, adapter resultAdapter) (*mcp.
 
 	// Phase 2: Reduce message count (bounded to prevent infinite loops)
 	for i := 0; i < 20; i++ {
+		if ctx.Err() != nil {
+			return fitCancelled(), nil
+		}
 		if !adapter.reduceMsgs() {
 			break
 		}
 		result["response_truncated"] = true
-		jsonBytes, err = json.Marshal(result)
+		jsonBytes, err = marshal(result)
 		if err != nil {
-			return toolError("failed to marshal response: " + err.Error()), nil
+			return classifiedError(errInternal, "failed to marshal response: "+err.Error()), nil
 		}
 		if len(jsonBytes) <= maxSize {
 			return toolSuccessJSON(jsonBytes), nil
 		}
 	}
 
+	if ctx.Err() != nil {
+		return fitCancelled(), nil
+	}
+
 	// Last resort
 	if adapter.lastResort != nil {
 		metadata := adapter.lastResort()
-		jsonBytes, err = json.Marshal(metadata)
+		jsonBytes, err = marshal(metadata)
 		if err != nil {
-			return toolError("failed to marshal response: " + err.Error()), nil
+			return classifiedError(errInternal, "failed to marshal response: "+err.Error()), nil
 		}
 		return toolSuccessJSON(jsonBytes), nil
 	}
@@ -67,9 +82,13 @@ func fitResult(result map[string]any, maxSize int, adapter resultAdapter) (*mcp.
 	// Defensive: ensure response_truncated is set even if all reduction phases
 	// failed to bring the response below maxSize (e.g. single oversized message).
 	result["response_truncated"] = true
-	jsonBytes, err = json.Marshal(result)
+	jsonBytes, err = marshal(result)
 	if err != nil {
-		return toolError("failed to marshal response: " + err.Error()), nil
+		return classifiedError(errInternal, "failed to marshal response: "+err.Error()), nil
 	}
 	return toolSuccessJSON(jsonBytes), nil
 }
+
+func fitCancelled() *mcp.CallToolResult {
+	return classifiedError(errRetryable, "request cancelled while fitting the response")
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestFitResultStopsOnCancelledContext(t *testing.T) {
+	messages := make([]map[string]any, 5000)
+	for i := range messages {
+		messages[i] = map[string]any{
+			"message": map[string]any{"_id": "id", "timestamp": "2024-01-01T00:00:00.000Z", "source": "svc", "message": strings.Repeat("x", 2000)},
+			"index":   "idx",
+		}
+	}
+	result := map[string]any{"messages": messages, "total_results": len(messages), "has_more": false}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var truncations, reductions int
+	start := time.Now()
+	toolResult, err := fitResult(ctx, result, 1000, resultAdapter{
+		truncateMsgs: func(int) { truncations++ },
+		reduceMsgs:   func() bool { reductions++; return true },
+		lastResort:   func() map[string]any { t.Error("lastResort must not run on a cancelled context"); return nil },
+	})
+	if err != nil {
+		t.Fatalf("fitResult returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("fitResult took %s on a cancelled context", elapsed)
+	}
+	if !toolResult.IsError {
+		t.Fatalf("expected a tool error, got %+v", toolResult.Content)
+	}
+	if text := toolResult.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "request cancelled") {
+		t.Errorf("expected a request cancelled error, got %q", text)
+	}
+	if truncations != 0 || reductions != 0 {
+		t.Errorf("expected no fitting phases to run, got %d truncations and %d reductions", truncations, reductions)
+	}
+}
+
+func TestFitResultIgnoresCancellationWhenResultFits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	toolResult, err := fitSearchResult(ctx, map[string]any{"messages": []map[string]any{}, "total_results": 0}, defaultMaxResultSize, false)
+	if err != nil {
+		t.Fatalf("fitSearchResult returned error: %v", err)
+	}
+	if toolResult.IsError {
+		t.Errorf("a result that already fits should be returned as is, got %+v", toolResult.Content)
+	}
+}
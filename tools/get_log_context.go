@@ -2,28 +2,40 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/dedup"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
 
 const (
-	contextResultMaxSize        = 50000
-	contextOverfetchMultiplier  = 3
-	contextMaxFetchLimitPerSide = 1501
+	contextResultMaxSize          = 50000
+	contextOverfetchMultiplier    = 3
+	contextMaxOverfetchMultiplier = 10
+	contextMaxFetchLimitPerSide   = 1501
+	// contextMaxWindowSeconds caps 'window' at a day; wider context should
+	// leave it unset (unbounded) instead.
+	contextMaxWindowSeconds = 86400
 )
 
 func getLogContextTool() mcp.Tool {
 	return mcp.NewTool("get_log_context",
 		mcp.WithDescription("Get surrounding log messages around a specific message. Useful for understanding the context of an event."),
 		mcp.WithString("message_id",
-			mcp.Required(),
-			mcp.Description("The _id of the target message"),
+			mcp.Description("The _id of the target message. Required unless 'ref' is set."),
 		),
 		mcp.WithString("index",
-			mcp.Required(),
-			mcp.Description("The Elasticsearch index of the target message"),
+			mcp.Description("The Elasticsearch index of the target message. Required unless 'ref' is set."),
+		),
+		mcp.WithString("ref",
+			mcp.Description("The context_ref token of a search_logs message or dedup group, naming both its index and _id. Replaces 'message_id' and 'index'."),
 		),
 		mcp.WithNumber("before",
 			mcp.Description("Number of messages to fetch before the target (default: 5)"),
@@ -32,31 +44,62 @@ func getLogContextTool() mcp.Tool {
 			mcp.Description("Number of messages to fetch after the target (default: 5)"),
 		),
 		mcp.WithString("fields",
-			mcp.Description("Comma-separated list of fields to return"),
+			mcp.Description("Comma-separated list of fields to return. Supports '*' globs ('kubernetes_*') and '-' exclusions; core fields are always kept. Defaults to the server's GRAYLOG_DEFAULT_FIELDS if set; pass '*' for all fields."),
+		),
+		mcp.WithString("field_aliases",
+			mcp.Description("Comma-separated 'field=alias' pairs renaming fields in the output (e.g. 'winlogbeat_winlog_event_data_TargetUserName=target_user'). Core fields can't be renamed."),
 		),
 		mcp.WithString("stream_id",
 			mcp.Description("Optional stream ID to restrict context search to a specific stream"),
 		),
+		mcp.WithString("correlate_field",
+			mcp.Description("Optional field (e.g. 'trace_id') to correlate on: context is restricted to messages sharing the target's value for it. Falls back to uncorrelated context if the target lacks the field."),
+		),
+		mcp.WithString("same_timestamp",
+			mcp.Description("Where to place messages sharing the target's exact timestamp: 'before' (default), 'after', or 'split' (by _id relative to the target). They are ordered by _id."),
+		),
+		mcp.WithNumber("window",
+			mcp.Description("Search only this many seconds before and after the target instead of all time (default: 0 = unbounded, max: 86400). Much cheaper on large indices; raise it if context_incomplete is returned."),
+		),
+		mcp.WithBoolean("deduplicate",
+			mcp.Description("If true, collapse content-identical context messages (e.g. repeated heartbeats) into groups with 'count' and 'message_ids', in order of first occurrence. 'before'/'after' then count groups, not raw messages."),
+		),
+		mcp.WithBoolean("merged",
+			mcp.Description("If true, return one chronological 'timeline' array (before messages, the target marked with is_target: true, after messages) instead of separate 'messages_before', 'target_message' and 'messages_after'."),
+		),
+		mcp.WithNumber("overfetch",
+			mcp.Description("Overfetch multiplier per side to compensate for duplicate messages (default: 3, max: 10). Raise it in noisy streams if context_incomplete is returned."),
+		),
+		redactParam(),
+		timeoutSecondsParam(),
 	)
 }
 
-func getLogContextHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func getLogContextHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		c := getClient(ctx)
 		if c == nil {
-			return toolError("no Graylog credentials: Authorization header required"), nil
+			return noCredentialsError(), nil
 		}
 
 		args := request.GetArguments()
 
 		messageID := getStringParam(args, "message_id")
+		index := getStringParam(args, "index")
+		if ref := getStringParam(args, "ref"); ref != "" {
+			if messageID != "" || index != "" {
+				return toolError("'ref' can't be combined with 'message_id' or 'index'"), nil
+			}
+			var err error
+			if index, messageID, err = parseContextRef(ref); err != nil {
+				return toolError(err.Error()), nil
+			}
+		}
 		if messageID == "" {
-			return toolError("'message_id' parameter is required"), nil
+			return toolError("'message_id' parameter is required (or pass 'ref')"), nil
 		}
-
-		index := getStringParam(args, "index")
 		if index == "" {
-			return toolError("'index' parameter is required"), nil
+			return toolError("'index' parameter is required (or pass 'ref')"), nil
 		}
 
 		before, err := getStrictNonNegativeIntParam(args, "before", 5)
@@ -73,22 +116,39 @@ func getLogContextHandler(getClient ClientFunc) func(ctx context.Context, reques
 		if after > 500 {
 			after = 500
 		}
-		fields := getStringParam(args, "fields")
-		streamID := getStringParam(args, "stream_id")
-
-		var streamIDs []string
-		if streamID != "" {
-			streamIDs = []string{streamID}
+		overfetch, err := getStrictNonNegativeIntParam(args, "overfetch", contextOverfetchMultiplier)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		overfetch = max(1, min(overfetch, contextMaxOverfetchMultiplier))
+		window, err := getStrictNonNegativeIntParam(args, "window", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if window > contextMaxWindowSeconds {
+			return toolError(fmt.Sprintf("'window' %d exceeds the maximum of %d seconds; omit it to search without a window", window, contextMaxWindowSeconds)), nil
+		}
+		fields := getFieldsParam(args, cfg)
+		streamIDs := getStreamIDsParam(args, cfg)
+		aliases, err := parseFieldAliases(getStringParam(args, "field_aliases"))
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		sameTimestampSide := getStringParam(args, "same_timestamp")
+		if sameTimestampSide == "" {
+			sameTimestampSide = "before"
+		}
+		if sameTimestampSide != "before" && sameTimestampSide != "after" && sameTimestampSide != "split" {
+			return toolError("'same_timestamp' must be 'before', 'after' or 'split'"), nil
 		}
 
 		// Fetch the target message
 		target, err := c.GetMessage(ctx, index, messageID)
 		if err != nil {
-			if apiErr, ok := err.(*graylog.APIError); ok {
-				return toolError(apiErr.Error()), nil
-			}
-			return toolError("Failed to get message: " + err.Error()), nil
+			return graylogError("Failed to get message", err), nil
 		}
+		redact := getRedactPatterns(args, cfg)
+		redactMessage(&target.Message, redact)
 
 		timestamp := target.Message.Timestamp
 
@@ -96,90 +156,173 @@ func getLogContextHandler(getClient ClientFunc) func(ctx context.Context, reques
 			"target_message": target,
 		}
 
-		beforeLimit := min(before*contextOverfetchMultiplier+1, contextMaxFetchLimitPerSide)
-		afterLimit := min(after*contextOverfetchMultiplier+1, contextMaxFetchLimitPerSide)
+		contextQuery := "*"
+		if correlateField := getStringParam(args, "correlate_field"); correlateField != "" {
+			if v, ok := target.Message.ToFilteredMap(nil)[correlateField]; ok && v != nil && v != "" {
+				contextQuery = correlateField + ":" + escapeLuceneValue(v)
+				result["correlated_by"] = correlateField
+			} else {
+				result["correlation_note"] = "target message has no '" + correlateField + "' field; context is not correlated"
+			}
+		}
+
+		beforeFrom, afterTo := unboundedFrom, unboundedTo
+		if window > 0 {
+			if from, to, ok := contextWindowBounds(timestamp, time.Duration(window)*time.Second); ok {
+				beforeFrom, afterTo = from, to
+				result["window"] = window
+			} else {
+				result["window_note"] = "target timestamp '" + timestamp + "' could not be parsed; searched without a window"
+			}
+		}
+
+		beforeLimit := min(before*overfetch+1, contextMaxFetchLimitPerSide)
+		afterLimit := min(after*overfetch+1, contextMaxFetchLimitPerSide)
 
 		// Search for messages before
-		messagesBefore := make([]graylog.MessageWrapper, 0)
+		var beforeRaw []graylog.MessageWrapper
 		if before > 0 {
 			beforeParams := graylog.SearchParams{
-				Query:     "*",
-				From:      "1970-01-01T00:00:00.000Z",
-				To:        timestamp,
+				Query:     contextQuery,
 				Limit:     beforeLimit, // +1 to account for the target message itself
 				Sort:      "timestamp:desc",
 				Fields:    fields,
 				StreamIDs: streamIDs,
 			}
+			absoluteTimeRange(beforeFrom, timestamp).applyTo(&beforeParams)
 			beforeResp, err := c.Search(ctx, beforeParams)
 			if err != nil {
 				result["before_error"] = err.Error()
 			} else {
-				messagesBefore = filterOutContextMessageID(beforeResp.Messages, messageID)
+				beforeRaw = filterOutContextMessageID(beforeResp.Messages, messageID)
 			}
 		}
-		// Reverse to chronological order
-		for i, j := 0, len(messagesBefore)-1; i < j; i, j = i+1, j-1 {
-			messagesBefore[i], messagesBefore[j] = messagesBefore[j], messagesBefore[i]
-		}
-		messagesBefore = deduplicateContextMessagesByID(messagesBefore)
-		if len(messagesBefore) > before {
-			messagesBefore = messagesBefore[:before]
-		}
 
 		// Search for messages after
-		messagesAfter := make([]graylog.MessageWrapper, 0)
+		var afterRaw []graylog.MessageWrapper
 		if after > 0 {
 			afterParams := graylog.SearchParams{
-				Query:     "*",
-				From:      timestamp,
-				To:        "2099-12-31T23:59:59.999Z",
+				Query:     contextQuery,
 				Limit:     afterLimit,
 				Sort:      "timestamp:asc",
 				Fields:    fields,
 				StreamIDs: streamIDs,
 			}
+			absoluteTimeRange(timestamp, afterTo).applyTo(&afterParams)
 			afterResp, err := c.Search(ctx, afterParams)
 			if err != nil {
 				result["after_error"] = err.Error()
 			} else {
-				messagesAfter = filterOutContextMessageID(afterResp.Messages, messageID)
+				afterRaw = filterOutContextMessageID(afterResp.Messages, messageID)
 			}
 		}
 
-		messagesAfter = deduplicateContextMessagesByID(messagesAfter)
-		messagesAfter = removeContextOverlapByID(messagesAfter, messagesBefore)
+		messagesBefore, messagesAfter := splitContextMessages(beforeRaw, afterRaw, target.Message, sameTimestampSide)
+		redactMessages(messagesBefore, redact)
+		redactMessages(messagesAfter, redact)
+		merged := getBoolParam(args, "merged")
+		if getBoolParam(args, "deduplicate") {
+			return dedupContextResult(ctx, result, target, messagesBefore, messagesAfter, before, after, fields, aliases, merged)
+		}
+		// Keep the messages closest to the target on each side.
+		if len(messagesBefore) > before {
+			messagesBefore = messagesBefore[len(messagesBefore)-before:]
+		}
 		if len(messagesAfter) > after {
 			messagesAfter = messagesAfter[:after]
 		}
 
 		// Filter Extra fields if user requested specific fields
-		if fields != "" {
-			fieldSet := make(map[string]bool)
-			for _, f := range strings.Split(fields, ",") {
-				fieldSet[strings.TrimSpace(f)] = true
-			}
+		if fieldFilter := graylog.NewFieldFilter(strings.Split(fields, ",")); fieldFilter != nil {
 			for i := range messagesBefore {
-				filterMessageExtraFields(messagesBefore[i].Message.Extra, fieldSet)
+				filterMessageExtraFields(messagesBefore[i].Message.Extra, fieldFilter)
 			}
 			for i := range messagesAfter {
-				filterMessageExtraFields(messagesAfter[i].Message.Extra, fieldSet)
+				filterMessageExtraFields(messagesAfter[i].Message.Extra, fieldFilter)
 			}
 			if target != nil {
-				filterMessageExtraFields(target.Message.Extra, fieldSet)
+				filterMessageExtraFields(target.Message.Extra, fieldFilter)
+			}
+		}
+
+		if err := applyFieldAliases(target.Message.Extra, aliases); err != nil {
+			return toolError(err.Error()), nil
+		}
+		for _, msgs := range [][]graylog.MessageWrapper{messagesBefore, messagesAfter} {
+			for i := range msgs {
+				if err := applyFieldAliases(msgs[i].Message.Extra, aliases); err != nil {
+					return toolError(err.Error()), nil
+				}
 			}
 		}
 
 		result["messages_before"] = messagesBefore
 		result["messages_after"] = messagesAfter
 		result["context_incomplete"] = len(messagesBefore) < before || len(messagesAfter) < after
+		if merged {
+			mergeContextTimeline(result)
+		}
+
+		return fitContextResult(ctx, result, contextResultMaxSize)
+	}
+}
+
+// dedupContextResult groups content-identical messages on each side of the
+// target, keeping the before/after groups closest to it. Groups stay in
+// chronological order of their first occurrence.
+func dedupContextResult(ctx context.Context, result map[string]any, target *graylog.MessageWrapper, messagesBefore, messagesAfter []graylog.MessageWrapper, before, after int, fields string, aliases map[string]string, merged bool) (*mcp.CallToolResult, error) {
+	groupsBefore := dedup.Deduplicate(messagesBefore, nil)
+	groupsAfter := dedup.Deduplicate(messagesAfter, nil)
+	if len(groupsBefore) > before {
+		groupsBefore = groupsBefore[len(groupsBefore)-before:]
+	}
+	if len(groupsAfter) > after {
+		groupsAfter = groupsAfter[:after]
+	}
+	dedup.CapMessageIDs(groupsBefore, 5)
+	dedup.CapMessageIDs(groupsAfter, 5)
+
+	fieldFilter := graylog.NewFieldFilter(strings.Split(fields, ","))
+	if fieldFilter != nil {
+		filterMessageExtraFields(target.Message.Extra, fieldFilter)
+		filterDedupResultFields(groupsBefore, fieldFilter)
+		filterDedupResultFields(groupsAfter, fieldFilter)
+	}
+	if err := applyFieldAliases(target.Message.Extra, aliases); err != nil {
+		return toolError(err.Error()), nil
+	}
+	for _, groups := range [][]dedup.DedupResult{groupsBefore, groupsAfter} {
+		for i := range groups {
+			if err := applyFieldAliases(groups[i].Message.Extra, aliases); err != nil {
+				return toolError(err.Error()), nil
+			}
+		}
+	}
+
+	result["deduplicated"] = true
+	result["messages_before"] = groupsBefore
+	result["messages_after"] = groupsAfter
+	result["context_incomplete"] = len(groupsBefore) < before || len(groupsAfter) < after
+	if merged {
+		mergeContextTimeline(result)
+	}
 
-		return fitContextResult(result, contextResultMaxSize)
+	return fitContextResult(ctx, result, contextResultMaxSize)
+}
+
+// contextWindowBounds returns timestamp ± window formatted for a Graylog
+// absolute time range, or ok=false if timestamp can't be parsed.
+func contextWindowBounds(timestamp string, window time.Duration) (from, to string, ok bool) {
+	ts, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return "", "", false
 	}
+	ts = ts.UTC()
+	return ts.Add(-window).Format(graylogTimeLayout), ts.Add(window).Format(graylogTimeLayout), true
 }
 
-func fitContextResult(result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
-	return fitResult(result, maxSize, resultAdapter{
+func fitContextResult(ctx context.Context, result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
+	return fitResult(ctx, result, maxSize, resultAdapter{
 		truncateMsgs: func(maxLen int) {
 			truncateContextMessages(result, maxLen)
 		},
@@ -203,7 +346,7 @@ func fitContextResult(result map[string]any, maxSize int) (*mcp.CallToolResult,
 			return true
 		},
 		lastResort: func() map[string]any {
-			targetID, targetTimestamp, targetIndex := contextTargetMetadata(result["target_message"])
+			targetID, targetTimestamp, targetIndex := contextTargetMetadata(contextTarget(result))
 			metadata := map[string]any{
 				"target_message_id":  targetID,
 				"target_timestamp":   targetTimestamp,
@@ -224,6 +367,78 @@ func fitContextResult(result map[string]any, maxSize int) (*mcp.CallToolResult,
 	})
 }
 
+// splitContextMessages merges the before (newest first) and after (oldest
+// first) search results into chronological before/after lists. Messages
+// sharing the target's exact timestamp may come back from either query
+// depending on how Graylog treats range boundaries, so they are collected once,
+// ordered by _id (the search tiebreaker) and placed on sameSide: "before",
+// "after", or "split" (lower _id than the target before, higher after).
+func splitContextMessages(beforeDesc, afterAsc []graylog.MessageWrapper, target graylog.Message, sameSide string) ([]graylog.MessageWrapper, []graylog.MessageWrapper) {
+	var same []graylog.MessageWrapper
+	older := make([]graylog.MessageWrapper, 0, len(beforeDesc))
+	for _, mw := range beforeDesc {
+		if sameInstant(mw.Message.Timestamp, target.Timestamp) {
+			same = append(same, mw)
+		} else {
+			older = append(older, mw)
+		}
+	}
+	newer := make([]graylog.MessageWrapper, 0, len(afterAsc))
+	for _, mw := range afterAsc {
+		if sameInstant(mw.Message.Timestamp, target.Timestamp) {
+			same = append(same, mw)
+		} else {
+			newer = append(newer, mw)
+		}
+	}
+	slices.Reverse(older)
+	same = deduplicateContextMessagesByID(same)
+	sort.SliceStable(same, func(i, j int) bool { return same[i].Message.ID < same[j].Message.ID })
+
+	var before, after []graylog.MessageWrapper
+	switch sameSide {
+	case "after":
+		before = older
+		after = append(same, newer...)
+	case "split":
+		before = older
+		var sameAfter []graylog.MessageWrapper
+		for _, mw := range same {
+			if mw.Message.ID < target.ID {
+				before = append(before, mw)
+			} else {
+				sameAfter = append(sameAfter, mw)
+			}
+		}
+		after = append(sameAfter, newer...)
+	default:
+		before = append(older, same...)
+		after = newer
+	}
+
+	before = deduplicateContextMessagesByID(before)
+	after = deduplicateContextMessagesByID(after)
+	after = removeContextOverlapByID(after, before)
+	if before == nil {
+		before = []graylog.MessageWrapper{}
+	}
+	if after == nil {
+		after = []graylog.MessageWrapper{}
+	}
+	return before, after
+}
+
+// sameInstant reports whether two Graylog timestamps denote the same instant,
+// falling back to string equality when either fails to parse.
+func sameInstant(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339Nano, a)
+	tb, errB := time.Parse(time.RFC3339Nano, b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ta.Equal(tb)
+}
+
 func filterOutContextMessageID(messages []graylog.MessageWrapper, messageID string) []graylog.MessageWrapper {
 	filtered := make([]graylog.MessageWrapper, 0, len(messages))
 	for _, mw := range messages {
@@ -296,36 +511,126 @@ func contextTargetMetadata(target any) (id, timestamp, index string) {
 
 func truncateContextMessages(result map[string]any, maxLen int) {
 	// Truncate target message
-	if target, ok := result["target_message"].(*graylog.MessageWrapper); ok && target != nil {
-		target.Message.Message = truncateString(target.Message.Message, maxLen)
-	}
-
-	// Truncate before messages
-	if messages, ok := result["messages_before"].([]graylog.MessageWrapper); ok {
-		for i := range messages {
-			messages[i].Message.Message = truncateString(messages[i].Message.Message, maxLen)
-		}
+	if target, ok := contextTarget(result).(*graylog.MessageWrapper); ok && target != nil {
+		truncateMessageText(&target.Message, maxLen)
 	}
 
-	// Truncate after messages
-	if messages, ok := result["messages_after"].([]graylog.MessageWrapper); ok {
-		for i := range messages {
-			messages[i].Message.Message = truncateString(messages[i].Message.Message, maxLen)
+	// Truncate before and after messages
+	for _, key := range []string{"messages_before", "messages_after"} {
+		switch messages := contextSide(result, key).(type) {
+		case []graylog.MessageWrapper:
+			for i := range messages {
+				truncateMessageText(&messages[i].Message, maxLen)
+			}
+		case []dedup.DedupResult:
+			for i := range messages {
+				truncateMessageText(&messages[i].Message, maxLen)
+			}
 		}
 	}
 }
 
 func contextMessageCount(result map[string]any, key string) int {
-	if messages, ok := result[key].([]graylog.MessageWrapper); ok {
+	switch messages := contextSide(result, key).(type) {
+	case []graylog.MessageWrapper:
+		return len(messages)
+	case []dedup.DedupResult:
 		return len(messages)
 	}
 	return 0
 }
 
 func reduceContextMessages(result map[string]any, key string, count int) {
-	if messages, ok := result[key].([]graylog.MessageWrapper); ok {
+	switch messages := contextSide(result, key).(type) {
+	case []graylog.MessageWrapper:
 		if count < len(messages) {
-			result[key] = messages[:count]
+			setContextSide(result, key, messages[:count])
+		}
+	case []dedup.DedupResult:
+		if count < len(messages) {
+			setContextSide(result, key, messages[:count])
+		}
+	}
+}
+
+// contextTimeline is the merged form of a context result: the before
+// messages, the target and the after messages as one chronological array.
+// The sides stay separate until encoding so fitting can trim them like the
+// split form. Each side is a []graylog.MessageWrapper or []dedup.DedupResult.
+type contextTimeline struct {
+	before, after any
+	target        *graylog.MessageWrapper
+}
+
+func (tl *contextTimeline) MarshalJSON() ([]byte, error) {
+	entries := []any{}
+	appendSide := func(side any) {
+		switch messages := side.(type) {
+		case []graylog.MessageWrapper:
+			for _, m := range messages {
+				entries = append(entries, m)
+			}
+		case []dedup.DedupResult:
+			for _, g := range messages {
+				entries = append(entries, g)
+			}
 		}
 	}
+	appendSide(tl.before)
+	if tl.target != nil {
+		entries = append(entries, struct {
+			graylog.MessageWrapper
+			IsTarget bool `json:"is_target"`
+		}{*tl.target, true})
+	}
+	appendSide(tl.after)
+	return json.Marshal(entries)
+}
+
+// mergeContextTimeline replaces a result's target_message, messages_before
+// and messages_after with a single "timeline".
+func mergeContextTimeline(result map[string]any) {
+	target, _ := result["target_message"].(*graylog.MessageWrapper)
+	result["timeline"] = &contextTimeline{
+		before: result["messages_before"],
+		after:  result["messages_after"],
+		target: target,
+	}
+	delete(result, "target_message")
+	delete(result, "messages_before")
+	delete(result, "messages_after")
+}
+
+// contextTarget returns the target message of a split or merged result.
+func contextTarget(result map[string]any) any {
+	if tl, ok := result["timeline"].(*contextTimeline); ok {
+		return tl.target
+	}
+	return result["target_message"]
+}
+
+// contextSide returns the messages_before or messages_after side of a split
+// or merged result.
+func contextSide(result map[string]any, key string) any {
+	tl, ok := result["timeline"].(*contextTimeline)
+	switch {
+	case !ok:
+		return result[key]
+	case key == "messages_before":
+		return tl.before
+	default:
+		return tl.after
+	}
+}
+
+func setContextSide(result map[string]any, key string, messages any) {
+	tl, ok := result["timeline"].(*contextTimeline)
+	switch {
+	case !ok:
+		result[key] = messages
+	case key == "messages_before":
+		tl.before = messages
+	default:
+		tl.after = messages
+	}
 }
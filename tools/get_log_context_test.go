@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
 
@@ -70,7 +72,7 @@ func TestGetLogContextDedupUsesOverfetchAndRemovesOverlap(t *testing.T) {
 	defer server.Close()
 
 	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
-	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client })
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
 
 	req := mcp.CallToolRequest{}
 	req.Params.Arguments = map[string]any{
@@ -139,7 +141,7 @@ func TestFitContextResultUsesLastResortForOversizedPayload(t *testing.T) {
 		"context_incomplete": true,
 	}
 
-	toolResult, err := fitContextResult(result, 200)
+	toolResult, err := fitContextResult(context.Background(), result, 200)
 	if err != nil {
 		t.Fatalf("fitContextResult returned error: %v", err)
 	}
@@ -208,7 +210,7 @@ func TestGetLogContextFieldsFiltering(t *testing.T) {
 	defer server.Close()
 
 	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
-	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client })
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
 
 	req := mcp.CallToolRequest{}
 	req.Params.Arguments = map[string]any{
@@ -316,3 +318,618 @@ func extractContextMessageIDs(t *testing.T, payload map[string]any, key string)
 	}
 	return ids
 }
+
+func TestGetLogContextOverfetchScalesLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantLimit int
+	}{
+		{name: "default multiplier", args: map[string]any{"before": float64(10)}, wantLimit: 31},
+		{name: "custom multiplier", args: map[string]any{"before": float64(10), "overfetch": float64(6)}, wantLimit: 61},
+		{name: "multiplier clamped to max", args: map[string]any{"before": float64(10), "overfetch": float64(50)}, wantLimit: 101},
+		{name: "limit capped per side", args: map[string]any{"before": float64(500), "overfetch": float64(10)}, wantLimit: contextMaxFetchLimitPerSide},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var searchCalls []contextSearchCall
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/messages/test-index/target":
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"message": map[string]any{"fields": map[string]any{"_id": "target", "timestamp": "2024-01-01T00:00:00.000Z"}},
+						"index":   "test-index",
+					})
+				case "/api/views/search/sync":
+					call, err := parseContextSearchCall(r)
+					if err != nil {
+						t.Errorf("failed to parse search call: %v", err)
+					}
+					searchCalls = append(searchCalls, call)
+					writeViewsSearchResponse(w, 0, nil)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+			args := map[string]any{"message_id": "target", "index": "test-index", "after": float64(0)}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = args
+			if _, err := handler(context.Background(), req); err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			if len(searchCalls) != 1 {
+				t.Fatalf("expected 1 search call, got %d", len(searchCalls))
+			}
+			if searchCalls[0].Limit != tt.wantLimit {
+				t.Fatalf("expected search limit %d, got %d", tt.wantLimit, searchCalls[0].Limit)
+			}
+		})
+	}
+}
+
+func TestGetLogContextCorrelateField(t *testing.T) {
+	tests := []struct {
+		name      string
+		extra     map[string]any
+		wantQuery string
+		wantNote  bool
+	}{
+		{name: "target has field", extra: map[string]any{"trace_id": `abc"123`}, wantQuery: `trace_id:abc\"123`},
+		{name: "target lacks field", extra: map[string]any{}, wantQuery: "*", wantNote: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/messages/test-index/target":
+					fields := map[string]any{"_id": "target", "timestamp": "2024-01-01T00:00:00.000Z"}
+					for k, v := range tt.extra {
+						fields[k] = v
+					}
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"message": map[string]any{"fields": fields},
+						"index":   "test-index",
+					})
+				case "/api/views/search/sync":
+					var body struct {
+						Queries []struct {
+							Query struct {
+								QueryString string `json:"query_string"`
+							} `json:"query"`
+						} `json:"queries"`
+					}
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					queries = append(queries, body.Queries[0].Query.QueryString)
+					writeViewsSearchResponse(w, 0, nil)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{
+				"message_id":      "target",
+				"index":           "test-index",
+				"correlate_field": "trace_id",
+			}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			if len(queries) != 2 {
+				t.Fatalf("expected 2 context searches, got %d", len(queries))
+			}
+			for _, q := range queries {
+				if q != tt.wantQuery {
+					t.Errorf("expected context query %q, got %q", tt.wantQuery, q)
+				}
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			if _, hasNote := payload["correlation_note"]; hasNote != tt.wantNote {
+				t.Errorf("expected correlation_note present=%v, got %v", tt.wantNote, payload["correlation_note"])
+			}
+			if _, correlated := payload["correlated_by"]; correlated == tt.wantNote {
+				t.Errorf("expected correlated_by present=%v, got %v", !tt.wantNote, payload["correlated_by"])
+			}
+		})
+	}
+}
+
+func TestGetLogContextFieldAliases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/messages/test-index/target":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"message": map[string]any{"fields": map[string]any{
+					"_id": "target", "timestamp": "2024-01-01T00:00:00.000Z", "source": "svc", "message": "target",
+					"winlogbeat_winlog_event_data_TargetUserName": "bob",
+				}},
+				"index": "test-index",
+			})
+		case "/api/views/search/sync":
+			writeViewsSearchResponse(w, 1, []testLogMessage{{
+				ID: "other", Timestamp: "2024-01-01T00:00:01.000Z", Source: "svc", Message: "other", Index: "idx",
+				Extra: map[string]any{"winlogbeat_winlog_event_data_TargetUserName": "alice"},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"message_id":    "target",
+		"index":         "test-index",
+		"before":        float64(0),
+		"after":         float64(1),
+		"field_aliases": "winlogbeat_winlog_event_data_TargetUserName=target_user",
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	targetMsg := payload["target_message"].(map[string]any)["message"].(map[string]any)
+	if targetMsg["target_user"] != "bob" {
+		t.Errorf("expected target alias target_user=bob, got %v", targetMsg)
+	}
+	afterMsg := payload["messages_after"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if afterMsg["target_user"] != "alice" {
+		t.Errorf("expected context alias target_user=alice, got %v", afterMsg)
+	}
+	if _, ok := afterMsg["winlogbeat_winlog_event_data_TargetUserName"]; ok {
+		t.Error("original field name should be gone after aliasing")
+	}
+
+	req.Params.Arguments.(map[string]any)["field_aliases"] = "user=source"
+	result, _ = handler(context.Background(), req)
+	if !result.IsError {
+		t.Fatal("expected error when aliasing onto core field 'source'")
+	}
+}
+
+func TestGetLogContextSameTimestampPlacement(t *testing.T) {
+	const ts = "2024-01-01T00:00:00.000Z"
+	tests := []struct {
+		side       string
+		wantBefore []string
+		wantAfter  []string
+	}{
+		{side: "", wantBefore: []string{"older", "same-a", "same-b", "same-d", "same-e"}, wantAfter: []string{"newer"}},
+		{side: "after", wantBefore: []string{"older"}, wantAfter: []string{"same-a", "same-b", "same-d", "same-e", "newer"}},
+		{side: "split", wantBefore: []string{"older", "same-a", "same-b"}, wantAfter: []string{"same-d", "same-e", "newer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("side="+tt.side, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/messages/test-index/same-c":
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"message": map[string]any{
+							"fields": map[string]any{"_id": "same-c", "timestamp": ts, "message": "target"},
+						},
+						"index": "test-index",
+					})
+				case "/api/views/search/sync":
+					call, err := parseContextSearchCall(r)
+					if err != nil {
+						t.Fatalf("failed to parse search call: %v", err)
+					}
+					// Each query returns a different, overlapping subset of the
+					// same-timestamp messages in arbitrary order.
+					if call.Order == "DESC" {
+						writeViewsSearchResponse(w, 5, []testLogMessage{
+							{ID: "same-e", Timestamp: ts, Message: "e"},
+							{ID: "same-c", Timestamp: ts, Message: "target"},
+							{ID: "same-a", Timestamp: ts, Message: "a"},
+							{ID: "older", Timestamp: "2023-12-31T23:59:59.000Z", Message: "older"},
+						})
+						return
+					}
+					writeViewsSearchResponse(w, 5, []testLogMessage{
+						{ID: "same-b", Timestamp: ts, Message: "b"},
+						{ID: "same-d", Timestamp: "2024-01-01T00:00:00Z", Message: "d"},
+						{ID: "same-a", Timestamp: ts, Message: "a"},
+						{ID: "newer", Timestamp: "2024-01-01T00:00:01.000Z", Message: "newer"},
+					})
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{
+				"message_id":     "same-c",
+				"index":          "test-index",
+				"before":         float64(10),
+				"after":          float64(10),
+				"same_timestamp": tt.side,
+			}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %v", result.Content)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			if got := extractContextMessageIDs(t, payload, "messages_before"); !reflect.DeepEqual(got, tt.wantBefore) {
+				t.Fatalf("messages_before = %v, want %v", got, tt.wantBefore)
+			}
+			if got := extractContextMessageIDs(t, payload, "messages_after"); !reflect.DeepEqual(got, tt.wantAfter) {
+				t.Fatalf("messages_after = %v, want %v", got, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestGetLogContextRejectsInvalidSameTimestamp(t *testing.T) {
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"message_id":     "id",
+		"index":          "idx",
+		"same_timestamp": "middle",
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error for invalid same_timestamp")
+	}
+}
+
+func TestGetLogContextWindowBoundsSearches(t *testing.T) {
+	tests := []struct {
+		name       string
+		window     any
+		targetTS   string
+		wantBefore [2]string
+		wantAfter  [2]string
+		wantNote   bool
+	}{
+		{
+			name:       "no window keeps wide bounds",
+			targetTS:   "2024-03-10T12:00:00.250Z",
+			wantBefore: [2]string{unboundedFrom, "2024-03-10T12:00:00.250Z"},
+			wantAfter:  [2]string{"2024-03-10T12:00:00.250Z", unboundedTo},
+		},
+		{
+			name:       "window around target",
+			window:     float64(90),
+			targetTS:   "2024-03-10T12:00:00.250Z",
+			wantBefore: [2]string{"2024-03-10T11:58:30.250Z", "2024-03-10T12:00:00.250Z"},
+			wantAfter:  [2]string{"2024-03-10T12:00:00.250Z", "2024-03-10T12:01:30.250Z"},
+		},
+		{
+			name:       "unparseable timestamp falls back",
+			window:     float64(90),
+			targetTS:   "not-a-time",
+			wantBefore: [2]string{unboundedFrom, "not-a-time"},
+			wantAfter:  [2]string{"not-a-time", unboundedTo},
+			wantNote:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges := map[string][2]string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/messages/test-index/target":
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"message": map[string]any{"fields": map[string]any{"_id": "target", "timestamp": tt.targetTS}},
+						"index":   "test-index",
+					})
+				case "/api/views/search/sync":
+					var body struct {
+						Queries []struct {
+							TimeRange struct {
+								From string `json:"from"`
+								To   string `json:"to"`
+							} `json:"timerange"`
+							SearchTypes []struct {
+								Sort []struct {
+									Order string `json:"order"`
+								} `json:"sort"`
+							} `json:"search_types"`
+						} `json:"queries"`
+					}
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Errorf("failed to decode search request: %v", err)
+					}
+					q := body.Queries[0]
+					ranges[q.SearchTypes[0].Sort[0].Order] = [2]string{q.TimeRange.From, q.TimeRange.To}
+					writeViewsSearchResponse(w, 0, nil)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"message_id": "target", "index": "test-index"}
+			if tt.window != nil {
+				req.Params.Arguments.(map[string]any)["window"] = tt.window
+			}
+			result, err := handler(context.Background(), req)
+			if err != nil || result.IsError {
+				t.Fatalf("unexpected failure: %v %+v", err, result)
+			}
+
+			if ranges["DESC"] != tt.wantBefore {
+				t.Errorf("before timerange = %v, want %v", ranges["DESC"], tt.wantBefore)
+			}
+			if ranges["ASC"] != tt.wantAfter {
+				t.Errorf("after timerange = %v, want %v", ranges["ASC"], tt.wantAfter)
+			}
+			if _, ok := decodeToolResultJSON(t, result)["window_note"]; ok != tt.wantNote {
+				t.Errorf("window_note present = %v, want %v", ok, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestGetLogContextRejectsOversizedWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("no request should be made for an invalid window, got %s", r.URL.Path)
+	}))
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	for _, window := range []float64{contextMaxWindowSeconds + 1, 1e9} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{"message_id": "target", "index": "test-index", "window": window}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; !result.IsError || !strings.Contains(text, "exceeds the maximum of 86400") {
+			t.Errorf("window %v: expected a cap error, got %q", window, text)
+		}
+	}
+}
+
+func TestGetLogContextDeduplicate(t *testing.T) {
+	msg := func(id, ts, text string) testLogMessage {
+		return testLogMessage{ID: id, Timestamp: "2024-01-01T00:00:" + ts + ".000Z", Source: "node-1", Message: text, Index: "test-index"}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/messages/test-index/target":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"message": map[string]any{"fields": map[string]any{"_id": "target", "timestamp": "2024-01-01T00:00:10.000Z", "source": "node-1", "message": "panic"}},
+				"index":   "test-index",
+			})
+		case "/api/views/search/sync":
+			call, err := parseContextSearchCall(r)
+			if err != nil {
+				t.Errorf("failed to parse search: %v", err)
+			}
+			if call.Order == "DESC" {
+				writeViewsSearchResponse(w, 6, []testLogMessage{
+					msg("b1", "09", "heartbeat ok"), msg("b2", "08", "heartbeat ok"), msg("b3", "07", "heartbeat ok"),
+					msg("b4", "06", "heartbeat ok"), msg("b5", "05", "disk full"), msg("b6", "04", "heartbeat ok"),
+				})
+				return
+			}
+			writeViewsSearchResponse(w, 4, []testLogMessage{
+				msg("a1", "11", "heartbeat ok"), msg("a2", "12", "heartbeat ok"), msg("a3", "13", "shutting down"), msg("a4", "14", "heartbeat ok"),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"message_id": "target", "index": "test-index", "before": float64(5), "after": float64(5), "deduplicate": true}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	payload := decodeToolResultJSON(t, result)
+	if payload["deduplicated"] != true {
+		t.Errorf("expected deduplicated=true, got %v", payload["deduplicated"])
+	}
+
+	type group struct {
+		text  string
+		count float64
+		first string
+	}
+	groups := func(key string) []group {
+		var out []group
+		for _, raw := range payload[key].([]any) {
+			g := raw.(map[string]any)
+			ids := g["message_ids"].([]any)
+			out = append(out, group{g["message"].(map[string]any)["message"].(string), g["count"].(float64), ids[0].(string)})
+		}
+		return out
+	}
+	wantBefore := []group{{"heartbeat ok", 5, "b6"}, {"disk full", 1, "b5"}}
+	if got := groups("messages_before"); !reflect.DeepEqual(got, wantBefore) {
+		t.Errorf("messages_before = %+v, want %+v", got, wantBefore)
+	}
+	wantAfter := []group{{"heartbeat ok", 3, "a1"}, {"shutting down", 1, "a3"}}
+	if got := groups("messages_after"); !reflect.DeepEqual(got, wantAfter) {
+		t.Errorf("messages_after = %+v, want %+v", got, wantAfter)
+	}
+	if payload["context_incomplete"] != true {
+		t.Errorf("expected context_incomplete with fewer groups than requested, got %v", payload["context_incomplete"])
+	}
+}
+
+func TestGetLogContextMergedTimeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/messages/test-index/target":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"message": map[string]any{
+					"fields": map[string]any{"_id": "target", "timestamp": "2024-01-01T10:00:05.000Z", "message": "boom"},
+				},
+				"index": "test-index",
+			})
+		case "/api/views/search/sync":
+			call, err := parseContextSearchCall(r)
+			if err != nil {
+				t.Fatalf("failed to parse search call: %v", err)
+			}
+			if call.Order == "DESC" {
+				writeViewsSearchResponse(w, 4, []testLogMessage{
+					{ID: "target", Timestamp: "2024-01-01T10:00:05.000Z", Message: "boom"},
+					{ID: "b3", Timestamp: "2024-01-01T10:00:04.000Z", Message: "retrying"},
+					{ID: "b2", Timestamp: "2024-01-01T10:00:03.000Z", Message: "slow"},
+					{ID: "b1", Timestamp: "2024-01-01T10:00:01.000Z", Message: "start"},
+				})
+				return
+			}
+			writeViewsSearchResponse(w, 3, []testLogMessage{
+				{ID: "target", Timestamp: "2024-01-01T10:00:05.000Z", Message: "boom"},
+				{ID: "a1", Timestamp: "2024-01-01T10:00:06.000Z", Message: "rollback"},
+				{ID: "a2", Timestamp: "2024-01-01T10:00:07.000Z", Message: "recovered"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getLogContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	for _, deduplicate := range []bool{false, true} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{
+			"message_id":  "target",
+			"index":       "test-index",
+			"before":      float64(2),
+			"after":       float64(5),
+			"merged":      true,
+			"deduplicate": deduplicate,
+		}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+
+		payload := decodeToolResultJSON(t, result)
+		for _, key := range []string{"target_message", "messages_before", "messages_after"} {
+			if _, ok := payload[key]; ok {
+				t.Errorf("deduplicate=%v: merged result should not have %q", deduplicate, key)
+			}
+		}
+		timeline, ok := payload["timeline"].([]any)
+		if !ok {
+			t.Fatalf("deduplicate=%v: expected timeline array, got %v", deduplicate, payload["timeline"])
+		}
+		var ids []string
+		var targets []int
+		for i, raw := range timeline {
+			entry := raw.(map[string]any)
+			if entry["is_target"] == true {
+				targets = append(targets, i)
+			}
+			if id, ok := entry["message"].(map[string]any)["_id"].(string); ok {
+				ids = append(ids, id)
+			} else {
+				// Dedup groups name their messages in message_ids.
+				ids = append(ids, entry["message_ids"].([]any)[0].(string))
+			}
+		}
+		if want := []string{"b2", "b3", "target", "a1", "a2"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("deduplicate=%v: timeline = %v, want %v", deduplicate, ids, want)
+		}
+		if !reflect.DeepEqual(targets, []int{2}) {
+			t.Errorf("deduplicate=%v: expected only entry 2 flagged is_target, got %v", deduplicate, targets)
+		}
+		if payload["context_incomplete"] != true {
+			t.Errorf("deduplicate=%v: expected context_incomplete with 2 of 5 after messages", deduplicate)
+		}
+	}
+}
+
+func TestFitContextResultTrimsMergedTimeline(t *testing.T) {
+	var before, after []graylog.MessageWrapper
+	for i := range 20 {
+		before = append(before, graylog.MessageWrapper{Message: graylog.Message{ID: fmt.Sprintf("b%02d", i), Message: strings.Repeat("x", 200)}})
+		after = append(after, graylog.MessageWrapper{Message: graylog.Message{ID: fmt.Sprintf("a%02d", i), Message: strings.Repeat("y", 200)}})
+	}
+	result := map[string]any{
+		"target_message":  &graylog.MessageWrapper{Message: graylog.Message{ID: "target", Message: "boom"}, Index: "idx"},
+		"messages_before": before,
+		"messages_after":  after,
+	}
+	mergeContextTimeline(result)
+
+	fitted, err := fitContextResult(context.Background(), result, 2000)
+	if err != nil {
+		t.Fatalf("fitContextResult returned error: %v", err)
+	}
+	payload := decodeToolResultJSON(t, fitted)
+	timeline, ok := payload["timeline"].([]any)
+	if !ok || len(timeline) >= 41 {
+		t.Fatalf("expected a trimmed timeline, got %v", payload)
+	}
+	if payload["context_incomplete"] != true {
+		t.Error("expected context_incomplete after trimming")
+	}
+	found := false
+	for _, raw := range timeline {
+		found = found || raw.(map[string]any)["is_target"] == true
+	}
+	if !found {
+		t.Error("expected the target to survive fitting")
+	}
+}
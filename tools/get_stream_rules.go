@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// streamRuleTypeNames maps Graylog's numeric StreamRuleType codes to readable names.
+var streamRuleTypeNames = map[int]string{
+	1: "exact",
+	2: "regex",
+	3: "greater",
+	4: "smaller",
+	5: "presence",
+	6: "contains",
+	7: "always_match",
+	8: "match_input",
+}
+
+// streamRuleTypeName returns the readable name for a rule type code,
+// falling back to "unknown(N)" for codes this server doesn't know about.
+func streamRuleTypeName(code int) string {
+	if name, ok := streamRuleTypeNames[code]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(code) + ")"
+}
+
+func getStreamRulesTool() mcp.Tool {
+	return mcp.NewTool("get_stream_rules",
+		mcp.WithDescription("Get the routing rules of a Graylog stream. Explains why messages land in a stream."),
+		mcp.WithString("stream_id",
+			mcp.Required(),
+			mcp.Description("Graylog stream ID (see list_streams)"),
+		),
+	)
+}
+
+func getStreamRulesHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		streamID := getStringParam(args, "stream_id")
+		if streamID == "" {
+			return toolError("'stream_id' parameter is required"), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.GetStreamRules(ctx, streamID)
+		if err != nil {
+			return graylogError("Failed to get stream rules", err), nil
+		}
+
+		type ruleOutput struct {
+			ID          string `json:"id"`
+			Field       string `json:"field"`
+			Type        string `json:"type"`
+			Value       string `json:"value"`
+			Inverted    bool   `json:"inverted"`
+			Description string `json:"description,omitempty"`
+		}
+
+		rules := make([]ruleOutput, 0, len(resp.StreamRules))
+		for _, r := range resp.StreamRules {
+			rules = append(rules, ruleOutput{
+				ID:          r.ID,
+				Field:       r.Field,
+				Type:        streamRuleTypeName(r.Type),
+				Value:       r.Value,
+				Inverted:    r.Inverted,
+				Description: r.Description,
+			})
+		}
+
+		return toolSuccess(map[string]any{
+			"stream_id": streamID,
+			"rules":     rules,
+			"total":     len(rules),
+		}), nil
+	}
+}
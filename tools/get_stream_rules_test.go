@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestGetStreamRulesTranslatesTypesAndEscapesID(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"stream_rules": []map[string]any{
+				{"id": "r1", "field": "source", "type": 1, "value": "web-01", "inverted": false},
+				{"id": "r2", "field": "message", "type": 2, "value": "^ERROR", "inverted": true},
+				{"id": "r3", "field": "level", "type": 5, "value": "", "inverted": false},
+				{"id": "r4", "field": "x", "type": 42, "value": "", "inverted": false},
+			},
+			"total": 4,
+		})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := getStreamRulesHandler(func(_ context.Context) *graylog.Client { return client })
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"stream_id": "abc/def"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	if gotPath != "/api/streams/abc%2Fdef/rules" {
+		t.Fatalf("expected path-escaped stream ID, got path %q", gotPath)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	rules, ok := payload["rules"].([]any)
+	if !ok || len(rules) != 4 {
+		t.Fatalf("expected 4 rules, got %v", payload["rules"])
+	}
+
+	wantTypes := []string{"exact", "regex", "presence", "unknown(42)"}
+	for i, want := range wantTypes {
+		rule := rules[i].(map[string]any)
+		if rule["type"] != want {
+			t.Errorf("rule %d: expected type %q, got %v", i, want, rule["type"])
+		}
+	}
+	if inverted := rules[1].(map[string]any)["inverted"]; inverted != true {
+		t.Errorf("expected rule r2 inverted=true, got %v", inverted)
+	}
+}
@@ -1,16 +1,24 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
 
+// graylogTimeLayout formats absolute time range bounds like Graylog's own
+// timestamps. Format UTC times with it.
+const graylogTimeLayout = "2006-01-02T15:04:05.000Z"
+
 // defaultMaxResultSize is the maximum response size in bytes for search and aggregation tools.
 const defaultMaxResultSize = 50000
 
@@ -33,10 +41,42 @@ func truncateString(s string, maxBytes int) string {
 	return s[:maxBytes] + "...[truncated]"
 }
 
+// fullMessageField is Graylog's optional long form of a message (often the
+// whole stack trace). It is truncated together with 'message'.
+const fullMessageField = "full_message"
+
+// truncateMessageText truncates a message's text and its full_message.
+func truncateMessageText(m *graylog.Message, maxLen int) {
+	m.Message = truncateString(m.Message, maxLen)
+	truncateMapText(m.Extra, fullMessageField, maxLen)
+}
+
+// truncateMessageFields truncates the string values of the named fields of
+// m, core or extra.
+func truncateMessageFields(m *graylog.Message, fields []string, maxLen int) {
+	for _, f := range fields {
+		switch f {
+		case "message":
+			m.Message = truncateString(m.Message, maxLen)
+		case "source":
+			m.Source = truncateString(m.Source, maxLen)
+		default:
+			truncateMapText(m.Extra, f, maxLen)
+		}
+	}
+}
+
+// truncateMapText truncates the string value of key in m, if any.
+func truncateMapText(m map[string]any, key string, maxLen int) {
+	if s, ok := m[key].(string); ok {
+		m[key] = truncateString(s, maxLen)
+	}
+}
+
 func toolSuccess(data any) *mcp.CallToolResult {
 	b, err := json.Marshal(data)
 	if err != nil {
-		return toolError(fmt.Sprintf("failed to marshal response: %v", err))
+		return classifiedError(errInternal, fmt.Sprintf("failed to marshal response: %v", err))
 	}
 	return mcp.NewToolResultText(string(b))
 }
@@ -45,16 +85,62 @@ func toolSuccessJSON(data []byte) *mcp.CallToolResult {
 	return mcp.NewToolResultText(string(data))
 }
 
-func toolError(msg string) *mcp.CallToolResult {
-	return &mcp.CallToolResult{
-		IsError: true,
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: msg,
-			},
-		},
+// addStructuredContent copies a successful result's JSON object text into
+// StructuredContent, so clients get typed data next to the text fallback.
+// The raw bytes are reused, so the two can't disagree. Results that aren't
+// a single JSON object are left as they are.
+func addStructuredContent(result *mcp.CallToolResult) {
+	if result == nil || result.IsError || len(result.Content) != 1 {
+		return
 	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		return
+	}
+	trimmed := bytes.TrimSpace([]byte(text.Text))
+	if len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return
+	}
+	result.StructuredContent = json.RawMessage(trimmed)
+}
+
+type prettyJSONKey struct{}
+
+// withPrettyJSON marks ctx so tool results are encoded with indentation.
+func withPrettyJSON(ctx context.Context) context.Context {
+	return context.WithValue(ctx, prettyJSONKey{}, true)
+}
+
+// resultMarshaler returns the JSON encoder selected for ctx: indented when the
+// call asked for pretty output, compact otherwise.
+func resultMarshaler(ctx context.Context) func(v any) ([]byte, error) {
+	if pretty, _ := ctx.Value(prettyJSONKey{}).(bool); pretty {
+		return func(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+	}
+	return json.Marshal
+}
+
+// indentResultJSON indents the JSON text content of result in place. Already
+// indented or non-JSON content is left as it is.
+func indentResultJSON(result *mcp.CallToolResult) {
+	for i, c := range result.Content {
+		text, ok := c.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(text.Text), "", "  "); err != nil {
+			continue
+		}
+		text.Text = buf.String()
+		result.Content[i] = text
+	}
+}
+
+// toolError reports a problem with the caller's arguments. Failures of other
+// kinds go through classifiedError or graylogError.
+func toolError(msg string) *mcp.CallToolResult {
+	return classifiedError(errInvalidInput, msg)
 }
 
 func getStringParam(args map[string]any, key string) string {
@@ -104,6 +190,42 @@ func getStrictNonNegativeIntParam(args map[string]any, key string, defaultVal in
 	return value, nil
 }
 
+// getStreamIDsParam returns the stream filter for a tool call: the explicit
+// stream_id argument if given, otherwise the configured default stream (if any).
+func getStreamIDsParam(args map[string]any, cfg *config.Config) []string {
+	if streamID := getStringParam(args, "stream_id"); streamID != "" {
+		return []string{streamID}
+	}
+	if cfg != nil && cfg.DefaultStreamID != "" {
+		return []string{cfg.DefaultStreamID}
+	}
+	return nil
+}
+
+// getFieldsParam returns the fields projection for a tool call: the explicit
+// fields argument if given, otherwise the configured default (if any). An
+// explicit '*' matches every field, bypassing the default.
+func getFieldsParam(args map[string]any, cfg *config.Config) string {
+	if fields := getStringParam(args, "fields"); fields != "" {
+		return fields
+	}
+	if cfg != nil {
+		return cfg.DefaultFields
+	}
+	return ""
+}
+
+// getCommaListParam splits a comma-separated string param into trimmed, non-empty items.
+func getCommaListParam(args map[string]any, key string) []string {
+	var items []string
+	for _, item := range strings.Split(getStringParam(args, key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getBoolParam(args map[string]any, key string) bool {
 	if v, ok := args[key]; ok {
 		if b, ok := v.(bool); ok {
@@ -113,11 +235,42 @@ func getBoolParam(args map[string]any, key string) bool {
 	return false
 }
 
-// filterMessageExtraFields removes Extra map entries not in fieldSet from a Message.
+// luceneSpecialChars are the characters Lucene's query syntax gives a meaning
+// to; '&' and '|' only as "&&" and "||", but escaping them singly is harmless.
+const luceneSpecialChars = `+-&|!(){}[]^"~*?:\/`
+
+// luceneOperators are the words Lucene parses as operators rather than terms
+// when they stand alone. Escaping characters can't neutralize them, so they
+// are quoted.
+var luceneOperators = map[string]bool{"AND": true, "OR": true, "NOT": true, "TO": true}
+
+// escapeLuceneValue renders v as a single Lucene term matching it literally:
+// every special character is backslash-escaped, and values containing
+// whitespace, empty ones and bare operators (AND, OR, NOT, TO) are wrapped in
+// double quotes as a phrase.
+func escapeLuceneValue(v any) string {
+	s := fmt.Sprint(v)
+	var b strings.Builder
+	quote := s == "" || luceneOperators[s]
+	for _, r := range s {
+		if strings.ContainsRune(luceneSpecialChars, r) {
+			b.WriteByte('\\')
+		} else if unicode.IsSpace(r) {
+			quote = true
+		}
+		b.WriteRune(r)
+	}
+	if quote {
+		return `"` + b.String() + `"`
+	}
+	return b.String()
+}
+
+// filterMessageExtraFields removes Extra map entries the filter doesn't select from a Message.
 // Known struct fields (_id, timestamp, source, message) are unaffected.
-func filterMessageExtraFields(extra map[string]any, fieldSet map[string]bool) {
+func filterMessageExtraFields(extra map[string]any, filter *graylog.FieldFilter) {
 	for k := range extra {
-		if !fieldSet[k] {
+		if !filter.Match(k) {
 			delete(extra, k)
 		}
 	}
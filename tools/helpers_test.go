@@ -0,0 +1,47 @@
+package tools
+
+import "testing"
+
+func TestEscapeLuceneValue(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"web-1", `web\-1`},
+		{"a+b", `a\+b`},
+		{"a&&b", `a\&\&b`},
+		{"a||b", `a\|\|b`},
+		{"!a", `\!a`},
+		{"f(x)", `f\(x\)`},
+		{"{a}", `\{a\}`},
+		{"[a]", `\[a\]`},
+		{"a^2", `a\^2`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"a~", `a\~`},
+		{"a*", `a\*`},
+		{"a?", `a\?`},
+		{"host:9000", `host\:9000`},
+		{`DOMAIN\user`, `DOMAIN\\user`},
+		{"/var/log", `\/var\/log`},
+		{"plain", "plain"},
+		{"two words", `"two words"`},
+		{"tab\there", "\"tab\there\""},
+		{"line\nbreak", "\"line\nbreak\""},
+		{"", `""`},
+		{"OR", `"OR"`},
+		{"AND", `"AND"`},
+		{"NOT", `"NOT"`},
+		{"TO", `"TO"`},
+		{"or", "or"},
+		{"ORACLE", "ORACLE"},
+		{"ünïcode", "ünïcode"},
+		{42, "42"},
+		{-1.5, `\-1.5`},
+		{true, "true"},
+	}
+	for _, tt := range tests {
+		if got := escapeLuceneValue(tt.in); got != tt.want {
+			t.Errorf("escapeLuceneValue(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
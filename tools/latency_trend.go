@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	latencyTrendDefaultPercentile = 95
+	latencyTrendDefaultInterval   = "5m"
+	latencyTrendDefaultRange      = 3600
+)
+
+func latencyTrendTool() mcp.Tool {
+	return mcp.NewTool("latency_trend",
+		mcp.WithDescription("Show how a latency percentile (p95 by default) of a numeric field changes over time. Buckets the time range and returns one row per bucket, oldest first, with the percentile and the message count."),
+		mcp.WithString("field",
+			mcp.Required(),
+			mcp.Description("Numeric field to measure (e.g. 'took_ms', 'response_time')"),
+		),
+		mcp.WithNumber("percentile",
+			mcp.Description("Percentile to compute, greater than 0 and at most 100 (default: 95)"),
+		),
+		mcp.WithString("interval",
+			mcp.Description("Bucket size as '<amount><unit>' with unit s, m, h, d, w, M or y (default: '5m')"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Lucene query selecting the measured messages (default: '*')"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 3600). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+	)
+}
+
+func latencyTrendHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		field := getStringParam(args, "field")
+		if field == "" {
+			return toolError("'field' parameter is required"), nil
+		}
+		if nonAggregatableFields[field] {
+			return toolError(fmt.Sprintf("field '%s' is a full-text analyzed field, not a number; use a numeric field like 'took_ms'", field)), nil
+		}
+
+		percentile := float64(latencyTrendDefaultPercentile)
+		if v, ok := args["percentile"]; ok {
+			p, ok := v.(float64)
+			if !ok || math.IsNaN(p) || p <= 0 || p > 100 {
+				return toolError(fmt.Sprintf("'percentile' must be a number greater than 0 and at most 100, got %v", v)), nil
+			}
+			percentile = p
+		}
+
+		interval := getStringParam(args, "interval")
+		if interval == "" {
+			interval = latencyTrendDefaultInterval
+		}
+		if !timeUnitPattern.MatchString(interval) {
+			return toolError(fmt.Sprintf("invalid 'interval' %q: use '<amount><unit>' with unit s, m, h, d, w, M or y (e.g. '5m')", interval)), nil
+		}
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = "*"
+		}
+
+		tr, err := parseTimeRange(args, latencyTrendDefaultRange)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		req := graylog.ScriptingAggregateRequest{
+			Query:     query,
+			TimeRange: tr.scripting(),
+			GroupBy:   []graylog.ScriptingGrouping{{Field: "timestamp", TimeUnit: interval}},
+			Metrics: []graylog.ScriptingMetric{
+				{Function: "percentile", Field: field, Configuration: &graylog.ScriptingMetricConfig{Percentile: percentile}},
+				{Function: "count"},
+			},
+			Streams: getStreamIDsParam(args, cfg),
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.Aggregate(ctx, req)
+		if err != nil {
+			return aggregateError("Latency trend aggregation failed", err), nil
+		}
+
+		label := percentileLabel(percentile)
+		buckets := latencyBuckets(resp, label)
+		result := map[string]any{
+			"field":         field,
+			"percentile":    percentile,
+			"interval":      interval,
+			"query":         query,
+			"buckets":       buckets,
+			"total_buckets": len(buckets),
+		}
+		return fitLatencyTrendResult(ctx, result, defaultMaxResultSize)
+	}
+}
+
+// percentileLabel names the percentile column, e.g. "p95" or "p99.9".
+func percentileLabel(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// latencyBuckets turns a timestamp-bucketed percentile+count response into
+// rows of {bucket_timestamp, <label>, count}, oldest bucket first. Bucket
+// times are normalized like aggregate_logs time buckets.
+func latencyBuckets(resp *graylog.ScriptingTabularResponse, label string) []map[string]any {
+	timeCol, pctCol, countCol := -1, -1, -1
+	for j, entry := range resp.Schema {
+		switch {
+		case entry.ColumnType == "grouping" && entry.Field == "timestamp":
+			timeCol = j
+		case entry.ColumnType == "metric" && entry.Function == "percentile":
+			pctCol = j
+		case entry.ColumnType == "metric" && entry.Function == "count":
+			countCol = j
+		}
+	}
+
+	buckets := make([]map[string]any, 0, len(resp.DataRows))
+	if timeCol < 0 {
+		return buckets
+	}
+	cell := func(row []any, j int) any {
+		if j < 0 || j >= len(row) {
+			return nil
+		}
+		return row[j]
+	}
+	for _, row := range resp.DataRows {
+		buckets = append(buckets, map[string]any{
+			"bucket_timestamp": formatBucketTime(cell(row, timeCol)),
+			label:              cell(row, pctCol),
+			"count":            cell(row, countCol),
+		})
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		return fmt.Sprint(buckets[i]["bucket_timestamp"]) < fmt.Sprint(buckets[j]["bucket_timestamp"])
+	})
+	return buckets
+}
+
+// fitLatencyTrendResult drops the oldest buckets first, keeping the recent
+// end of the trend.
+func fitLatencyTrendResult(ctx context.Context, result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
+	return fitResult(ctx, result, maxSize, resultAdapter{
+		truncateMsgs: func(int) {},
+		reduceMsgs: func() bool {
+			buckets, _ := result["buckets"].([]map[string]any)
+			if len(buckets) <= 1 {
+				return false
+			}
+			result["buckets"] = buckets[len(buckets)/2:]
+			result["note"] = "oldest buckets dropped to fit the response; use a larger interval or a shorter range"
+			return true
+		},
+	})
+}
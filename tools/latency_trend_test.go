@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestLatencyTrendHandler(t *testing.T) {
+	var got graylog.ScriptingAggregateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"date","field":"timestamp","name":"grouping: timestamp"},
+				{"column_type":"metric","type":"numeric","function":"percentile","field":"took_ms","name":"metric: percentile(took_ms)"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": [
+				[1705316400000, 240.5, 7],
+				["2024-01-15T10:00:00.000Z", 180, 12]
+			],
+			"metadata": {}
+		}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := latencyTrendHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"field": "took_ms", "percentile": float64(99), "interval": "1h"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	if len(got.GroupBy) != 1 || got.GroupBy[0].Field != "timestamp" || got.GroupBy[0].TimeUnit != "1h" {
+		t.Fatalf("expected timestamp grouping by 1h, got %+v", got.GroupBy)
+	}
+	if len(got.Metrics) != 2 || got.Metrics[0].Function != "percentile" || got.Metrics[0].Field != "took_ms" ||
+		got.Metrics[0].Configuration == nil || got.Metrics[0].Configuration.Percentile != 99 {
+		t.Fatalf("expected percentile(took_ms, 99) metric first, got %+v", got.Metrics)
+	}
+	if got.TimeRange.Type != "relative" || got.TimeRange.Range != 3600 {
+		t.Fatalf("expected relative 3600s range by default, got %+v", got.TimeRange)
+	}
+
+	data := decodeToolResultJSON(t, result)
+	buckets := data["buckets"].([]any)
+	want := []struct {
+		bucket string
+		p99    float64
+		count  float64
+	}{
+		{"2024-01-15T10:00:00Z", 180, 12},
+		{"2024-01-15T11:00:00Z", 240.5, 7},
+	}
+	if len(buckets) != len(want) {
+		t.Fatalf("expected %d buckets, got %d", len(want), len(buckets))
+	}
+	for i, raw := range buckets {
+		b := raw.(map[string]any)
+		if b["bucket_timestamp"] != want[i].bucket || b["p99"] != want[i].p99 || b["count"] != want[i].count {
+			t.Errorf("bucket %d: expected %+v, got %v", i, want[i], b)
+		}
+	}
+}
+
+func TestLatencyTrendHandlerValidation(t *testing.T) {
+	handler := latencyTrendHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr string
+	}{
+		{name: "missing field", args: map[string]any{}, wantErr: "'field' parameter is required"},
+		{name: "analyzed field", args: map[string]any{"field": "message"}, wantErr: "full-text analyzed"},
+		{name: "zero percentile", args: map[string]any{"field": "took_ms", "percentile": float64(0)}, wantErr: "'percentile'"},
+		{name: "percentile over 100", args: map[string]any{"field": "took_ms", "percentile": float64(101)}, wantErr: "'percentile'"},
+		{name: "bad interval", args: map[string]any{"field": "took_ms", "interval": "5 minutes"}, wantErr: "invalid 'interval'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			text := result.Content[0].(mcp.TextContent).Text
+			if !strings.Contains(text, tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, text)
+			}
+		})
+	}
+}
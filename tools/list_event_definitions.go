@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// eventPriorityNames maps Graylog's numeric event priorities to readable names.
+var eventPriorityNames = map[int]string{
+	1: "low",
+	2: "normal",
+	3: "high",
+}
+
+// eventPriorityName returns the readable name for a priority,
+// falling back to "unknown(N)" for values this server doesn't know about.
+func eventPriorityName(priority int) string {
+	if name, ok := eventPriorityNames[priority]; ok {
+		return name
+	}
+	return "unknown(" + strconv.Itoa(priority) + ")"
+}
+
+func listEventDefinitionsTool() mcp.Tool {
+	return mcp.NewTool("list_event_definitions",
+		mcp.WithDescription("List Graylog event definitions (alert rules) with their priority and condition type. Use it to interpret events and alerts."),
+		mcp.WithString("title_filter",
+			mcp.Description("Optional substring filter for event definition titles (case-insensitive)"),
+		),
+	)
+}
+
+func listEventDefinitionsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		titleFilter := strings.ToLower(getStringParam(args, "title_filter"))
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.GetEventDefinitions(ctx)
+		if err != nil {
+			return graylogError("Failed to get event definitions", err), nil
+		}
+
+		type definitionOutput struct {
+			ID            string `json:"id"`
+			Title         string `json:"title"`
+			Description   string `json:"description,omitempty"`
+			Priority      string `json:"priority"`
+			ConditionType string `json:"condition_type"`
+			Query         string `json:"query,omitempty"`
+			Alert         bool   `json:"alert"`
+			State         string `json:"state,omitempty"`
+		}
+
+		definitions := make([]definitionOutput, 0, len(resp.EventDefinitions))
+		for _, d := range resp.EventDefinitions {
+			if titleFilter != "" && !strings.Contains(strings.ToLower(d.Title), titleFilter) {
+				continue
+			}
+			definitions = append(definitions, definitionOutput{
+				ID:            d.ID,
+				Title:         d.Title,
+				Description:   d.Description,
+				Priority:      eventPriorityName(d.Priority),
+				ConditionType: d.Config.Type,
+				Query:         d.Config.Query,
+				Alert:         d.Alert,
+				State:         d.State,
+			})
+		}
+
+		return toolSuccess(map[string]any{
+			"event_definitions": definitions,
+			"total":             len(definitions),
+		}), nil
+	}
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const eventDefinitionsJSON = `{"event_definitions":[
+	{"id":"ed-1","title":"Too many login failures","description":"More than 10 failed logins in 5 minutes","priority":3,"alert":true,"state":"ENABLED",
+	 "config":{"type":"aggregation-v1","query":"action:login AND result:failure","streams":["s-1"],"series":[{"id":"count-","type":"count"}]}},
+	{"id":"ed-2","title":"Disk usage","priority":2,"alert":false,"state":"DISABLED","config":{"type":"correlation-v1"}},
+	{"id":"ed-3","title":"System notifications","priority":7,"alert":true,"config":{"type":"system-notifications-v1"}}
+],"total":3,"page":1,"per_page":100,"count":3}`
+
+func TestListEventDefinitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantIDs []string
+	}{
+		{name: "all", args: map[string]any{}, wantIDs: []string{"ed-1", "ed-2", "ed-3"}},
+		{name: "title filter", args: map[string]any{"title_filter": "LOGIN"}, wantIDs: []string{"ed-1"}},
+		{name: "no match", args: map[string]any{"title_filter": "nothing"}, wantIDs: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/events/definitions" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(eventDefinitionsJSON))
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := listEventDefinitionsHandler(func(_ context.Context) *graylog.Client { return client })
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			defs := payload["event_definitions"].([]any)
+			ids := make([]string, 0, len(defs))
+			for _, raw := range defs {
+				ids = append(ids, raw.(map[string]any)["id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Fatalf("expected ids %v, got %v", tt.wantIDs, ids)
+			}
+			if payload["total"] != float64(len(tt.wantIDs)) {
+				t.Errorf("expected total %d, got %v", len(tt.wantIDs), payload["total"])
+			}
+		})
+	}
+}
+
+func TestListEventDefinitionsParsesFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(eventDefinitionsJSON))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := listEventDefinitionsHandler(func(_ context.Context) *graylog.Client { return client })
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	payload := decodeToolResultJSON(t, result)
+	defs := payload["event_definitions"].([]any)
+
+	want := []map[string]any{
+		{
+			"id": "ed-1", "title": "Too many login failures", "description": "More than 10 failed logins in 5 minutes",
+			"priority": "high", "condition_type": "aggregation-v1", "query": "action:login AND result:failure",
+			"alert": true, "state": "ENABLED",
+		},
+		{"id": "ed-2", "title": "Disk usage", "priority": "normal", "condition_type": "correlation-v1", "alert": false, "state": "DISABLED"},
+		{"id": "ed-3", "title": "System notifications", "priority": "unknown(7)", "condition_type": "system-notifications-v1", "alert": true},
+	}
+	for i, raw := range defs {
+		if !reflect.DeepEqual(raw, want[i]) {
+			t.Errorf("definition %d = %v, want %v", i, raw, want[i])
+		}
+	}
+}
@@ -2,7 +2,6 @@ package tools
 
 import (
 	"context"
-	"sort"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -18,36 +17,44 @@ func listFieldsTool() mcp.Tool {
 	)
 }
 
-func listFieldsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func listFieldsHandler(getClient ClientFunc, cache *metadataCache) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
-		nameFilter := strings.ToLower(getStringParam(args, "name_filter"))
+		nameFilter := getStringParam(args, "name_filter")
 
 		c := getClient(ctx)
 		if c == nil {
-			return toolError("no Graylog credentials: Authorization header required"), nil
+			return noCredentialsError(), nil
 		}
-		resp, err := c.GetFields(ctx)
+		fields, err := fieldNames(ctx, c, cache, nameFilter)
 		if err != nil {
-			if apiErr, ok := err.(*graylog.APIError); ok {
-				return toolError(apiErr.Error()), nil
-			}
-			return toolError("Failed to get fields: " + err.Error()), nil
+			return graylogError("Failed to get fields", err), nil
 		}
 
-		var fields []string
-		for name := range resp {
-			if nameFilter != "" && !strings.Contains(strings.ToLower(name), nameFilter) {
-				continue
-			}
-			fields = append(fields, name)
-		}
-
-		sort.Strings(fields)
-
 		return toolSuccess(map[string]any{
 			"fields": fields,
 			"total":  len(fields),
 		}), nil
 	}
 }
+
+// fieldNames returns the sorted field names containing nameFilter
+// (case-insensitive; empty matches all).
+func fieldNames(ctx context.Context, c *graylog.Client, cache *metadataCache, nameFilter string) ([]string, error) {
+	resp, err := cachedFetch(cache, "fields|"+c.CacheKey(), func() (graylog.FieldsResponse, error) {
+		return c.GetFields(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nameFilter = strings.ToLower(nameFilter)
+	var fields []string
+	for _, name := range resp.Names() {
+		if nameFilter != "" && !strings.Contains(strings.ToLower(name), nameFilter) {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
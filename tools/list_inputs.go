@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func listInputsTool() mcp.Tool {
+	return mcp.NewTool("list_inputs",
+		mcp.WithDescription("List Graylog inputs with their running state on each cluster node. Use it to check whether an input stopped when logs stop flowing."),
+		mcp.WithString("title_filter",
+			mcp.Description("Optional substring filter for input titles (case-insensitive)"),
+		),
+	)
+}
+
+// inputNodeState is an input's state on one node. Node is empty when only the
+// node answering the request could be asked.
+type inputNodeState struct {
+	Node            string `json:"node,omitempty"`
+	State           string `json:"state"`
+	StartedAt       string `json:"started_at,omitempty"`
+	DetailedMessage string `json:"detailed_message,omitempty"`
+}
+
+// inputStates holds input states by node ID, then input ID. A nil inner map is
+// a node Graylog couldn't reach. local marks states from the answering node
+// alone, keyed by "".
+type inputStates struct {
+	byNode map[string]map[string]graylog.InputState
+	local  bool
+}
+
+// fetchInputStates asks every node for its input states, falling back to the
+// answering node's own states if the cluster endpoint fails.
+func fetchInputStates(ctx context.Context, c *graylog.Client) (*inputStates, error) {
+	cluster, err := c.GetClusterInputStates(ctx)
+	if err == nil {
+		states := &inputStates{byNode: make(map[string]map[string]graylog.InputState, len(cluster))}
+		for node, list := range cluster {
+			states.byNode[node] = nil
+			if list != nil {
+				states.byNode[node] = indexInputStates(list)
+			}
+		}
+		return states, nil
+	}
+	local, localErr := c.GetInputStates(ctx)
+	if localErr != nil {
+		return nil, err
+	}
+	return &inputStates{byNode: map[string]map[string]graylog.InputState{"": indexInputStates(local.States)}, local: true}, nil
+}
+
+func indexInputStates(list []graylog.InputState) map[string]graylog.InputState {
+	byID := make(map[string]graylog.InputState, len(list))
+	for _, st := range list {
+		byID[st.ID] = st
+	}
+	return byID
+}
+
+// nodeStates returns in's state on each node expected to run it: every node
+// for a global input, its own node otherwise. A node that answered without
+// listing the input isn't running it; an unreachable one is UNKNOWN. With
+// local states only, an input the answering node doesn't list may run on
+// another node, so it is UNKNOWN too.
+func (s *inputStates) nodeStates(in graylog.Input) []inputNodeState {
+	var out []inputNodeState
+	for _, node := range slices.Sorted(maps.Keys(s.byNode)) {
+		byID := s.byNode[node]
+		expected := s.local || in.Global || node == in.Node
+		st, listed := byID[in.ID]
+		switch {
+		case listed:
+			out = append(out, inputNodeState{Node: node, State: st.State, StartedAt: st.StartedAt, DetailedMessage: st.DetailedMessage})
+		case !expected:
+		case byID == nil || s.local:
+			out = append(out, inputNodeState{Node: node, State: "UNKNOWN"})
+		default:
+			out = append(out, inputNodeState{Node: node, State: "NOT_RUNNING"})
+		}
+	}
+	if _, known := s.byNode[in.Node]; !s.local && !in.Global && in.Node != "" && !known {
+		out = append(out, inputNodeState{Node: in.Node, State: "UNKNOWN"})
+	}
+	return out
+}
+
+// summarizeInputState folds per-node states into one: the shared state if all
+// nodes agree, MIXED if they don't, NOT_RUNNING if no node reports the input.
+func summarizeInputState(nodes []inputNodeState) (state string, running bool) {
+	for _, n := range nodes {
+		running = running || n.State == "RUNNING"
+		switch {
+		case state == "":
+			state = n.State
+		case state != n.State:
+			state = "MIXED"
+		}
+	}
+	if state == "" {
+		state = "NOT_RUNNING"
+	}
+	return state, running
+}
+
+func listInputsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		titleFilter := strings.ToLower(getStringParam(args, "title_filter"))
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.GetInputs(ctx)
+		if err != nil {
+			return graylogError("Failed to get inputs", err), nil
+		}
+
+		result := map[string]any{}
+		states, err := fetchInputStates(ctx, c)
+		if err != nil {
+			result["states_error"] = err.Error()
+		} else if states.local {
+			result["states_note"] = "cluster input states are unavailable; states come from the node that answered, so inputs it doesn't run are UNKNOWN"
+		}
+
+		type inputOutput struct {
+			ID      string           `json:"id"`
+			Title   string           `json:"title"`
+			Type    string           `json:"type"`
+			Global  bool             `json:"global"`
+			Node    string           `json:"node,omitempty"`
+			State   string           `json:"state"`
+			Running bool             `json:"running"`
+			Nodes   []inputNodeState `json:"nodes,omitempty"`
+		}
+
+		inputs := make([]inputOutput, 0, len(resp.Inputs))
+		for _, in := range resp.Inputs {
+			if titleFilter != "" && !strings.Contains(strings.ToLower(in.Title), titleFilter) {
+				continue
+			}
+			out := inputOutput{
+				ID:     in.ID,
+				Title:  in.Title,
+				Type:   in.Type,
+				Global: in.Global,
+				Node:   in.Node,
+				State:  "UNKNOWN",
+			}
+			if states != nil {
+				out.Nodes = states.nodeStates(in)
+				out.State, out.Running = summarizeInputState(out.Nodes)
+			}
+			inputs = append(inputs, out)
+		}
+
+		result["inputs"] = inputs
+		result["total"] = len(inputs)
+		return toolSuccess(result), nil
+	}
+}
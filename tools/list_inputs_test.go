@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const testInputsJSON = `{"inputs":[
+	{"id":"in-1","title":"Syslog UDP","type":"org.graylog2.inputs.syslog.udp.SyslogUDPInput","global":true},
+	{"id":"in-2","title":"GELF TCP","type":"org.graylog2.inputs.gelf.tcp.GELFTCPInput","global":false,"node":"node-1"},
+	{"id":"in-3","title":"Beats","type":"org.graylog.plugins.beats.Beats2Input","global":true},
+	{"id":"in-4","title":"Raw TCP","type":"org.graylog2.inputs.raw.tcp.RawTCPInput","global":false,"node":"node-2"}
+],"total":4}`
+
+func listInputsStates(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)) (map[string]any, map[string]map[string]any) {
+	t.Helper()
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	payload := decodeToolResultJSON(t, result)
+	byID := make(map[string]map[string]any)
+	for _, raw := range payload["inputs"].([]any) {
+		in := raw.(map[string]any)
+		byID[in["id"].(string)] = in
+	}
+	return payload, byID
+}
+
+func TestListInputsJoinsClusterStates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/system/inputs":
+			_, _ = w.Write([]byte(testInputsJSON))
+		case "/api/cluster/inputstates":
+			_, _ = w.Write([]byte(`{
+				"node-1":[
+					{"id":"in-1","state":"RUNNING","started_at":"2024-01-01T00:00:00.000Z"},
+					{"id":"in-2","state":"FAILED","detailed_message":"bind: address already in use"}
+				],
+				"node-2":[
+					{"id":"in-1","state":"RUNNING"},
+					{"id":"in-4","state":"RUNNING"}
+				]
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := listInputsHandler(func(_ context.Context) *graylog.Client { return client })
+
+	payload, inputs := listInputsStates(t, handler)
+	if len(inputs) != 4 {
+		t.Fatalf("expected 4 inputs, got %d", len(inputs))
+	}
+	want := map[string]struct {
+		state   string
+		running bool
+		nodes   int
+	}{
+		"in-1": {"RUNNING", true, 2},
+		"in-2": {"FAILED", false, 1},
+		"in-3": {"NOT_RUNNING", false, 2},
+		// Running on a node other than the first one listed.
+		"in-4": {"RUNNING", true, 1},
+	}
+	for id, w := range want {
+		in := inputs[id]
+		if in["state"] != w.state || in["running"] != w.running || len(in["nodes"].([]any)) != w.nodes {
+			t.Errorf("input %s: expected state=%s running=%v on %d nodes, got state=%v running=%v nodes=%v", id, w.state, w.running, w.nodes, in["state"], in["running"], in["nodes"])
+		}
+	}
+	failed := inputs["in-2"]["nodes"].([]any)[0].(map[string]any)
+	if failed["node"] != "node-1" || failed["detailed_message"] != "bind: address already in use" {
+		t.Errorf("expected failure detail for node-1, got %v", failed)
+	}
+	if _, ok := payload["states_note"]; ok {
+		t.Errorf("unexpected states_note with cluster states: %v", payload["states_note"])
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"title_filter": "gelf"}
+	result, _ := handler(context.Background(), req)
+	payload = decodeToolResultJSON(t, result)
+	if payload["total"] != float64(1) {
+		t.Fatalf("expected title_filter to match 1 input, got %v", payload["total"])
+	}
+}
+
+func TestListInputsUnreachableNode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/system/inputs":
+			_, _ = w.Write([]byte(testInputsJSON))
+		case "/api/cluster/inputstates":
+			_, _ = w.Write([]byte(`{"node-1":[{"id":"in-1","state":"RUNNING"},{"id":"in-2","state":"RUNNING"}],"node-2":null}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	_, inputs := listInputsStates(t, listInputsHandler(func(_ context.Context) *graylog.Client { return client }))
+
+	want := map[string]string{"in-1": "MIXED", "in-2": "RUNNING", "in-3": "MIXED", "in-4": "UNKNOWN"}
+	for id, state := range want {
+		if inputs[id]["state"] != state {
+			t.Errorf("input %s: expected state %s, got %v (nodes %v)", id, state, inputs[id]["state"], inputs[id]["nodes"])
+		}
+	}
+	if inputs["in-1"]["running"] != true {
+		t.Error("expected an input running on a reachable node to be running")
+	}
+}
+
+func TestListInputsFallsBackToLocalStates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/system/inputs":
+			_, _ = w.Write([]byte(testInputsJSON))
+		case "/api/system/inputstates":
+			_, _ = w.Write([]byte(`{"states":[{"id":"in-1","state":"RUNNING"},{"id":"in-2","state":"FAILED"}]}`))
+		default:
+			http.Error(w, `{"message":"forbidden"}`, http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	payload, inputs := listInputsStates(t, listInputsHandler(func(_ context.Context) *graylog.Client { return client }))
+
+	// Inputs the answering node doesn't list may run elsewhere.
+	want := map[string]string{"in-1": "RUNNING", "in-2": "FAILED", "in-3": "UNKNOWN", "in-4": "UNKNOWN"}
+	for id, state := range want {
+		if inputs[id]["state"] != state {
+			t.Errorf("input %s: expected state %s, got %v", id, state, inputs[id]["state"])
+		}
+	}
+	if _, ok := payload["states_note"]; !ok {
+		t.Error("expected states_note when only local states are available")
+	}
+}
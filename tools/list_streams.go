@@ -2,7 +2,11 @@ package tools
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/n0madic/graylog-mcp/graylog"
@@ -14,52 +18,159 @@ func listStreamsTool() mcp.Tool {
 		mcp.WithString("title_filter",
 			mcp.Description("Optional substring filter for stream titles (case-insensitive)"),
 		),
+		mcp.WithBoolean("readable_only",
+			mcp.Description("Only list streams the authenticated user may read, according to its Graylog permissions (default: true)"),
+		),
 	)
 }
 
-func listStreamsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func listStreamsHandler(getClient ClientFunc, cache *metadataCache) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
-		titleFilter := strings.ToLower(getStringParam(args, "title_filter"))
+		titleFilter := getStringParam(args, "title_filter")
+		readableOnly := true
+		if v, ok := args["readable_only"].(bool); ok {
+			readableOnly = v
+		}
 
 		c := getClient(ctx)
 		if c == nil {
-			return toolError("no Graylog credentials: Authorization header required"), nil
+			return noCredentialsError(), nil
 		}
-		resp, err := c.GetStreams(ctx)
+		streams, err := enabledStreams(ctx, c, cache, titleFilter)
 		if err != nil {
-			if apiErr, ok := err.(*graylog.APIError); ok {
-				return toolError(apiErr.Error()), nil
+			return graylogError("Failed to get streams", err), nil
+		}
+
+		result := map[string]any{}
+		if readableOnly {
+			// A failed permission lookup shouldn't hide the streams; list
+			// them all and say the filter wasn't applied.
+			user, err := cachedFetch(cache, "user|"+c.CacheKey(), func() (*graylog.CurrentUser, error) {
+				return c.GetCurrentUser(ctx)
+			})
+			if err != nil {
+				result["warning"] = "could not check stream permissions, listing all streams: " + err.Error()
+			} else {
+				streams = filterReadableStreams(streams, user)
 			}
-			return toolError("Failed to get streams: " + err.Error()), nil
 		}
+		if streams == nil {
+			streams = []streamSummary{}
+		}
+		result["streams"] = streams
+		result["total"] = len(streams)
+		return toolSuccess(result), nil
+	}
+}
 
-		type streamOutput struct {
-			ID          string `json:"id"`
-			Title       string `json:"title"`
-			Description string `json:"description"`
-			IndexSetID  string `json:"index_set_id"`
+// filterReadableStreams keeps the streams user may read, as decided by
+// readableStreams.
+func filterReadableStreams(streams []streamSummary, user *graylog.CurrentUser) []streamSummary {
+	all, ids := readableStreams(user)
+	if all {
+		return streams
+	}
+	var readable []streamSummary
+	for _, s := range streams {
+		if slices.Contains(ids, s.ID) {
+			readable = append(readable, s)
 		}
+	}
+	return readable
+}
 
-		var streams []streamOutput
-		for _, s := range resp.Streams {
-			if s.Disabled {
-				continue
-			}
-			if titleFilter != "" && !strings.Contains(strings.ToLower(s.Title), titleFilter) {
-				continue
-			}
-			streams = append(streams, streamOutput{
-				ID:          s.ID,
-				Title:       s.Title,
-				Description: s.Description,
-				IndexSetID:  s.IndexSetID,
-			})
+// streamSummary is the part of a stream that list_streams and the
+// graylog://streams resource expose.
+type streamSummary struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	IndexSetID  string `json:"index_set_id"`
+}
+
+// enabledStreams returns the enabled streams whose title contains
+// titleFilter (case-insensitive; empty matches all), sorted by title.
+func enabledStreams(ctx context.Context, c *graylog.Client, cache *metadataCache, titleFilter string) ([]streamSummary, error) {
+	resp, err := cachedFetch(cache, "streams|"+c.CacheKey(), func() (*graylog.StreamsResponse, error) {
+		return c.GetStreams(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summarizeStreams(resp.Streams, titleFilter), nil
+}
+
+// summarizeStreams returns the enabled streams whose title contains
+// titleFilter (case-insensitive; empty matches all), sorted by title.
+func summarizeStreams(all []graylog.Stream, titleFilter string) []streamSummary {
+	titleFilter = strings.ToLower(titleFilter)
+	var streams []streamSummary
+	for _, s := range all {
+		if s.Disabled {
+			continue
+		}
+		if titleFilter != "" && !strings.Contains(strings.ToLower(s.Title), titleFilter) {
+			continue
+		}
+		streams = append(streams, streamSummary{
+			ID:          s.ID,
+			Title:       s.Title,
+			Description: s.Description,
+			IndexSetID:  s.IndexSetID,
+		})
+	}
+
+	// Graylog's order isn't stable across calls; sort so repeated
+	// listings are identical.
+	sort.Slice(streams, func(i, j int) bool {
+		if ti, tj := strings.ToLower(streams[i].Title), strings.ToLower(streams[j].Title); ti != tj {
+			return ti < tj
+		}
+		if streams[i].Title != streams[j].Title {
+			return streams[i].Title < streams[j].Title
+		}
+		return streams[i].ID < streams[j].ID
+	})
+	return streams
+}
+
+// streamCheckTTL is how long checkStreamExists reuses a stream listing when
+// GRAYLOG_METADATA_CACHE_TTL is off. A stream created meanwhile is only
+// rejected until the listing expires.
+const streamCheckTTL = 30 * time.Second
+
+// maxStreamSuggestions caps the valid streams listed when a stream_id is
+// unknown.
+const maxStreamSuggestions = 5
+
+// checkStreamExists returns a tool error when streamID isn't among the
+// streams the user can see, listing a few valid ones, or nil if it is. A
+// failed stream lookup returns nil so the search itself reports the problem.
+func checkStreamExists(ctx context.Context, c *graylog.Client, cache *metadataCache, streamID string) *mcp.CallToolResult {
+	resp, err := cachedFetch(cache, "streams|"+c.CacheKey(), func() (*graylog.StreamsResponse, error) {
+		return c.GetStreams(ctx)
+	})
+	if err != nil {
+		return nil
+	}
+	for _, s := range resp.Streams {
+		if s.ID == streamID {
+			return nil
 		}
+	}
 
-		return toolSuccess(map[string]any{
-			"streams": streams,
-			"total":   len(streams),
-		}), nil
+	valid := summarizeStreams(resp.Streams, "")
+	if len(valid) == 0 {
+		return toolError(fmt.Sprintf("stream '%s' not found or not accessible; no readable streams are available", streamID))
+	}
+	names := make([]string, 0, maxStreamSuggestions)
+	for _, s := range valid[:min(len(valid), maxStreamSuggestions)] {
+		names = append(names, fmt.Sprintf("%s (%s)", s.Title, s.ID))
+	}
+	msg := fmt.Sprintf("stream '%s' not found or not accessible; valid streams include: %s", streamID, strings.Join(names, ", "))
+	if len(valid) > maxStreamSuggestions {
+		msg += "; use list_streams or resolve_stream to find others"
 	}
+	return toolError(msg)
 }
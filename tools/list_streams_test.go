@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestListStreamsHandlerStableOrder(t *testing.T) {
+	// Each call returns the same streams in a different order.
+	responses := []string{
+		`{"streams":[{"id":"s-3","title":"payments"},{"id":"s-1","title":"Auth"},{"id":"s-4","title":"Payments"},{"id":"s-2","title":"auth"},{"id":"s-0","title":"Auth"}],"total":5}`,
+		`{"streams":[{"id":"s-0","title":"Auth"},{"id":"s-4","title":"Payments"},{"id":"s-2","title":"auth"},{"id":"s-3","title":"payments"},{"id":"s-1","title":"Auth"}],"total":5}`,
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(responses[calls%len(responses)]))
+		calls++
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := listStreamsHandler(func(_ context.Context) *graylog.Client { return client }, nil)
+
+	// Skip the permission lookup so every request hits /api/streams.
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"readable_only": false}
+
+	var orders [][]string
+	for range responses {
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		var ids []string
+		for _, s := range decodeToolResultJSON(t, result)["streams"].([]any) {
+			ids = append(ids, s.(map[string]any)["id"].(string))
+		}
+		orders = append(orders, ids)
+	}
+
+	want := []string{"s-0", "s-1", "s-2", "s-4", "s-3"}
+	for i, got := range orders {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("call %d: stream order = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestListStreamsHandlerReadableOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/streams":
+			_, _ = w.Write([]byte(`{"streams":[{"id":"s-1","title":"Auth"},{"id":"s-2","title":"Billing"},{"id":"s-3","title":"Payments"}],"total":3}`))
+		case "/api/system/sessions":
+			_, _ = w.Write([]byte(`{"is_valid":true,"username":"alice"}`))
+		case "/api/users/alice":
+			_, _ = w.Write([]byte(`{"username":"alice","roles":["Reader"],"permissions":["streams:read:s-1","dashboards:read:d-1","streams:edit:s-2"],"grant_permissions":["streams:read:s-3"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := listStreamsHandler(func(_ context.Context) *graylog.Client { return client }, nil)
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{name: "default filters", args: nil, want: []string{"s-1", "s-3"}},
+		{name: "explicit true", args: map[string]any{"readable_only": true}, want: []string{"s-1", "s-3"}},
+		{name: "disabled", args: map[string]any{"readable_only": false}, want: []string{"s-1", "s-2", "s-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			payload := decodeToolResultJSON(t, result)
+			var ids []string
+			for _, s := range payload["streams"].([]any) {
+				ids = append(ids, s.(map[string]any)["id"].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("streams = %v, want %v", ids, tt.want)
+			}
+			if payload["total"] != float64(len(tt.want)) {
+				t.Errorf("total = %v, want %d", payload["total"], len(tt.want))
+			}
+		})
+	}
+}
+
+func TestListStreamsHandlerReadableOnlyLookupFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/streams" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[{"id":"s-1","title":"Auth"},{"id":"s-2","title":"Billing"}],"total":2}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := listStreamsHandler(func(_ context.Context) *graylog.Client { return client }, nil)
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("a failed permission lookup must not fail the listing: %+v", result.Content)
+	}
+	payload := decodeToolResultJSON(t, result)
+	if payload["total"] != float64(2) {
+		t.Errorf("expected all 2 streams, got %v", payload["total"])
+	}
+	if _, ok := payload["warning"].(string); !ok {
+		t.Errorf("expected a warning about the skipped filter, got %v", payload)
+	}
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"sync"
+	"time"
+)
+
+// metadataCache holds rarely-changing Graylog listings (fields, streams) for a
+// short TTL, keyed per backend and credentials so users never see each other's
+// data. A nil cache is valid and disables caching.
+type metadataCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]metadataCacheEntry
+}
+
+type metadataCacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// newMetadataCache returns a cache with the given TTL, or nil when ttl is not positive.
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &metadataCache{ttl: ttl, now: time.Now, entries: make(map[string]metadataCacheEntry)}
+}
+
+func (c *metadataCache) get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *metadataCache) set(key string, value any) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	// Drop expired entries so per-user keys in http mode don't accumulate.
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = metadataCacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// cachedFetch returns the cached value for key or calls fetch and caches its
+// result. Errors are never cached.
+func cachedFetch[T any](c *metadataCache, key string, fetch func() (T, error)) (T, error) {
+	if v, ok := c.get(key); ok {
+		return v.(T), nil
+	}
+	v, err := fetch()
+	if err != nil {
+		return v, err
+	}
+	c.set(key, v)
+	return v, nil
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestMetadataCacheHitAndExpiry(t *testing.T) {
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/streams":
+			_ = json.NewEncoder(w).Encode(map[string]any{"streams": []map[string]any{{"id": "s1", "title": "App"}}, "total": 1})
+		case "/api/system/fields":
+			_ = json.NewEncoder(w).Encode(map[string]any{"fields": []string{"level", "source"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newMetadataCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	getClient := func(_ context.Context) *graylog.Client { return client }
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)) {
+		t.Helper()
+		result, err := handler(context.Background(), mcp.CallToolRequest{})
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected failure: %v %+v", err, result)
+		}
+	}
+	streams, fields := listStreamsHandler(getClient, cache), listFieldsHandler(getClient, cache)
+
+	call(streams)
+	call(fields)
+	now = now.Add(59 * time.Second)
+	call(streams)
+	call(fields)
+	if calls["/api/streams"] != 1 || calls["/api/system/fields"] != 1 {
+		t.Fatalf("expected one request per listing within the TTL, got %v", calls)
+	}
+
+	now = now.Add(time.Second)
+	call(streams)
+	call(fields)
+	if calls["/api/streams"] != 2 || calls["/api/system/fields"] != 2 {
+		t.Fatalf("expected a refetch after expiry, got %v", calls)
+	}
+
+	// Other credentials on the same backend don't share entries.
+	other := graylog.NewClient(server.URL, "other-token", "token", false, 2*time.Second)
+	call(listStreamsHandler(func(_ context.Context) *graylog.Client { return other }, cache))
+	if calls["/api/streams"] != 3 {
+		t.Fatalf("expected a separate fetch for other credentials, got %v", calls)
+	}
+}
+
+func TestMetadataCacheDisabled(t *testing.T) {
+	if c := newMetadataCache(0); c != nil {
+		t.Fatalf("expected nil cache for ttl 0, got %+v", c)
+	}
+	var c *metadataCache
+	c.set("k", 1)
+	if _, ok := c.get("k"); ok {
+		t.Fatal("nil cache must never hit")
+	}
+}
@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	// histogramValueLimit caps the distinct values fetched for bucketing.
+	// Neither the Scripting API nor Views pivots offer a numeric histogram or
+	// range grouping, so past it the result is partial.
+	histogramValueLimit = 10000
+	// maxHistogramBuckets caps the non-empty buckets returned.
+	maxHistogramBuckets = 200
+)
+
+func numericHistogramTool() mcp.Tool {
+	return mcp.NewTool("numeric_histogram",
+		mcp.WithDescription("Show the value distribution of a numeric field (e.g. response size) as fixed-width buckets with message counts, lowest bucket first. Empty buckets are omitted."),
+		mcp.WithString("field",
+			mcp.Required(),
+			mcp.Description("Numeric field to bucket (e.g. 'response_bytes', 'took_ms')"),
+		),
+		mcp.WithNumber("interval",
+			mcp.Required(),
+			mcp.Description("Bucket width in the field's unit, greater than 0 (e.g. 1000 for 0-1000, 1000-2000, ...)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Lucene query selecting the counted messages (default: '*')"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+	)
+}
+
+func numericHistogramHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		field := getStringParam(args, "field")
+		if field == "" {
+			return toolError("'field' parameter is required"), nil
+		}
+		if nonAggregatableFields[field] {
+			return toolError(fmt.Sprintf("field '%s' is a full-text analyzed field, not a number; use a numeric field like 'response_bytes'", field)), nil
+		}
+
+		interval, ok := args["interval"].(float64)
+		if !ok || math.IsNaN(interval) || math.IsInf(interval, 0) || interval <= 0 {
+			return toolError(fmt.Sprintf("'interval' must be a number greater than 0, got %v", args["interval"])), nil
+		}
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = "*"
+		}
+
+		tr, err := parseTimeRange(args, 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		// The Scripting API has no numeric histogram grouping, so fetch the
+		// distinct values with their counts and bucket them here.
+		req := graylog.ScriptingAggregateRequest{
+			Query:     query,
+			TimeRange: tr.scripting(),
+			GroupBy:   []graylog.ScriptingGrouping{{Field: field, Limit: histogramValueLimit}},
+			Metrics:   []graylog.ScriptingMetric{{Function: "count"}},
+			Streams:   getStreamIDsParam(args, cfg),
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := c.Aggregate(ctx, req)
+		if err != nil {
+			return aggregateError(fmt.Sprintf("Histogram aggregation on '%s' failed (is it a numeric field?)", field), err), nil
+		}
+
+		values := fieldValueCounts(resp)
+		buckets, err := histogramBuckets(values, interval)
+		if err != nil {
+			return toolError(fmt.Sprintf("field '%s' is not numeric: %v", field, err)), nil
+		}
+		if len(buckets) > maxHistogramBuckets {
+			return toolError(fmt.Sprintf("interval %v yields %d non-empty buckets (max %d); use a larger interval", interval, len(buckets), maxHistogramBuckets)), nil
+		}
+
+		result := map[string]any{
+			"field":         field,
+			"interval":      interval,
+			"query":         query,
+			"buckets":       buckets,
+			"total_buckets": len(buckets),
+			"partial":       false,
+		}
+		if len(values) >= histogramValueLimit {
+			// Values past the limit are missing from the buckets; count the
+			// messages they hold so the gap is explicit.
+			result["partial"] = true
+			params := graylog.SearchParams{
+				Query:     fmt.Sprintf("(%s) AND _exists_:%s", query, field),
+				Limit:     1,
+				StreamIDs: req.Streams,
+			}
+			tr.applyTo(&params)
+			if sr, err := c.Search(ctx, params); err != nil {
+				result["uncovered_count"] = nil
+				result["note"] = fmt.Sprintf("field has more than %d distinct values; counts cover the %d most frequent, and the rest could not be counted: %v", histogramValueLimit, histogramValueLimit, err)
+			} else {
+				uncovered := max(int64(sr.TotalResults)-bucketsTotal(buckets), 0)
+				result["uncovered_count"] = uncovered
+				result["note"] = fmt.Sprintf("field has more than %d distinct values; counts cover the %d most frequent, and %d messages with other values are not in any bucket", histogramValueLimit, histogramValueLimit, uncovered)
+			}
+		}
+		return toolSuccess(result), nil
+	}
+}
+
+type histogramBucket struct {
+	BucketStart float64 `json:"bucket_start"`
+	Count       int64   `json:"count"`
+}
+
+// histogramBuckets sums value counts into buckets of width interval starting
+// at multiples of interval, sorted by bucket start. Values may arrive as
+// numbers or numeric strings; anything else is an error.
+func histogramBuckets(values []fieldValueCount, interval float64) ([]histogramBucket, error) {
+	counts := make(map[float64]int64)
+	for _, vc := range values {
+		v, ok := numericValue(vc.Value)
+		if !ok {
+			return nil, fmt.Errorf("got value %v", vc.Value)
+		}
+		n, _ := numericValue(vc.Count)
+		counts[math.Floor(v/interval)*interval] += int64(n)
+	}
+
+	buckets := make([]histogramBucket, 0, len(counts))
+	for start, n := range counts {
+		buckets = append(buckets, histogramBucket{BucketStart: start, Count: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].BucketStart < buckets[j].BucketStart })
+	return buckets, nil
+}
+
+// bucketsTotal sums the counts of buckets.
+func bucketsTotal(buckets []histogramBucket) int64 {
+	var n int64
+	for _, b := range buckets {
+		n += b.Count
+	}
+	return n
+}
+
+// numericValue returns v as a float64 if it is a JSON number or a numeric
+// string.
+func numericValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		parsed, ok := parseNumeric(n)
+		if !ok {
+			return 0, false
+		}
+		switch p := parsed.(type) {
+		case int64:
+			return float64(p), true
+		case float64:
+			return p, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func numericHistogramTestServer(t *testing.T, datarows string, got *graylog.ScriptingAggregateRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"schema": [
+				{"column_type":"grouping","type":"numeric","field":"response_bytes","name":"grouping: response_bytes"},
+				{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+			],
+			"datarows": ` + datarows + `,
+			"metadata": {}
+		}`))
+	}))
+}
+
+func TestNumericHistogramHandler(t *testing.T) {
+	var got graylog.ScriptingAggregateRequest
+	// Graylog orders values by count, not by value; some arrive as strings.
+	server := numericHistogramTestServer(t, `[[2500, 9], ["120", 7], [2999.5, 4], [-10, 1], ["1000", 3], [999, 2]]`, &got)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := numericHistogramHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"field": "response_bytes", "interval": float64(1000)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if len(got.GroupBy) != 1 || got.GroupBy[0].Field != "response_bytes" || got.GroupBy[0].Limit != histogramValueLimit {
+		t.Fatalf("expected a values grouping on response_bytes, got %+v", got.GroupBy)
+	}
+
+	var payload struct {
+		Buckets []histogramBucket `json:"buckets"`
+		Total   int               `json:"total_buckets"`
+		Partial bool              `json:"partial"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &payload); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	want := []histogramBucket{
+		{BucketStart: -1000, Count: 1},
+		{BucketStart: 0, Count: 9},
+		{BucketStart: 1000, Count: 3},
+		{BucketStart: 2000, Count: 13},
+	}
+	if !reflect.DeepEqual(payload.Buckets, want) || payload.Total != len(want) || payload.Partial {
+		t.Fatalf("expected complete %+v, got %+v (total %d, partial %v)", want, payload.Buckets, payload.Total, payload.Partial)
+	}
+}
+
+func TestNumericHistogramHandlerErrors(t *testing.T) {
+	var got graylog.ScriptingAggregateRequest
+	server := numericHistogramTestServer(t, `[["GET", 10], ["POST", 2]]`, &got)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := numericHistogramHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr string
+	}{
+		{name: "missing field", args: map[string]any{"interval": float64(10)}, wantErr: "'field' parameter is required"},
+		{name: "missing interval", args: map[string]any{"field": "response_bytes"}, wantErr: "'interval'"},
+		{name: "zero interval", args: map[string]any{"field": "response_bytes", "interval": float64(0)}, wantErr: "'interval'"},
+		{name: "analyzed field", args: map[string]any{"field": "message", "interval": float64(10)}, wantErr: "full-text analyzed"},
+		{name: "string values", args: map[string]any{"field": "method", "interval": float64(10)}, wantErr: "field 'method' is not numeric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, text)
+			}
+		})
+	}
+}
+
+func TestNumericHistogramHandlerPartial(t *testing.T) {
+	// Every value seen once, filling the value limit.
+	rows := make([][]any, histogramValueLimit)
+	for i := range rows {
+		rows[i] = []any{i, 1}
+	}
+	datarows, _ := json.Marshal(rows)
+	var searchQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/search/aggregate":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+				"schema": [
+					{"column_type":"grouping","type":"numeric","field":"took_ms","name":"grouping: took_ms"},
+					{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}
+				],
+				"datarows": ` + string(datarows) + `,
+				"metadata": {}
+			}`))
+		case "/api/views/search/sync":
+			var body struct {
+				Queries []struct {
+					Query struct {
+						QueryString string `json:"query_string"`
+					} `json:"query"`
+				} `json:"queries"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			searchQuery = body.Queries[0].Query.QueryString
+			writeViewsSearchResponse(w, histogramValueLimit+250, nil)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := numericHistogramHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"field": "took_ms", "interval": float64(1000), "query": "service:api"}
+	result, err := handler(context.Background(), req)
+	if err != nil || result.IsError {
+		t.Fatalf("unexpected result=%+v err=%v", result, err)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["partial"] != true || payload["uncovered_count"] != float64(250) {
+		t.Errorf("expected partial with 250 uncovered messages, got partial=%v uncovered_count=%v", payload["partial"], payload["uncovered_count"])
+	}
+	if searchQuery != "(service:api) AND _exists_:took_ms" {
+		t.Errorf("unexpected count query %q", searchQuery)
+	}
+}
@@ -0,0 +1,294 @@
+package tools
+
+import "github.com/mark3labs/mcp-go/mcp"
+
+// Output schemas describe the JSON object each tool returns, so strict MCP
+// clients can validate structuredContent without guessing. The MCP spec
+// requires structured results from tools that declare a schema, so RegisterAll
+// only declares them with GRAYLOG_STRUCTURED_OUTPUT.
+//
+// Properties are never required: size fitting, debug and count-only modes
+// return different subsets of them. Extra properties are allowed, and arrays
+// are nullable because empty Go slices often encode as null.
+
+var (
+	stringSchema  = map[string]any{"type": "string"}
+	integerSchema = map[string]any{"type": "integer"}
+	numberSchema  = map[string]any{"type": "number"}
+	booleanSchema = map[string]any{"type": "boolean"}
+	objectSchema  = map[string]any{"type": "object"}
+	anySchema     = map[string]any{}
+)
+
+func objectOf(props map[string]any) map[string]any {
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func arrayOf(items map[string]any) map[string]any {
+	return map[string]any{"type": []string{"array", "null"}, "items": items}
+}
+
+func nullable(typ string) map[string]any {
+	return map[string]any{"type": []string{typ, "null"}}
+}
+
+// fittedProps are the keys fitResult and the last-resort fallbacks add.
+var fittedProps = map[string]any{
+	"response_truncated": booleanSchema,
+	"error":              stringSchema,
+}
+
+var debugProps = map[string]any{
+	"debug":   booleanSchema,
+	"request": objectSchema,
+}
+
+var searchMessageSchema = objectOf(map[string]any{
+	"message":     objectSchema,
+	"index":       stringSchema,
+	"context_ref": stringSchema,
+})
+
+// searchOutputProps covers every mode of executeSearch: plain messages,
+// deduplicated groups, templates, count-only and the no-result-structure
+// warning.
+var searchOutputProps = map[string]any{
+	"messages": arrayOf(searchMessageSchema),
+	"deduplicated": arrayOf(objectOf(map[string]any{
+		"message":           objectSchema,
+		"index":             stringSchema,
+		"count":             integerSchema,
+		"representative_id": stringSchema,
+		"message_ids":       arrayOf(stringSchema),
+		"first_seen":        stringSchema,
+		"last_seen":         stringSchema,
+		"context_ref":       stringSchema,
+	})),
+	"templates": arrayOf(objectOf(map[string]any{
+		"template":    stringSchema,
+		"count":       integerSchema,
+		"message_ids": arrayOf(stringSchema),
+	})),
+	"total_results":     integerSchema,
+	"total_raw_results": integerSchema,
+	"unique_in_batch":   integerSchema,
+	"template_count":    integerSchema,
+	"messages_analyzed": integerSchema,
+	"sampled":           booleanSchema,
+	"sample_size":       integerSchema,
+	"limit":             integerSchema,
+	"offset":            integerSchema,
+	"has_more":          booleanSchema,
+	"next_offset":       nullable("integer"),
+	"collapse_field":    stringSchema,
+	"collapsed_groups":  integerSchema,
+	"effective_range":   effectiveRangeSchema,
+	"warning":           stringSchema,
+	"detail":            stringSchema,
+	"hint":              stringSchema,
+}
+
+var effectiveRangeSchema = objectOf(map[string]any{
+	"from": stringSchema,
+	"to":   stringSchema,
+})
+
+var bucketsProps = map[string]any{
+	"field":         stringSchema,
+	"query":         stringSchema,
+	"buckets":       arrayOf(objectSchema),
+	"total_buckets": integerSchema,
+	"note":          stringSchema,
+}
+
+var windowSummarySchema = objectOf(map[string]any{
+	"from":  stringSchema,
+	"to":    stringSchema,
+	"count": integerSchema,
+})
+
+// toolOutputProperties holds the top-level properties of each tool's result,
+// keyed by tool name.
+var toolOutputProperties = map[string]map[string]any{
+	"search_logs": mergeProps(searchOutputProps, fittedProps, debugProps),
+	"source_logs": mergeProps(searchOutputProps, fittedProps),
+	"tail_logs": mergeProps(map[string]any{
+		"query":        stringSchema,
+		"messages":     arrayOf(searchMessageSchema),
+		"has_more":     booleanSchema,
+		"new_messages": integerSchema,
+		"watermark":    stringSchema,
+		"cursor":       stringSchema,
+		"warning":      stringSchema,
+	}, fittedProps),
+	"search_with_context": mergeProps(map[string]any{
+		"query":              stringSchema,
+		"messages":           arrayOf(searchMessageSchema),
+		"total_results":      integerSchema,
+		"messages_truncated": booleanSchema,
+		"context":            nullable("object"),
+		"context_error":      stringSchema,
+		"note":               stringSchema,
+		"warning":            stringSchema,
+		"detail":             stringSchema,
+		"hint":               stringSchema,
+	}, fittedProps),
+	"aggregate_logs": mergeProps(map[string]any{
+		"rows":                arrayOf(objectSchema),
+		"total_rows":          integerSchema,
+		"rows_truncated":      booleanSchema,
+		"metadata":            objectSchema,
+		"rollup_rows_skipped": integerSchema,
+		"keyword_fields":      objectSchema,
+		"warning":             stringSchema,
+		"effective_range":     effectiveRangeSchema,
+		"sample_note":         stringSchema,
+		"percentage_total":    numberSchema,
+		"groups":              objectSchema,
+		"group_levels":        arrayOf(stringSchema),
+	}, fittedProps, debugProps),
+	"get_log_context": mergeProps(map[string]any{
+		"target_message":     objectSchema,
+		"messages_before":    arrayOf(objectSchema),
+		"messages_after":     arrayOf(objectSchema),
+		"timeline":           arrayOf(objectSchema),
+		"context_incomplete": booleanSchema,
+		"deduplicated":       booleanSchema,
+		"correlated_by":      stringSchema,
+		"correlation_note":   stringSchema,
+		"window":             integerSchema,
+		"window_note":        stringSchema,
+		"before_error":       stringSchema,
+		"after_error":        stringSchema,
+		"has_more":           booleanSchema,
+		"target_message_id":  stringSchema,
+		"target_timestamp":   stringSchema,
+		"target_index":       stringSchema,
+	}, fittedProps),
+	"list_streams": {
+		"streams": arrayOf(objectOf(map[string]any{
+			"id":           stringSchema,
+			"title":        stringSchema,
+			"description":  stringSchema,
+			"index_set_id": stringSchema,
+		})),
+		"total":   integerSchema,
+		"warning": stringSchema,
+	},
+	"resolve_stream": {
+		"title":      stringSchema,
+		"matches":    integerSchema,
+		"stream_id":  stringSchema,
+		"stream":     stringSchema,
+		"candidates": arrayOf(objectSchema),
+		"hint":       stringSchema,
+	},
+	"get_stream_rules": {
+		"stream_id": stringSchema,
+		"rules":     arrayOf(objectSchema),
+		"total":     integerSchema,
+	},
+	"list_fields": {
+		"fields": arrayOf(stringSchema),
+		"total":  integerSchema,
+	},
+	"field_values": {
+		"field": stringSchema,
+		"values": arrayOf(objectOf(map[string]any{
+			"value": anySchema,
+			"count": anySchema,
+		})),
+		"total": integerSchema,
+	},
+	"latency_trend": mergeProps(bucketsProps, map[string]any{
+		"percentile": numberSchema,
+		"interval":   stringSchema,
+	}),
+	"numeric_histogram": mergeProps(bucketsProps, map[string]any{
+		"interval":        numberSchema,
+		"partial":         booleanSchema,
+		"uncovered_count": nullable("integer"),
+	}),
+	"top_errors": mergeProps(map[string]any{
+		"query": stringSchema,
+		"top_errors": arrayOf(objectOf(map[string]any{
+			"template":    stringSchema,
+			"count":       integerSchema,
+			"sample":      objectSchema,
+			"message_ids": arrayOf(stringSchema),
+		})),
+		"template_count":    integerSchema,
+		"messages_analyzed": integerSchema,
+		"total_results":     integerSchema,
+		"note":              stringSchema,
+	}, fittedProps),
+	"compare_windows": {
+		"query":          stringSchema,
+		"offset":         stringSchema,
+		"current":        windowSummarySchema,
+		"previous":       windowSummarySchema,
+		"delta":          integerSchema,
+		"percent_change": nullable("number"),
+	},
+	"export_logs": {
+		"path":      stringSchema,
+		"format":    stringSchema,
+		"rows":      integerSchema,
+		"bytes":     integerSchema,
+		"truncated": booleanSchema,
+		"note":      stringSchema,
+	},
+	"list_inputs": {
+		"inputs":       arrayOf(objectSchema),
+		"total":        integerSchema,
+		"states_error": stringSchema,
+		"states_note":  stringSchema,
+	},
+	"list_event_definitions": {
+		"event_definitions": arrayOf(objectSchema),
+		"total":             integerSchema,
+	},
+	"system_info": {
+		"version":        stringSchema,
+		"hostname":       stringSchema,
+		"cluster_id":     stringSchema,
+		"lifecycle":      stringSchema,
+		"is_processing":  booleanSchema,
+		"timezone":       stringSchema,
+		"cluster_status": stringSchema,
+		"node_count":     integerSchema,
+		"total_messages": integerSchema,
+		"warnings":       arrayOf(stringSchema),
+	},
+	"whoami": {
+		"username":            stringSchema,
+		"full_name":           stringSchema,
+		"roles":               arrayOf(stringSchema),
+		"read_only":           booleanSchema,
+		"all_streams":         booleanSchema,
+		"readable_stream_ids": arrayOf(stringSchema),
+		"hint":                stringSchema,
+	},
+	"raw_search": {
+		"result": anySchema,
+	},
+}
+
+func mergeProps(sets ...map[string]any) map[string]any {
+	merged := make(map[string]any)
+	for _, set := range sets {
+		for k, v := range set {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// withOutputSchema declares the tool's output schema, if it has one.
+func withOutputSchema(tool *mcp.Tool) {
+	props, ok := toolOutputProperties[tool.Name]
+	if !ok {
+		return
+	}
+	tool.OutputSchema = mcp.ToolOutputSchema{Type: "object", Properties: props}
+}
@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestRegisterAllDeclaresOutputSchemas(t *testing.T) {
+	getClient := func(_ context.Context) *graylog.Client { return nil }
+	for _, structured := range []bool{false, true} {
+		s := server.NewMCPServer("test", "1.0.0")
+		RegisterAll(s, getClient, &config.Config{EnableRaw: true, StructuredOutput: structured})
+		for name, tool := range s.ListTools() {
+			schema := tool.Tool.OutputSchema
+			if !structured {
+				if schema.Type != "" {
+					t.Errorf("%s: expected no output schema without structured output", name)
+				}
+				continue
+			}
+			if schema.Type != "object" || len(schema.Properties) == 0 {
+				t.Errorf("%s: expected an object output schema with properties, got %+v", name, schema)
+			}
+		}
+	}
+}
+
+func TestToolResultsConformToOutputSchemas(t *testing.T) {
+	now := time.Now().UTC()
+	messages := []testLogMessage{
+		{ID: "m-1", Timestamp: now.Add(-time.Second).Format(graylogTimeLayout), Source: "web-1", Message: "disk full on /var", Index: "graylog_0"},
+		{ID: "m-2", Timestamp: now.Add(-2 * time.Second).Format(graylogTimeLayout), Source: "web-1", Message: "disk full on /var", Index: "graylog_0"},
+		{ID: "m-3", Timestamp: now.Add(-3 * time.Second).Format(graylogTimeLayout), Source: "db-1", Message: "connection reset by peer", Index: "graylog_0", Extra: map[string]any{"level": 3}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/views/search/sync":
+			writeViewsSearchResponse(w, len(messages), messages)
+		case "/api/search/aggregate":
+			_, _ = w.Write([]byte(`{"schema":[{"column_type":"grouping","type":"string","field":"source","name":"grouping: source"},{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}],"datarows":[["web-1",2],["db-1",1]],"metadata":{"effective_timerange":{"from":"2024-01-01T00:00:00.000Z","to":"2024-01-01T00:05:00.000Z","type":"absolute"}}}`))
+		case "/api/streams":
+			_, _ = w.Write([]byte(`{"streams":[{"id":"s-1","title":"Auth"},{"id":"s-2","title":"Auth Audit"}],"total":2}`))
+		case "/api/system/fields":
+			_, _ = w.Write([]byte(`{"fields":["level","source"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := graylog.NewClient(srv.URL, "token", "token", false, 2*time.Second)
+	s := server.NewMCPServer("test", "1.0.0")
+	RegisterAll(s, func(_ context.Context) *graylog.Client { return client }, &config.Config{StructuredOutput: true})
+
+	tests := []struct {
+		tool string
+		args map[string]any
+	}{
+		{"search_logs", map[string]any{"query": "*"}},
+		{"search_logs", map[string]any{"query": "*", "deduplicate": true}},
+		{"search_logs", map[string]any{"query": "*", "extract_templates": true}},
+		{"search_logs", map[string]any{"query": "*", "count_only": true}},
+		{"search_logs", map[string]any{"query": "*", "debug": true}},
+		{"source_logs", map[string]any{"source": "web-1"}},
+		{"tail_logs", map[string]any{}},
+		{"top_errors", map[string]any{}},
+		{"compare_windows", map[string]any{"query": "*", "offset": "1h"}},
+		{"aggregate_logs", map[string]any{"query": "*", "group_by": "source", "metrics": "count"}},
+		{"aggregate_logs", map[string]any{"query": "*", "group_by": "source", "metrics": "count", "nested": true}},
+		{"field_values", map[string]any{"field": "source"}},
+		{"list_streams", map[string]any{"readable_only": false}},
+		{"resolve_stream", map[string]any{"title": "auth"}},
+		{"list_fields", map[string]any{}},
+	}
+	for _, tt := range tests {
+		tool := s.GetTool(tt.tool)
+		if tool == nil {
+			t.Fatalf("%s is not registered", tt.tool)
+		}
+		req := mcp.CallToolRequest{}
+		req.Params.Name = tt.tool
+		req.Params.Arguments = tt.args
+		result, err := tool.Handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s %v: handler returned error: %v", tt.tool, tt.args, err)
+		}
+		if result.IsError {
+			t.Fatalf("%s %v: unexpected tool error: %+v", tt.tool, tt.args, result.Content)
+		}
+		raw, ok := result.StructuredContent.(json.RawMessage)
+		if !ok {
+			t.Fatalf("%s %v: expected structured content, got %T", tt.tool, tt.args, result.StructuredContent)
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			t.Fatal(err)
+		}
+		schemaJSON, err := json.Marshal(tool.Tool.OutputSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema map[string]any
+		if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+			t.Fatal(err)
+		}
+		for _, problem := range schemaViolations("$", schema, value) {
+			t.Errorf("%s %v: %s", tt.tool, tt.args, problem)
+		}
+	}
+
+	// The checker itself must reject a mismatch.
+	bad := map[string]any{"messages": []any{map[string]any{"index": 7}}}
+	if len(schemaViolations("$", objectOf(searchOutputProps), bad)) == 0 {
+		t.Error("expected a numeric message index to violate the search_logs schema")
+	}
+}
+
+// schemaViolations checks value against the JSON Schema subset the output
+// schemas use: type (single or list), properties and items.
+func schemaViolations(path string, schema map[string]any, value any) []string {
+	var types []string
+	switch typ := schema["type"].(type) {
+	case string:
+		types = []string{typ}
+	case []any:
+		for _, v := range typ {
+			types = append(types, v.(string))
+		}
+	}
+	if len(types) > 0 {
+		matched := false
+		for _, typ := range types {
+			matched = matched || schemaTypeMatches(typ, value)
+		}
+		if !matched {
+			return []string{path + ": expected " + jsonString(schema["type"]) + ", got " + jsonString(value)}
+		}
+	}
+
+	var problems []string
+	if props, ok := schema["properties"].(map[string]any); ok {
+		if obj, ok := value.(map[string]any); ok {
+			for name, sub := range props {
+				if v, present := obj[name]; present {
+					problems = append(problems, schemaViolations(path+"."+name, sub.(map[string]any), v)...)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		if arr, ok := value.([]any); ok {
+			for _, v := range arr {
+				problems = append(problems, schemaViolations(path+"[]", items, v)...)
+			}
+		}
+	}
+	return problems
+}
+
+func schemaTypeMatches(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressReporter sends MCP progress notifications for one tool call. A nil
+// reporter is valid and does nothing, so callers need not check.
+type progressReporter func(progress, total float64, message string)
+
+// newProgressReporter returns a reporter bound to request's progress token,
+// or nil when the client didn't ask for progress or no MCP server is in ctx.
+func newProgressReporter(ctx context.Context, request mcp.CallToolRequest) progressReporter {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return nil
+	}
+	token := request.Params.Meta.ProgressToken
+	return func(progress, total float64, message string) {
+		params := map[string]any{
+			"progressToken": token,
+			"progress":      progress,
+		}
+		if total > 0 {
+			params["total"] = total
+		}
+		if message != "" {
+			params["message"] = message
+		}
+		// Progress is best effort: a slow or gone client must not fail the call.
+		_ = srv.SendNotificationToClient(ctx, "notifications/progress", params)
+	}
+}
+
+func (p progressReporter) report(progress, total float64, message string) {
+	if p != nil {
+		p(progress, total, message)
+	}
+}
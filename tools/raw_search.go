@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	maxRawSearchTypes = 10
+	// maxRawMessagesLimit caps the limit of a raw "messages" search type, the
+	// same ceiling search_logs can be configured to.
+	maxRawMessagesLimit = 10000
+)
+
+// rawSearchTypes are the Views search types raw_search forwards. Others
+// (e.g. event or export types) are rejected.
+var rawSearchTypes = map[string]bool{"messages": true, "pivot": true}
+
+// rawForbiddenKeys may not appear at any depth of a raw search type: scripts
+// run code on the indexer, and "streams" would escape the stream filter.
+var rawForbiddenKeys = map[string]bool{"script": true, "streams": true}
+
+func rawSearchTool() mcp.Tool {
+	return mcp.NewTool("raw_search",
+		mcp.WithDescription("Power-user escape hatch: run caller-supplied Graylog Views search types (e.g. a pivot the other tools don't model) inside the usual query, time range and stream filter, and return Graylog's raw results. Prefer search_logs and aggregate_logs when they fit."),
+		mcp.WithString("raw_search_type",
+			mcp.Required(),
+			mcp.Description(`JSON array of Views search_types, each with a unique "id" and a "type" of "messages" or "pivot", e.g. [{"id":"p","type":"pivot","row_groups":[{"type":"values","fields":["source"],"limit":10}],"series":[{"id":"count()","type":"count"}],"rollup":true}]`),
+		),
+		mcp.WithString("query",
+			mcp.Description("Lucene query (default: '*')"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+		redactParam(),
+	)
+}
+
+func rawSearchHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		raw := getStringParam(args, "raw_search_type")
+		if raw == "" {
+			return toolError("'raw_search_type' parameter is required"), nil
+		}
+		searchTypes, err := parseRawSearchTypes(raw)
+		if err != nil {
+			return toolError("invalid 'raw_search_type': " + err.Error()), nil
+		}
+
+		tr, err := parseTimeRange(args, 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = "*"
+		}
+		params := graylog.SearchParams{Query: query, StreamIDs: getStreamIDsParam(args, cfg)}
+		tr.applyTo(&params)
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		result, err := c.RawSearch(ctx, params, searchTypes)
+		if err != nil {
+			return graylogError("Raw search failed", err), nil
+		}
+		if result, err = redactRawResult(result, getRedactPatterns(args, cfg)); err != nil {
+			return toolError("Raw search failed: parsing result for redaction: " + err.Error()), nil
+		}
+		// Raw results are opaque, so they can't be fitted; refuse oversized
+		// ones instead of cutting JSON.
+		if len(result) > defaultMaxResultSize {
+			return toolError(fmt.Sprintf("raw search result is %d bytes, over the %d byte limit; lower the limits in 'raw_search_type'", len(result), defaultMaxResultSize)), nil
+		}
+		return toolSuccess(map[string]any{"result": result}), nil
+	}
+}
+
+// parseRawSearchTypes checks that raw is a JSON array of 1 to
+// maxRawSearchTypes objects with unique string ids, an allowed type, a
+// bounded messages limit and no forbidden keys. The elements are returned
+// verbatim.
+func parseRawSearchTypes(raw string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil, fmt.Errorf("must be a JSON array of search type objects: %v", err)
+	}
+	if len(items) == 0 || len(items) > maxRawSearchTypes {
+		return nil, fmt.Errorf("must contain 1 to %d search types, got %d", maxRawSearchTypes, len(items))
+	}
+
+	ids := make(map[string]bool, len(items))
+	for i, item := range items {
+		var st map[string]any
+		if err := json.Unmarshal(item, &st); err != nil || st == nil {
+			return nil, fmt.Errorf("search type %d is not a JSON object", i)
+		}
+		id, _ := st["id"].(string)
+		if id == "" || ids[id] {
+			return nil, fmt.Errorf("search type %d needs a unique non-empty string 'id'", i)
+		}
+		ids[id] = true
+		typ, _ := st["type"].(string)
+		if !rawSearchTypes[typ] {
+			return nil, fmt.Errorf("search type %q has unsupported type %q (allowed: messages, pivot)", id, typ)
+		}
+		if typ == "messages" {
+			if limit, ok := st["limit"].(float64); ok && limit > maxRawMessagesLimit {
+				return nil, fmt.Errorf("search type %q: limit %v exceeds %d", id, limit, maxRawMessagesLimit)
+			}
+		}
+		if key := findForbiddenKey(st); key != "" {
+			return nil, fmt.Errorf("search type %q: %q is not allowed", id, key)
+		}
+	}
+	return items, nil
+}
+
+// findForbiddenKey returns the first rawForbiddenKeys key found in v at any
+// depth, or "".
+func findForbiddenKey(v any) string {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if rawForbiddenKeys[k] {
+				return k
+			}
+			if key := findForbiddenKey(child); key != "" {
+				return key
+			}
+		}
+	case []any:
+		for _, child := range t {
+			if key := findForbiddenKey(child); key != "" {
+				return key
+			}
+		}
+	}
+	return ""
+}
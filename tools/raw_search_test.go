@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestRawSearchForwardsSearchTypesVerbatim(t *testing.T) {
+	var sent struct {
+		Queries []struct {
+			Query       map[string]any    `json:"query"`
+			TimeRange   map[string]any    `json:"timerange"`
+			Filter      map[string]any    `json:"filter"`
+			SearchTypes []json.RawMessage `json:"search_types"`
+		} `json:"queries"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/views/search/sync" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":{"q1":{"search_types":{"p":{"rows":[{"key":["web"],"values":[{"value":3}]}]}},"errors":[]}}}`))
+	}))
+	defer srv.Close()
+
+	client := graylog.NewClient(srv.URL, "token", "token", false, 2*time.Second)
+	handler := rawSearchHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	rawType := `{"id":"p","type":"pivot","row_groups":[{"type":"values","fields":["source"],"limit":10}],"series":[{"id":"count()","type":"count"}],"rollup":true,"custom_option":{"keep":[1,"two"]}}`
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"raw_search_type": "[" + rawType + "]", "query": "level:3", "stream_id": "s-1", "range": float64(600)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	if len(sent.Queries) != 1 || len(sent.Queries[0].SearchTypes) != 1 {
+		t.Fatalf("expected one query with one search type, got %+v", sent)
+	}
+	if got := string(sent.Queries[0].SearchTypes[0]); got != rawType {
+		t.Fatalf("search type not forwarded verbatim:\n got %s\nwant %s", got, rawType)
+	}
+	q := sent.Queries[0]
+	if q.Query["query_string"] != "level:3" || q.TimeRange["range"] != float64(600) || q.Filter == nil {
+		t.Errorf("expected the standard envelope, got query=%v timerange=%v filter=%v", q.Query, q.TimeRange, q.Filter)
+	}
+
+	var payload struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &payload); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if !bytes.Contains(payload.Result, []byte(`"rows":[{"key":["web"]`)) {
+		t.Errorf("expected Graylog's raw result, got %s", payload.Result)
+	}
+}
+
+func TestRawSearchRejectsInvalidSearchTypes(t *testing.T) {
+	handler := rawSearchHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{name: "not JSON", raw: `[{"id":`, wantErr: "JSON array"},
+		{name: "object instead of array", raw: `{"id":"a","type":"pivot"}`, wantErr: "JSON array"},
+		{name: "empty", raw: `[]`, wantErr: "1 to 10"},
+		{name: "non-object element", raw: `["pivot"]`, wantErr: "not a JSON object"},
+		{name: "missing id", raw: `[{"type":"pivot"}]`, wantErr: "unique non-empty string 'id'"},
+		{name: "duplicate id", raw: `[{"id":"a","type":"pivot"},{"id":"a","type":"messages"}]`, wantErr: "unique non-empty string 'id'"},
+		{name: "unsupported type", raw: `[{"id":"a","type":"export"}]`, wantErr: `unsupported type "export"`},
+		{name: "huge messages limit", raw: `[{"id":"m","type":"messages","limit":1000000}]`, wantErr: "exceeds"},
+		{name: "nested script", raw: `[{"id":"p","type":"pivot","series":[{"id":"x","type":"max","script":"doc['a']"}]}]`, wantErr: `"script" is not allowed`},
+		{name: "streams override", raw: `[{"id":"p","type":"pivot","streams":["other"]}]`, wantErr: `"streams" is not allowed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"raw_search_type": tt.raw}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, tt.wantErr) {
+				t.Errorf("expected error containing %q, got %q", tt.wantErr, text)
+			}
+		})
+	}
+}
+
+func TestRawSearchRegisteredOnlyWhenEnabled(t *testing.T) {
+	getClient := func(_ context.Context) *graylog.Client { return nil }
+	for _, enabled := range []bool{false, true} {
+		s := server.NewMCPServer("test", "1.0.0")
+		RegisterAll(s, getClient, &config.Config{EnableRaw: enabled})
+		if got := s.GetTool("raw_search") != nil; got != enabled {
+			t.Errorf("EnableRaw=%v: raw_search registered = %v", enabled, got)
+		}
+	}
+}
+
+func TestRawSearchRedactsMessages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "m-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Index: "idx",
+			Message: "login by jane@example.com",
+			Extra:   map[string]any{"full_message": "from 10.0.0.1", "bytes": 12345678901},
+		}})
+	}))
+	defer srv.Close()
+
+	client := graylog.NewClient(srv.URL, "token", "token", false, 2*time.Second)
+	getClient := func(_ context.Context) *graylog.Client { return client }
+	call := func(cfg *config.Config, args map[string]any) string {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := rawSearchHandler(getClient, cfg)(context.Background(), req)
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected result=%+v err=%v", result, err)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+	rawType := `[{"id":"msgs","type":"messages","limit":10}]`
+
+	text := call(&config.Config{Redact: true}, map[string]any{"raw_search_type": rawType})
+	if strings.Contains(text, "jane@example.com") || strings.Contains(text, "10.0.0.1") {
+		t.Errorf("expected forced redaction, got %s", text)
+	}
+	if !strings.Contains(text, "login by [REDACTED]") || !strings.Contains(text, "12345678901") {
+		t.Errorf("expected redacted text and intact numbers, got %s", text)
+	}
+
+	if text := call(&config.Config{}, map[string]any{"raw_search_type": rawType}); !strings.Contains(text, "jane@example.com") {
+		t.Errorf("expected no redaction by default, got %s", text)
+	}
+	if text := call(&config.Config{}, map[string]any{"raw_search_type": rawType, "redact": true}); strings.Contains(text, "jane@example.com") {
+		t.Errorf("expected opt-in redaction, got %s", text)
+	}
+}
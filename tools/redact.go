@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// redactedText replaces every match of a redaction pattern.
+const redactedText = "[REDACTED]"
+
+// defaultRedactPatterns are used when redaction is on and
+// GRAYLOG_REDACT_PATTERNS is not set: email addresses, IPv4 addresses and
+// bearer tokens.
+var defaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\b`),
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+}
+
+func redactParam() mcp.ToolOption {
+	return mcp.WithBoolean("redact",
+		mcp.Description("If true, replace sensitive substrings (by default emails, IPv4 addresses and bearer tokens; the server may configure others) in message and full_message with [REDACTED]. Always on when the server sets GRAYLOG_REDACT."),
+	)
+}
+
+// getRedactPatterns returns the patterns to scrub from this call's messages,
+// or nil when redaction is off. GRAYLOG_REDACT turns it on for every call;
+// otherwise the caller's 'redact' does.
+func getRedactPatterns(args map[string]any, cfg *config.Config) []*regexp.Regexp {
+	forced := cfg != nil && cfg.Redact
+	if !forced && !getBoolParam(args, "redact") {
+		return nil
+	}
+	if cfg != nil && len(cfg.RedactPatterns) > 0 {
+		return cfg.RedactPatterns
+	}
+	return defaultRedactPatterns
+}
+
+func redactText(s string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		s = re.ReplaceAllLiteralString(s, redactedText)
+	}
+	return s
+}
+
+// redactMessage scrubs message and a string full_message in place.
+func redactMessage(m *graylog.Message, patterns []*regexp.Regexp) {
+	if len(patterns) == 0 {
+		return
+	}
+	m.Message = redactText(m.Message, patterns)
+	if full, ok := m.Extra["full_message"].(string); ok {
+		m.Extra["full_message"] = redactText(full, patterns)
+	}
+}
+
+func redactMessages(msgs []graylog.MessageWrapper, patterns []*regexp.Regexp) {
+	if len(patterns) == 0 {
+		return
+	}
+	for i := range msgs {
+		redactMessage(&msgs[i].Message, patterns)
+	}
+}
+
+// redactRawResult scrubs message and a string full_message of every message
+// in a raw Views query result, i.e. search_types.<id>.messages[].message.
+// Other values pass through unchanged, numbers included.
+func redactRawResult(raw json.RawMessage, patterns []*regexp.Regexp) (json.RawMessage, error) {
+	if len(patterns) == 0 {
+		return raw, nil
+	}
+	var full map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&full); err != nil {
+		return nil, err
+	}
+	searchTypes, _ := full["search_types"].(map[string]any)
+	for _, st := range searchTypes {
+		stMap, _ := st.(map[string]any)
+		messages, _ := stMap["messages"].([]any)
+		for _, item := range messages {
+			wrapper, _ := item.(map[string]any)
+			msg, _ := wrapper["message"].(map[string]any)
+			for _, key := range []string{"message", "full_message"} {
+				if text, ok := msg[key].(string); ok {
+					msg[key] = redactText(text, patterns)
+				}
+			}
+		}
+	}
+	return json.Marshal(full)
+}
@@ -1,13 +1,168 @@
 package tools
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
 )
 
-func RegisterAll(s *server.MCPServer, getClient ClientFunc) {
-	s.AddTool(searchLogsTool(), searchLogsHandler(getClient))
-	s.AddTool(listStreamsTool(), listStreamsHandler(getClient))
-	s.AddTool(listFieldsTool(), listFieldsHandler(getClient))
-	s.AddTool(getLogContextTool(), getLogContextHandler(getClient))
-	s.AddTool(aggregateLogsTool(), aggregateLogsHandler(getClient))
+func RegisterAll(s *server.MCPServer, getClient ClientFunc, cfg *config.Config) {
+	add := func(tool mcp.Tool, h server.ToolHandlerFunc) {
+		mcp.WithBoolean("pretty",
+			mcp.Description("If true, return indented JSON for human reading. Size limits apply to the indented output, so fewer messages may fit."),
+		)(&tool)
+		if cfg.StructuredOutput {
+			withOutputSchema(&tool)
+		}
+		s.AddTool(tool, withRequestID(withToolTimeout(cfg.ToolTimeout, withStructuredOutput(cfg.StructuredOutput, withPrettyOutput(h)))))
+	}
+	metadata := newMetadataCache(cfg.MetadataTTL)
+	add(searchLogsTool(), searchLogsHandler(getClient, cfg, metadata))
+	add(listStreamsTool(), listStreamsHandler(getClient, metadata))
+	add(listFieldsTool(), listFieldsHandler(getClient, metadata))
+	add(getLogContextTool(), getLogContextHandler(getClient, cfg))
+	add(aggregateLogsTool(), aggregateLogsHandler(getClient, cfg))
+	add(getStreamRulesTool(), getStreamRulesHandler(getClient))
+	add(systemInfoTool(), systemInfoHandler(getClient))
+	add(listInputsTool(), listInputsHandler(getClient))
+	add(fieldValuesTool(), fieldValuesHandler(getClient, cfg))
+	add(topErrorsTool(), topErrorsHandler(getClient, cfg))
+	// Exports are written on the server's disk, which only a local (stdio)
+	// client can read.
+	if cfg.Transport != "http" {
+		add(exportLogsTool(), exportLogsHandler(getClient, cfg))
+	}
+	add(listEventDefinitionsTool(), listEventDefinitionsHandler(getClient))
+	add(resolveStreamTool(), resolveStreamHandler(getClient, metadata))
+	add(compareWindowsTool(), compareWindowsHandler(getClient, cfg))
+	add(sourceLogsTool(), sourceLogsHandler(getClient, cfg))
+	add(whoamiTool(), whoamiHandler(getClient))
+	add(latencyTrendTool(), latencyTrendHandler(getClient, cfg))
+	add(numericHistogramTool(), numericHistogramHandler(getClient, cfg))
+	add(tailLogsTool(), tailLogsHandler(getClient, cfg))
+	add(searchWithContextTool(), searchWithContextHandler(getClient, cfg))
+	if cfg.EnableRaw {
+		add(rawSearchTool(), rawSearchHandler(getClient, cfg))
+	}
+
+	registerResources(s, getClient, metadata)
+}
+
+// withPrettyOutput honors the shared 'pretty' parameter. Size-fitted tools
+// encode with the indenting marshaler from ctx; every other result is
+// re-indented here.
+func withPrettyOutput(h server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !getBoolParam(request.GetArguments(), "pretty") {
+			return h(ctx, request)
+		}
+		result, err := h(withPrettyJSON(ctx), request)
+		if err == nil && result != nil && !result.IsError {
+			indentResultJSON(result)
+		}
+		return result, err
+	}
+}
+
+// withStructuredOutput adds StructuredContent to successful results when
+// enabled (GRAYLOG_STRUCTURED_OUTPUT).
+func withStructuredOutput(enabled bool, h server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if !enabled {
+		return h
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := h(ctx, request)
+		if err == nil {
+			addStructuredContent(result)
+		}
+		return result, err
+	}
+}
+
+// withRequestID gives every call a request ID: the one the HTTP transport
+// took from the client's X-Request-Id, or a new random one. The ID is sent to
+// Graylog with each request and echoed in the result's _meta.request_id.
+func withRequestID(h server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := graylog.RequestIDFromContext(ctx)
+		if id == "" {
+			id = newRequestID()
+			ctx = graylog.WithRequestID(ctx, id)
+		}
+		result, err := h(ctx, request)
+		if result != nil {
+			if result.Meta == nil {
+				result.Meta = &mcp.Meta{}
+			}
+			if result.Meta.AdditionalFields == nil {
+				result.Meta.AdditionalFields = map[string]any{}
+			}
+			result.Meta.AdditionalFields["request_id"] = id
+		}
+		return result, err
+	}
+}
+
+// newRequestID returns 16 random hex digits.
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// maxTimeoutSeconds caps the per-call 'timeout_seconds' override.
+const maxTimeoutSeconds = 300
+
+// timeoutSecondsParam declares the per-call 'timeout_seconds' override that
+// withToolTimeout honors. Only slow tools declare it.
+func timeoutSecondsParam() mcp.ToolOption {
+	return mcp.WithNumber("timeout_seconds",
+		mcp.Description(fmt.Sprintf("Deadline for this call in seconds, replacing the server's default request and tool timeouts for this invocation only (max %d). Raise it for a known-heavy query.", maxTimeoutSeconds)),
+	)
+}
+
+// withToolTimeout bounds every call of h with its own deadline, independent of
+// the client timeout, and replaces the resulting error with a clear timeout
+// message. A positive 'timeout_seconds' argument replaces both the tool
+// deadline and the client timeout for that call. Without one, a non-positive
+// timeout disables the deadline.
+func withToolTimeout(timeout time.Duration, h server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		override, err := getStrictNonNegativeIntParam(request.GetArguments(), "timeout_seconds", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if override > maxTimeoutSeconds {
+			return toolError(fmt.Sprintf("'timeout_seconds' %d exceeds the maximum of %d", override, maxTimeoutSeconds)), nil
+		}
+		// The override applies to this call only; timeout is shared by all calls.
+		callTimeout := timeout
+		hint := "narrow the time range or query, or raise GRAYLOG_TOOL_TIMEOUT"
+		if override > 0 {
+			callTimeout = time.Duration(override) * time.Second
+			ctx = graylog.WithTimeout(ctx, callTimeout)
+			hint = fmt.Sprintf("narrow the time range or query, or raise timeout_seconds (max %d)", maxTimeoutSeconds)
+		}
+		if callTimeout <= 0 {
+			return h(ctx, request)
+		}
+
+		toolCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		defer cancel()
+
+		result, err := h(toolCtx, request)
+		// Only report a timeout when our deadline fired, not when the caller cancelled.
+		if errors.Is(toolCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil && (err != nil || (result != nil && result.IsError)) {
+			return classifiedError(errRetryable, fmt.Sprintf("tool timed out after %s; %s", callTimeout, hint)), nil
+		}
+		return result, err
+	}
 }
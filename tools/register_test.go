@@ -0,0 +1,321 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestWithToolTimeoutReturnsTimeoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	// The client timeout is generous; only the tool deadline should fire.
+	client := graylog.NewClient(server.URL, "token", "token", false, 30*time.Second)
+	handler := withToolTimeout(100*time.Millisecond,
+		aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}))
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "metrics": "count", "group_by": "source"}
+
+	start := time.Now()
+	result, err := handler(context.Background(), req)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected IsError=true when the tool deadline is exceeded")
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "tool timed out after 100ms") {
+		t.Errorf("expected tool timeout message, got %q", text)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("expected handler to return promptly after the tool timeout, took %s", elapsed)
+	}
+}
+
+func TestWithToolTimeoutOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(1500 * time.Millisecond):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"schema":[{"column_type":"metric","type":"numeric","function":"count","name":"metric: count()"}],"datarows":[[3]],"metadata":{}}`))
+	}))
+	defer server.Close()
+
+	// Both defaults are shorter than the slow aggregation.
+	client := graylog.NewClient(server.URL, "token", "token", false, 500*time.Millisecond)
+	handler := withToolTimeout(time.Second,
+		aggregateLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}))
+	call := func(timeout any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{"query": "*", "group_by": "source", "metrics": "count"}
+		if timeout != nil {
+			req.Params.Arguments.(map[string]any)["timeout_seconds"] = timeout
+		}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	if result := call(nil); !result.IsError {
+		t.Fatal("expected the default timeouts to cut the slow aggregation")
+	}
+	result := call(float64(1))
+	if !result.IsError {
+		t.Fatal("expected a 1s override to time out")
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "tool timed out after 1s") || !strings.Contains(text, "timeout_seconds") {
+		t.Errorf("expected override timeout message, got %q", text)
+	}
+	if result := call(float64(5)); result.IsError {
+		t.Fatalf("expected a 5s override to succeed, got %+v", result.Content)
+	}
+	if result := call(float64(maxTimeoutSeconds + 1)); !result.IsError {
+		t.Error("expected an override above the maximum to be rejected")
+	}
+}
+
+func TestWithToolTimeoutOverrideIsPerCall(t *testing.T) {
+	// The handler reports whether its context carries a deadline, and waits
+	// out a short one so the wrapper reports the timeout.
+	inner := func(ctx context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		deadline, ok := ctx.Deadline()
+		if ok && time.Until(deadline) < time.Second {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return toolSuccess(map[string]any{"has_deadline": ok}), nil
+	}
+	call := func(h server.ToolHandlerFunc, timeout any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{}
+		if timeout != nil {
+			req.Params.Arguments.(map[string]any)["timeout_seconds"] = timeout
+		}
+		result, err := h(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		return result
+	}
+
+	h := withToolTimeout(50*time.Millisecond, inner)
+	if result := call(h, float64(5)); result.IsError {
+		t.Fatalf("expected the override call to succeed, got %+v", result.Content)
+	}
+	result := call(h, nil)
+	if !result.IsError {
+		t.Fatal("expected the default call to keep the default timeout")
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, "tool timed out after 50ms") {
+		t.Errorf("expected the default timeout in the message, got %q", text)
+	}
+
+	// A disabled default stays disabled after an override.
+	h = withToolTimeout(0, inner)
+	call(h, float64(5))
+	result = call(h, nil)
+	if result.IsError {
+		t.Fatalf("expected no deadline, got %+v", result.Content)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, `"has_deadline":false`) {
+		t.Errorf("expected the default call to run without a deadline, got %q", text)
+	}
+}
+
+func TestWithToolTimeoutKeepsSuccess(t *testing.T) {
+	h := withToolTimeout(time.Second, func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return toolSuccess(map[string]any{"ok": true}), nil
+	})
+	result, err := h(context.Background(), mcp.CallToolRequest{})
+	if err != nil || result.IsError {
+		t.Fatalf("expected success to pass through, got result=%+v err=%v", result, err)
+	}
+}
+
+func TestWithPrettyOutputIndentsResult(t *testing.T) {
+	h := withPrettyOutput(func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return toolSuccess(map[string]any{"ok": true, "items": []int{1, 2}}), nil
+	})
+
+	req := mcp.CallToolRequest{}
+	result, err := h(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; strings.Contains(text, "\n") {
+		t.Errorf("expected compact output by default, got %q", text)
+	}
+
+	req.Params.Arguments = map[string]any{"pretty": true}
+	result, err = h(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	want := "{\n  \"items\": [\n    1,\n    2\n  ],\n  \"ok\": true\n}"
+	if text := result.Content[0].(mcp.TextContent).Text; text != want {
+		t.Errorf("expected indented output %q, got %q", want, text)
+	}
+}
+
+func TestWithStructuredOutput(t *testing.T) {
+	payload := map[string]any{"total": 2, "streams": []map[string]any{{"id": "s-1"}, {"id": "s-2"}}}
+	h := withPrettyOutput(func(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if getBoolParam(request.GetArguments(), "fail") {
+			return toolError("bad input"), nil
+		}
+		return toolSuccess(payload), nil
+	})
+
+	for _, pretty := range []bool{false, true} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{"pretty": pretty}
+		result, err := withStructuredOutput(true, h)(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.StructuredContent == nil {
+			t.Fatalf("pretty=%v: expected structured content", pretty)
+		}
+
+		// Both representations must decode to the same value on the wire.
+		wire, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			StructuredContent map[string]any `json:"structuredContent"`
+		}
+		if err := json.Unmarshal(wire, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		var fromText map[string]any
+		if err := json.Unmarshal([]byte(decoded.Content[0].Text), &fromText); err != nil {
+			t.Fatalf("pretty=%v: text fallback is not JSON: %v", pretty, err)
+		}
+		if !reflect.DeepEqual(fromText, decoded.StructuredContent) {
+			t.Errorf("pretty=%v: structured %v != text %v", pretty, decoded.StructuredContent, fromText)
+		}
+		if decoded.StructuredContent["total"] != float64(2) {
+			t.Errorf("pretty=%v: unexpected structured content %v", pretty, decoded.StructuredContent)
+		}
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"fail": true}
+	if result, _ := withStructuredOutput(true, h)(context.Background(), req); result.StructuredContent != nil {
+		t.Errorf("errors must not carry structured content, got %v", result.StructuredContent)
+	}
+	if result, _ := withStructuredOutput(false, h)(context.Background(), mcp.CallToolRequest{}); result.StructuredContent != nil {
+		t.Errorf("expected no structured content when disabled, got %v", result.StructuredContent)
+	}
+}
+
+func TestFitResultMeasuresPrettyEncoding(t *testing.T) {
+	newResult := func() map[string]any {
+		messages := make([]map[string]any, 20)
+		for i := range messages {
+			messages[i] = map[string]any{
+				"message": map[string]any{"_id": "id", "timestamp": "2024-01-01T00:00:00.000Z", "source": "svc", "message": "short", "level": "INFO"},
+				"index":   "idx",
+			}
+		}
+		return map[string]any{"messages": messages, "total_results": 20, "has_more": false}
+	}
+	compact, _ := json.Marshal(newResult())
+	pretty, _ := json.MarshalIndent(newResult(), "", "  ")
+	maxSize := len(compact) + 100
+	if len(pretty) <= maxSize {
+		t.Fatalf("test setup: pretty encoding (%d bytes) must exceed maxSize %d", len(pretty), maxSize)
+	}
+
+	result, err := fitSearchResult(context.Background(), newResult(), maxSize, false)
+	if err != nil {
+		t.Fatalf("fitSearchResult returned error: %v", err)
+	}
+	if payload := decodeToolResultJSON(t, result); payload["response_truncated"] != nil {
+		t.Errorf("compact output should fit untouched, got response_truncated=%v", payload["response_truncated"])
+	}
+
+	result, err = fitSearchResult(withPrettyJSON(context.Background()), newResult(), maxSize, false)
+	if err != nil {
+		t.Fatalf("fitSearchResult returned error: %v", err)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	if len(text) > maxSize {
+		t.Errorf("pretty output is %d bytes, exceeds max %d", len(text), maxSize)
+	}
+	if !strings.Contains(text, "\n  ") {
+		t.Errorf("expected indented output, got %q", text)
+	}
+	if payload := decodeToolResultJSON(t, result); payload["response_truncated"] != true {
+		t.Errorf("expected pretty output to be fitted with response_truncated, got %v", payload["response_truncated"])
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Header.Get(graylog.RequestIDHeader))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[{"id":"s-1","title":"App"}],"total":1}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	h := withRequestID(listStreamsHandler(func(_ context.Context) *graylog.Client { return client }, nil))
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"readable_only": false}
+
+	call := func(ctx context.Context) string {
+		t.Helper()
+		sent = nil
+		result, err := h(ctx, req)
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected failure: %v %+v", err, result)
+		}
+		if result.Meta == nil {
+			t.Fatal("expected _meta on the result")
+		}
+		id, _ := result.Meta.AdditionalFields["request_id"].(string)
+		if len(sent) != 1 || sent[0] != id {
+			t.Fatalf("expected Graylog to receive request ID %q, got %q", id, sent)
+		}
+		return id
+	}
+
+	first, second := call(context.Background()), call(context.Background())
+	if len(first) != 16 || first == second {
+		t.Errorf("expected distinct generated IDs, got %q and %q", first, second)
+	}
+	if got := call(graylog.WithRequestID(context.Background(), "inbound-7")); got != "inbound-7" {
+		t.Errorf("expected inbound request ID to be kept, got %q", got)
+	}
+}
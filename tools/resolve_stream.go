@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func resolveStreamTool() mcp.Tool {
+	return mcp.NewTool("resolve_stream",
+		mcp.WithDescription("Resolve a Graylog stream title to its ID for use as 'stream_id' in other tools. Returns 'stream_id' on a unique match, or 'candidates' to choose from when several streams match."),
+		mcp.WithString("title",
+			mcp.Required(),
+			mcp.Description("Stream title: an exact title (case-insensitive) or a substring of one"),
+		),
+	)
+}
+
+func resolveStreamHandler(getClient ClientFunc, cache *metadataCache) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		title := strings.TrimSpace(getStringParam(args, "title"))
+		if title == "" {
+			return toolError("'title' parameter is required"), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		resp, err := cachedFetch(cache, "streams|"+c.CacheKey(), func() (*graylog.StreamsResponse, error) {
+			return c.GetStreams(ctx)
+		})
+		if err != nil {
+			return graylogError("Failed to get streams", err), nil
+		}
+
+		matches := matchStreamTitle(resp.Streams, title)
+
+		type streamCandidate struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		}
+
+		switch len(matches) {
+		case 0:
+			return toolSuccess(map[string]any{
+				"title":   title,
+				"matches": 0,
+				"hint":    "no enabled stream title contains '" + title + "'; use list_streams to see all streams",
+			}), nil
+		case 1:
+			return toolSuccess(map[string]any{
+				"title":     title,
+				"matches":   1,
+				"stream_id": matches[0].ID,
+				"stream":    matches[0].Title,
+			}), nil
+		}
+
+		candidates := make([]streamCandidate, len(matches))
+		for i, s := range matches {
+			candidates[i] = streamCandidate{ID: s.ID, Title: s.Title}
+		}
+		return toolSuccess(map[string]any{
+			"title":      title,
+			"matches":    len(candidates),
+			"candidates": candidates,
+			"hint":       "several streams match; pick one by id or retry with the exact title",
+		}), nil
+	}
+}
+
+// matchStreamTitle returns enabled streams whose title equals title
+// (case-insensitive), or, if none does, those containing it as a substring.
+func matchStreamTitle(streams []graylog.Stream, title string) []graylog.Stream {
+	lower := strings.ToLower(title)
+	var exact, partial []graylog.Stream
+	for _, s := range streams {
+		if s.Disabled {
+			continue
+		}
+		streamTitle := strings.ToLower(s.Title)
+		if streamTitle == lower {
+			exact = append(exact, s)
+		} else if strings.Contains(streamTitle, lower) {
+			partial = append(partial, s)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return partial
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const resolveStreamsJSON = `{"streams":[
+	{"id":"s-1","title":"Payments API"},
+	{"id":"s-2","title":"Payments Worker"},
+	{"id":"s-3","title":"Auth"},
+	{"id":"s-4","title":"Authentication Audit"},
+	{"id":"s-5","title":"Legacy Payments","disabled":true}
+],"total":5}`
+
+func TestResolveStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		title          string
+		wantStreamID   string
+		wantCandidates []string
+		wantMatches    float64
+	}{
+		{name: "unique substring match", title: "worker", wantStreamID: "s-2", wantMatches: 1},
+		{name: "exact title wins over substrings", title: "auth", wantStreamID: "s-3", wantMatches: 1},
+		{name: "multiple matches", title: "payments", wantCandidates: []string{"s-1", "s-2"}, wantMatches: 2},
+		{name: "no match", title: "billing", wantMatches: 0},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/streams" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(resolveStreamsJSON))
+	}))
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := resolveStreamHandler(func(_ context.Context) *graylog.Client { return client }, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"title": tt.title}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			if payload["matches"] != tt.wantMatches {
+				t.Errorf("matches = %v, want %v", payload["matches"], tt.wantMatches)
+			}
+			if tt.wantStreamID != "" && payload["stream_id"] != tt.wantStreamID {
+				t.Errorf("stream_id = %v, want %s", payload["stream_id"], tt.wantStreamID)
+			}
+			if tt.wantStreamID == "" {
+				if _, ok := payload["stream_id"]; ok {
+					t.Errorf("stream_id must be absent unless the match is unique, got %v", payload["stream_id"])
+				}
+			}
+			var ids []string
+			if candidates, ok := payload["candidates"].([]any); ok {
+				for _, c := range candidates {
+					ids = append(ids, c.(map[string]any)["id"].(string))
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantCandidates) {
+				t.Errorf("candidates = %v, want %v", ids, tt.wantCandidates)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	streamsResourceURI = "graylog://streams"
+	fieldsResourceURI  = "graylog://fields"
+)
+
+// registerResources exposes the stream and field lists as read-only MCP
+// resources, so clients can offer them in a picker without a tool call. They
+// share the tools' metadata cache.
+func registerResources(s *server.MCPServer, getClient ClientFunc, cache *metadataCache) {
+	s.AddResource(streamsResource(), streamsResourceHandler(getClient, cache))
+	s.AddResource(fieldsResource(), fieldsResourceHandler(getClient, cache))
+}
+
+func streamsResource() mcp.Resource {
+	return mcp.NewResource(streamsResourceURI, "Graylog streams",
+		mcp.WithResourceDescription("Enabled Graylog streams (id, title, description, index_set_id), sorted by title. Same data as list_streams."),
+		mcp.WithMIMEType("application/json"),
+	)
+}
+
+func fieldsResource() mcp.Resource {
+	return mcp.NewResource(fieldsResourceURI, "Graylog fields",
+		mcp.WithResourceDescription("All message field names known to Graylog, sorted. Same data as list_fields."),
+		mcp.WithMIMEType("application/json"),
+	)
+}
+
+func streamsResourceHandler(getClient ClientFunc, cache *metadataCache) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		c := getClient(ctx)
+		if c == nil {
+			return nil, errNoCredentials
+		}
+		streams, err := enabledStreams(ctx, c, cache, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get streams: %w", err)
+		}
+		return jsonResourceContents(request.Params.URI, map[string]any{
+			"streams": streams,
+			"total":   len(streams),
+		})
+	}
+}
+
+func fieldsResourceHandler(getClient ClientFunc, cache *metadataCache) server.ResourceHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		c := getClient(ctx)
+		if c == nil {
+			return nil, errNoCredentials
+		}
+		fields, err := fieldNames(ctx, c, cache, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get fields: %w", err)
+		}
+		return jsonResourceContents(request.Params.URI, map[string]any{
+			"fields": fields,
+			"total":  len(fields),
+		})
+	}
+}
+
+// errNoCredentials is the resource counterpart of noCredentialsError.
+// Resource reads have no IsError result, so it surfaces as a JSON-RPC error.
+var errNoCredentials = errors.New("no Graylog credentials: Authorization header required")
+
+func jsonResourceContents(uri string, data any) ([]mcp.ResourceContents, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal resource: %w", err)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(b)},
+	}, nil
+}
@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestStreamsResourceHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/streams" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"streams":[{"id":"s-2","title":"payments","index_set_id":"is-1"},{"id":"s-1","title":"Auth","description":"logins"},{"id":"s-3","title":"old","disabled":true}],"total":3}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := streamsResourceHandler(func(_ context.Context) *graylog.Client { return client }, nil)
+
+	req := mcp.ReadResourceRequest{}
+	req.Params.URI = streamsResourceURI
+	contents, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if len(contents) != 1 {
+		t.Fatalf("expected 1 content item, got %d", len(contents))
+	}
+	text, ok := contents[0].(mcp.TextResourceContents)
+	if !ok {
+		t.Fatalf("expected TextResourceContents, got %T", contents[0])
+	}
+	if text.URI != streamsResourceURI || text.MIMEType != "application/json" {
+		t.Errorf("unexpected uri/mime: %q %q", text.URI, text.MIMEType)
+	}
+
+	var got struct {
+		Streams []streamSummary `json:"streams"`
+		Total   int             `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(text.Text), &got); err != nil {
+		t.Fatalf("resource text is not JSON: %v", err)
+	}
+	want := []streamSummary{
+		{ID: "s-1", Title: "Auth", Description: "logins"},
+		{ID: "s-2", Title: "payments", IndexSetID: "is-1"},
+	}
+	if got.Total != 2 || !reflect.DeepEqual(got.Streams, want) {
+		t.Fatalf("expected %+v, got %+v (total %d)", want, got.Streams, got.Total)
+	}
+}
+
+func TestResourceHandlersRequireCredentials(t *testing.T) {
+	noClient := func(_ context.Context) *graylog.Client { return nil }
+	for uri, handler := range map[string]func(context.Context, mcp.ReadResourceRequest) ([]mcp.ResourceContents, error){
+		streamsResourceURI: streamsResourceHandler(noClient, nil),
+		fieldsResourceURI:  fieldsResourceHandler(noClient, nil),
+	} {
+		req := mcp.ReadResourceRequest{}
+		req.Params.URI = uri
+		if _, err := handler(context.Background(), req); err != errNoCredentials {
+			t.Errorf("%s: expected errNoCredentials, got %v", uri, err)
+		}
+	}
+}
@@ -2,9 +2,14 @@ package tools
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/dedup"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
@@ -13,14 +18,19 @@ func searchLogsTool() mcp.Tool {
 	return mcp.NewTool("search_logs",
 		mcp.WithDescription("Search Graylog logs globally using Lucene query syntax. Returns matching log messages with metadata."),
 		mcp.WithString("query",
-			mcp.Required(),
-			mcp.Description("Lucene query string (e.g. 'level:ERROR AND service:auth')"),
+			mcp.Description("Lucene query string (e.g. 'level:ERROR AND service:auth'). Required unless 'regex' is set."),
+		),
+		mcp.WithString("regex",
+			mcp.Description("Regex filter as 'field:pattern' (e.g. 'message:timeout after [0-9]+ms'), compiled into a Lucene 'field:/pattern/' clause and ANDed with 'query'. Lucene regexes match the whole term, so use '.*' for partial matches."),
 		),
 		mcp.WithString("stream_id",
 			mcp.Description("Graylog stream ID to search within"),
 		),
+		mcp.WithBoolean("validate_stream",
+			mcp.Description("Check 'stream_id' against the streams you can read before searching, and fail with a list of valid streams if it isn't one of them (default: true). Set false to skip the extra lookup."),
+		),
 		mcp.WithNumber("range",
-			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to or timerange_keyword are set."),
 		),
 		mcp.WithString("from",
 			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
@@ -28,116 +38,483 @@ func searchLogsTool() mcp.Tool {
 		mcp.WithString("to",
 			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
 		),
+		mcp.WithString("timerange_keyword",
+			mcp.Description("Natural-language time range parsed by Graylog (e.g. 'last 24 hours', 'yesterday'). Mutually exclusive with from/to."),
+		),
 		mcp.WithNumber("limit",
-			mcp.Description("Maximum number of messages to return (default: 50, max: 10000)"),
+			mcp.Description("Maximum number of messages to return (default: 50, max: 10000 unless the server sets GRAYLOG_MAX_SEARCH_LIMIT; larger values are rejected)"),
 		),
 		mcp.WithNumber("offset",
-			mcp.Description("Number of messages to skip for pagination (default: 0)"),
+			mcp.Description("Number of messages to skip for pagination (default: 0). Pass next_offset from the previous response to get the next page."),
 		),
 		mcp.WithString("fields",
-			mcp.Description("Comma-separated list of fields to return (e.g. 'timestamp,source,message,level')"),
+			mcp.Description("Comma-separated list of fields to return (e.g. 'timestamp,source,message,level'). Supports '*' globs ('kubernetes_*', '*_id') and '-' exclusions ('-kubernetes_labels_*'); core fields are always kept. Defaults to the server's GRAYLOG_DEFAULT_FIELDS if set; pass '*' for all fields."),
+		),
+		mcp.WithNumber("truncate_message",
+			mcp.Description("Truncate 'message' and 'full_message' (the complete text, e.g. a stack trace) to this many bytes (default: 0 = no limit)"),
+		),
+		mcp.WithString("truncate_fields",
+			mcp.Description("Comma-separated fields (core or extra, e.g. 'stack_trace,payload') whose string values are truncated to 'truncate_field_chars' bytes. Use for long fields other than 'message'."),
+		),
+		mcp.WithNumber("truncate_field_chars",
+			mcp.Description("Length in bytes that 'truncate_fields' are cut to; required with 'truncate_fields'"),
 		),
 		mcp.WithString("sort",
-			mcp.Description("Sort order as 'field:asc' or 'field:desc' (e.g. 'timestamp:desc')"),
+			mcp.Description("Sort order as 'field:asc' or 'field:desc' (default: 'timestamp:desc')"),
+		),
+		mcp.WithString("index",
+			mcp.Description("Restrict the search to one Elasticsearch/OpenSearch index (e.g. 'graylog_42'). Combines with stream_id if both are set."),
+		),
+		mcp.WithString("range_filters",
+			mcp.Description("Comma-separated field comparisons ANDed with 'query', e.g. 'took_ms>=100,status<500'. Operators: >=, <=, >, < (Lucene range clauses) and = (exact term)."),
+		),
+		mcp.WithString("has_fields",
+			mcp.Description("Comma-separated fields that must be present on matching messages (adds _exists_:field clauses)"),
+		),
+		mcp.WithString("missing_fields",
+			mcp.Description("Comma-separated fields that must be absent on matching messages (adds NOT _exists_:field clauses)"),
+		),
+		mcp.WithBoolean("count_only",
+			mcp.Description("If true, return only total_results without fetching any messages. Cheapest way to answer 'how many match'."),
+		),
+		mcp.WithNumber("sample_size",
+			mcp.Description("With extract_templates: if more messages than this are fetched, uniformly sample down to this many before mining and scale counts back up (default: 0 = no sampling)"),
+		),
+		mcp.WithString("expand_fields",
+			mcp.Description("Comma-separated fields holding stringified JSON to expand into dotted keys (e.g. 'payload' → 'payload.user.id'). Non-JSON values are left as-is."),
+		),
+		mcp.WithString("field_aliases",
+			mcp.Description("Comma-separated 'field=alias' pairs renaming fields in the output (e.g. 'winlogbeat_winlog_event_data_TargetUserName=target_user'). Core fields can't be renamed."),
+		),
+		mcp.WithString("coerce_numeric",
+			mcp.Description("Comma-separated fields whose string values should be returned as numbers when they parse as one (e.g. 'took_ms,status'). Non-numeric values are left as-is."),
 		),
 		mcp.WithBoolean("deduplicate",
 			mcp.Description("If true, deduplicate similar messages and show count"),
 		),
+		mcp.WithString("dedup_representative",
+			mcp.Description("With 'deduplicate': which message of each group is shown: 'first' (default, first in sort order), 'newest' (latest timestamp) or 'longest' (most text, usually the most detail)."),
+		),
+		mcp.WithBoolean("dedup_include_index",
+			mcp.Description("With 'deduplicate': keep messages from different indices (e.g. different retention sets or tenants) in separate groups even when their content is identical (default: false)."),
+		),
+		mcp.WithString("collapse_field",
+			mcp.Description("Return only the first (per sort order) message for each distinct value of this field, e.g. one message per host. Unlike 'deduplicate' it compares this single field, not content. Each message carries 'collapsed_count'; 'collapsed_groups' is the number of distinct values. Mutually exclusive with 'deduplicate' and 'extract_templates'."),
+		),
+		mcp.WithNumber("dedup_overfetch",
+			mcp.Description("With 'deduplicate', 'collapse_field' or 'extract_templates': fetch this many times (offset+limit) messages to fill 'limit' unique results (default: 3, max: 10, total fetch capped at the server's maximum limit, 10000 by default). Raise it in streams dominated by duplicates."),
+		),
+		mcp.WithBoolean("highlight",
+			mcp.Description("If true, include per-field 'highlight_ranges' showing which parts of each message matched the query. Ignored with 'deduplicate' or 'extract_templates'."),
+		),
+		mcp.WithBoolean("decorate",
+			mcp.Description("If true, ask Graylog to apply its message decorators (e.g. GeoIP lookups, value mappings) so enriched fields are returned, with per-message 'decoration_stats' listing what changed. Requires a Graylog version that supports it."),
+		),
+		mcp.WithBoolean("debug",
+			mcp.Description("If true, don't run the search; return the exact Graylog Views API request that would be sent (time range, filters, sort, fields)"),
+		),
 		mcp.WithBoolean("extract_templates",
 			mcp.Description("If true, extract log templates using pattern mining (ULP). Groups similar messages and replaces dynamic parts with <*>. Mutually exclusive with 'deduplicate'."),
 		),
+		redactParam(),
+		timeoutSecondsParam(),
 	)
 }
 
-func searchLogsHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func searchLogsHandler(getClient ClientFunc, cfg *config.Config, cache *metadataCache) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// validate_stream runs on every stream-scoped search, so without the
+	// opt-in metadata cache the stream listing gets a short-lived cache of its own.
+	streamCache := cache
+	if streamCache == nil {
+		streamCache = newMetadataCache(streamCheckTTL)
+	}
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
 
 		query := getStringParam(args, "query")
-		if query == "" {
+		regex := getStringParam(args, "regex")
+		if query == "" && regex == "" {
 			return toolError("'query' parameter is required"), nil
 		}
+		if regex != "" {
+			clause, err := buildRegexClause(regex)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			if query == "" {
+				query = clause
+			} else {
+				query = "(" + query + ") AND " + clause
+			}
+		}
 
-		from := getStringParam(args, "from")
-		to := getStringParam(args, "to")
-		if (from == "") != (to == "") {
-			return toolError("'from' and 'to' must be used together"), nil
+		if index := getStringParam(args, "index"); index != "" {
+			clause, err := buildIndexClause(index)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			query = "(" + query + ") AND " + clause
 		}
 
-		limit, err := getStrictNonNegativeIntParam(args, "limit", 50)
+		if filters := getStringParam(args, "range_filters"); filters != "" {
+			clauses, err := buildRangeFilterClauses(filters)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			query = "(" + query + ") AND " + strings.Join(clauses, " AND ")
+		}
+
+		tr, err := parseTimeRange(args, 0)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-		if limit > 10000 {
-			limit = 10000
-		}
-		if limit < 1 {
-			limit = 50
+
+		limit, maxLimit, err := getSearchLimitParam(args, cfg)
+		if err != nil {
+			return toolError(err.Error()), nil
 		}
 
 		params := graylog.SearchParams{
-			Query:  query,
-			From:   from,
-			To:     to,
-			Limit:  limit,
-			Fields: getStringParam(args, "fields"),
-			Sort:   getStringParam(args, "sort"),
+			Query:    buildFieldPresenceQuery(query, getCommaListParam(args, "has_fields"), getCommaListParam(args, "missing_fields")),
+			Limit:    limit,
+			Fields:   getFieldsParam(args, cfg),
+			Sort:     getStringParam(args, "sort"),
+			Decorate: getBoolParam(args, "decorate"),
+		}
+		tr.applyTo(&params)
+
+		params.StreamIDs = getStreamIDsParam(args, cfg)
+
+		offset, err := getStrictNonNegativeIntParam(args, "offset", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
 		}
+		params.Offset = offset
 
-		if streamID := getStringParam(args, "stream_id"); streamID != "" {
-			params.StreamIDs = []string{streamID}
+		sampleSize, err := getStrictNonNegativeIntParam(args, "sample_size", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
 		}
 
-		rangeVal, err := getStrictNonNegativeIntParam(args, "range", 0)
+		aliases, err := parseFieldAliases(getStringParam(args, "field_aliases"))
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-		params.Range = rangeVal
 
-		offset, err := getStrictNonNegativeIntParam(args, "offset", 0)
+		truncateMessage, err := getStrictNonNegativeIntParam(args, "truncate_message", 0)
 		if err != nil {
 			return toolError(err.Error()), nil
 		}
-		params.Offset = offset
 
-		deduplicate := getBoolParam(args, "deduplicate")
-		extractTemplates := getBoolParam(args, "extract_templates")
-		if extractTemplates && deduplicate {
+		truncateFields := getCommaListParam(args, "truncate_fields")
+		truncateFieldChars, err := getStrictNonNegativeIntParam(args, "truncate_field_chars", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if len(truncateFields) > 0 && truncateFieldChars == 0 {
+			return toolError("'truncate_fields' requires 'truncate_field_chars' greater than 0"), nil
+		}
+
+		dedupOverfetch, err := getStrictNonNegativeIntParam(args, "dedup_overfetch", dedupFetchMultiplier)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		dedupOverfetch = max(1, min(dedupOverfetch, dedupMaxFetchMultiplier))
+
+		representative, err := dedup.ParseRepresentative(getStringParam(args, "dedup_representative"))
+		if err != nil {
+			return toolError("'dedup_representative': " + err.Error()), nil
+		}
+
+		opts := searchOptions{
+			deduplicate:        getBoolParam(args, "deduplicate"),
+			representative:     representative,
+			dedupIncludeIndex:  getBoolParam(args, "dedup_include_index"),
+			redact:             getRedactPatterns(args, cfg),
+			extractTemplates:   getBoolParam(args, "extract_templates"),
+			expandFields:       getCommaListParam(args, "expand_fields"),
+			fieldAliases:       aliases,
+			coerceNumeric:      getCommaListParam(args, "coerce_numeric"),
+			collapseField:      getStringParam(args, "collapse_field"),
+			truncateMessage:    truncateMessage,
+			truncateFields:     truncateFields,
+			truncateFieldChars: truncateFieldChars,
+			highlight:          getBoolParam(args, "highlight"),
+			debug:              getBoolParam(args, "debug"),
+			dedupOverfetch:     dedupOverfetch,
+			maxFetch:           maxLimit,
+			templateSampleSize: sampleSize,
+		}
+		if opts.extractTemplates && opts.deduplicate {
 			return toolError("'extract_templates' and 'deduplicate' are mutually exclusive"), nil
 		}
+		if opts.collapseField != "" && (opts.deduplicate || opts.extractTemplates) {
+			return toolError("'collapse_field' can't be combined with 'deduplicate' or 'extract_templates'"), nil
+		}
 
 		c := getClient(ctx)
 		if c == nil {
-			return toolError("no Graylog credentials: Authorization header required"), nil
+			return noCredentialsError(), nil
+		}
+		validateStream := true
+		if v, ok := args["validate_stream"].(bool); ok {
+			validateStream = v
+		}
+		if streamID := getStringParam(args, "stream_id"); streamID != "" && validateStream && !opts.debug {
+			if errResult := checkStreamExists(ctx, c, streamCache, streamID); errResult != nil {
+				return errResult, nil
+			}
+		}
+		if getBoolParam(args, "count_only") {
+			return executeCountSearch(ctx, c, params, opts.debug)
+		}
+		return executeSearch(ctx, c, params, opts, defaultMaxResultSize)
+	}
+}
+
+// getSearchLimitParam reads 'limit' (default 50) and rejects values above the
+// server's GRAYLOG_MAX_SEARCH_LIMIT, which it also returns.
+func getSearchLimitParam(args map[string]any, cfg *config.Config) (limit, maxLimit int, err error) {
+	maxLimit = cfg.MaxSearchLimit
+	if maxLimit <= 0 {
+		maxLimit = defaultMaxSearchLimit
+	}
+	limit, err = getStrictNonNegativeIntParam(args, "limit", 50)
+	if err != nil {
+		return 0, 0, err
+	}
+	if limit > maxLimit {
+		return 0, 0, fmt.Errorf("'limit' %d exceeds this server's maximum of %d (GRAYLOG_MAX_SEARCH_LIMIT); lower it or page with 'offset'", limit, maxLimit)
+	}
+	if limit < 1 {
+		limit = 50
+	}
+	return limit, maxLimit, nil
+}
+
+// buildFieldPresenceQuery ANDs _exists_ / NOT _exists_ clauses onto query.
+// The user query is parenthesized so its own OR/AND precedence is preserved.
+func buildFieldPresenceQuery(query string, hasFields, missingFields []string) string {
+	if len(hasFields) == 0 && len(missingFields) == 0 {
+		return query
+	}
+	clauses := make([]string, 0, 1+len(hasFields)+len(missingFields))
+	clauses = append(clauses, "("+query+")")
+	for _, f := range hasFields {
+		clauses = append(clauses, "_exists_:"+f)
+	}
+	for _, f := range missingFields {
+		clauses = append(clauses, "NOT _exists_:"+f)
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// buildRegexClause turns a 'field:pattern' regex param into a Lucene
+// 'field:/pattern/' clause. The pattern is checked with regexp.Compile first so
+// obviously broken regexes fail locally instead of as an opaque Graylog error;
+// unescaped '/' is escaped since it would terminate the Lucene regex.
+func buildRegexClause(regex string) (string, error) {
+	field, pattern, ok := strings.Cut(regex, ":")
+	field = strings.TrimSpace(field)
+	if !ok || field == "" || pattern == "" || strings.ContainsAny(field, " \t") {
+		return "", fmt.Errorf("'regex' must be in 'field:pattern' form (e.g. 'message:timeout.*'), got %q", regex)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return "", fmt.Errorf("invalid 'regex' pattern %q: %v", pattern, err)
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range pattern {
+		if r == '/' && !escaped {
+			b.WriteByte('\\')
+		}
+		escaped = r == '\\' && !escaped
+		b.WriteRune(r)
+	}
+	return field + ":/" + b.String() + "/", nil
+}
+
+// rangeFilterOperators maps each range_filters operator to the Lucene clause
+// template it produces, filled with the field and the escaped value. Two-byte
+// operators come first so '>=' isn't read as '>'.
+var rangeFilterOperators = []struct {
+	op     string
+	clause string
+}{
+	{">=", "%s:[%s TO *]"},
+	{"<=", "%s:[* TO %s]"},
+	{">", "%s:{%s TO *}"},
+	{"<", "%s:{* TO %s}"},
+	{"=", "%s:%s"},
+}
+
+// buildRangeFilterClauses turns comma-separated 'field<op>value' comparisons
+// into Lucene clauses, so callers don't have to write range syntax by hand.
+// Values are escaped; field names are taken as-is, like has_fields.
+func buildRangeFilterClauses(filters string) ([]string, error) {
+	var clauses []string
+	for _, filter := range strings.Split(filters, ",") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+		i := strings.IndexAny(filter, "<>=")
+		if i <= 0 {
+			return nil, fmt.Errorf("'range_filters' entry %q must be 'field<op>value' with op one of >=, <=, >, <, =", filter)
+		}
+		field, rest := strings.TrimSpace(filter[:i]), filter[i:]
+		if strings.ContainsAny(field, " \t") {
+			return nil, fmt.Errorf("'range_filters' entry %q has an invalid field name", filter)
+		}
+		for _, o := range rangeFilterOperators {
+			value, ok := strings.CutPrefix(rest, o.op)
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if value == "" || strings.ContainsAny(value[:1], "<>=") {
+				return nil, fmt.Errorf("'range_filters' entry %q must be 'field<op>value' with op one of >=, <=, >, <, =", filter)
+			}
+			clauses = append(clauses, fmt.Sprintf(o.clause, field, escapeLuceneValue(value)))
+			break
 		}
-		return executeSearch(ctx, c, params, deduplicate, extractTemplates, defaultMaxResultSize)
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("'range_filters' has no comparisons")
+	}
+	return clauses, nil
+}
+
+// indexNamePattern accepts Elasticsearch/OpenSearch index names: lowercase,
+// not starting with '-', '_' or '+', and free of wildcards and separators.
+var indexNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// buildIndexClause turns an 'index' param into a Lucene '_index:name' clause.
+// The Views API has no index filter, but Elasticsearch resolves _index in
+// query strings, so this scopes the search without touching stream filters.
+func buildIndexClause(index string) (string, error) {
+	index = strings.TrimSpace(index)
+	if len(index) > 255 || !indexNamePattern.MatchString(index) {
+		return "", fmt.Errorf("'index' must be a single index name like 'graylog_42' (lowercase, no wildcards), got %q", index)
+	}
+	return "_index:" + escapeLuceneValue(index), nil
+}
+
+// noResultStructureResult reports a Views response missing its result keys.
+// It deliberately has no messages or total_results so an agent can't mistake
+// a backend quirk for "no logs exist".
+func noResultStructureResult(err error) *mcp.CallToolResult {
+	return toolSuccess(map[string]any{
+		"warning": graylog.ErrNoResultStructure.Error(),
+		"detail":  err.Error(),
+		"hint":    "This does not mean nothing matched. Retry the search; if it persists, check the Graylog server logs.",
+	})
+}
+
+// executeCountSearch runs params with limit 0 and returns only the match count.
+// Post-processing modes (dedup, templates, fields) don't apply and are ignored.
+func executeCountSearch(ctx context.Context, client *graylog.Client, params graylog.SearchParams, debug bool) (*mcp.CallToolResult, error) {
+	params.CountOnly = true
+	params.Offset = 0
+	if debug {
+		return debugResult(client.PreviewSearch(params)), nil
+	}
+	resp, err := client.Search(ctx, params)
+	if err != nil {
+		if errors.Is(err, graylog.ErrNoResultStructure) {
+			return noResultStructureResult(err), nil
+		}
+		return graylogError("Search failed", err), nil
+	}
+	result := map[string]any{
+		"total_results": resp.TotalResults,
+		"limit":         0,
+	}
+	setEffectiveRange(result, resp, params)
+	return toolSuccess(result), nil
+}
+
+// setEffectiveRange adds the concrete window a relative or keyword search
+// covered as effective_range. Absolute searches already name their window.
+func setEffectiveRange(result map[string]any, resp *graylog.SearchResponse, params graylog.SearchParams) {
+	if params.From != "" {
+		return
+	}
+	if window := effectiveRange(resp.EffectiveTimerange, params.Range, time.Now()); window != nil {
+		result["effective_range"] = window
 	}
 }
 
 // dedupFetchMultiplier controls how many more messages to fetch from Graylog
 // when deduplication is enabled, to increase the chance of getting enough
-// unique results despite duplicate messages in the stream.
-const dedupFetchMultiplier = 3
+// unique results despite duplicate messages in the stream. The dedup_overfetch
+// param overrides it up to dedupMaxFetchMultiplier.
+const (
+	dedupFetchMultiplier    = 3
+	dedupMaxFetchMultiplier = 10
+)
+
+// defaultMaxSearchLimit is the search_logs limit ceiling when
+// GRAYLOG_MAX_SEARCH_LIMIT isn't set; it matches Elasticsearch's default
+// max_result_window.
+const defaultMaxSearchLimit = 10000
+
+// searchOptions holds the post-processing modes of executeSearch.
+type searchOptions struct {
+	deduplicate       bool
+	representative    dedup.Representative // with deduplicate: which message represents a group
+	dedupIncludeIndex bool                 // with deduplicate: hash the index so cross-index duplicates stay apart
+	redact            []*regexp.Regexp     // patterns scrubbed from message bodies (nil = off)
+	extractTemplates  bool
+	expandFields      []string          // fields whose JSON string values are expanded into dotted keys
+	fieldAliases      map[string]string // output renames applied after field filtering
+	coerceNumeric     []string          // fields whose numeric string values are output as numbers
+	collapseField     string            // keep one message per distinct value of this field
+	truncateMessage   int               // truncate message and full_message to this many bytes (0 = off)
+	truncateFields    []string          // fields whose string values are truncated to truncateFieldChars bytes
+	highlight         bool              // include Graylog's highlight_ranges with each message
+	debug             bool              // return the Views request instead of executing it
+	dedupOverfetch    int               // fetch multiplier for deduplicate/extractTemplates/collapseField (0 = dedupFetchMultiplier)
+	maxFetch          int               // cap on messages fetched for deduplicate/extractTemplates/collapseField (0 = defaultMaxSearchLimit)
+
+	templateSampleSize int // with extractTemplates: sample down to this many messages before mining (0 = off)
+	truncateFieldChars int // length for truncateFields
+}
 
-func executeSearch(ctx context.Context, client *graylog.Client, params graylog.SearchParams, deduplicate bool, extractTemplates bool, maxResultSize int) (*mcp.CallToolResult, error) {
+func executeSearch(ctx context.Context, client *graylog.Client, params graylog.SearchParams, opts searchOptions, maxResultSize int) (*mcp.CallToolResult, error) {
+	deduplicate, extractTemplates := opts.deduplicate, opts.extractTemplates
 	requestedLimit := params.Limit
 	originalOffset := params.Offset
 
-	// When deduplicating or extracting templates, fetch from offset=0 so processing
-	// works across the full range. Offset is applied to the results afterwards.
-	if deduplicate || extractTemplates {
+	// When deduplicating, collapsing or extracting templates, fetch from offset=0
+	// so processing works across the full range. Offset is applied to the results afterwards.
+	if deduplicate || extractTemplates || opts.collapseField != "" {
 		params.Offset = 0
-		params.Limit = min((originalOffset+requestedLimit)*dedupFetchMultiplier, 10000)
+		multiplier := opts.dedupOverfetch
+		if multiplier <= 0 {
+			multiplier = dedupFetchMultiplier
+		}
+		maxFetch := opts.maxFetch
+		if maxFetch <= 0 {
+			maxFetch = defaultMaxSearchLimit
+		}
+		params.Limit = min((originalOffset+requestedLimit)*multiplier, maxFetch)
+	}
+
+	if opts.debug {
+		return debugResult(client.PreviewSearch(params)), nil
 	}
 
 	resp, err := client.Search(ctx, params)
 	if err != nil {
-		if apiErr, ok := err.(*graylog.APIError); ok {
-			return toolError(apiErr.Error()), nil
+		if errors.Is(err, graylog.ErrNoResultStructure) {
+			return noResultStructureResult(err), nil
 		}
-		return toolError("Search failed: " + err.Error()), nil
+		return graylogError("Search failed", err), nil
 	}
 
+	redactMessages(resp.Messages, opts.redact)
+
 	hasMoreFromPagination := originalOffset+requestedLimit < resp.TotalResults
 
 	var fieldList []string
@@ -147,10 +524,24 @@ func executeSearch(ctx context.Context, client *graylog.Client, params graylog.S
 		}
 	}
 
+	if len(opts.expandFields) > 0 {
+		expanded := expandJSONFields(resp.Messages, opts.expandFields)
+		// Keep expanded keys when their source field was explicitly requested.
+		if len(fieldList) > 0 {
+			fieldList = append(fieldList, expandedFieldsFor(graylog.NewFieldFilter(fieldList), expanded)...)
+		}
+	}
+	fieldFilter := graylog.NewFieldFilter(fieldList)
+
 	if extractTemplates && len(resp.Messages) > 0 {
-		templates, err := templateizeMessages(resp.Messages)
+		analyzed := sampleMessages(resp.Messages, opts.templateSampleSize)
+		templates, err := templateizeMessages(analyzed)
 		if err != nil {
-			return toolError("Template extraction failed: " + err.Error()), nil
+			return classifiedError(errInternal, "Template extraction failed: "+err.Error()), nil
+		}
+		sampled := len(analyzed) < len(resp.Messages)
+		if sampled {
+			scaleTemplateCounts(templates, float64(len(resp.Messages))/float64(len(analyzed)))
 		}
 		capTemplateMessageIDs(templates, 5)
 
@@ -167,12 +558,20 @@ func executeSearch(ctx context.Context, client *graylog.Client, params graylog.S
 			"messages_analyzed": len(resp.Messages),
 			"has_more":          hasMore,
 		}
-		return fitTemplateSearchResult(result, maxResultSize)
+		if sampled {
+			result["sampled"] = true
+			result["sample_size"] = len(analyzed)
+		}
+		setEffectiveRange(result, resp, params)
+		return fitTemplateSearchResult(ctx, result, maxResultSize)
 	}
 
 	if deduplicate && len(resp.Messages) > 0 {
+		// The representative depends on message order, so make the batch
+		// follow the requested sort before grouping.
+		sortMessages(resp.Messages, params.Sort)
 		// Always hash by all fields — fieldList is for output filtering only.
-		dedupResults := dedup.Deduplicate(resp.Messages, nil)
+		dedupResults := dedup.DeduplicateWith(resp.Messages, nil, opts.representative, opts.dedupIncludeIndex)
 		uniqueCount := len(dedupResults)
 
 		// Cap message_ids before any fitting (including when max_result_size=0).
@@ -192,8 +591,19 @@ func executeSearch(ctx context.Context, client *graylog.Client, params graylog.S
 		}
 		hasMore := hasMoreFromPagination || uniqueCount > originalOffset+len(dedupResults)
 
-		if len(fieldList) > 0 {
-			filterDedupResultFields(dedupResults, fieldList)
+		if fieldFilter != nil {
+			filterDedupResultFields(dedupResults, fieldFilter)
+		}
+		for i := range dedupResults {
+			if opts.truncateMessage > 0 {
+				truncateMessageText(&dedupResults[i].Message, opts.truncateMessage)
+			}
+			truncateMessageFields(&dedupResults[i].Message, opts.truncateFields, opts.truncateFieldChars)
+			coerceNumericFields(dedupResults[i].Message.Extra, opts.coerceNumeric)
+			if err := applyFieldAliases(dedupResults[i].Message.Extra, opts.fieldAliases); err != nil {
+				return toolError(err.Error()), nil
+			}
+			dedupResults[i].ContextRef = contextRef(dedupResults[i].Index, dedupResults[i].Message.ID)
 		}
 
 		result := map[string]any{
@@ -204,29 +614,120 @@ func executeSearch(ctx context.Context, client *graylog.Client, params graylog.S
 			"offset":            originalOffset,
 			"has_more":          hasMore,
 		}
-		return fitSearchResult(result, maxResultSize, true)
+		// Dedup offsets count unique groups, not raw messages.
+		setNextOffset(result, len(dedupResults))
+		setEffectiveRange(result, resp, params)
+		return fitSearchResult(ctx, result, maxResultSize, true)
+	}
+
+	limit, offset, hasMore := params.Limit, params.Offset, hasMoreFromPagination
+	var collapsedCounts []int
+	collapsedGroups := 0
+	if opts.collapseField != "" {
+		fetched := resp.Messages
+		var kept []graylog.MessageWrapper
+		kept, collapsedCounts = collapseByField(fetched, opts.collapseField)
+		collapsedGroups = len(kept)
+		// Offset and limit page through groups, like dedup offsets.
+		lo := min(originalOffset, collapsedGroups)
+		hi := min(lo+requestedLimit, collapsedGroups)
+		resp.Messages, collapsedCounts = kept[lo:hi], collapsedCounts[lo:hi]
+		limit, offset = requestedLimit, originalOffset
+		// Unfetched matches may hold further groups.
+		hasMore = collapsedGroups > hi || len(fetched) < resp.TotalResults
 	}
 
 	messages := make([]map[string]any, len(resp.Messages))
 	for i, wrapper := range resp.Messages {
+		msgMap := wrapper.Message.FilteredMap(fieldFilter)
+		if opts.truncateMessage > 0 {
+			truncateMapText(msgMap, "message", opts.truncateMessage)
+			truncateMapText(msgMap, fullMessageField, opts.truncateMessage)
+		}
+		for _, f := range opts.truncateFields {
+			truncateMapText(msgMap, f, opts.truncateFieldChars)
+		}
+		coerceNumericFields(msgMap, opts.coerceNumeric)
+		if err := applyFieldAliases(msgMap, opts.fieldAliases); err != nil {
+			return toolError(err.Error()), nil
+		}
 		messages[i] = map[string]any{
-			"message": wrapper.Message.ToFilteredMap(fieldList),
+			"message": msgMap,
 			"index":   wrapper.Index,
 		}
+		if ref := contextRef(wrapper.Index, wrapper.Message.ID); ref != "" {
+			messages[i]["context_ref"] = ref
+		}
+		if collapsedCounts != nil {
+			messages[i]["collapsed_count"] = collapsedCounts[i]
+		}
+		if opts.highlight {
+			if ranges := filterHighlightRanges(wrapper.HighlightRanges, fieldFilter); len(ranges) > 0 {
+				// Highlight keys follow the renamed fields; they can't collide
+				// because the message map above didn't.
+				_ = applyFieldAliases(ranges, opts.fieldAliases)
+				messages[i]["highlight_ranges"] = ranges
+			}
+		}
+		if wrapper.DecorationStats != nil {
+			messages[i]["decoration_stats"] = wrapper.DecorationStats
+		}
 	}
 
 	result := map[string]any{
 		"messages":      messages,
 		"total_results": resp.TotalResults,
-		"limit":         params.Limit,
-		"offset":        params.Offset,
-		"has_more":      hasMoreFromPagination,
+		"limit":         limit,
+		"offset":        offset,
+		"has_more":      hasMore,
 	}
+	if opts.collapseField != "" {
+		result["collapse_field"] = opts.collapseField
+		result["collapsed_groups"] = collapsedGroups
+	}
+	setNextOffset(result, len(messages))
+	setEffectiveRange(result, resp, params)
 
-	return fitSearchResult(result, maxResultSize, false)
+	return fitSearchResult(ctx, result, maxResultSize, false)
 }
 
-func fitSearchResult(result map[string]any, maxSize int, isDedup bool) (*mcp.CallToolResult, error) {
+// setNextOffset sets next_offset to the offset that continues after the
+// returned items, or null when has_more is false.
+func setNextOffset(result map[string]any, returned int) {
+	if hasMore, _ := result["has_more"].(bool); !hasMore {
+		result["next_offset"] = nil
+		return
+	}
+	offset, _ := result["offset"].(int)
+	result["next_offset"] = offset + returned
+}
+
+// debugResult wraps a request preview returned by a tool's debug mode.
+func debugResult(preview graylog.RequestPreview) *mcp.CallToolResult {
+	return toolSuccess(map[string]any{
+		"debug":   true,
+		"request": preview,
+	})
+}
+
+// filterHighlightRanges drops ranges for fields excluded by the 'fields'
+// filter, so highlights never reference a field missing from the output.
+// Core fields are always kept, mirroring Message.ToFilteredMap.
+func filterHighlightRanges(ranges map[string]any, filter *graylog.FieldFilter) map[string]any {
+	if filter == nil || len(ranges) == 0 {
+		return ranges
+	}
+	core := map[string]bool{"_id": true, "timestamp": true, "source": true, "message": true}
+	filtered := make(map[string]any, len(ranges))
+	for k, v := range ranges {
+		if core[k] || filter.Match(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+func fitSearchResult(ctx context.Context, result map[string]any, maxSize int, isDedup bool) (*mcp.CallToolResult, error) {
 	adapter := resultAdapter{
 		truncateMsgs: func(maxLen int) {
 			truncateMessagesInResult(result, maxLen, isDedup)
@@ -242,6 +743,7 @@ func fitSearchResult(result map[string]any, maxSize int, isDedup bool) (*mcp.Cal
 			}
 			reduceMessagesInResult(result, newCount, isDedup)
 			result["has_more"] = true
+			setNextOffset(result, newCount)
 			return true
 		},
 		lastResort: func() map[string]any {
@@ -264,22 +766,14 @@ func fitSearchResult(result map[string]any, maxSize int, isDedup bool) (*mcp.Cal
 		},
 	}
 
-	return fitResult(result, maxSize, adapter)
+	return fitResult(ctx, result, maxSize, adapter)
 }
 
-// filterDedupResultFields removes Extra fields not in fieldList from each DedupResult.
+// filterDedupResultFields removes Extra fields the filter doesn't select from each DedupResult.
 // Known struct fields (timestamp, source, message) are always kept; _id is omitted by MarshalJSON.
-func filterDedupResultFields(results []dedup.DedupResult, fieldList []string) {
-	fieldSet := make(map[string]bool, len(fieldList))
-	for _, f := range fieldList {
-		fieldSet[f] = true
-	}
+func filterDedupResultFields(results []dedup.DedupResult, filter *graylog.FieldFilter) {
 	for i := range results {
-		for k := range results[i].Message.Extra {
-			if !fieldSet[k] {
-				delete(results[i].Message.Extra, k)
-			}
-		}
+		filterMessageExtraFields(results[i].Message.Extra, filter)
 	}
 }
 
@@ -287,16 +781,15 @@ func truncateMessagesInResult(result map[string]any, maxLen int, isDedup bool) {
 	if isDedup {
 		if dedupResults, ok := result["deduplicated"].([]dedup.DedupResult); ok {
 			for i := range dedupResults {
-				dedupResults[i].Message.Message = truncateString(dedupResults[i].Message.Message, maxLen)
+				truncateMessageText(&dedupResults[i].Message, maxLen)
 			}
 		}
 	} else {
 		if messages, ok := result["messages"].([]map[string]any); ok {
 			for _, wrapper := range messages {
 				if msgMap, ok := wrapper["message"].(map[string]any); ok {
-					if msgStr, ok := msgMap["message"].(string); ok {
-						msgMap["message"] = truncateString(msgStr, maxLen)
-					}
+					truncateMapText(msgMap, "message", maxLen)
+					truncateMapText(msgMap, fullMessageField, maxLen)
 				}
 			}
 		}
@@ -2,18 +2,24 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
 	"github.com/n0madic/graylog-mcp/graylog"
 )
 
 func TestSearchLogsHandlerRejectsInvalidNumericParams(t *testing.T) {
 	client := graylog.NewClient("https://graylog.example.com", "token", "token", false, 2*time.Second)
-	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client })
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
 
 	tests := []struct {
 		name string
@@ -23,6 +29,7 @@ func TestSearchLogsHandlerRejectsInvalidNumericParams(t *testing.T) {
 		{name: "fractional offset", args: map[string]any{"query": "*", "offset": 1.5}},
 		{name: "negative range", args: map[string]any{"query": "*", "range": float64(-5)}},
 		{name: "fractional range", args: map[string]any{"query": "*", "range": 10.25}},
+		{name: "unknown dedup representative", args: map[string]any{"query": "*", "deduplicate": true, "dedup_representative": "oldest"}},
 	}
 
 	for _, tt := range tests {
@@ -67,7 +74,7 @@ func TestExecuteSearchDedupHonorsLimit(t *testing.T) {
 	result, err := executeSearch(context.Background(), client, graylog.SearchParams{
 		Query: "*",
 		Limit: 3,
-	}, true, false, 50000)
+	}, searchOptions{deduplicate: true}, 50000)
 	if err != nil {
 		t.Fatalf("executeSearch returned error: %v", err)
 	}
@@ -102,6 +109,53 @@ func TestExecuteSearchDedupHonorsLimit(t *testing.T) {
 	}
 }
 
+func TestExecuteSearchDedupFollowsSort(t *testing.T) {
+	// Arrival order matches no sort, as it can when Graylog breaks ties or
+	// merges shards differently.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 6, []testLogMessage{
+			{ID: "id-2", Timestamp: "2024-01-01T00:00:01.000Z", Source: "svc-a", Message: "dup-a", Index: "idx"},
+			{ID: "id-5", Timestamp: "2024-01-01T00:00:05.000Z", Source: "svc-b", Message: "dup-b", Index: "idx"},
+			{ID: "id-4", Timestamp: "2024-01-01T00:00:03.000Z", Source: "svc-a", Message: "dup-a", Index: "idx"},
+			{ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc-a", Message: "dup-a", Index: "idx"},
+			{ID: "id-3", Timestamp: "2024-01-01T00:00:02.000Z", Source: "svc-b", Message: "dup-b", Index: "idx"},
+			{ID: "id-6", Timestamp: "2024-01-01T00:00:02.000Z", Source: "svc-b", Message: "dup-b", Index: "idx"},
+		})
+	}))
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	tests := []struct {
+		sort    string
+		wantIDs []string // representative of each group, in output order
+	}{
+		{sort: "", wantIDs: []string{"id-5", "id-4"}},
+		{sort: "timestamp:desc", wantIDs: []string{"id-5", "id-4"}},
+		{sort: "timestamp:asc", wantIDs: []string{"id-1", "id-3"}},
+		{sort: "_id:desc", wantIDs: []string{"id-6", "id-4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			result, err := executeSearch(context.Background(), client, graylog.SearchParams{
+				Query: "*",
+				Limit: 10,
+				Sort:  tt.sort,
+			}, searchOptions{deduplicate: true}, 50000)
+			if err != nil {
+				t.Fatalf("executeSearch returned error: %v", err)
+			}
+			var ids []string
+			for _, row := range decodeToolResultJSON(t, result)["deduplicated"].([]any) {
+				// Rows carry no _id; the representative is the group's first message ID.
+				ids = append(ids, row.(map[string]any)["message_ids"].([]any)[0].(string))
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Fatalf("expected representatives %v, got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
 func TestExecuteSearchDedupWithOffset(t *testing.T) {
 	// 8 messages, 6 unique after dedup. With offset=2, limit=2 we should get unique[2] and unique[3].
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -119,11 +173,13 @@ func TestExecuteSearchDedupWithOffset(t *testing.T) {
 	defer server.Close()
 
 	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	// The mock returns oldest first, so ask for that order.
 	result, err := executeSearch(context.Background(), client, graylog.SearchParams{
 		Query:  "*",
 		Limit:  2,
 		Offset: 2,
-	}, true, false, 50000)
+		Sort:   "timestamp:asc",
+	}, searchOptions{deduplicate: true}, 50000)
 	if err != nil {
 		t.Fatalf("executeSearch returned error: %v", err)
 	}
@@ -175,7 +231,7 @@ func TestExecuteSearchDedupRespectsFields(t *testing.T) {
 		Query:  "*",
 		Limit:  10,
 		Fields: "timestamp,source,message,level",
-	}, true, false, 50000)
+	}, searchOptions{deduplicate: true}, 50000)
 	if err != nil {
 		t.Fatalf("executeSearch returned error: %v", err)
 	}
@@ -216,7 +272,7 @@ func TestExecuteSearchTemplateize(t *testing.T) {
 	result, err := executeSearch(context.Background(), client, graylog.SearchParams{
 		Query: "*",
 		Limit: 50,
-	}, false, true, 50000)
+	}, searchOptions{extractTemplates: true}, 50000)
 	if err != nil {
 		t.Fatalf("executeSearch returned error: %v", err)
 	}
@@ -269,7 +325,7 @@ func TestExecuteSearchTemplateize(t *testing.T) {
 
 func TestSearchLogsRejectsExtractTemplatesWithDeduplicate(t *testing.T) {
 	client := graylog.NewClient("https://graylog.example.com", "token", "token", false, 2*time.Second)
-	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client })
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
 
 	req := mcp.CallToolRequest{}
 	req.Params.Arguments = map[string]any{
@@ -303,7 +359,7 @@ func TestExecuteSearchOmitsQueryTimeInNonDedupMode(t *testing.T) {
 	result, err := executeSearch(context.Background(), client, graylog.SearchParams{
 		Query: "*",
 		Limit: 10,
-	}, false, false, 50000)
+	}, searchOptions{}, 50000)
 	if err != nil {
 		t.Fatalf("executeSearch returned error: %v", err)
 	}
@@ -313,3 +369,1318 @@ func TestExecuteSearchOmitsQueryTimeInNonDedupMode(t *testing.T) {
 		t.Fatal("query_time_ms should not be present in non-dedup search_logs response")
 	}
 }
+
+func TestSearchLogsHandlerDefaultStreamID(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       map[string]any
+		wantStream string
+	}{
+		{name: "default applied", args: map[string]any{"query": "*"}, wantStream: "default-stream"},
+		{name: "explicit overrides default", args: map[string]any{"query": "*", "stream_id": "explicit-stream", "validate_stream": false}, wantStream: "explicit-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotStreams []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Queries []struct {
+						Filter *struct {
+							Filters []struct {
+								ID string `json:"id"`
+							} `json:"filters"`
+						} `json:"filter"`
+					} `json:"queries"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if len(body.Queries) > 0 && body.Queries[0].Filter != nil {
+					for _, f := range body.Queries[0].Filter.Filters {
+						gotStreams = append(gotStreams, f.ID)
+					}
+				}
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			cfg := &config.Config{DefaultStreamID: "default-stream"}
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, cfg, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if len(gotStreams) != 1 || gotStreams[0] != tt.wantStream {
+				t.Fatalf("expected stream filter [%s], got %v", tt.wantStream, gotStreams)
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerFieldPresenceQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantQuery string
+	}{
+		{
+			name:      "no presence params",
+			args:      map[string]any{"query": "level:ERROR OR level:WARN"},
+			wantQuery: "level:ERROR OR level:WARN",
+		},
+		{
+			name:      "has fields only",
+			args:      map[string]any{"query": "level:ERROR OR level:WARN", "has_fields": "trace_id, user_id"},
+			wantQuery: "(level:ERROR OR level:WARN) AND _exists_:trace_id AND _exists_:user_id",
+		},
+		{
+			name:      "missing fields only",
+			args:      map[string]any{"query": "*", "missing_fields": "trace_id"},
+			wantQuery: "(*) AND NOT _exists_:trace_id",
+		},
+		{
+			name:      "has and missing fields",
+			args:      map[string]any{"query": "source:web", "has_fields": "status", "missing_fields": "trace_id,,span_id"},
+			wantQuery: "(source:web) AND _exists_:status AND NOT _exists_:trace_id AND NOT _exists_:span_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Queries []struct {
+						Query struct {
+							QueryString string `json:"query_string"`
+						} `json:"query"`
+					} `json:"queries"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if len(body.Queries) > 0 {
+					gotQuery = body.Queries[0].Query.QueryString
+				}
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Fatalf("expected query %q, got %q", tt.wantQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerRegex(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantQuery string
+	}{
+		{
+			name:      "regex only",
+			args:      map[string]any{"regex": "message:timeout after [0-9]+ms"},
+			wantQuery: "message:/timeout after [0-9]+ms/",
+		},
+		{
+			name:      "regex ANDed with query",
+			args:      map[string]any{"query": "level:ERROR OR level:WARN", "regex": "source:web-[0-9]+"},
+			wantQuery: "(level:ERROR OR level:WARN) AND source:/web-[0-9]+/",
+		},
+		{
+			name:      "slashes escaped",
+			args:      map[string]any{"query": "*", "regex": `path:/api/v[12]/users\/.*`},
+			wantQuery: `(*) AND path:/\/api\/v[12]\/users\/.*/`,
+		},
+		{
+			name:      "combined with field presence",
+			args:      map[string]any{"query": "*", "regex": "user:adm.*", "has_fields": "trace_id"},
+			wantQuery: "((*) AND user:/adm.*/) AND _exists_:trace_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Queries []struct {
+						Query struct {
+							QueryString string `json:"query_string"`
+						} `json:"query"`
+					} `json:"queries"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if len(body.Queries) > 0 {
+					gotQuery = body.Queries[0].Query.QueryString
+				}
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Fatalf("expected query %q, got %q", tt.wantQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerRegexRejectedLocally(t *testing.T) {
+	tests := []struct {
+		name    string
+		regex   string
+		wantErr string
+	}{
+		{name: "invalid pattern", regex: "message:timeout (after", wantErr: "invalid 'regex' pattern"},
+		{name: "missing field", regex: ":foo.*", wantErr: "'field:pattern' form"},
+		{name: "missing colon", regex: "foo.*", wantErr: "'field:pattern' form"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"query": "*", "regex": tt.regex}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error")
+			}
+			if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %q", tt.wantErr, text)
+			}
+			if called {
+				t.Fatal("invalid regex should be rejected before calling Graylog")
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerDecorate(t *testing.T) {
+	var sentDecorate []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				SearchTypes []map[string]any `json:"search_types"`
+			} `json:"queries"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sentDecorate = append(sentDecorate, body.Queries[0].SearchTypes[0]["decorate"])
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID:        "id-1",
+			Timestamp: "2024-01-01T00:00:00.000Z",
+			Source:    "web",
+			Message:   "GET /",
+			Index:     "graylog_0",
+			Extra:     map[string]any{"client_ip": "8.8.8.8", "client_country": "US", "status": "OK"},
+			DecorationStats: map[string]any{
+				"added_fields":   map[string]any{"client_country": "US"},
+				"changed_fields": map[string]any{"status": float64(200)},
+			},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "decorate": true}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	req.Params.Arguments = map[string]any{"query": "*"}
+	if _, err := handler(context.Background(), req); err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if len(sentDecorate) != 2 || sentDecorate[0] != true || sentDecorate[1] != nil {
+		t.Fatalf("expected decorate=true only when requested, got %v", sentDecorate)
+	}
+
+	entry := decodeToolResultJSON(t, result)["messages"].([]any)[0].(map[string]any)
+	if msg := entry["message"].(map[string]any); msg["client_country"] != "US" || msg["status"] != "OK" {
+		t.Errorf("expected decorated fields in the message, got %v", msg)
+	}
+	stats, ok := entry["decoration_stats"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected decoration_stats, got %v", entry)
+	}
+	if added := stats["added_fields"].(map[string]any); added["client_country"] != "US" {
+		t.Errorf("unexpected added_fields: %v", stats["added_fields"])
+	}
+	if changed := stats["changed_fields"].(map[string]any); changed["status"] != float64(200) {
+		t.Errorf("unexpected changed_fields: %v", stats["changed_fields"])
+	}
+	if _, ok := stats["removed_fields"]; ok {
+		t.Errorf("empty removed_fields should be omitted, got %v", stats)
+	}
+}
+
+func TestSearchLogsHandlerValidateStream(t *testing.T) {
+	var streamLookups, searches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/streams":
+			streamLookups++
+			_, _ = w.Write([]byte(`{"streams":[{"id":"s-1","title":"Auth"},{"id":"s-2","title":"Billing"}],"total":2}`))
+		default:
+			searches++
+			writeViewsSearchResponse(w, 0, nil)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	getClient := func(_ context.Context) *graylog.Client { return client }
+
+	tests := []struct {
+		name        string
+		args        map[string]any
+		wantErr     bool
+		wantSearch  bool
+		wantLookups int
+	}{
+		{name: "bogus stream", args: map[string]any{"query": "*", "stream_id": "bogus"}, wantErr: true, wantLookups: 1},
+		{name: "valid stream", args: map[string]any{"query": "*", "stream_id": "s-2"}, wantSearch: true, wantLookups: 1},
+		{name: "validation disabled", args: map[string]any{"query": "*", "stream_id": "bogus", "validate_stream": false}, wantSearch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamLookups, searches = 0, 0
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			// A fresh handler per case, so each case counts its own stream lookup.
+			result, err := searchLogsHandler(getClient, &config.Config{}, nil)(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError != tt.wantErr {
+				t.Fatalf("IsError = %v, want %v: %+v", result.IsError, tt.wantErr, result.Content)
+			}
+			if tt.wantErr {
+				text := result.Content[0].(mcp.TextContent).Text
+				for _, want := range []string{"stream 'bogus' not found or not accessible", "Auth (s-1)", "Billing (s-2)"} {
+					if !strings.Contains(text, want) {
+						t.Errorf("error %q should contain %q", text, want)
+					}
+				}
+			}
+			if (searches > 0) != tt.wantSearch {
+				t.Errorf("searches = %d, wantSearch %v", searches, tt.wantSearch)
+			}
+			if streamLookups != tt.wantLookups {
+				t.Errorf("stream lookups = %d, want %d", streamLookups, tt.wantLookups)
+			}
+		})
+	}
+
+	// Repeated validations reuse one stream lookup, with or without the
+	// metadata cache.
+	for name, cache := range map[string]*metadataCache{"metadata cache": newMetadataCache(time.Minute), "no metadata cache": nil} {
+		handler := searchLogsHandler(getClient, &config.Config{}, cache)
+		streamLookups = 0
+		for range 2 {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"query": "*", "stream_id": "s-1"}
+			if result, _ := handler(context.Background(), req); result.IsError {
+				t.Fatalf("%s: unexpected tool error: %+v", name, result.Content)
+			}
+		}
+		if streamLookups != 1 {
+			t.Errorf("%s: expected 1 stream lookup for two searches, got %d", name, streamLookups)
+		}
+	}
+}
+
+func TestSearchLogsHandlerDefaultFields(t *testing.T) {
+	var sentFields []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				SearchTypes []map[string]any `json:"search_types"`
+			} `json:"queries"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sentFields = append(sentFields, body.Queries[0].SearchTypes[0]["fields"])
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "web", Message: "hello", Index: "idx",
+			Extra: map[string]any{"level": "INFO", "kubernetes_pod": "web-1", "facility": "app"},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{DefaultFields: "level,kubernetes_*"}, nil)
+
+	tests := []struct {
+		name       string
+		args       map[string]any
+		wantFields []string
+	}{
+		{name: "default projection", args: map[string]any{"query": "*"}, wantFields: []string{"level", "kubernetes_pod"}},
+		{name: "explicit fields override", args: map[string]any{"query": "*", "fields": "facility"}, wantFields: []string{"facility"}},
+		{name: "star bypasses default", args: map[string]any{"query": "*", "fields": "*"}, wantFields: []string{"level", "kubernetes_pod", "facility"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			msg := decodeToolResultJSON(t, result)["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+			var extra []string
+			for k := range msg {
+				switch k {
+				case "_id", "timestamp", "source", "message":
+				default:
+					extra = append(extra, k)
+				}
+			}
+			sort.Strings(extra)
+			want := append([]string(nil), tt.wantFields...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(extra, want) {
+				t.Fatalf("expected fields %v, got %v", want, extra)
+			}
+		})
+	}
+	// Globs are resolved locally, so only the explicit exact list reaches Graylog.
+	if len(sentFields) != 3 || sentFields[0] != nil || !reflect.DeepEqual(sentFields[1], []any{"facility"}) || sentFields[2] != nil {
+		t.Errorf("unexpected server-side fields: %v", sentFields)
+	}
+}
+
+func TestSearchLogsHandlerIndex(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantQuery string
+		wantErr   string
+	}{
+		{
+			name:      "scoped to index",
+			args:      map[string]any{"query": "level:ERROR", "index": "graylog_42"},
+			wantQuery: "(level:ERROR) AND _index:graylog_42",
+		},
+		{
+			name:      "dash escaped",
+			args:      map[string]any{"query": "*", "index": "audit-logs_3"},
+			wantQuery: `(*) AND _index:audit\-logs_3`,
+		},
+		{
+			name:      "combined with field presence",
+			args:      map[string]any{"query": "*", "index": "graylog_0", "has_fields": "trace_id"},
+			wantQuery: "((*) AND _index:graylog_0) AND _exists_:trace_id",
+		},
+		{name: "wildcard rejected", args: map[string]any{"query": "*", "index": "graylog_*"}, wantErr: "single index name"},
+		{name: "uppercase rejected", args: map[string]any{"query": "*", "index": "Graylog_1"}, wantErr: "single index name"},
+		{name: "leading underscore rejected", args: map[string]any{"query": "*", "index": "_all"}, wantErr: "single index name"},
+		{name: "list rejected", args: map[string]any{"query": "*", "index": "graylog_1,graylog_2"}, wantErr: "single index name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery string
+			called := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				var body struct {
+					Queries []struct {
+						Query struct {
+							QueryString string `json:"query_string"`
+						} `json:"query"`
+					} `json:"queries"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if len(body.Queries) > 0 {
+					gotQuery = body.Queries[0].Query.QueryString
+				}
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if tt.wantErr != "" {
+				if !result.IsError {
+					t.Fatal("expected tool error")
+				}
+				if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %q", tt.wantErr, text)
+				}
+				if called {
+					t.Fatal("invalid index should be rejected before calling Graylog")
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if gotQuery != tt.wantQuery {
+				t.Fatalf("expected query %q, got %q", tt.wantQuery, gotQuery)
+			}
+		})
+	}
+}
+
+func TestBuildRangeFilterClauses(t *testing.T) {
+	tests := []struct {
+		filters string
+		want    []string
+		wantErr bool
+	}{
+		{filters: "took_ms>=100", want: []string{"took_ms:[100 TO *]"}},
+		{filters: "took_ms<=100", want: []string{"took_ms:[* TO 100]"}},
+		{filters: "status>499", want: []string{"status:{499 TO *}"}},
+		{filters: "status<500", want: []string{"status:{* TO 500}"}},
+		{filters: "level=ERROR", want: []string{"level:ERROR"}},
+		{filters: " took_ms >= 100 , status < 500 ,", want: []string{"took_ms:[100 TO *]", "status:{* TO 500}"}},
+		{filters: "delta>=-5", want: []string{`delta:[\-5 TO *]`}},
+		{filters: "path=/api/v1 users", want: []string{`path:"\/api\/v1 users"`}},
+		{filters: "took_ms", wantErr: true},
+		{filters: ">=100", wantErr: true},
+		{filters: "took_ms>=", wantErr: true},
+		{filters: "took_ms=>100", wantErr: true},
+		{filters: "took ms>=100", wantErr: true},
+		{filters: " , ", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := buildRangeFilterClauses(tt.filters)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", tt.filters, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.filters, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.filters, got, tt.want)
+		}
+	}
+}
+
+func TestSearchLogsHandlerRangeFilters(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				Query struct {
+					QueryString string `json:"query_string"`
+				} `json:"query"`
+			} `json:"queries"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Queries) > 0 {
+			gotQuery = body.Queries[0].Query.QueryString
+		}
+		writeViewsSearchResponse(w, 0, nil)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"query":         "service:api OR service:web",
+		"range_filters": "took_ms>=100,status<500",
+		"has_fields":    "trace_id",
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	// The base query is parenthesized so its OR doesn't swallow the filters.
+	if want := "((service:api OR service:web) AND took_ms:[100 TO *] AND status:{* TO 500}) AND _exists_:trace_id"; gotQuery != want {
+		t.Errorf("expected query %q, got %q", want, gotQuery)
+	}
+
+	gotQuery = ""
+	req.Params.Arguments = map[string]any{"query": "*", "range_filters": "took_ms~100"}
+	result, _ = handler(context.Background(), req)
+	if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "[invalid_input]") {
+		t.Errorf("expected an invalid_input error, got %+v", result.Content)
+	}
+	if gotQuery != "" {
+		t.Error("invalid range_filters should be rejected before calling Graylog")
+	}
+}
+
+func TestExecuteSearchTemplateizeSampling(t *testing.T) {
+	messages := make([]testLogMessage, 0, 40)
+	for i := 0; i < 40; i++ {
+		messages = append(messages, testLogMessage{
+			ID:        fmt.Sprintf("id-%d", i),
+			Timestamp: "2024-01-01T00:00:00.000Z",
+			Source:    "svc",
+			Message:   fmt.Sprintf("Connection to 10.0.0.%d failed: timeout", i),
+			Index:     "idx",
+		})
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 40, messages)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	run := func(sampleSize int) map[string]any {
+		t.Helper()
+		result, err := executeSearch(context.Background(), client, graylog.SearchParams{Query: "*", Limit: 50},
+			searchOptions{extractTemplates: true, templateSampleSize: sampleSize}, 50000)
+		if err != nil {
+			t.Fatalf("executeSearch returned error: %v", err)
+		}
+		return decodeToolResultJSON(t, result)
+	}
+
+	payload := run(10)
+	if payload["sampled"] != true {
+		t.Fatalf("expected sampled=true above the threshold, got %v", payload["sampled"])
+	}
+	if payload["sample_size"] != float64(10) {
+		t.Fatalf("expected sample_size=10, got %v", payload["sample_size"])
+	}
+	total := 0.0
+	for _, tmpl := range payload["templates"].([]any) {
+		total += tmpl.(map[string]any)["count"].(float64)
+	}
+	if total != 40 {
+		t.Fatalf("expected template counts scaled back to 40, got %v", total)
+	}
+
+	payload = run(100)
+	if _, exists := payload["sampled"]; exists {
+		t.Fatal("sampled flag must not be set below the threshold")
+	}
+	if _, exists := payload["sample_size"]; exists {
+		t.Fatal("sample_size must not be set below the threshold")
+	}
+}
+
+func TestSearchLogsHandlerCountOnly(t *testing.T) {
+	var gotLimit = -1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, err := parseContextSearchCall(r)
+		if err != nil {
+			t.Errorf("failed to parse search call: %v", err)
+		}
+		gotLimit = call.Limit
+		writeViewsSearchResponse(w, 1234, nil)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "level:ERROR", "count_only": true, "limit": float64(200)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if gotLimit != 0 {
+		t.Fatalf("expected outgoing search limit 0, got %d", gotLimit)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["total_results"] != float64(1234) {
+		t.Fatalf("expected total_results=1234, got %v", payload["total_results"])
+	}
+	if payload["limit"] != float64(0) {
+		t.Fatalf("expected limit=0, got %v", payload["limit"])
+	}
+	if _, exists := payload["messages"]; exists {
+		t.Fatal("count_only response must not include messages")
+	}
+}
+
+func TestExecuteSearchHighlightOnlyWhenRequested(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc-a", Message: "login failed", Index: "idx",
+			Extra: map[string]any{"user": "bob failed"},
+			HighlightRanges: map[string]any{
+				"message": []any{map[string]any{"start": 6, "length": 6}},
+				"user":    []any{map[string]any{"start": 4, "length": 6}},
+			},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	firstMessage := func(opts searchOptions, fields string) map[string]any {
+		t.Helper()
+		result, err := executeSearch(context.Background(), client, graylog.SearchParams{
+			Query: "failed", Limit: 10, Fields: fields,
+		}, opts, 50000)
+		if err != nil {
+			t.Fatalf("executeSearch returned error: %v", err)
+		}
+		messages, ok := decodeToolResultJSON(t, result)["messages"].([]any)
+		if !ok || len(messages) != 1 {
+			t.Fatalf("expected 1 message, got %v", messages)
+		}
+		return messages[0].(map[string]any)
+	}
+
+	if _, ok := firstMessage(searchOptions{}, "")["highlight_ranges"]; ok {
+		t.Error("highlight_ranges should be omitted unless highlight=true")
+	}
+
+	ranges, ok := firstMessage(searchOptions{highlight: true}, "")["highlight_ranges"].(map[string]any)
+	if !ok || ranges["message"] == nil || ranges["user"] == nil {
+		t.Fatalf("expected highlight ranges for message and user, got %v", ranges)
+	}
+
+	msg := firstMessage(searchOptions{highlight: true}, "timestamp")
+	ranges, _ = msg["highlight_ranges"].(map[string]any)
+	if ranges["message"] == nil {
+		t.Errorf("expected core field 'message' highlight to survive field filtering, got %v", ranges)
+	}
+	if _, ok := ranges["user"]; ok {
+		t.Errorf("expected highlight for filtered-out field 'user' to be dropped, got %v", ranges)
+	}
+	if _, ok := msg["message"].(map[string]any)["user"]; ok {
+		t.Error("field filtering should still drop 'user' from the message")
+	}
+}
+
+func TestSearchLogsHandlerDebugReturnsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("debug mode must not call Graylog, got %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{
+		"query":     "level:ERROR",
+		"from":      "2024-01-15T10:00:00.000Z",
+		"to":        "2024-01-15T11:00:00.000Z",
+		"stream_id": "stream-1",
+		"fields":    "source,level",
+		"sort":      "timestamp:asc",
+		"limit":     float64(25),
+		"offset":    float64(50),
+		"debug":     true,
+	}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	want := client.PreviewSearch(graylog.SearchParams{
+		Query:     "level:ERROR",
+		From:      "2024-01-15T10:00:00.000Z",
+		To:        "2024-01-15T11:00:00.000Z",
+		Limit:     25,
+		Offset:    50,
+		Fields:    "source,level",
+		Sort:      "timestamp:asc",
+		StreamIDs: []string{"stream-1"},
+	})
+	b, _ := json.Marshal(want)
+	var wantJSON map[string]any
+	_ = json.Unmarshal(b, &wantJSON)
+	if payload["debug"] != true || !reflect.DeepEqual(payload["request"], wantJSON) {
+		t.Fatalf("unexpected debug payload:\ngot:  %v\nwant: %v", payload["request"], wantJSON)
+	}
+}
+
+func TestExecuteSearchNextOffset(t *testing.T) {
+	// 8 raw messages, 6 unique groups after dedup.
+	messages := []testLogMessage{
+		{ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc-a", Message: "dup-a", Index: "idx"},
+		{ID: "id-2", Timestamp: "2024-01-01T00:00:01.000Z", Source: "svc-a", Message: "dup-a", Index: "idx"},
+		{ID: "id-3", Timestamp: "2024-01-01T00:00:02.000Z", Source: "svc-b", Message: "dup-b", Index: "idx"},
+		{ID: "id-4", Timestamp: "2024-01-01T00:00:03.000Z", Source: "svc-b", Message: "dup-b", Index: "idx"},
+		{ID: "id-5", Timestamp: "2024-01-01T00:00:04.000Z", Source: "svc-c", Message: "unique-1", Index: "idx"},
+		{ID: "id-6", Timestamp: "2024-01-01T00:00:05.000Z", Source: "svc-d", Message: "unique-2", Index: "idx"},
+		{ID: "id-7", Timestamp: "2024-01-01T00:00:06.000Z", Source: "svc-e", Message: "unique-3", Index: "idx"},
+		{ID: "id-8", Timestamp: "2024-01-01T00:00:07.000Z", Source: "svc-f", Message: "unique-4", Index: "idx"},
+	}
+
+	tests := []struct {
+		name        string
+		total       int
+		returned    []testLogMessage
+		offset      int
+		limit       int
+		deduplicate bool
+		want        any // float64 next offset, or nil when exhausted
+	}{
+		{name: "raw first page", total: 5, returned: messages[:2], limit: 2, want: float64(2)},
+		{name: "raw last page", total: 5, returned: messages[3:5], offset: 3, limit: 2, want: nil},
+		{name: "dedup steps through groups", total: 20, returned: messages, offset: 2, limit: 2, deduplicate: true, want: float64(4)},
+		{name: "dedup exhausted", total: 8, returned: messages, offset: 4, limit: 4, deduplicate: true, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeViewsSearchResponse(w, tt.total, tt.returned)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			result, err := executeSearch(context.Background(), client, graylog.SearchParams{
+				Query:  "*",
+				Limit:  tt.limit,
+				Offset: tt.offset,
+			}, searchOptions{deduplicate: tt.deduplicate}, 50000)
+			if err != nil {
+				t.Fatalf("executeSearch returned error: %v", err)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			next, ok := payload["next_offset"]
+			if !ok {
+				t.Fatal("next_offset missing from response")
+			}
+			if next != tt.want {
+				t.Fatalf("expected next_offset=%v, got %v (has_more=%v)", tt.want, next, payload["has_more"])
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerTimerangeKeyword(t *testing.T) {
+	client := graylog.NewClient("https://graylog.example.com", "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "timerange_keyword": "last 24 hours", "debug": true}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	request := decodeToolResultJSON(t, result)["request"].(map[string]any)
+	tr := request["body"].(map[string]any)["queries"].([]any)[0].(map[string]any)["timerange"]
+	if want := (map[string]any{"type": "keyword", "keyword": "last 24 hours"}); !reflect.DeepEqual(tr, want) {
+		t.Fatalf("expected keyword timerange %v, got %v", want, tr)
+	}
+
+	req.Params.Arguments = map[string]any{
+		"query":             "*",
+		"timerange_keyword": "last 24 hours",
+		"from":              "2024-01-15T10:00:00.000Z",
+		"to":                "2024-01-15T11:00:00.000Z",
+	}
+	result, err = handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected IsError=true when timerange_keyword is combined with from/to")
+	}
+}
+
+func TestSearchLogsHandlerMissingResultStructure(t *testing.T) {
+	for _, countOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("count_only=%v", countOnly), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"results":{"q1":{"search_types":{}}}}`))
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"query": "*", "count_only": countOnly}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			payload := decodeToolResultJSON(t, result)
+			if payload["warning"] != "search backend returned no result structure" {
+				t.Fatalf("expected no-result-structure warning, got %v", payload)
+			}
+			if _, ok := payload["messages"]; ok {
+				t.Error("warning result must not carry messages")
+			}
+			if _, ok := payload["total_results"]; ok {
+				t.Error("warning result must not carry total_results")
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerDedupOverfetchScalesLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantLimit int
+	}{
+		{name: "default multiplier", args: map[string]any{"limit": float64(20)}, wantLimit: 60},
+		{name: "custom multiplier", args: map[string]any{"limit": float64(20), "dedup_overfetch": float64(7)}, wantLimit: 140},
+		{name: "includes offset", args: map[string]any{"limit": float64(20), "offset": float64(10), "dedup_overfetch": float64(5)}, wantLimit: 150},
+		{name: "multiplier clamped to max", args: map[string]any{"limit": float64(20), "dedup_overfetch": float64(50)}, wantLimit: 200},
+		{name: "zero treated as one", args: map[string]any{"limit": float64(20), "dedup_overfetch": float64(0)}, wantLimit: 20},
+		{name: "capped at 10000", args: map[string]any{"limit": float64(5000), "dedup_overfetch": float64(4)}, wantLimit: 10000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLimit int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call, err := parseContextSearchCall(r)
+				if err != nil {
+					t.Errorf("failed to parse search call: %v", err)
+				}
+				gotLimit = call.Limit
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+			args := map[string]any{"query": "*", "deduplicate": true}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if gotLimit != tt.wantLimit {
+				t.Fatalf("expected search limit %d, got %d", tt.wantLimit, gotLimit)
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerMaxSearchLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		args      map[string]any
+		wantLimit int    // limit sent to Graylog
+		wantError string // substring of the tool error, if rejected
+	}{
+		{name: "at configured ceiling", cfg: &config.Config{MaxSearchLimit: 100}, args: map[string]any{"limit": float64(100)}, wantLimit: 100},
+		{name: "above configured ceiling", cfg: &config.Config{MaxSearchLimit: 100}, args: map[string]any{"limit": float64(101)}, wantError: "exceeds this server's maximum of 100"},
+		{name: "above default ceiling", cfg: &config.Config{}, args: map[string]any{"limit": float64(10001)}, wantError: "maximum of 10000"},
+		{name: "raised ceiling", cfg: &config.Config{MaxSearchLimit: 20000}, args: map[string]any{"limit": float64(15000)}, wantLimit: 15000},
+		{name: "dedup fetch capped by ceiling", cfg: &config.Config{MaxSearchLimit: 100}, args: map[string]any{"limit": float64(50), "deduplicate": true}, wantLimit: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotLimit := -1
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call, err := parseContextSearchCall(r)
+				if err != nil {
+					t.Errorf("failed to parse search call: %v", err)
+				}
+				gotLimit = call.Limit
+				writeViewsSearchResponse(w, 0, nil)
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, tt.cfg, nil)
+
+			args := map[string]any{"query": "*"}
+			for k, v := range tt.args {
+				args[k] = v
+			}
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+
+			if tt.wantError != "" {
+				if !result.IsError {
+					t.Fatal("expected tool error")
+				}
+				if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, tt.wantError) {
+					t.Errorf("expected error containing %q, got %q", tt.wantError, text)
+				}
+				if gotLimit != -1 {
+					t.Error("rejected request must not reach Graylog")
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			if gotLimit != tt.wantLimit {
+				t.Errorf("expected search limit %d, got %d", tt.wantLimit, gotLimit)
+			}
+		})
+	}
+}
+
+func TestSearchLogsHandlerFieldPatterns(t *testing.T) {
+	var sentFields []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		searchType := body["queries"].([]any)[0].(map[string]any)["search_types"].([]any)[0].(map[string]any)
+		sentFields, _ = searchType["fields"].([]any)
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "web-1", Message: "boom", Index: "idx",
+			Extra: map[string]any{"kubernetes_pod": "api-0", "kubernetes_labels_app": "api", "trace_id": "t1", "level": 3},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "fields": "kubernetes_*, *_id, -kubernetes_labels_*"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if sentFields != nil {
+		t.Errorf("field patterns must not be sent to Graylog, got %v", sentFields)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	msg := payload["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	for _, k := range []string{"_id", "timestamp", "source", "message", "kubernetes_pod", "trace_id"} {
+		if _, ok := msg[k]; !ok {
+			t.Errorf("expected field %q in output, got %v", k, msg)
+		}
+	}
+	for _, k := range []string{"kubernetes_labels_app", "level"} {
+		if _, ok := msg[k]; ok {
+			t.Errorf("expected field %q to be filtered out, got %v", k, msg)
+		}
+	}
+}
+
+func TestSearchLogsHandlerCollapseField(t *testing.T) {
+	var gotLimit, gotOffset float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		searchType := body["queries"].([]any)[0].(map[string]any)["search_types"].([]any)[0].(map[string]any)
+		gotLimit, _ = searchType["limit"].(float64)
+		gotOffset, _ = searchType["offset"].(float64)
+		msg := func(id, host string) testLogMessage {
+			m := testLogMessage{ID: id, Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Message: "msg " + id, Index: "idx"}
+			if host != "" {
+				m.Extra = map[string]any{"host": host}
+			}
+			return m
+		}
+		writeViewsSearchResponse(w, 6, []testLogMessage{
+			msg("id-1", "web-1"), msg("id-2", "web-1"), msg("id-3", "web-2"),
+			msg("id-4", ""), msg("id-5", "web-2"), msg("id-6", "web-3"),
+		})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "collapse_field": "host", "limit": float64(3), "offset": float64(1)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if gotOffset != 0 || gotLimit != float64((1+3)*dedupFetchMultiplier) {
+		t.Errorf("expected an overfetch from offset 0, got limit=%v offset=%v", gotLimit, gotOffset)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["collapsed_groups"] != float64(4) || payload["collapse_field"] != "host" {
+		t.Fatalf("expected 4 collapsed groups on host, got %v", payload)
+	}
+	messages := payload["messages"].([]any)
+	var ids []string
+	var counts []float64
+	for _, m := range messages {
+		entry := m.(map[string]any)
+		ids = append(ids, entry["message"].(map[string]any)["_id"].(string))
+		counts = append(counts, entry["collapsed_count"].(float64))
+	}
+	// Groups in order: web-1 (id-1), web-2 (id-3), missing (id-4), web-3 (id-6); offset 1 skips web-1.
+	if !reflect.DeepEqual(ids, []string{"id-3", "id-4", "id-6"}) || !reflect.DeepEqual(counts, []float64{2, 1, 1}) {
+		t.Errorf("expected one message per host, got ids %v counts %v", ids, counts)
+	}
+	if payload["has_more"] != false || payload["offset"] != float64(1) || payload["limit"] != float64(3) {
+		t.Errorf("unexpected paging: has_more=%v offset=%v limit=%v", payload["has_more"], payload["offset"], payload["limit"])
+	}
+}
+
+func TestSearchLogsHandlerCollapseFieldExclusive(t *testing.T) {
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client {
+		t.Fatal("client must not be requested for invalid arguments")
+		return nil
+	}, &config.Config{}, nil)
+	for _, mode := range []string{"deduplicate", "extract_templates"} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = map[string]any{"query": "*", "collapse_field": "host", mode: true}
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if !result.IsError {
+			t.Errorf("expected collapse_field with %s to be rejected", mode)
+		}
+	}
+}
+
+func TestSearchLogsHandlerFullMessage(t *testing.T) {
+	stack := "NullPointerException\n" + strings.Repeat("\tat com.example.Service.handle(Service.java:42)\n", 20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 2, []testLogMessage{
+			{ID: "id-1", Timestamp: "2024-01-01T00:00:01.000Z", Source: "svc", Message: "request failed", Index: "idx", Extra: map[string]any{"full_message": stack + "req=a1"}},
+			{ID: "id-2", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Message: "request failed", Index: "idx", Extra: map[string]any{"full_message": stack + "req=b2"}},
+		})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+	call := func(args map[string]any) map[string]any {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %+v", result.Content)
+		}
+		return decodeToolResultJSON(t, result)
+	}
+
+	payload := call(map[string]any{"query": "*"})
+	msg := payload["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if msg["full_message"] != stack+"req=a1" {
+		t.Errorf("expected full_message to be returned untouched, got %q", msg["full_message"])
+	}
+
+	payload = call(map[string]any{"query": "*", "truncate_message": float64(20)})
+	msg = payload["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+	if full, _ := msg["full_message"].(string); full != stack[:20]+"...[truncated]" {
+		t.Errorf("expected full_message truncated to 20 bytes, got %q", full)
+	}
+	if msg["message"] != "request failed" {
+		t.Errorf("message shorter than the limit should be unchanged, got %q", msg["message"])
+	}
+
+	payload = call(map[string]any{"query": "*", "deduplicate": true, "truncate_message": float64(20)})
+	groups := payload["deduplicated"].([]any)
+	if len(groups) != 1 || groups[0].(map[string]any)["count"] != float64(2) {
+		t.Fatalf("expected differing full_message values to share one dedup group, got %v", groups)
+	}
+	if full, _ := groups[0].(map[string]any)["message"].(map[string]any)["full_message"].(string); full != stack[:20]+"...[truncated]" {
+		t.Errorf("expected dedup full_message truncated, got %q", full)
+	}
+}
+
+func TestSearchLogsHandlerTruncateFields(t *testing.T) {
+	trace := strings.Repeat("at com.example.Service.handle(Service.java:42) ", 10)
+	payloadBlob := `{"items":[` + strings.Repeat(`{"id":1},`, 20) + `]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeViewsSearchResponse(w, 1, []testLogMessage{{
+			ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: "svc", Message: "request failed with a long message", Index: "idx",
+			Extra: map[string]any{"stack_trace": trace, "payload": payloadBlob, "status": float64(500)},
+		}})
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+
+	for _, tt := range []struct {
+		name string
+		args map[string]any
+		msg  func(map[string]any) map[string]any
+	}{
+		{
+			name: "plain",
+			args: map[string]any{"query": "*", "truncate_fields": "stack_trace,status,message", "truncate_field_chars": float64(16)},
+			msg: func(p map[string]any) map[string]any {
+				return p["messages"].([]any)[0].(map[string]any)["message"].(map[string]any)
+			},
+		},
+		{
+			name: "dedup",
+			args: map[string]any{"query": "*", "deduplicate": true, "truncate_fields": "stack_trace,status,message", "truncate_field_chars": float64(16)},
+			msg: func(p map[string]any) map[string]any {
+				return p["deduplicated"].([]any)[0].(map[string]any)["message"].(map[string]any)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = tt.args
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+			msg := tt.msg(decodeToolResultJSON(t, result))
+			if msg["stack_trace"] != trace[:16]+"...[truncated]" {
+				t.Errorf("expected stack_trace truncated to 16 bytes, got %q", msg["stack_trace"])
+			}
+			if msg["message"] != "request failed w...[truncated]" {
+				t.Errorf("expected listed core field message truncated, got %q", msg["message"])
+			}
+			if msg["payload"] != payloadBlob {
+				t.Errorf("unlisted field payload must be untouched, got %q", msg["payload"])
+			}
+			if msg["status"] != float64(500) {
+				t.Errorf("non-string values must be untouched, got %v", msg["status"])
+			}
+		})
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "truncate_fields": "stack_trace"}
+	if result, _ := handler(context.Background(), req); !result.IsError {
+		t.Error("expected truncate_fields without truncate_field_chars to be rejected")
+	}
+}
+
+func TestTruncateMessagesInResultTruncatesFullMessage(t *testing.T) {
+	result := map[string]any{"messages": []map[string]any{{
+		"message": map[string]any{"message": strings.Repeat("m", 100), "full_message": strings.Repeat("f", 100)},
+	}}}
+	truncateMessagesInResult(result, 10, false)
+	msg := result["messages"].([]map[string]any)[0]["message"].(map[string]any)
+	if msg["message"] != strings.Repeat("m", 10)+"...[truncated]" || msg["full_message"] != strings.Repeat("f", 10)+"...[truncated]" {
+		t.Errorf("expected both message and full_message truncated, got %v", msg)
+	}
+}
+
+func TestSearchLogsHandlerEffectiveRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":{"q1":{"search_types":{"msgs":{
+			"total_results": 0,
+			"messages": [],
+			"effective_timerange": {"type":"absolute","from":"2024-01-15T09:55:00.000Z","to":"2024-01-15T10:00:00.000Z"}
+		}}}}}`))
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{}, nil)
+	want := map[string]any{"from": "2024-01-15T09:55:00.000Z", "to": "2024-01-15T10:00:00.000Z"}
+
+	for name, args := range map[string]map[string]any{
+		"relative":   {"query": "*", "range": float64(300)},
+		"keyword":    {"query": "*", "timerange_keyword": "last 5 minutes"},
+		"count_only": {"query": "*", "count_only": true},
+		"dedup":      {"query": "*", "deduplicate": true},
+	} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: handler returned error: %v", name, err)
+		}
+		if got := decodeToolResultJSON(t, result)["effective_range"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected effective_range %v, got %v", name, want, got)
+		}
+	}
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "*", "from": "2024-01-15T09:55:00.000Z", "to": "2024-01-15T10:00:00.000Z"}
+	result, _ := handler(context.Background(), req)
+	if got, ok := decodeToolResultJSON(t, result)["effective_range"]; ok {
+		t.Errorf("expected no effective_range for an absolute range, got %v", got)
+	}
+}
@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	searchWithContextDefaultLimit = 10
+	searchWithContextMaxLimit     = 100
+)
+
+func searchWithContextTool() mcp.Tool {
+	return mcp.NewTool("search_with_context",
+		mcp.WithDescription("Search logs and fetch the surrounding context of the newest match in one call: a search_logs followed by get_log_context on its top result. Returns the matches and the context block."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Lucene query string (e.g. 'level:ERROR AND service:auth')"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within; also restricts the context"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 300). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of matches to return, newest first (default: %d, max: %d)", searchWithContextDefaultLimit, searchWithContextMaxLimit)),
+		),
+		mcp.WithString("fields",
+			mcp.Description("Comma-separated list of fields to return for matches and context. Supports '*' globs and '-' exclusions; core fields are always kept. Defaults to the server's GRAYLOG_DEFAULT_FIELDS if set; pass '*' for all fields."),
+		),
+		mcp.WithNumber("before",
+			mcp.Description("Number of messages to fetch before the top match (default: 5)"),
+		),
+		mcp.WithNumber("after",
+			mcp.Description("Number of messages to fetch after the top match (default: 5)"),
+		),
+		redactParam(),
+	)
+}
+
+func searchWithContextHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	contextHandler := getLogContextHandler(getClient, cfg)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			return toolError("'query' parameter is required"), nil
+		}
+		limit, err := getStrictNonNegativeIntParam(args, "limit", searchWithContextDefaultLimit)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if limit > searchWithContextMaxLimit {
+			return toolError(fmt.Sprintf("'limit' must be at most %d", searchWithContextMaxLimit)), nil
+		}
+		limit = max(limit, 1)
+		// Validated here so a bad value fails before the search runs.
+		for _, key := range []string{"before", "after"} {
+			if _, err := getStrictNonNegativeIntParam(args, key, 5); err != nil {
+				return toolError(err.Error()), nil
+			}
+		}
+		tr, err := parseTimeRange(args, 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		params := graylog.SearchParams{
+			Query:     query,
+			Limit:     limit,
+			Sort:      "timestamp:desc",
+			Fields:    getFieldsParam(args, cfg),
+			StreamIDs: getStreamIDsParam(args, cfg),
+		}
+		tr.applyTo(&params)
+		resp, err := c.Search(ctx, params)
+		if err != nil {
+			if errors.Is(err, graylog.ErrNoResultStructure) {
+				return noResultStructureResult(err), nil
+			}
+			return graylogError("Search failed", err), nil
+		}
+		redactMessages(resp.Messages, getRedactPatterns(args, cfg))
+		sortMessages(resp.Messages, params.Sort)
+
+		var fieldList []string
+		if params.Fields != "" {
+			for _, f := range strings.Split(params.Fields, ",") {
+				fieldList = append(fieldList, strings.TrimSpace(f))
+			}
+		}
+		fieldFilter := graylog.NewFieldFilter(fieldList)
+		messages := make([]map[string]any, len(resp.Messages))
+		for i, w := range resp.Messages {
+			messages[i] = map[string]any{
+				"message": w.Message.FilteredMap(fieldFilter),
+				"index":   w.Index,
+			}
+			if ref := contextRef(w.Index, w.Message.ID); ref != "" {
+				messages[i]["context_ref"] = ref
+			}
+		}
+		result := map[string]any{
+			"query":         query,
+			"messages":      messages,
+			"total_results": resp.TotalResults,
+		}
+		if len(resp.Messages) == 0 {
+			result["context"] = nil
+			result["note"] = "no messages matched; widen the time range or relax the query"
+			return toolSuccess(result), nil
+		}
+
+		// The context is exactly what get_log_context returns for the top
+		// match, so both tools agree on ordering, fitting and errors.
+		top := resp.Messages[0]
+		contextArgs := map[string]any{
+			"message_id": top.Message.ID,
+			"index":      top.Index,
+		}
+		for _, key := range []string{"before", "after", "fields", "stream_id", "redact"} {
+			if v, ok := args[key]; ok {
+				contextArgs[key] = v
+			}
+		}
+		contextReq := mcp.CallToolRequest{}
+		contextReq.Params.Name = "get_log_context"
+		contextReq.Params.Arguments = contextArgs
+		contextResult, _ := contextHandler(ctx, contextReq)
+		text := ""
+		if len(contextResult.Content) > 0 {
+			if tc, ok := contextResult.Content[0].(mcp.TextContent); ok {
+				text = tc.Text
+			}
+		}
+		if contextResult.IsError || !json.Valid([]byte(text)) {
+			result["context"] = nil
+			result["context_error"] = text
+		} else {
+			result["context"] = json.RawMessage(text)
+		}
+
+		return fitResult(ctx, result, defaultMaxResultSize+contextResultMaxSize, resultAdapter{
+			truncateMsgs: func(maxLen int) {
+				truncateMessagesInResult(result, maxLen, false)
+			},
+			reduceMsgs: func() bool {
+				messages, _ := result["messages"].([]map[string]any)
+				if len(messages) <= 1 {
+					return false
+				}
+				result["messages"] = messages[:len(messages)/2]
+				result["messages_truncated"] = true
+				return true
+			},
+		})
+	}
+}
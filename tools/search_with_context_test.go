@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestSearchWithContextHandler(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/views/search/sync":
+			call, err := parseContextSearchCall(r)
+			if err != nil {
+				t.Fatalf("failed to parse search call: %v", err)
+			}
+			switch {
+			case len(calls) == 0:
+				calls = append(calls, "search")
+				writeViewsSearchResponse(w, 7, []testLogMessage{
+					{ID: "hit-2", Timestamp: "2024-01-01T10:00:05.000Z", Source: "api", Message: "timeout calling db", Index: "graylog_3"},
+					{ID: "hit-1", Timestamp: "2024-01-01T09:59:00.000Z", Source: "api", Message: "timeout calling db", Index: "graylog_3"},
+				})
+			case call.Order == "DESC":
+				calls = append(calls, "context-before")
+				writeViewsSearchResponse(w, 1, []testLogMessage{
+					{ID: "ctx-b", Timestamp: "2024-01-01T10:00:04.000Z", Source: "api", Message: "pool exhausted", Index: "graylog_3"},
+				})
+			default:
+				calls = append(calls, "context-after")
+				writeViewsSearchResponse(w, 1, []testLogMessage{
+					{ID: "ctx-a", Timestamp: "2024-01-01T10:00:06.000Z", Source: "api", Message: "retry ok", Index: "graylog_3"},
+				})
+			}
+		case "/api/messages/graylog_3/hit-2":
+			calls = append(calls, "target")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"message":{"fields":{"_id":"hit-2","timestamp":"2024-01-01T10:00:05.000Z","source":"api","message":"timeout calling db"}},"index":"graylog_3"}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchWithContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "timeout", "before": float64(1), "after": float64(1)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+
+	// The search runs first; the context lookups follow on its top hit.
+	if len(calls) != 4 || calls[0] != "search" || calls[1] != "target" {
+		t.Fatalf("unexpected call order %v", calls)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["total_results"] != float64(7) {
+		t.Errorf("expected total_results 7, got %v", payload["total_results"])
+	}
+	var hits []string
+	for _, m := range payload["messages"].([]any) {
+		hits = append(hits, m.(map[string]any)["message"].(map[string]any)["_id"].(string))
+	}
+	if !reflect.DeepEqual(hits, []string{"hit-2", "hit-1"}) {
+		t.Errorf("messages = %v, want newest first", hits)
+	}
+
+	block, ok := payload["context"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected a context block, got %v (error %v)", payload["context"], payload["context_error"])
+	}
+	target := block["target_message"].(map[string]any)["message"].(map[string]any)
+	if target["_id"] != "hit-2" {
+		t.Errorf("expected context around the top hit, got target %v", target["_id"])
+	}
+	if got := extractContextMessageIDs(t, block, "messages_before"); !reflect.DeepEqual(got, []string{"ctx-b"}) {
+		t.Errorf("messages_before = %v", got)
+	}
+	if got := extractContextMessageIDs(t, block, "messages_after"); !reflect.DeepEqual(got, []string{"ctx-a"}) {
+		t.Errorf("messages_after = %v", got)
+	}
+}
+
+func TestSearchWithContextHandlerNoMatches(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeViewsSearchResponse(w, 0, nil)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := searchWithContextHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"query": "nothing:here"}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	payload := decodeToolResultJSON(t, result)
+	if requests != 1 {
+		t.Errorf("expected only the search request, got %d", requests)
+	}
+	if payload["context"] != nil || payload["note"] == nil {
+		t.Errorf("expected a null context with a note, got %v", payload)
+	}
+
+	for _, args := range []map[string]any{
+		{},
+		{"query": "*", "limit": float64(searchWithContextMaxLimit + 1)},
+		{"query": "*", "before": float64(-1)},
+	} {
+		req.Params.Arguments = args
+		result, _ := handler(context.Background(), req)
+		if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "[invalid_input]") {
+			t.Errorf("args %v: expected an invalid_input error, got %+v", args, result.Content)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// sortMessages stably orders messages by a 'field:asc|desc' spec the way
+// Graylog does (empty spec means timestamp:desc), with _id in the same
+// direction as the tiebreaker and messages missing the field last. It makes
+// order-dependent post-processing such as dedup representatives follow the
+// caller's sort rather than the order a batch happened to arrive in.
+func sortMessages(messages []graylog.MessageWrapper, spec string) {
+	if spec == "" {
+		spec = "timestamp:desc"
+	}
+	field, order, ok := strings.Cut(spec, ":")
+	if !ok {
+		return
+	}
+	desc := strings.EqualFold(order, "desc")
+
+	slices.SortStableFunc(messages, func(a, b graylog.MessageWrapper) int {
+		av, aok := messageFieldValue(a.Message, field)
+		bv, bok := messageFieldValue(b.Message, field)
+		switch {
+		case !aok && !bok:
+		case !aok:
+			return 1
+		case !bok:
+			return -1
+		default:
+			if c := compareFieldValues(field, av, bv); c != 0 {
+				if desc {
+					return -c
+				}
+				return c
+			}
+		}
+		c := strings.Compare(a.Message.ID, b.Message.ID)
+		if desc {
+			return -c
+		}
+		return c
+	})
+}
+
+// compareFieldValues orders numbers numerically and strings lexically,
+// timestamps by instant; numbers sort before strings, anything else after
+// both and compares equal.
+func compareFieldValues(field string, a, b any) int {
+	rank := func(v any) int {
+		switch v.(type) {
+		case float64:
+			return 0
+		case string:
+			return 1
+		}
+		return 2
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+	switch av := a.(type) {
+	case float64:
+		return cmp.Compare(av, b.(float64))
+	case string:
+		bv := b.(string)
+		if field == "timestamp" {
+			at, aerr := time.Parse(time.RFC3339Nano, av)
+			bt, berr := time.Parse(time.RFC3339Nano, bv)
+			if aerr == nil && berr == nil {
+				return at.Compare(bt)
+			}
+		}
+		return strings.Compare(av, bv)
+	}
+	return 0
+}
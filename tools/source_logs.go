@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const sourceLogsDefaultRange = 3600
+
+func sourceLogsTool() mcp.Tool {
+	return mcp.NewTool("source_logs",
+		mcp.WithDescription("Show the most recent messages from one source host, newest first. A shortcut for search_logs with a correctly quoted source:\"<value>\" query."),
+		mcp.WithString("source",
+			mcp.Required(),
+			mcp.Description("Exact value of the message 'source' field (usually a hostname)"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Optional Lucene query to narrow the messages further (e.g. 'level:ERROR')"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of messages to return (default: 50, max: 10000 unless the server sets GRAYLOG_MAX_SEARCH_LIMIT)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of messages to skip for pagination (default: 0). Pass next_offset from the previous response to get the next page."),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 3600). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+		mcp.WithString("fields",
+			mcp.Description("Comma-separated list of fields to return. Supports '*' globs and '-' exclusions; core fields are always kept."),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		redactParam(),
+	)
+}
+
+func sourceLogsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		source := strings.TrimSpace(getStringParam(args, "source"))
+		if source == "" {
+			return toolError("'source' parameter is required"), nil
+		}
+
+		tr, err := parseTimeRange(args, sourceLogsDefaultRange)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		limit, maxLimit, err := getSearchLimitParam(args, cfg)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		offset, err := getStrictNonNegativeIntParam(args, "offset", 0)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		params := graylog.SearchParams{
+			Query:     sourceQuery(source, getStringParam(args, "query")),
+			Limit:     limit,
+			Offset:    offset,
+			Fields:    getStringParam(args, "fields"),
+			Sort:      "timestamp:desc",
+			StreamIDs: getStreamIDsParam(args, cfg),
+		}
+		tr.applyTo(&params)
+		return executeSearch(ctx, c, params, searchOptions{maxFetch: maxLimit, redact: getRedactPatterns(args, cfg)}, defaultMaxResultSize)
+	}
+}
+
+// sourceQuery matches source exactly; escapeLuceneValue makes spaces, colons
+// and other Lucene syntax in it literal.
+func sourceQuery(source, query string) string {
+	clause := "source:" + escapeLuceneValue(source)
+	if query == "" {
+		return clause
+	}
+	return "(" + query + ") AND " + clause
+}
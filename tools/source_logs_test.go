@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestSourceLogsHandlerQuotesSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		query     string
+		wantQuery string
+	}{
+		{name: "plain", source: "web-1", wantQuery: `source:web\-1`},
+		{name: "spaces", source: "web 1 prod", wantQuery: `source:"web 1 prod"`},
+		{name: "colons", source: "fe80::1:9000", wantQuery: `source:fe80\:\:1\:9000`},
+		{name: "quotes", source: `web"1" OR *`, wantQuery: `source:"web\"1\" OR \*"`},
+		{name: "backslash", source: `DOMAIN\host`, wantQuery: `source:DOMAIN\\host`},
+		{name: "with query", source: "web-1", query: "level:ERROR OR level:WARN", wantQuery: `(level:ERROR OR level:WARN) AND source:web\-1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Queries []struct {
+					Query struct {
+						QueryString string `json:"query_string"`
+					} `json:"query"`
+					TimeRange struct {
+						Range int `json:"range"`
+					} `json:"timerange"`
+					SearchTypes []struct {
+						Limit int `json:"limit"`
+						Sort  []struct {
+							Field string `json:"field"`
+							Order string `json:"order"`
+						} `json:"sort"`
+					} `json:"search_types"`
+				} `json:"queries"`
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("failed to decode search request: %v", err)
+				}
+				writeViewsSearchResponse(w, 1, []testLogMessage{
+					{ID: "id-1", Timestamp: "2024-01-01T00:00:00.000Z", Source: tt.source, Message: "hello", Index: "idx"},
+				})
+			}))
+			defer server.Close()
+
+			client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+			handler := sourceLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+			req := mcp.CallToolRequest{}
+			req.Params.Arguments = map[string]any{"source": tt.source, "query": tt.query, "limit": float64(10)}
+			result, err := handler(context.Background(), req)
+			if err != nil {
+				t.Fatalf("handler returned error: %v", err)
+			}
+			if result.IsError {
+				t.Fatalf("unexpected tool error: %+v", result.Content)
+			}
+
+			q := got.Queries[0]
+			if q.Query.QueryString != tt.wantQuery {
+				t.Errorf("query = %q, want %q", q.Query.QueryString, tt.wantQuery)
+			}
+			if q.TimeRange.Range != sourceLogsDefaultRange {
+				t.Errorf("expected default range %d, got %d", sourceLogsDefaultRange, q.TimeRange.Range)
+			}
+			st := q.SearchTypes[0]
+			if st.Limit != 10 || len(st.Sort) == 0 || st.Sort[0].Field != "timestamp" || st.Sort[0].Order != "DESC" {
+				t.Errorf("expected 10 newest messages, got limit %d sort %+v", st.Limit, st.Sort)
+			}
+			payload := decodeToolResultJSON(t, result)
+			if msgs, _ := payload["messages"].([]any); len(msgs) != 1 {
+				t.Errorf("expected 1 message, got %v", payload["messages"])
+			}
+		})
+	}
+}
+
+func TestSourceLogsHandlerRequiresSource(t *testing.T) {
+	handler := sourceLogsHandler(func(_ context.Context) *graylog.Client {
+		t.Fatal("client must not be requested without a source")
+		return nil
+	}, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"source": "  "}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Error("expected an error for a blank source")
+	}
+}
@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func systemInfoTool() mcp.Tool {
+	return mcp.NewTool("system_info",
+		mcp.WithDescription("Get Graylog version, indexer cluster status (green/yellow/red), node count and total indexed message count. Useful for diagnosing ingestion gaps."),
+	)
+}
+
+func systemInfoHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		info, err := c.GetSystemInfo(ctx)
+		if err != nil {
+			return graylogError("Failed to get system info", err), nil
+		}
+
+		return toolSuccess(info), nil
+	}
+}
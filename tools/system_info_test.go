@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func newSystemInfoTestServer(healthy bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/system":
+			_, _ = w.Write([]byte(`{"version":"6.1.4","hostname":"gl-1","cluster_id":"c-1","lifecycle":"running","is_processing":true,"timezone":"UTC"}`))
+		case "/api/system/indexer/cluster/health":
+			if !healthy {
+				http.Error(w, `{"message":"indexer unreachable"}`, http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"yellow","shards":{"active":10}}`))
+		case "/api/system/cluster/nodes":
+			_, _ = w.Write([]byte(`{"nodes":[{},{},{}],"total":3}`))
+		case "/api/count/total":
+			_, _ = w.Write([]byte(`{"events":123456789}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSystemInfoHandler(t *testing.T) {
+	server := newSystemInfoTestServer(true)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := systemInfoHandler(func(_ context.Context) *graylog.Client { return client })
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["version"] != "6.1.4" {
+		t.Errorf("expected version 6.1.4, got %v", payload["version"])
+	}
+	if payload["cluster_status"] != "yellow" {
+		t.Errorf("expected cluster_status yellow, got %v", payload["cluster_status"])
+	}
+	if payload["node_count"] != float64(3) {
+		t.Errorf("expected node_count 3, got %v", payload["node_count"])
+	}
+	if payload["total_messages"] != float64(123456789) {
+		t.Errorf("expected total_messages 123456789, got %v", payload["total_messages"])
+	}
+	if _, exists := payload["warnings"]; exists {
+		t.Errorf("expected no warnings, got %v", payload["warnings"])
+	}
+}
+
+func TestSystemInfoHandlerDegradedIndexer(t *testing.T) {
+	server := newSystemInfoTestServer(false)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := systemInfoHandler(func(_ context.Context) *graylog.Client { return client })
+
+	result, err := handler(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("indexer failure should not fail the whole tool: %+v", result.Content)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	if payload["version"] != "6.1.4" {
+		t.Errorf("expected version to still be reported, got %v", payload["version"])
+	}
+	warnings, ok := payload["warnings"].([]any)
+	if !ok || len(warnings) != 1 {
+		t.Fatalf("expected 1 warning for cluster health, got %v", payload["warnings"])
+	}
+}
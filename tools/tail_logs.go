@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	tailLogsDefaultRange = 300
+	// maxTailBoundaryIDs caps the IDs a cursor remembers for messages at its
+	// watermark timestamp. Past it the watermark moves on by a millisecond.
+	maxTailBoundaryIDs = 100
+)
+
+var errInvalidTailCursor = errors.New("'cursor' is not a valid tail_logs cursor; pass the cursor from the previous tail_logs result")
+
+func tailLogsTool() mcp.Tool {
+	return mcp.NewTool("tail_logs",
+		mcp.WithDescription("Follow new log messages like 'tail -f'. The first call returns the latest messages; pass the returned 'cursor' to the next call to get only messages newer than those already seen, oldest first."),
+		mcp.WithString("query",
+			mcp.Description("Lucene query (default: '*'). Keep it the same across calls that share a cursor."),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("The 'cursor' from the previous tail_logs call. Resumes exactly after the messages already returned, including ones sharing the last timestamp."),
+		),
+		mcp.WithString("since",
+			mcp.Description("Alternative to 'cursor': an ISO8601 timestamp (e.g. the previous 'watermark'); only messages strictly newer are returned."),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Lookback in seconds for the first call, without 'cursor' or 'since' (default: 300)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of messages per call (default: 50, max: 10000 unless the server sets GRAYLOG_MAX_SEARCH_LIMIT). If more arrived, has_more is true and the next call continues."),
+		),
+		mcp.WithString("fields",
+			mcp.Description("Comma-separated list of fields to return. Supports '*' globs and '-' exclusions; core fields are always kept. Defaults to the server's GRAYLOG_DEFAULT_FIELDS if set; pass '*' for all fields."),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		redactParam(),
+	)
+}
+
+// tailCursor is the decoded form of a tail_logs cursor: the newest timestamp
+// returned so far and the IDs of the returned messages carrying it, which the
+// next call skips because its search starts at that timestamp inclusively.
+type tailCursor struct {
+	Watermark string   `json:"ts"`
+	IDs       []string `json:"ids,omitempty"`
+}
+
+func (tc tailCursor) encode() string {
+	b, _ := json.Marshal(tc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseTailCursor(s string) (tailCursor, time.Time, error) {
+	var tc tailCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(raw, &tc) != nil {
+		return tailCursor{}, time.Time{}, errInvalidTailCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, tc.Watermark)
+	if err != nil {
+		return tailCursor{}, time.Time{}, errInvalidTailCursor
+	}
+	return tc, ts, nil
+}
+
+func tailLogsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = "*"
+		}
+		limit, _, err := getSearchLimitParam(args, cfg)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		lookback, err := getStrictNonNegativeIntParam(args, "range", tailLogsDefaultRange)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if lookback < 1 {
+			lookback = tailLogsDefaultRange
+		}
+
+		cursorArg, since := getStringParam(args, "cursor"), getStringParam(args, "since")
+		if cursorArg != "" && since != "" {
+			return toolError("'cursor' and 'since' are mutually exclusive"), nil
+		}
+
+		now := time.Now().UTC()
+		params := graylog.SearchParams{
+			Query:     query,
+			Fields:    getFieldsParam(args, cfg),
+			StreamIDs: getStreamIDsParam(args, cfg),
+			To:        now.Format(graylogTimeLayout),
+		}
+		// prev is the position already returned; skip holds the IDs at its
+		// timestamp that must not be returned again.
+		var prev tailCursor
+		skip := map[string]bool{}
+		resuming := true
+		switch {
+		case cursorArg != "":
+			tc, ts, err := parseTailCursor(cursorArg)
+			if err != nil {
+				return toolError(err.Error()), nil
+			}
+			prev = tc
+			for _, id := range tc.IDs {
+				skip[id] = true
+			}
+			params.From = ts.UTC().Format(graylogTimeLayout)
+		case since != "":
+			ts, err := time.Parse(time.RFC3339Nano, since)
+			if err != nil {
+				return toolError(fmt.Sprintf("invalid 'since' %q: use ISO8601, e.g. '2024-01-15T10:00:00.000Z'", since)), nil
+			}
+			// Timestamps have millisecond precision and 'from' is inclusive.
+			prev = tailCursor{Watermark: ts.UTC().Format(graylogTimeLayout)}
+			params.From = ts.UTC().Add(time.Millisecond).Truncate(time.Millisecond).Format(graylogTimeLayout)
+		default:
+			resuming = false
+			params.From = now.Add(-time.Duration(lookback) * time.Second).Format(graylogTimeLayout)
+			prev = tailCursor{Watermark: params.From}
+		}
+		if params.From > params.To {
+			params.From = params.To
+		}
+
+		// A resumed tail reads forward from the watermark; the first call
+		// wants the newest messages, so it reads backward.
+		if resuming {
+			params.Sort = "timestamp:asc"
+			params.Limit = limit + len(skip)
+		} else {
+			params.Sort = "timestamp:desc"
+			params.Limit = limit
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		// A tail exists to see new messages, so it never reads a cached page.
+		resp, err := c.Search(graylog.WithoutSearchCache(ctx), params)
+		if err != nil {
+			if errors.Is(err, graylog.ErrNoResultStructure) {
+				return noResultStructureResult(err), nil
+			}
+			return graylogError("Search failed", err), nil
+		}
+		redactMessages(resp.Messages, getRedactPatterns(args, cfg))
+
+		fetched := resp.Messages
+		// Oldest first, ties by _id, whichever way the search read.
+		sortMessages(fetched, "timestamp:asc")
+		var fresh []graylog.MessageWrapper
+		for _, m := range fetched {
+			if !skip[m.Message.ID] {
+				fresh = append(fresh, m)
+			}
+		}
+		hasMore := resuming && (len(fresh) > limit || len(fetched) < resp.TotalResults)
+		if len(fresh) > limit {
+			fresh = fresh[:limit]
+		}
+
+		var fieldList []string
+		if params.Fields != "" {
+			for _, f := range strings.Split(params.Fields, ",") {
+				fieldList = append(fieldList, strings.TrimSpace(f))
+			}
+		}
+		fieldFilter := graylog.NewFieldFilter(fieldList)
+		messages := make([]map[string]any, len(fresh))
+		for i, w := range fresh {
+			messages[i] = map[string]any{
+				"message": w.Message.FilteredMap(fieldFilter),
+				"index":   w.Index,
+			}
+			if ref := contextRef(w.Index, w.Message.ID); ref != "" {
+				messages[i]["context_ref"] = ref
+			}
+		}
+
+		if !resuming && len(fresh) == 0 {
+			// Nothing in the lookback window: start the next call from now.
+			prev = tailCursor{Watermark: params.To}
+		}
+		result := map[string]any{
+			"query":    query,
+			"messages": messages,
+			"has_more": hasMore,
+		}
+		setTailPosition(result, prev, fresh)
+		return fitTailResult(ctx, result, prev, fresh, defaultMaxResultSize)
+	}
+}
+
+// setTailPosition sets the new_messages count and the watermark and cursor
+// reached after returning msgs, which continue from prev in timestamp order.
+// Once more than maxTailBoundaryIDs returned messages share the watermark
+// millisecond, the cursor can't list them all, so the watermark moves 1ms past
+// it and a warning reports that unreturned messages at that millisecond will
+// be skipped; otherwise the next call would return the unlisted ones forever.
+func setTailPosition(result map[string]any, prev tailCursor, msgs []graylog.MessageWrapper) {
+	next := prev
+	var skipped string
+	for _, m := range msgs {
+		ts, err := time.Parse(time.RFC3339Nano, m.Message.Timestamp)
+		if err != nil {
+			continue
+		}
+		stamp := ts.UTC().Format(graylogTimeLayout)
+		switch {
+		case stamp > next.Watermark:
+			next = tailCursor{Watermark: stamp, IDs: []string{m.Message.ID}}
+		case stamp != next.Watermark || slices.Contains(next.IDs, m.Message.ID):
+		case len(next.IDs) < maxTailBoundaryIDs:
+			next.IDs = append(slices.Clip(next.IDs), m.Message.ID)
+		default:
+			skipped = stamp
+			next = tailCursor{Watermark: ts.UTC().Truncate(time.Millisecond).Add(time.Millisecond).Format(graylogTimeLayout)}
+		}
+	}
+	result["new_messages"] = len(msgs)
+	result["watermark"] = next.Watermark
+	result["cursor"] = next.encode()
+	if skipped != "" {
+		result["warning"] = fmt.Sprintf("more than %d messages share timestamp %s; the cursor moved 1ms past it, so any not returned yet are skipped", maxTailBoundaryIDs, skipped)
+	} else {
+		delete(result, "warning")
+	}
+}
+
+// fitTailResult drops the newest messages first and moves the cursor back
+// with them, so the next call returns what was dropped.
+func fitTailResult(ctx context.Context, result map[string]any, prev tailCursor, msgs []graylog.MessageWrapper, maxSize int) (*mcp.CallToolResult, error) {
+	return fitResult(ctx, result, maxSize, resultAdapter{
+		truncateMsgs: func(maxLen int) {
+			truncateMessagesInResult(result, maxLen, false)
+		},
+		reduceMsgs: func() bool {
+			messages, _ := result["messages"].([]map[string]any)
+			if len(messages) <= 1 {
+				return false
+			}
+			n := len(messages) / 2
+			msgs = msgs[:n]
+			result["messages"] = messages[:n]
+			result["has_more"] = true
+			setTailPosition(result, prev, msgs)
+			return true
+		},
+	})
+}
@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// tailStore serves Views searches over an in-memory message list, honoring
+// the absolute time range, sort order and limit like Graylog.
+type tailStore struct {
+	mu       sync.Mutex
+	messages []testLogMessage
+}
+
+func (s *tailStore) add(msgs ...testLogMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msgs...)
+}
+
+func (s *tailStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Queries []struct {
+			Timerange struct {
+				From string `json:"from"`
+				To   string `json:"to"`
+			} `json:"timerange"`
+			SearchTypes []struct {
+				Limit int `json:"limit"`
+				Sort  []struct {
+					Order string `json:"order"`
+				} `json:"sort"`
+			} `json:"search_types"`
+		} `json:"queries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q, st := req.Queries[0], req.Queries[0].SearchTypes[0]
+	from, _ := time.Parse(time.RFC3339Nano, q.Timerange.From)
+	to, _ := time.Parse(time.RFC3339Nano, q.Timerange.To)
+
+	s.mu.Lock()
+	var matched []testLogMessage
+	for _, m := range s.messages {
+		ts, _ := time.Parse(time.RFC3339Nano, m.Timestamp)
+		if !ts.Before(from) && !ts.After(to) {
+			matched = append(matched, m)
+		}
+	}
+	s.mu.Unlock()
+	sort.SliceStable(matched, func(i, j int) bool {
+		if st.Sort[0].Order == "DESC" {
+			return matched[i].Timestamp > matched[j].Timestamp
+		}
+		return matched[i].Timestamp < matched[j].Timestamp
+	})
+	total := len(matched)
+	if len(matched) > st.Limit {
+		matched = matched[:st.Limit]
+	}
+	writeViewsSearchResponse(w, total, matched)
+}
+
+func tailMessageIDs(t *testing.T, payload map[string]any) []string {
+	t.Helper()
+	ids := []string{}
+	for _, raw := range payload["messages"].([]any) {
+		ids = append(ids, raw.(map[string]any)["message"].(map[string]any)["_id"].(string))
+	}
+	return ids
+}
+
+func TestTailLogsHandlerWatermark(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	at := func(sec int) string { return base.Add(time.Duration(sec) * time.Second).Format(graylogTimeLayout) }
+	msg := func(id string, sec int) testLogMessage {
+		return testLogMessage{ID: id, Timestamp: at(sec), Source: "web", Message: id, Index: "idx"}
+	}
+
+	store := &tailStore{}
+	store.add(msg("m1", 10), msg("m2", 20), msg("m3a", 30), msg("m3b", 30))
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := tailLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	call := func(args map[string]any) map[string]any {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil {
+			t.Fatalf("handler returned error: %v", err)
+		}
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %+v", result.Content)
+		}
+		return decodeToolResultJSON(t, result)
+	}
+
+	first := call(map[string]any{"range": float64(300)})
+	if got, want := tailMessageIDs(t, first), []string{"m1", "m2", "m3a", "m3b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first call returned %v, want %v (oldest first)", got, want)
+	}
+	if first["watermark"] != at(30) {
+		t.Fatalf("watermark = %v, want %s", first["watermark"], at(30))
+	}
+
+	// m4 shares the watermark's millisecond but wasn't returned yet.
+	store.add(msg("m4", 30), msg("m5", 40))
+	second := call(map[string]any{"cursor": first["cursor"]})
+	if got, want := tailMessageIDs(t, second), []string{"m4", "m5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second call returned %v, want only the newer %v", got, want)
+	}
+	if second["watermark"] != at(40) || second["has_more"] != false {
+		t.Fatalf("unexpected position: watermark %v, has_more %v", second["watermark"], second["has_more"])
+	}
+
+	third := call(map[string]any{"cursor": second["cursor"]})
+	if got := tailMessageIDs(t, third); len(got) != 0 {
+		t.Fatalf("third call returned %v, want nothing new", got)
+	}
+	if third["watermark"] != second["watermark"] {
+		t.Errorf("watermark moved without new messages: %v -> %v", second["watermark"], third["watermark"])
+	}
+
+	// 'since' is strictly newer than the timestamp.
+	since := call(map[string]any{"since": first["watermark"]})
+	if got, want := tailMessageIDs(t, since), []string{"m5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("since call returned %v, want %v", got, want)
+	}
+}
+
+func TestTailLogsHandlerPagesWithLimit(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	store := &tailStore{}
+	store.add(testLogMessage{ID: "m0", Timestamp: base.Format(graylogTimeLayout), Source: "web", Message: "m0", Index: "idx"})
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := tailLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{}
+	result, _ := handler(context.Background(), req)
+	cursor := decodeToolResultJSON(t, result)["cursor"]
+
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprintf("m%d", i)
+		store.add(testLogMessage{ID: id, Timestamp: base.Add(time.Duration(i) * time.Second).Format(graylogTimeLayout), Source: "web", Message: id, Index: "idx"})
+	}
+
+	var got []string
+	for range 3 {
+		req.Params.Arguments = map[string]any{"cursor": cursor, "limit": float64(2)}
+		result, _ := handler(context.Background(), req)
+		payload := decodeToolResultJSON(t, result)
+		got = append(got, tailMessageIDs(t, payload)...)
+		cursor = payload["cursor"]
+		if len(got) < 5 && payload["has_more"] != true {
+			t.Fatalf("expected has_more after %v", got)
+		}
+	}
+	if want := []string{"m1", "m2", "m3", "m4", "m5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paged tail returned %v, want %v", got, want)
+	}
+}
+
+func TestTailLogsHandlerAdvancesPastFullBoundary(t *testing.T) {
+	base := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	store := &tailStore{}
+	store.add(testLogMessage{ID: "m0", Timestamp: base.Format(graylogTimeLayout), Source: "web", Message: "m0", Index: "idx"})
+	server := httptest.NewServer(store)
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := tailLogsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{}
+	result, _ := handler(context.Background(), req)
+	cursor := decodeToolResultJSON(t, result)["cursor"]
+
+	// More messages share one millisecond than a cursor can list.
+	burst := base.Add(time.Second)
+	for i := range 150 {
+		id := fmt.Sprintf("b%03d", i)
+		store.add(testLogMessage{ID: id, Timestamp: burst.Format(graylogTimeLayout), Source: "web", Message: id, Index: "idx"})
+	}
+	store.add(testLogMessage{ID: "late", Timestamp: burst.Add(time.Second).Format(graylogTimeLayout), Source: "web", Message: "late", Index: "idx"})
+
+	var got []string
+	var warned bool
+	for calls := 0; ; calls++ {
+		if calls == 5 {
+			t.Fatalf("tail didn't reach the newest message after %d calls, got %d messages", calls, len(got))
+		}
+		req.Params.Arguments = map[string]any{"cursor": cursor, "limit": float64(60)}
+		result, _ := handler(context.Background(), req)
+		payload := decodeToolResultJSON(t, result)
+		got = append(got, tailMessageIDs(t, payload)...)
+		cursor = payload["cursor"]
+		if _, ok := payload["warning"]; ok {
+			warned = true
+			if want := burst.Add(time.Millisecond).Format(graylogTimeLayout); payload["watermark"] != want {
+				t.Errorf("watermark = %v, want %s", payload["watermark"], want)
+			}
+		}
+		if payload["has_more"] != true {
+			break
+		}
+	}
+	if !warned {
+		t.Error("expected a warning that messages at the full millisecond may be skipped")
+	}
+	if got[len(got)-1] != "late" {
+		t.Errorf("expected the tail to end at the newest message, got %v", got[len(got)-1])
+	}
+	seen := map[string]bool{}
+	for _, id := range got {
+		if seen[id] {
+			t.Fatalf("message %s returned twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestTailLogsHandlerRejectsBadInput(t *testing.T) {
+	handler := tailLogsHandler(func(_ context.Context) *graylog.Client {
+		t.Fatal("client must not be requested for invalid arguments")
+		return nil
+	}, &config.Config{})
+	for _, args := range []map[string]any{
+		{"cursor": "not-a-cursor"},
+		{"since": "yesterday"},
+		{"cursor": tailCursor{Watermark: "2024-01-01T00:00:00.000Z"}.encode(), "since": "2024-01-01T00:00:00.000Z"},
+	} {
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		if result, _ := handler(context.Background(), req); !result.IsError {
+			t.Errorf("expected tool error for %v", args)
+		}
+	}
+}
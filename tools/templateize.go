@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"context"
+	"math"
 	"sort"
 	"strings"
 
@@ -78,6 +80,27 @@ func templateizeMessages(messages []graylog.MessageWrapper) ([]TemplateResult, e
 	return results, nil
 }
 
+// sampleMessages uniformly samples messages down to n by taking evenly spaced
+// entries. It returns messages unchanged when n <= 0 or len(messages) <= n.
+func sampleMessages(messages []graylog.MessageWrapper, n int) []graylog.MessageWrapper {
+	if n <= 0 || len(messages) <= n {
+		return messages
+	}
+	sampled := make([]graylog.MessageWrapper, n)
+	for i := range sampled {
+		sampled[i] = messages[i*len(messages)/n]
+	}
+	return sampled
+}
+
+// scaleTemplateCounts multiplies each template count by ratio (population/sample),
+// rounding to the nearest integer and never going below the observed count.
+func scaleTemplateCounts(results []TemplateResult, ratio float64) {
+	for i := range results {
+		results[i].Count = max(results[i].Count, int(math.Round(float64(results[i].Count)*ratio)))
+	}
+}
+
 // capTemplateMessageIDs caps the MessageIDs slice on each template to maxIDs.
 func capTemplateMessageIDs(results []TemplateResult, maxIDs int) {
 	for i := range results {
@@ -88,7 +111,7 @@ func capTemplateMessageIDs(results []TemplateResult, maxIDs int) {
 }
 
 // fitTemplateSearchResult applies progressive fitting to a templateized search result.
-func fitTemplateSearchResult(result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
+func fitTemplateSearchResult(ctx context.Context, result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
 	adapter := resultAdapter{
 		truncateMsgs: func(maxLen int) {
 			if templates, ok := result["templates"].([]TemplateResult); ok {
@@ -119,5 +142,5 @@ func fitTemplateSearchResult(result map[string]any, maxSize int) (*mcp.CallToolR
 		},
 	}
 
-	return fitResult(result, maxSize, adapter)
+	return fitResult(ctx, result, maxSize, adapter)
 }
@@ -1,6 +1,8 @@
 package tools
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/n0madic/graylog-mcp/graylog"
@@ -84,3 +86,27 @@ func TestCapTemplateMessageIDs(t *testing.T) {
 		t.Fatalf("expected 2 message IDs (unchanged), got %d", len(results[1].MessageIDs))
 	}
 }
+
+func TestSampleMessages(t *testing.T) {
+	messages := make([]graylog.MessageWrapper, 10)
+	for i := range messages {
+		messages[i] = graylog.MessageWrapper{Message: graylog.Message{ID: fmt.Sprintf("id-%d", i)}}
+	}
+
+	if got := sampleMessages(messages, 0); len(got) != 10 {
+		t.Fatalf("sample_size=0 should disable sampling, got %d", len(got))
+	}
+	if got := sampleMessages(messages, 10); len(got) != 10 {
+		t.Fatalf("no sampling expected at the threshold, got %d", len(got))
+	}
+
+	got := sampleMessages(messages, 5)
+	var ids []string
+	for _, mw := range got {
+		ids = append(ids, mw.Message.ID)
+	}
+	want := []string{"id-0", "id-2", "id-4", "id-6", "id-8"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected evenly spaced sample %v, got %v", want, ids)
+	}
+}
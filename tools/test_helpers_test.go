@@ -15,6 +15,9 @@ type testLogMessage struct {
 	Message   string
 	Index     string
 	Extra     map[string]any
+
+	HighlightRanges map[string]any
+	DecorationStats map[string]any
 }
 
 func decodeToolResultJSON(t *testing.T, result *mcp.CallToolResult) map[string]any {
@@ -58,10 +61,17 @@ func writeViewsSearchResponse(w http.ResponseWriter, totalResults int, messages
 		for k, v := range msg.Extra {
 			msgFields[k] = v
 		}
-		serializedMessages = append(serializedMessages, map[string]any{
+		serialized := map[string]any{
 			"message": msgFields,
 			"index":   msg.Index,
-		})
+		}
+		if msg.HighlightRanges != nil {
+			serialized["highlight_ranges"] = msg.HighlightRanges
+		}
+		if msg.DecorationStats != nil {
+			serialized["decoration_stats"] = msg.DecorationStats
+		}
+		serializedMessages = append(serializedMessages, serialized)
 	}
 
 	_ = json.NewEncoder(w).Encode(map[string]any{
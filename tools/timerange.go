@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// defaultRangeSeconds is the relative range used when a call sets no time
+// range at all, or range 0. It matches Graylog's own search default.
+const defaultRangeSeconds = 300
+
+// Bounds of an absolute range covering all indexed data, for searches that
+// are positioned by sort order rather than by time.
+const (
+	unboundedFrom = "1970-01-01T00:00:00.000Z"
+	unboundedTo   = "2099-12-31T23:59:59.999Z"
+)
+
+// timeRange is a validated tool time range. Exactly one form is set: Keyword,
+// From/To (absolute), or Range (relative, in seconds).
+type timeRange struct {
+	Range   int
+	From    string
+	To      string
+	Keyword string
+}
+
+// parseTimeRange reads the shared 'range', 'from', 'to' and
+// 'timerange_keyword' params. 'from' and 'to' must come together and exclude
+// 'timerange_keyword'; 'range' is still validated when one of them wins.
+// defaultRange is used when 'range' is absent.
+func parseTimeRange(args map[string]any, defaultRange int) (timeRange, error) {
+	from := getStringParam(args, "from")
+	to := getStringParam(args, "to")
+	if (from == "") != (to == "") {
+		return timeRange{}, errors.New("'from' and 'to' must be used together")
+	}
+	keyword := getStringParam(args, "timerange_keyword")
+	if keyword != "" && from != "" {
+		return timeRange{}, errors.New("'timerange_keyword' and 'from'/'to' are mutually exclusive")
+	}
+	rangeVal, err := getStrictNonNegativeIntParam(args, "range", defaultRange)
+	if err != nil {
+		return timeRange{}, err
+	}
+
+	switch {
+	case keyword != "":
+		return timeRange{Keyword: keyword}, nil
+	case from != "":
+		return absoluteTimeRange(from, to), nil
+	case rangeVal == 0:
+		return timeRange{Range: defaultRangeSeconds}, nil
+	default:
+		return timeRange{Range: rangeVal}, nil
+	}
+}
+
+// absoluteTimeRange is the range between two ISO8601 timestamps.
+func absoluteTimeRange(from, to string) timeRange {
+	return timeRange{From: from, To: to}
+}
+
+// bounds returns the concrete window of an absolute or relative tr, a
+// relative one ending at now. A keyword range only has bounds once Graylog
+// resolves it, so it is an error here.
+func (tr timeRange) bounds(now time.Time) (from, to time.Time, err error) {
+	switch {
+	case tr.Keyword != "":
+		return from, to, errors.New("'timerange_keyword' has no fixed bounds before Graylog resolves it")
+	case tr.From != "":
+		if from, err = time.Parse(time.RFC3339Nano, tr.From); err != nil {
+			return from, to, fmt.Errorf("invalid 'from' %q: use ISO8601, e.g. '2024-01-15T10:00:00.000Z'", tr.From)
+		}
+		if to, err = time.Parse(time.RFC3339Nano, tr.To); err != nil {
+			return from, to, fmt.Errorf("invalid 'to' %q: use ISO8601, e.g. '2024-01-15T11:00:00.000Z'", tr.To)
+		}
+		if !from.Before(to) {
+			return from, to, errors.New("'from' must be before 'to'")
+		}
+		return from, to, nil
+	default:
+		return now.Add(-time.Duration(tr.Range) * time.Second), now, nil
+	}
+}
+
+// applyTo sets the time range of a Views API search.
+func (tr timeRange) applyTo(params *graylog.SearchParams) {
+	params.Range = tr.Range
+	params.From = tr.From
+	params.To = tr.To
+	params.Keyword = tr.Keyword
+}
+
+// scripting returns tr in Scripting API form.
+func (tr timeRange) scripting() graylog.ScriptingTimeRange {
+	switch {
+	case tr.Keyword != "":
+		return graylog.ScriptingTimeRange{Type: "keyword", Keyword: tr.Keyword}
+	case tr.From != "":
+		return graylog.ScriptingTimeRange{Type: "absolute", From: tr.From, To: tr.To}
+	default:
+		return graylog.ScriptingTimeRange{Type: "relative", Range: tr.Range}
+	}
+}
+
+// effectiveRange normalizes Graylog's effective_timerange into the concrete
+// {from, to} window a query covered, as UTC graylogTimeLayout timestamps.
+// Graylog reports it as an absolute range; when it is missing or unparseable,
+// a relative range of rangeSeconds ending at now stands in. Returns nil when
+// neither is available, e.g. for a keyword range Graylog didn't report.
+func effectiveRange(raw map[string]any, rangeSeconds int, now time.Time) map[string]string {
+	from, fromOK := parseEffectiveTime(raw["from"])
+	to, toOK := parseEffectiveTime(raw["to"])
+	if !fromOK || !toOK {
+		if rangeSeconds <= 0 {
+			return nil
+		}
+		from, to = now.Add(-time.Duration(rangeSeconds)*time.Second), now
+	}
+	return map[string]string{
+		"from": from.UTC().Format(graylogTimeLayout),
+		"to":   to.UTC().Format(graylogTimeLayout),
+	}
+}
+
+func parseEffectiveTime(v any) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         map[string]any
+		defaultRange int
+		want         timeRange
+		wantErr      string
+	}{
+		{name: "no params uses Graylog default", args: map[string]any{}, want: timeRange{Range: 300}},
+		{name: "no params uses tool default", args: map[string]any{}, defaultRange: 3600, want: timeRange{Range: 3600}},
+		{name: "explicit range", args: map[string]any{"range": float64(900)}, defaultRange: 3600, want: timeRange{Range: 900}},
+		{name: "range 0 falls back to Graylog default", args: map[string]any{"range": float64(0)}, defaultRange: 3600, want: timeRange{Range: 300}},
+		{
+			name: "absolute",
+			args: map[string]any{"from": "2024-01-15T10:00:00.000Z", "to": "2024-01-15T11:00:00.000Z", "range": float64(60)},
+			want: timeRange{From: "2024-01-15T10:00:00.000Z", To: "2024-01-15T11:00:00.000Z"},
+		},
+		{
+			name: "keyword",
+			args: map[string]any{"timerange_keyword": "last 24 hours", "range": float64(60)},
+			want: timeRange{Keyword: "last 24 hours"},
+		},
+		{name: "from without to", args: map[string]any{"from": "2024-01-15T10:00:00.000Z"}, wantErr: "'from' and 'to' must be used together"},
+		{name: "to without from", args: map[string]any{"to": "2024-01-15T10:00:00.000Z"}, wantErr: "'from' and 'to' must be used together"},
+		{
+			name:    "keyword with absolute",
+			args:    map[string]any{"timerange_keyword": "yesterday", "from": "2024-01-15T10:00:00.000Z", "to": "2024-01-15T11:00:00.000Z"},
+			wantErr: "mutually exclusive",
+		},
+		{name: "negative range", args: map[string]any{"range": float64(-5)}, wantErr: "'range'"},
+		{name: "fractional range", args: map[string]any{"range": 1.5}, wantErr: "'range' must be an integer"},
+		{
+			name:    "invalid range rejected even with absolute",
+			args:    map[string]any{"from": "2024-01-15T10:00:00.000Z", "to": "2024-01-15T11:00:00.000Z", "range": "soon"},
+			wantErr: "'range'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeRange(tt.args, tt.defaultRange)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeRangeConversions(t *testing.T) {
+	tests := []struct {
+		name          string
+		tr            timeRange
+		wantSearch    graylog.SearchParams
+		wantScripting graylog.ScriptingTimeRange
+	}{
+		{
+			name:          "relative",
+			tr:            timeRange{Range: 900},
+			wantSearch:    graylog.SearchParams{Query: "*", Range: 900},
+			wantScripting: graylog.ScriptingTimeRange{Type: "relative", Range: 900},
+		},
+		{
+			name:          "absolute",
+			tr:            absoluteTimeRange("2024-01-15T10:00:00.000Z", "2024-01-15T11:00:00.000Z"),
+			wantSearch:    graylog.SearchParams{Query: "*", From: "2024-01-15T10:00:00.000Z", To: "2024-01-15T11:00:00.000Z"},
+			wantScripting: graylog.ScriptingTimeRange{Type: "absolute", From: "2024-01-15T10:00:00.000Z", To: "2024-01-15T11:00:00.000Z"},
+		},
+		{
+			name:          "keyword",
+			tr:            timeRange{Keyword: "yesterday"},
+			wantSearch:    graylog.SearchParams{Query: "*", Keyword: "yesterday"},
+			wantScripting: graylog.ScriptingTimeRange{Type: "keyword", Keyword: "yesterday"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Stale fields from an earlier range must be overwritten.
+			params := graylog.SearchParams{Query: "*", Range: 60, From: "x", To: "y", Keyword: "z"}
+			tt.tr.applyTo(&params)
+			if !reflect.DeepEqual(params, tt.wantSearch) {
+				t.Errorf("applyTo: expected %+v, got %+v", tt.wantSearch, params)
+			}
+			if got := tt.tr.scripting(); got != tt.wantScripting {
+				t.Errorf("scripting: expected %+v, got %+v", tt.wantScripting, got)
+			}
+		})
+	}
+}
+
+func TestTimeRangeBounds(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		tr       timeRange
+		from, to time.Time
+		wantErr  string
+	}{
+		{name: "relative ends now", tr: timeRange{Range: 900}, from: now.Add(-15 * time.Minute), to: now},
+		{
+			name: "absolute",
+			tr:   absoluteTimeRange("2024-01-15T10:00:00Z", "2024-01-15T11:00:00.5+01:00"),
+			from: now,
+			to:   time.Date(2024, 1, 15, 10, 0, 0, 500000000, time.UTC),
+		},
+		{name: "bad from", tr: absoluteTimeRange("yesterday", "2024-01-15T11:00:00Z"), wantErr: "invalid 'from'"},
+		{name: "bad to", tr: absoluteTimeRange("2024-01-15T10:00:00Z", "now"), wantErr: "invalid 'to'"},
+		{name: "from after to", tr: absoluteTimeRange("2024-01-15T11:00:00Z", "2024-01-15T10:00:00Z"), wantErr: "'from' must be before 'to'"},
+		{name: "keyword", tr: timeRange{Keyword: "yesterday"}, wantErr: "'timerange_keyword'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := tt.tr.bounds(now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("expected %s..%s, got %s..%s", tt.from, tt.to, from, to)
+			}
+		})
+	}
+}
+
+func TestEffectiveRange(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		raw  map[string]any
+		rng  int
+		want map[string]string
+	}{
+		{
+			name: "graylog window normalized to UTC",
+			raw:  map[string]any{"type": "absolute", "from": "2024-01-15T10:55:00+01:00", "to": "2024-01-15T11:00:00.5+01:00"},
+			rng:  300,
+			want: map[string]string{"from": "2024-01-15T09:55:00.000Z", "to": "2024-01-15T10:00:00.500Z"},
+		},
+		{
+			name: "relative fallback",
+			raw:  nil,
+			rng:  900,
+			want: map[string]string{"from": "2024-01-15T09:45:00.000Z", "to": "2024-01-15T10:00:00.000Z"},
+		},
+		{
+			name: "unparseable window falls back",
+			raw:  map[string]any{"type": "relative", "range": 60},
+			rng:  60,
+			want: map[string]string{"from": "2024-01-15T09:59:00.000Z", "to": "2024-01-15T10:00:00.000Z"},
+		},
+		{name: "keyword without a window", raw: nil, rng: 0, want: nil},
+	}
+	for _, tt := range tests {
+		if got := effectiveRange(tt.raw, tt.rng, now); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const (
+	topErrorsDefaultQuery       = "level:ERROR OR level:CRITICAL"
+	topErrorsDefaultRange       = 3600
+	topErrorsDefaultMaxMessages = 1000
+)
+
+func topErrorsTool() mcp.Tool {
+	return mcp.NewTool("top_errors",
+		mcp.WithDescription("Show the most frequent error patterns in a time window, for incident triage. Fetches matching messages, groups them into templates (dynamic parts replaced by <*>) and returns the top groups ranked by count, each with a sample message and message IDs."),
+		mcp.WithString("query",
+			mcp.Description("Lucene query selecting error messages (default: 'level:ERROR OR level:CRITICAL')"),
+		),
+		mcp.WithNumber("top_n",
+			mcp.Description("Number of error groups to return (default: 10)"),
+		),
+		mcp.WithNumber("max_messages",
+			mcp.Description("Maximum number of messages to analyze (default: 1000, max: 10000)"),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Time range in seconds for relative search (default: 3600). Ignored if from/to are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start time in ISO8601 format (e.g. '2024-01-15T10:00:00.000Z'). Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End time in ISO8601 format. Must be used with 'from'."),
+		),
+		redactParam(),
+	)
+}
+
+// topErrorGroup is one ranked error template with a representative message.
+type topErrorGroup struct {
+	Template   string         `json:"template"`
+	Count      int            `json:"count"`
+	Sample     map[string]any `json:"sample,omitempty"`
+	MessageIDs []string       `json:"message_ids"`
+}
+
+func topErrorsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			query = topErrorsDefaultQuery
+		}
+
+		topN, err := getStrictNonNegativeIntParam(args, "top_n", 10)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if topN < 1 {
+			topN = 10
+		}
+
+		maxMessages, err := getStrictNonNegativeIntParam(args, "max_messages", topErrorsDefaultMaxMessages)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		if maxMessages < 1 {
+			maxMessages = topErrorsDefaultMaxMessages
+		}
+		maxMessages = min(maxMessages, 10000)
+
+		tr, err := parseTimeRange(args, topErrorsDefaultRange)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		params := graylog.SearchParams{
+			Query:     query,
+			Limit:     maxMessages,
+			StreamIDs: getStreamIDsParam(args, cfg),
+		}
+		tr.applyTo(&params)
+		resp, err := c.Search(ctx, params)
+		if err != nil {
+			return graylogError("Search failed", err), nil
+		}
+		redactMessages(resp.Messages, getRedactPatterns(args, cfg))
+
+		templates, err := templateizeMessages(resp.Messages)
+		if err != nil {
+			return classifiedError(errInternal, "Template extraction failed: "+err.Error()), nil
+		}
+		groups := rankTopErrors(templates, resp.Messages, topN)
+
+		result := map[string]any{
+			"query":             query,
+			"top_errors":        groups,
+			"template_count":    len(templates),
+			"messages_analyzed": len(resp.Messages),
+			"total_results":     resp.TotalResults,
+		}
+		if resp.TotalResults > len(resp.Messages) {
+			result["note"] = "counts cover the most recent messages_analyzed matches only; raise max_messages or narrow the time range for exact counts"
+		}
+		return fitTopErrorsResult(ctx, result, defaultMaxResultSize)
+	}
+}
+
+// rankTopErrors keeps the topN most frequent templates (already sorted by
+// count) and attaches the first message of each as its sample.
+func rankTopErrors(templates []TemplateResult, messages []graylog.MessageWrapper, topN int) []topErrorGroup {
+	if len(templates) > topN {
+		templates = templates[:topN]
+	}
+	byID := make(map[string]graylog.Message, len(messages))
+	for _, mw := range messages {
+		byID[mw.Message.ID] = mw.Message
+	}
+	capTemplateMessageIDs(templates, 5)
+
+	groups := make([]topErrorGroup, 0, len(templates))
+	for _, tmpl := range templates {
+		g := topErrorGroup{Template: tmpl.Template, Count: tmpl.Count, MessageIDs: tmpl.MessageIDs}
+		if len(tmpl.MessageIDs) > 0 {
+			if msg, ok := byID[tmpl.MessageIDs[0]]; ok {
+				g.Sample = msg.ToFilteredMap(nil)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+func fitTopErrorsResult(ctx context.Context, result map[string]any, maxSize int) (*mcp.CallToolResult, error) {
+	return fitResult(ctx, result, maxSize, resultAdapter{
+		truncateMsgs: func(maxLen int) {
+			groups, _ := result["top_errors"].([]topErrorGroup)
+			for i := range groups {
+				groups[i].Template = truncateString(groups[i].Template, maxLen)
+				if msg, ok := groups[i].Sample["message"].(string); ok {
+					groups[i].Sample["message"] = truncateString(msg, maxLen)
+				}
+			}
+		},
+		reduceMsgs: func() bool {
+			groups, _ := result["top_errors"].([]topErrorGroup)
+			if len(groups) <= 1 {
+				return false
+			}
+			result["top_errors"] = groups[:len(groups)/2]
+			return true
+		},
+		lastResort: func() map[string]any {
+			return map[string]any{
+				"query":              result["query"],
+				"template_count":     result["template_count"],
+				"messages_analyzed":  result["messages_analyzed"],
+				"total_results":      result["total_results"],
+				"response_truncated": true,
+				"error":              "Response too large even after truncation. Lower top_n or narrow the query.",
+			}
+		},
+	})
+}
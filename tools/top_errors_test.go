@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func TestTopErrorsHandlerRanksGroups(t *testing.T) {
+	var messages []testLogMessage
+	add := func(n int, format string) {
+		for i := 0; i < n; i++ {
+			messages = append(messages, testLogMessage{
+				ID:        fmt.Sprintf("id-%d", len(messages)),
+				Timestamp: "2024-01-01T00:00:00.000Z",
+				Source:    "svc",
+				Message:   fmt.Sprintf(format, i),
+				Index:     "idx",
+			})
+		}
+	}
+	add(2, "Disk full on volume vol%d")
+	add(5, "Connection to db-%d failed: timeout")
+	add(1, "Panic in worker %d: nil pointer dereference")
+
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				Query struct {
+					QueryString string `json:"query_string"`
+				} `json:"query"`
+			} `json:"queries"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Queries) > 0 {
+			gotQuery = body.Queries[0].Query.QueryString
+		}
+		writeViewsSearchResponse(w, len(messages), messages)
+	}))
+	defer server.Close()
+
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+	handler := topErrorsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"top_n": float64(2)}
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	if gotQuery != topErrorsDefaultQuery {
+		t.Errorf("expected default query %q, got %q", topErrorsDefaultQuery, gotQuery)
+	}
+
+	payload := decodeToolResultJSON(t, result)
+	groups := payload["top_errors"].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("expected top 2 groups, got %d: %v", len(groups), groups)
+	}
+	first, second := groups[0].(map[string]any), groups[1].(map[string]any)
+	if first["count"] != float64(5) || !strings.Contains(first["template"].(string), "Connection to") {
+		t.Errorf("expected the connection failure group (5) first, got %v", first)
+	}
+	if second["count"] != float64(2) || !strings.Contains(second["template"].(string), "Disk full") {
+		t.Errorf("expected the disk full group (2) second, got %v", second)
+	}
+	sample, ok := first["sample"].(map[string]any)
+	if !ok || !strings.HasPrefix(sample["message"].(string), "Connection to db-") {
+		t.Errorf("expected a connection failure sample message, got %v", first["sample"])
+	}
+	if ids := first["message_ids"].([]any); len(ids) != 5 {
+		t.Errorf("expected 5 message IDs, got %d", len(ids))
+	}
+	if payload["messages_analyzed"] != float64(8) {
+		t.Errorf("expected messages_analyzed=8, got %v", payload["messages_analyzed"])
+	}
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func whoamiTool() mcp.Tool {
+	return mcp.NewTool("whoami",
+		mcp.WithDescription("Show the Graylog user these credentials authenticate as: username, roles and the stream IDs it may read. Use it to explain authorization errors, e.g. a search on a stream the user can't access."),
+	)
+}
+
+func whoamiHandler(getClient ClientFunc) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		user, err := c.GetCurrentUser(ctx)
+		if err != nil {
+			return graylogError("Failed to get current user", err), nil
+		}
+
+		roles := user.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		result := map[string]any{
+			"username":  user.Username,
+			"full_name": user.FullName,
+			"roles":     roles,
+			"read_only": user.ReadOnly,
+		}
+		allStreams, streamIDs := readableStreams(user)
+		if allStreams {
+			result["all_streams"] = true
+		} else {
+			result["readable_stream_ids"] = streamIDs
+			result["hint"] = "only these streams can be searched; use list_streams for their titles"
+		}
+		return toolSuccess(result), nil
+	}
+}
+
+// readableStreams interprets Graylog's Shiro-style permissions
+// ("domain:actions:ids", comma-separated lists, "*" wildcards), both the
+// user's own and those from streams shared with it. The Admin role and any
+// wildcard grant on streams read every stream.
+func readableStreams(user *graylog.CurrentUser) (all bool, ids []string) {
+	if slices.Contains(user.Roles, "Admin") {
+		return true, nil
+	}
+	seen := make(map[string]bool)
+	for _, perm := range slices.Concat(user.Permissions, user.GrantPermissions) {
+		parts := strings.Split(perm, ":")
+		if parts[0] == "*" {
+			return true, nil
+		}
+		if parts[0] != "streams" {
+			continue
+		}
+		if len(parts) == 1 {
+			return true, nil
+		}
+		actions := strings.Split(parts[1], ",")
+		if !slices.Contains(actions, "read") && !slices.Contains(actions, "*") {
+			continue
+		}
+		if len(parts) == 2 {
+			return true, nil
+		}
+		for _, id := range strings.Split(parts[2], ",") {
+			if id == "*" {
+				return true, nil
+			}
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	if ids == nil {
+		ids = []string{}
+	}
+	return false, ids
+}
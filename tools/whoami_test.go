@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+func newWhoamiTestServer(t *testing.T, user string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/system/sessions":
+			_, _ = w.Write([]byte(`{"is_valid":true,"username":"analyst","session_id":null}`))
+		case "/api/users/analyst":
+			_, _ = w.Write([]byte(user))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func callWhoami(t *testing.T, client *graylog.Client) map[string]any {
+	t.Helper()
+	result, err := whoamiHandler(func(_ context.Context) *graylog.Client { return client })(context.Background(), mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	return decodeToolResultJSON(t, result)
+}
+
+func TestWhoamiHandler(t *testing.T) {
+	server := newWhoamiTestServer(t, `{
+		"id": "u-1", "username": "analyst", "full_name": "Log Analyst", "read_only": false,
+		"roles": ["Reader", "Payments Viewer"],
+		"permissions": ["users:passwordchange:analyst", "streams:read:s-2", "streams:read,edit:s-1", "streams:edit:s-9", "dashboards:read:d-1", "streams:read:s-1"],
+		"grant_permissions": ["streams:read:s-5", "dashboards:read:d-2", "streams:read:s-2"]
+	}`)
+	defer server.Close()
+
+	for name, client := range map[string]*graylog.Client{
+		"token":    graylog.NewClient(server.URL, "some-token", "token", false, 2*time.Second),
+		"password": graylog.NewClient(server.URL, "analyst", "secret", false, 2*time.Second),
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload := callWhoami(t, client)
+			if payload["username"] != "analyst" || payload["full_name"] != "Log Analyst" {
+				t.Errorf("unexpected user: %v", payload)
+			}
+			if !reflect.DeepEqual(payload["roles"], []any{"Reader", "Payments Viewer"}) {
+				t.Errorf("unexpected roles: %v", payload["roles"])
+			}
+			// s-5 is only readable through a share (grant).
+			if !reflect.DeepEqual(payload["readable_stream_ids"], []any{"s-1", "s-2", "s-5"}) {
+				t.Errorf("expected readable streams [s-1 s-2 s-5], got %v", payload["readable_stream_ids"])
+			}
+			if _, ok := payload["all_streams"]; ok {
+				t.Errorf("did not expect all_streams, got %v", payload)
+			}
+		})
+	}
+}
+
+func TestReadableStreamsWildcards(t *testing.T) {
+	for _, user := range []graylog.CurrentUser{
+		{Roles: []string{"Admin"}},
+		{Permissions: []string{"*"}},
+		{Permissions: []string{"streams:*"}},
+		{Permissions: []string{"streams:read"}},
+		{Permissions: []string{"streams:read:*"}},
+		{Permissions: []string{"streams"}},
+		{GrantPermissions: []string{"streams:read:*"}},
+	} {
+		if all, _ := readableStreams(&user); !all {
+			t.Errorf("expected %+v to read all streams", user)
+		}
+	}
+	if all, ids := readableStreams(&graylog.CurrentUser{Permissions: []string{"streams:edit:s-1"}}); all || len(ids) != 0 {
+		t.Errorf("edit-only permission must not grant read, got all=%v ids=%v", all, ids)
+	}
+}
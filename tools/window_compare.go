@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+const compareWindowsDefaultRange = 3600
+
+func compareWindowsTool() mcp.Tool {
+	return mcp.NewTool("compare_windows",
+		mcp.WithDescription("Compare how many messages match a query now versus the same-length window shifted back in time (e.g. the last hour vs. the same hour yesterday). Returns both counts, the delta and the percent change."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Lucene query string (e.g. 'level:ERROR AND service:auth')"),
+		),
+		mcp.WithString("offset",
+			mcp.Required(),
+			mcp.Description("How far back the comparison window is shifted, e.g. '24h', '7d', '30m'"),
+		),
+		mcp.WithNumber("range",
+			mcp.Description("Length of the current window in seconds, ending now (default: 3600). Ignored if from/to or timerange_keyword are set."),
+		),
+		mcp.WithString("from",
+			mcp.Description("Start of the current window in ISO8601 format. Must be used with 'to'."),
+		),
+		mcp.WithString("to",
+			mcp.Description("End of the current window in ISO8601 format. Must be used with 'from'."),
+		),
+		mcp.WithString("timerange_keyword",
+			mcp.Description("Natural-language current window parsed by Graylog (e.g. 'yesterday', 'last week'). Mutually exclusive with from/to."),
+		),
+		mcp.WithString("stream_id",
+			mcp.Description("Graylog stream ID to search within"),
+		),
+	)
+}
+
+func compareWindowsHandler(getClient ClientFunc, cfg *config.Config) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query := getStringParam(args, "query")
+		if query == "" {
+			return toolError("'query' parameter is required"), nil
+		}
+		offsetStr := getStringParam(args, "offset")
+		if offsetStr == "" {
+			return toolError("'offset' parameter is required"), nil
+		}
+		offset, err := parseWindowOffset(offsetStr)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+
+		tr, err := parseTimeRange(args, compareWindowsDefaultRange)
+		if err != nil {
+			return toolError(err.Error()), nil
+		}
+		var start, end time.Time
+		if tr.Keyword == "" {
+			if start, end, err = tr.bounds(time.Now()); err != nil {
+				return toolError(err.Error()), nil
+			}
+		}
+
+		c := getClient(ctx)
+		if c == nil {
+			return noCredentialsError(), nil
+		}
+		streamIDs := getStreamIDsParam(args, cfg)
+
+		// Graylog resolves a keyword, so the current window is counted first
+		// and its effective range gives the bounds to shift.
+		counted := 0
+		var windows [2]compareWindow
+		if tr.Keyword != "" {
+			params := graylog.SearchParams{Query: query, CountOnly: true, StreamIDs: streamIDs}
+			tr.applyTo(&params)
+			resp, err := c.Search(ctx, params)
+			if err != nil {
+				return graylogError("Count search failed", err), nil
+			}
+			var fromOK, toOK bool
+			start, fromOK = parseEffectiveTime(resp.EffectiveTimerange["from"])
+			end, toOK = parseEffectiveTime(resp.EffectiveTimerange["to"])
+			if !fromOK || !toOK {
+				return toolError(fmt.Sprintf("Graylog didn't report the window 'timerange_keyword' %q resolves to; use 'from'/'to' instead", tr.Keyword)), nil
+			}
+			windows[0].count = resp.TotalResults
+			counted = 1
+		}
+		windows[0].from, windows[0].to = start, end
+		windows[1].from, windows[1].to = start.Add(-offset), end.Add(-offset)
+
+		var wg sync.WaitGroup
+		for i := counted; i < len(windows); i++ {
+			w := &windows[i]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := c.Search(ctx, graylog.SearchParams{
+					Query:     query,
+					From:      w.fromString(),
+					To:        w.toString(),
+					CountOnly: true,
+					StreamIDs: streamIDs,
+				})
+				if err != nil {
+					w.err = err
+					return
+				}
+				w.count = resp.TotalResults
+			}()
+		}
+		wg.Wait()
+
+		for _, w := range windows {
+			if w.err != nil {
+				return graylogError("Count search failed", w.err), nil
+			}
+		}
+
+		current, previous := windows[0], windows[1]
+		delta := current.count - previous.count
+		var percentChange any
+		if previous.count > 0 {
+			percentChange = float64(delta) / float64(previous.count) * 100
+		}
+		return toolSuccess(map[string]any{
+			"query":          query,
+			"offset":         offset.String(),
+			"current":        current.summary(),
+			"previous":       previous.summary(),
+			"delta":          delta,
+			"percent_change": percentChange, // null when the previous window had no matches
+		}), nil
+	}
+}
+
+// compareWindow is one counted time window of compare_windows.
+type compareWindow struct {
+	from, to time.Time
+	count    int
+	err      error
+}
+
+func (w compareWindow) fromString() string { return w.from.UTC().Format(graylogTimeLayout) }
+func (w compareWindow) toString() string   { return w.to.UTC().Format(graylogTimeLayout) }
+
+func (w compareWindow) summary() map[string]any {
+	return map[string]any{"from": w.fromString(), "to": w.toString(), "count": w.count}
+}
+
+// parseWindowOffset parses a positive Go duration, also accepting whole days
+// ("7d") since time.ParseDuration has no day unit.
+func parseWindowOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid 'offset' %q: use a positive duration like '24h', '7d' or '30m'", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/n0madic/graylog-mcp/config"
+	"github.com/n0madic/graylog-mcp/graylog"
+)
+
+// newCountServer answers Views searches with counts[from] and records every
+// requested [from, to] time range.
+func newCountServer(t *testing.T, counts func(from string) int, ranges *[][2]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				TimeRange struct {
+					Type string `json:"type"`
+					From string `json:"from"`
+					To   string `json:"to"`
+				} `json:"timerange"`
+			} `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode search request: %v", err)
+		}
+		tr := body.Queries[0].TimeRange
+		if tr.Type != "absolute" {
+			t.Errorf("expected absolute timerange, got %q", tr.Type)
+		}
+		mu.Lock()
+		*ranges = append(*ranges, [2]string{tr.From, tr.To})
+		mu.Unlock()
+		writeViewsSearchResponse(w, counts(tr.From), nil)
+	}))
+}
+
+func runCompareWindows(t *testing.T, client *graylog.Client, args map[string]any) map[string]any {
+	t.Helper()
+	handler := compareWindowsHandler(func(_ context.Context) *graylog.Client { return client }, &config.Config{})
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = args
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %+v", result.Content)
+	}
+	return decodeToolResultJSON(t, result)
+}
+
+func TestCompareWindowsRelativeRange(t *testing.T) {
+	var ranges [][2]string
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	server := newCountServer(t, func(from string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[from] = true
+		// The earlier window is the previous one.
+		parsed, _ := time.Parse(time.RFC3339Nano, from)
+		if time.Since(parsed) > 12*time.Hour {
+			return 100
+		}
+		return 150
+	}, &ranges)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	before := time.Now()
+	payload := runCompareWindows(t, client, map[string]any{"query": "level:ERROR", "offset": "24h", "range": float64(1800)})
+
+	if len(ranges) != 2 {
+		t.Fatalf("expected 2 count searches, got %d", len(ranges))
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] > ranges[j][0] })
+	parse := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t.Fatalf("bad timestamp %q: %v", s, err)
+		}
+		return ts
+	}
+	curFrom, curTo := parse(ranges[0][0]), parse(ranges[0][1])
+	prevFrom, prevTo := parse(ranges[1][0]), parse(ranges[1][1])
+	if curTo.Sub(curFrom) != 30*time.Minute || prevTo.Sub(prevFrom) != 30*time.Minute {
+		t.Errorf("expected 30m windows, got %s and %s", curTo.Sub(curFrom), prevTo.Sub(prevFrom))
+	}
+	if curTo.Sub(prevTo) != 24*time.Hour {
+		t.Errorf("expected windows 24h apart, got %s", curTo.Sub(prevTo))
+	}
+	if curTo.Before(before.Add(-time.Second)) || curTo.After(time.Now().Add(time.Second)) {
+		t.Errorf("expected current window to end now, got %s", curTo)
+	}
+
+	current := payload["current"].(map[string]any)
+	previous := payload["previous"].(map[string]any)
+	if current["count"] != float64(150) || previous["count"] != float64(100) {
+		t.Fatalf("unexpected counts: current %v, previous %v", current, previous)
+	}
+	if current["from"] != ranges[0][0] || previous["to"] != ranges[1][1] {
+		t.Errorf("reported windows don't match the searched ones: %v %v", current, previous)
+	}
+	if payload["delta"] != float64(50) || payload["percent_change"] != float64(50) {
+		t.Errorf("expected delta 50 and percent_change 50, got %v and %v", payload["delta"], payload["percent_change"])
+	}
+	if payload["offset"] != "24h0m0s" {
+		t.Errorf("expected offset 24h0m0s, got %v", payload["offset"])
+	}
+}
+
+func TestCompareWindowsAbsoluteRangeAndZeroBaseline(t *testing.T) {
+	var ranges [][2]string
+	server := newCountServer(t, func(from string) int {
+		if from == "2024-03-10T12:00:00.000Z" {
+			return 7
+		}
+		return 0
+	}, &ranges)
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	payload := runCompareWindows(t, client, map[string]any{
+		"query":  "*",
+		"offset": "7d",
+		"from":   "2024-03-10T12:00:00Z",
+		"to":     "2024-03-10T13:00:00Z",
+	})
+
+	want := map[string]any{"from": "2024-03-03T12:00:00.000Z", "to": "2024-03-03T13:00:00.000Z", "count": float64(0)}
+	previous := payload["previous"].(map[string]any)
+	for k, v := range want {
+		if previous[k] != v {
+			t.Errorf("previous[%s] = %v, want %v", k, previous[k], v)
+		}
+	}
+	if payload["delta"] != float64(7) {
+		t.Errorf("expected delta 7, got %v", payload["delta"])
+	}
+	if v, ok := payload["percent_change"]; !ok || v != nil {
+		t.Errorf("expected null percent_change for a zero baseline, got %v", v)
+	}
+}
+
+func TestCompareWindowsKeywordShiftsResolvedWindow(t *testing.T) {
+	var mu sync.Mutex
+	var timeranges []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Queries []struct {
+				TimeRange map[string]any `json:"timerange"`
+			} `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode search request: %v", err)
+		}
+		tr := body.Queries[0].TimeRange
+		mu.Lock()
+		timeranges = append(timeranges, tr)
+		mu.Unlock()
+		total := 3
+		if tr["type"] == "keyword" {
+			total = 9
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results": map[string]any{"q1": map[string]any{"search_types": map[string]any{"msgs": map[string]any{
+				"total_results":       total,
+				"messages":            []any{},
+				"effective_timerange": map[string]any{"type": "absolute", "from": "2024-03-09T00:00:00.000Z", "to": "2024-03-10T00:00:00.000Z"},
+			}}}},
+		})
+	}))
+	defer server.Close()
+	client := graylog.NewClient(server.URL, "token", "token", false, 2*time.Second)
+
+	payload := runCompareWindows(t, client, map[string]any{"query": "*", "offset": "7d", "timerange_keyword": "yesterday"})
+
+	if len(timeranges) != 2 {
+		t.Fatalf("expected 2 count searches, got %d", len(timeranges))
+	}
+	if timeranges[0]["type"] != "keyword" || timeranges[0]["keyword"] != "yesterday" {
+		t.Errorf("expected the current window searched by keyword, got %v", timeranges[0])
+	}
+	if timeranges[1]["type"] != "absolute" || timeranges[1]["from"] != "2024-03-02T00:00:00.000Z" || timeranges[1]["to"] != "2024-03-03T00:00:00.000Z" {
+		t.Errorf("expected the resolved window shifted by 7d, got %v", timeranges[1])
+	}
+	current := payload["current"].(map[string]any)
+	if current["from"] != "2024-03-09T00:00:00.000Z" || current["count"] != float64(9) {
+		t.Errorf("unexpected current window: %v", current)
+	}
+	if payload["delta"] != float64(6) {
+		t.Errorf("expected delta 6, got %v", payload["delta"])
+	}
+}
+
+func TestCompareWindowsRejectsInvalidTimeRange(t *testing.T) {
+	handler := compareWindowsHandler(func(_ context.Context) *graylog.Client { return nil }, &config.Config{})
+	for want, args := range map[string]map[string]any{
+		"'from' and 'to' must be used together":            {"from": "2024-03-10T12:00:00Z"},
+		"'timerange_keyword' and 'from'/'to' are mutually": {"from": "2024-03-10T12:00:00Z", "to": "2024-03-10T13:00:00Z", "timerange_keyword": "yesterday"},
+		"'from' must be before 'to'":                       {"from": "2024-03-10T13:00:00Z", "to": "2024-03-10T12:00:00Z"},
+		"invalid 'to'":                                     {"from": "2024-03-10T12:00:00Z", "to": "tomorrow"},
+	} {
+		args["query"], args["offset"] = "*", "24h"
+		req := mcp.CallToolRequest{}
+		req.Params.Arguments = args
+		result, err := handler(context.Background(), req)
+		if err != nil || !result.IsError {
+			t.Fatalf("expected a tool error for %v, got %+v, %v", args, result, err)
+		}
+		if text := result.Content[0].(mcp.TextContent).Text; !strings.Contains(text, want) {
+			t.Errorf("expected error containing %q for %v, got %q", want, args, text)
+		}
+	}
+}
+
+func TestParseWindowOffset(t *testing.T) {
+	for in, want := range map[string]time.Duration{"24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour, "90m": 90 * time.Minute} {
+		if got, err := parseWindowOffset(in); err != nil || got != want {
+			t.Errorf("parseWindowOffset(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "0h", "-1h", "d", "yesterday"} {
+		if _, err := parseWindowOffset(bad); err == nil {
+			t.Errorf("expected error for offset %q", bad)
+		}
+	}
+}